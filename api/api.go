@@ -0,0 +1,47 @@
+// Package api expone el servidor HTTP local del servicio (métricas y estado).
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"orgmserver/utils"
+	"time"
+)
+
+// Server agrupa los endpoints registrados por los distintos componentes
+type Server struct {
+	addr  string
+	mux   *http.ServeMux
+	debug bool
+}
+
+func NewServer(addr string, debug bool) *Server {
+	return &Server{addr: addr, mux: http.NewServeMux(), debug: debug}
+}
+
+// Handle registra un endpoint
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Start abre el puerto y atiende en segundo plano; retorna error si no puede escuchar
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("no se pudo escuchar en %s: %w", s.addr, err)
+	}
+
+	srv := &http.Server{
+		Handler:           s.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			utils.WriteLog(fmt.Sprintf("[API] Error en servidor HTTP: %v", err), s.debug)
+		}
+	}()
+
+	utils.WriteLog(fmt.Sprintf("[API] Escuchando en %s", ln.Addr()), s.debug)
+	return nil
+}
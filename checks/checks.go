@@ -0,0 +1,365 @@
+// Package checks ejecuta verificaciones de servicios locales y remotos (HTTP, TCP,
+// DNS y comandos) definidas en un archivo JSON, cada una con su intervalo y umbrales.
+package checks
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Tipos de check soportados
+const (
+	TypeHTTP    = "http"
+	TypeTCP     = "tcp"
+	TypeDNS     = "dns"
+	TypeCommand = "command"
+	TypeTLS     = "tls"
+)
+
+// Definition describe un check del archivo de configuración. Los tiempos están en segundos.
+type Definition struct {
+	Name             string `json:"name"`
+	Type             string `json:"type"`
+	Interval         int    `json:"interval"`
+	Timeout          int    `json:"timeout"`
+	FailThreshold    int    `json:"fail_threshold"`
+	SuccessThreshold int    `json:"success_threshold"`
+
+	// http
+	URL                string            `json:"url,omitempty"`
+	Method             string            `json:"method,omitempty"`
+	Headers            map[string]string `json:"headers,omitempty"`
+	ExpectStatus       int               `json:"expect_status,omitempty"`
+	ExpectBody         string            `json:"expect_body,omitempty"` // expresión regular
+	InsecureSkipVerify bool              `json:"insecure_skip_verify,omitempty"`
+
+	// tcp y tls
+	Address string `json:"address,omitempty"`
+
+	// tls
+	ServerName string `json:"server_name,omitempty"` // SNI; por defecto el host de address
+	WarnDays   int    `json:"warn_days,omitempty"`
+	CAFile     string `json:"ca_file,omitempty"` // CA de confianza en PEM; reemplaza las raíces del sistema
+
+	// dns
+	Host       string `json:"host,omitempty"`
+	RecordType string `json:"record_type,omitempty"`
+	Resolver   string `json:"resolver,omitempty"`
+	Expect     string `json:"expect,omitempty"`
+
+	// command
+	Command    []string `json:"command,omitempty"`
+	ExpectExit int      `json:"expect_exit,omitempty"`
+
+	bodyRe *regexp.Regexp
+	roots  *x509.CertPool
+}
+
+// Target describe en texto qué verifica el check
+func (d *Definition) Target() string {
+	switch d.Type {
+	case TypeHTTP:
+		return d.URL
+	case TypeTCP:
+		return d.Address
+	case TypeTLS:
+		if host, _, err := net.SplitHostPort(d.Address); err == nil && host != d.ServerName {
+			return d.Address + " (" + d.ServerName + ")"
+		}
+		return d.Address
+	case TypeDNS:
+		return d.RecordType + " " + d.Host
+	case TypeCommand:
+		return fmt.Sprint(d.Command)
+	}
+	return ""
+}
+
+// Load lee y valida el archivo de checks, aplicando valores por defecto
+func Load(path string) ([]Definition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var defs []Definition
+	if err := json.Unmarshal(data, &defs); err != nil {
+		return nil, fmt.Errorf("archivo de checks inválido: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	for i := range defs {
+		d := &defs[i]
+		if d.Name == "" {
+			return nil, fmt.Errorf("check %d sin nombre", i+1)
+		}
+		if seen[d.Name] {
+			return nil, fmt.Errorf("check duplicado: %s", d.Name)
+		}
+		seen[d.Name] = true
+
+		if err := d.validate(); err != nil {
+			return nil, fmt.Errorf("check %s: %w", d.Name, err)
+		}
+	}
+	return defs, nil
+}
+
+func (d *Definition) validate() error {
+	if d.Interval <= 0 {
+		d.Interval = 60
+	}
+	if d.Timeout <= 0 {
+		d.Timeout = 10
+	}
+	if d.FailThreshold <= 0 {
+		d.FailThreshold = 2
+		if d.Type == TypeTLS {
+			// Un certificado por vencer no se corrige solo: basta un fallo para avisar
+			d.FailThreshold = 1
+		}
+	}
+	if d.SuccessThreshold <= 0 {
+		d.SuccessThreshold = 1
+	}
+
+	switch d.Type {
+	case TypeHTTP:
+		if d.URL == "" {
+			return fmt.Errorf("falta url")
+		}
+		if d.Method == "" {
+			d.Method = "GET"
+		}
+		if d.ExpectStatus == 0 {
+			d.ExpectStatus = 200
+		}
+	case TypeTCP:
+		if d.Address == "" {
+			return fmt.Errorf("falta address")
+		}
+	case TypeTLS:
+		if d.Address == "" {
+			return fmt.Errorf("falta address")
+		}
+		host, port, err := net.SplitHostPort(d.Address)
+		if err != nil {
+			// Sin puerto se asume HTTPS
+			host, port = d.Address, "443"
+			d.Address = net.JoinHostPort(host, port)
+		}
+		if d.ServerName == "" {
+			d.ServerName = host
+		}
+		if d.WarnDays <= 0 {
+			d.WarnDays = 14
+		}
+	case TypeDNS:
+		if d.Host == "" {
+			return fmt.Errorf("falta host")
+		}
+		if d.RecordType == "" {
+			d.RecordType = "A"
+		}
+		switch d.RecordType {
+		case "A", "AAAA", "CNAME", "TXT", "MX":
+		default:
+			return fmt.Errorf("record_type no soportado: %s", d.RecordType)
+		}
+	case TypeCommand:
+		if len(d.Command) == 0 {
+			return fmt.Errorf("falta command")
+		}
+	default:
+		return fmt.Errorf("tipo no soportado: %s", d.Type)
+	}
+
+	return d.compile()
+}
+
+// Status es el estado confirmado de un check
+type Status string
+
+const (
+	StatusUnknown Status = ""
+	StatusUp      Status = "up"
+	StatusDown    Status = "down"
+)
+
+// Check es un check con su estado de ejecución
+type Check struct {
+	Definition
+
+	Status       Status
+	Since        time.Time // desde cuándo está en el estado actual
+	LastRun      time.Time
+	LastError    string
+	LastDuration time.Duration
+
+	fails     int
+	successes int
+	notified  Status
+	prevSince time.Time
+}
+
+// Transition es un cambio de estado todavía no notificado
+type Transition struct {
+	Name     string
+	Type     string
+	Target   string
+	Status   Status
+	Since    time.Time
+	Error    string
+	Previous time.Time // inicio del estado anterior (para calcular la duración de una caída)
+}
+
+// Manager ejecuta los checks y recuerda qué cambios ya se notificaron
+type Manager struct {
+	mu     sync.Mutex
+	checks []*Check
+}
+
+func NewManager(defs []Definition) *Manager {
+	m := &Manager{}
+	for _, d := range defs {
+		m.checks = append(m.checks, &Check{Definition: d})
+	}
+	return m
+}
+
+// Restore recupera el estado guardado de un check tras un reinicio
+func (m *Manager) Restore(name string, status Status, since time.Time, notified Status) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range m.checks {
+		if c.Name == name {
+			c.Status = status
+			c.Since = since
+			c.notified = notified
+		}
+	}
+}
+
+// RunDue ejecuta en paralelo los checks cuyo intervalo se cumplió
+func (m *Manager) RunDue(ctx context.Context, now time.Time) {
+	m.mu.Lock()
+	var due []*Check
+	for _, c := range m.checks {
+		if c.LastRun.IsZero() || now.Sub(c.LastRun) >= time.Duration(c.Interval)*time.Second {
+			c.LastRun = now
+			due = append(due, c)
+		}
+	}
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, c := range due {
+		wg.Add(1)
+		go func(c *Check) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, time.Duration(c.Timeout)*time.Second)
+			defer cancel()
+
+			// La duración se mide con el reloj monotónico; now solo fecha el resultado
+			start := time.Now()
+			err := run(checkCtx, &c.Definition, now)
+			if ctx.Err() != nil {
+				// Check interrumpido: no cuenta como fallo
+				return
+			}
+			m.record(c, err, time.Since(start), now)
+		}(c)
+	}
+	wg.Wait()
+}
+
+// record aplica los umbrales: el estado cambia solo tras varios resultados iguales seguidos
+func (m *Manager) record(c *Check, err error, duration time.Duration, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c.LastDuration = duration
+	if err != nil {
+		c.LastError = err.Error()
+		c.fails++
+		c.successes = 0
+		if c.fails >= c.FailThreshold && c.Status != StatusDown {
+			c.prevSince = c.Since
+			c.Status = StatusDown
+			c.Since = now
+		}
+		return
+	}
+
+	c.LastError = ""
+	c.successes++
+	c.fails = 0
+	if c.successes >= c.SuccessThreshold && c.Status != StatusUp {
+		c.prevSince = c.Since
+		c.Status = StatusUp
+		c.Since = now
+	}
+}
+
+// Pending retorna los cambios de estado aún no notificados. El primer "up" tras
+// iniciar no se notifica.
+func (m *Manager) Pending() []Transition {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var pending []Transition
+	for _, c := range m.checks {
+		if c.Status == StatusUnknown || c.Status == c.notified {
+			continue
+		}
+		if c.Status == StatusUp && c.notified == StatusUnknown {
+			c.notified = StatusUp
+			continue
+		}
+		pending = append(pending, Transition{
+			Name:     c.Name,
+			Type:     c.Type,
+			Target:   c.Target(),
+			Status:   c.Status,
+			Since:    c.Since,
+			Error:    c.LastError,
+			Previous: c.prevSince,
+		})
+	}
+	return pending
+}
+
+// MarkNotified registra que se notificó el estado; si el envío falla se reintenta
+// en la siguiente ejecución
+func (m *Manager) MarkNotified(name string, status Status) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range m.checks {
+		if c.Name == name {
+			c.notified = status
+		}
+	}
+}
+
+// Snapshot retorna una copia del estado de todos los checks
+func (m *Manager) Snapshot() []Check {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]Check, len(m.checks))
+	for i, c := range m.checks {
+		list[i] = *c
+	}
+	return list
+}
+
+// Notified retorna el último estado notificado de un check (para persistirlo)
+func (c *Check) Notified() Status {
+	return c.notified
+}
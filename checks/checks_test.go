@@ -0,0 +1,230 @@
+package checks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunDueIntervalAndFailThreshold(t *testing.T) {
+	// Un puerto recién liberado rechaza la conexión
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("no se pudo escuchar: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	m := NewManager([]Definition{{
+		Name: "web", Type: TypeTCP, Address: addr,
+		Interval: 60, Timeout: 1, FailThreshold: 2, SuccessThreshold: 1,
+	}})
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	m.RunDue(context.Background(), now)
+	if c := m.Snapshot()[0]; c.Status != StatusUnknown || !c.LastRun.Equal(now) {
+		t.Fatalf("tras un fallo: estado %q, última ejecución %s", c.Status, c.LastRun)
+	}
+
+	// Antes del intervalo no se vuelve a ejecutar
+	m.RunDue(context.Background(), now.Add(30*time.Second))
+	if c := m.Snapshot()[0]; !c.LastRun.Equal(now) {
+		t.Fatalf("se ejecutó antes del intervalo: %s", c.LastRun)
+	}
+
+	down := now.Add(time.Minute)
+	m.RunDue(context.Background(), down)
+	pending := m.Pending()
+	if len(pending) != 1 || pending[0].Status != StatusDown || !pending[0].Since.Equal(down) {
+		t.Fatalf("pendientes = %+v", pending)
+	}
+}
+
+// newDefinition aplica los valores por defecto y compila la definición
+func newDefinition(t *testing.T, d Definition) *Definition {
+	t.Helper()
+	if err := d.validate(); err != nil {
+		t.Fatal(err)
+	}
+	return &d
+}
+
+func TestRunHTTP(t *testing.T) {
+	status, body := http.StatusOK, `{"status": "ok"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "sin autorización", http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	defer srv.Close()
+
+	d := newDefinition(t, Definition{
+		Name: "api", Type: TypeHTTP, URL: srv.URL + "/health",
+		Headers:    map[string]string{"Authorization": "Bearer token"},
+		ExpectBody: `"status":\s*"ok"`,
+	})
+	for _, tc := range []struct {
+		status int
+		body   string
+		want   string
+	}{
+		{http.StatusOK, `{"status": "ok"}`, ""},
+		{http.StatusServiceUnavailable, `{"status": "ok"}`, "status 503, se esperaba 200"},
+		{http.StatusOK, `{"status": "degradado"}`, `el cuerpo no coincide con "\"status\":\\s*\"ok\""`},
+	} {
+		status, body = tc.status, tc.body
+		err := runHTTP(context.Background(), d)
+		if (tc.want == "" && err != nil) || (tc.want != "" && (err == nil || err.Error() != tc.want)) {
+			t.Errorf("%d %s: error = %v, se esperaba %q", tc.status, tc.body, err, tc.want)
+		}
+	}
+
+	// El estado esperado es configurable y sin expect_body no se lee el cuerpo
+	d = newDefinition(t, Definition{Name: "api", Type: TypeHTTP, URL: srv.URL, ExpectStatus: http.StatusUnauthorized})
+	if err := runHTTP(context.Background(), d); err != nil {
+		t.Errorf("expect_status 401: %v", err)
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sin sh")
+	}
+	for _, tc := range []struct {
+		script string
+		expect int
+		want   string
+	}{
+		{"exit 0", 0, ""},
+		{"exit 3", 3, ""},
+		{"exit 3", 0, "código de salida 3, se esperaba 0"},
+		{"echo disco lleno >&2; exit 1", 0, "código de salida 1, se esperaba 0: disco lleno"},
+		{"true", 2, "código de salida 0, se esperaba 2"},
+	} {
+		d := newDefinition(t, Definition{Name: "cmd", Type: TypeCommand, Command: []string{"sh", "-c", tc.script}, ExpectExit: tc.expect})
+		err := runCommand(context.Background(), d)
+		if (tc.want == "" && err != nil) || (tc.want != "" && (err == nil || err.Error() != tc.want)) {
+			t.Errorf("%q (espera %d): error = %v, se esperaba %q", tc.script, tc.expect, err, tc.want)
+		}
+	}
+
+	d := newDefinition(t, Definition{Name: "cmd", Type: TypeCommand, Command: []string{"/no/existe"}})
+	if err := runCommand(context.Background(), d); err == nil || strings.Contains(err.Error(), "código de salida") {
+		t.Errorf("comando inexistente: error = %v", err)
+	}
+}
+
+func TestThresholdsAndPending(t *testing.T) {
+	m := NewManager([]Definition{*newDefinition(t, Definition{
+		Name: "web", Type: TypeTCP, Address: "127.0.0.1:80", FailThreshold: 3, SuccessThreshold: 2,
+	})})
+	c := m.checks[0]
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	step := func(err error) time.Time {
+		now = now.Add(time.Minute)
+		m.record(c, err, time.Millisecond, now)
+		return now
+	}
+	status := func() Status { return m.Snapshot()[0].Status }
+	refused := errors.New("connection refused")
+
+	// El primer up requiere success_threshold éxitos y no se notifica
+	step(nil)
+	if status() != StatusUnknown {
+		t.Fatalf("estado tras un éxito = %q", status())
+	}
+	upSince := step(nil)
+	if status() != StatusUp || len(m.Pending()) != 0 {
+		t.Fatalf("el primer up no debía notificarse: %q %+v", status(), m.Pending())
+	}
+
+	// Los fallos deben ser seguidos: un éxito reinicia la cuenta
+	step(refused)
+	step(refused)
+	step(nil)
+	step(refused)
+	step(refused)
+	if status() != StatusUp {
+		t.Fatalf("caído sin fallos seguidos suficientes")
+	}
+	downSince := step(refused)
+	pending := m.Pending()
+	if len(pending) != 1 || pending[0].Status != StatusDown || !pending[0].Since.Equal(downSince) ||
+		!pending[0].Previous.Equal(upSince) || pending[0].Error != "connection refused" || pending[0].Target != "127.0.0.1:80" {
+		t.Fatalf("pendientes = %+v", pending)
+	}
+	// Sin MarkNotified la caída se reintenta
+	if len(m.Pending()) != 1 {
+		t.Fatal("la caída no notificada debía seguir pendiente")
+	}
+	m.MarkNotified("web", StatusDown)
+	if len(m.Pending()) != 0 {
+		t.Fatalf("pendientes tras notificar = %+v", m.Pending())
+	}
+
+	step(nil)
+	step(refused)
+	step(nil)
+	if status() != StatusDown {
+		t.Fatal("recuperado sin éxitos seguidos suficientes")
+	}
+	upSince = step(nil)
+	pending = m.Pending()
+	if len(pending) != 1 || pending[0].Status != StatusUp || !pending[0].Since.Equal(upSince) ||
+		!pending[0].Previous.Equal(downSince) || pending[0].Error != "" {
+		t.Fatalf("pendientes = %+v", pending)
+	}
+	m.MarkNotified("web", StatusUp)
+
+	// Una caída que se recupera antes de notificarse no genera avisos
+	step(refused)
+	step(refused)
+	step(refused)
+	step(nil)
+	step(nil)
+	if len(m.Pending()) != 0 {
+		t.Fatalf("pendientes = %+v", m.Pending())
+	}
+}
+
+func TestRestore(t *testing.T) {
+	defs := []Definition{
+		*newDefinition(t, Definition{Name: "web", Type: TypeTCP, Address: "127.0.0.1:80", FailThreshold: 1}),
+		*newDefinition(t, Definition{Name: "db", Type: TypeTCP, Address: "127.0.0.1:5432", FailThreshold: 1}),
+	}
+	m := NewManager(defs)
+	downSince := time.Date(2025, 6, 1, 11, 0, 0, 0, time.UTC)
+	now := downSince.Add(time.Hour)
+
+	// web quedó caído y notificado; la caída de db no llegó a enviarse antes del reinicio
+	m.Restore("web", StatusDown, downSince, StatusDown)
+	m.Restore("db", StatusDown, downSince, StatusUp)
+	m.Restore("otro", StatusDown, downSince, StatusDown)
+
+	pending := m.Pending()
+	if len(pending) != 1 || pending[0].Name != "db" || pending[0].Status != StatusDown || !pending[0].Since.Equal(downSince) {
+		t.Fatalf("pendientes = %+v", pending)
+	}
+	m.MarkNotified("db", StatusDown)
+
+	// Seguir caído no repite el aviso; la recuperación informa la caída restaurada
+	m.record(m.checks[0], errors.New("connection refused"), 0, now)
+	if c := m.Snapshot()[0]; !c.Since.Equal(downSince) || len(m.Pending()) != 0 {
+		t.Fatalf("tras otro fallo = %+v, pendientes %+v", c, m.Pending())
+	}
+	m.record(m.checks[0], nil, 0, now)
+	pending = m.Pending()
+	if len(pending) != 1 || pending[0].Name != "web" || pending[0].Status != StatusUp || !pending[0].Previous.Equal(downSince) {
+		t.Fatalf("pendientes = %+v", pending)
+	}
+}
@@ -0,0 +1,202 @@
+package checks
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// compile prepara los campos que requieren procesamiento previo
+func (d *Definition) compile() error {
+	if d.ExpectBody != "" {
+		re, err := regexp.Compile(d.ExpectBody)
+		if err != nil {
+			return fmt.Errorf("expect_body inválido: %w", err)
+		}
+		d.bodyRe = re
+	}
+	if d.CAFile != "" {
+		pem, err := os.ReadFile(d.CAFile)
+		if err != nil {
+			return fmt.Errorf("ca_file: %w", err)
+		}
+		d.roots = x509.NewCertPool()
+		if !d.roots.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("ca_file no contiene certificados PEM")
+		}
+	}
+	return nil
+}
+
+// run ejecuta un check; nil significa que el servicio está arriba. now es la hora
+// de la ejecución, contra la que se comparan los vencimientos de certificados.
+func run(ctx context.Context, d *Definition, now time.Time) error {
+	switch d.Type {
+	case TypeHTTP:
+		return runHTTP(ctx, d)
+	case TypeTCP:
+		return runTCP(ctx, d)
+	case TypeDNS:
+		return runDNS(ctx, d)
+	case TypeCommand:
+		return runCommand(ctx, d)
+	case TypeTLS:
+		return runTLS(ctx, d, now)
+	}
+	return fmt.Errorf("tipo no soportado: %s", d.Type)
+}
+
+func runHTTP(ctx context.Context, d *Definition) error {
+	req, err := http.NewRequestWithContext(ctx, d.Method, d.URL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "ORGMServer/1.0")
+	for k, v := range d.Headers {
+		req.Header.Set(k, v)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: d.InsecureSkipVerify}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != d.ExpectStatus {
+		return fmt.Errorf("status %d, se esperaba %d", resp.StatusCode, d.ExpectStatus)
+	}
+
+	if d.bodyRe != nil {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if err != nil {
+			return err
+		}
+		if !d.bodyRe.Match(body) {
+			return fmt.Errorf("el cuerpo no coincide con %q", d.ExpectBody)
+		}
+	}
+	return nil
+}
+
+func runTCP(ctx context.Context, d *Definition) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", d.Address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// runDNS resuelve el registro y, si se indicó expect, exige que esté entre las respuestas
+func runDNS(ctx context.Context, d *Definition) error {
+	resolver := net.DefaultResolver
+	if d.Resolver != "" {
+		server := d.Resolver
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, server)
+			},
+		}
+	}
+
+	var answers []string
+	switch d.RecordType {
+	case "A", "AAAA":
+		network := "ip4"
+		if d.RecordType == "AAAA" {
+			network = "ip6"
+		}
+		addrs, err := resolver.LookupNetIP(ctx, network, d.Host)
+		if err != nil {
+			return err
+		}
+		for _, a := range addrs {
+			answers = append(answers, a.Unmap().String())
+		}
+	case "CNAME":
+		cname, err := resolver.LookupCNAME(ctx, d.Host)
+		if err != nil {
+			return err
+		}
+		answers = append(answers, strings.TrimSuffix(cname, "."))
+	case "TXT":
+		txts, err := resolver.LookupTXT(ctx, d.Host)
+		if err != nil {
+			return err
+		}
+		answers = txts
+	case "MX":
+		mxs, err := resolver.LookupMX(ctx, d.Host)
+		if err != nil {
+			return err
+		}
+		for _, mx := range mxs {
+			answers = append(answers, strings.TrimSuffix(mx.Host, "."))
+		}
+	}
+
+	if len(answers) == 0 {
+		return fmt.Errorf("sin registros %s", d.RecordType)
+	}
+	if d.Expect == "" {
+		return nil
+	}
+	expect := strings.TrimSuffix(d.Expect, ".")
+	for _, a := range answers {
+		if strings.EqualFold(a, expect) {
+			return nil
+		}
+	}
+	return fmt.Errorf("se esperaba %s, respuestas: %s", d.Expect, strings.Join(answers, ", "))
+}
+
+// runCommand ejecuta el comando sin shell y compara el código de salida
+func runCommand(ctx context.Context, d *Definition) error {
+	cmd := exec.CommandContext(ctx, d.Command[0], d.Command[1:]...)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	err := cmd.Run()
+	code := 0
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || ctx.Err() != nil {
+			return err
+		}
+		code = exitErr.ExitCode()
+	}
+
+	if code != d.ExpectExit {
+		out := strings.TrimSpace(output.String())
+		if len(out) > 200 {
+			out = out[:200] + "..."
+		}
+		if out == "" {
+			return fmt.Errorf("código de salida %d, se esperaba %d", code, d.ExpectExit)
+		}
+		return fmt.Errorf("código de salida %d, se esperaba %d: %s", code, d.ExpectExit, out)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package checks
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"time"
+)
+
+// runTLS se conecta con SNI y revisa la cadena servida: confianza, nombre y vencimiento.
+// La verificación se hace a mano tras el handshake para poder informar el motivo exacto.
+func runTLS(ctx context.Context, d *Definition, now time.Time) error {
+	dialer := &tls.Dialer{Config: &tls.Config{
+		ServerName:         d.ServerName,
+		InsecureSkipVerify: true,
+	}}
+	conn, err := dialer.DialContext(ctx, "tcp", d.Address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return fmt.Errorf("el servidor no presentó certificado")
+	}
+	return verifyChain(certs, d.ServerName, d.WarnDays, d.roots, now)
+}
+
+// verifyChain valida la cadena contra roots (nil = raíces del sistema) y avisa si
+// algún certificado usado vence en menos de warnDays días
+func verifyChain(certs []*x509.Certificate, serverName string, warnDays int, roots *x509.CertPool, now time.Time) error {
+	leaf := certs[0]
+
+	if err := leaf.VerifyHostname(serverName); err != nil {
+		return fmt.Errorf("el certificado no corresponde a %s (válido para: %v)", serverName, certNames(leaf))
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		DNSName:       serverName,
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+	})
+	if err != nil {
+		if now.After(leaf.NotAfter) {
+			return fmt.Errorf("el certificado venció el %s", leaf.NotAfter.Format("2006-01-02"))
+		}
+		return fmt.Errorf("cadena de certificados inválida: %v", err)
+	}
+
+	// La cadena deja de ser válida cuando vence el primero de sus certificados
+	expiring := leaf
+	for _, c := range chains[0] {
+		if c.NotAfter.Before(expiring.NotAfter) {
+			expiring = c
+		}
+	}
+
+	remaining := expiring.NotAfter.Sub(now)
+	if remaining < time.Duration(warnDays)*24*time.Hour {
+		who := "el certificado"
+		if expiring != leaf {
+			who = fmt.Sprintf("el certificado intermedio %q", expiring.Subject.CommonName)
+		}
+		return fmt.Errorf("%s vence en %d días (%s)", who, int(remaining.Hours()/24), expiring.NotAfter.Format("2006-01-02"))
+	}
+	return nil
+}
+
+func certNames(c *x509.Certificate) []string {
+	if len(c.DNSNames) > 0 {
+		return c.DNSNames
+	}
+	return []string{c.Subject.CommonName}
+}
@@ -0,0 +1,171 @@
+package checks
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+var tlsNow = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// testCert es un certificado generado junto con su clave
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// issue firma un certificado válido entre from y to con parent; sin parent es autofirmado
+func issue(t *testing.T, name string, ca bool, from, to time.Time, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    from,
+		NotAfter:     to,
+	}
+	if ca {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		tmpl.DNSNames = []string{name}
+		tmpl.KeyUsage = x509.KeyUsageDigitalSignature
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	}
+
+	signer, signKey := tmpl, key
+	if parent != nil {
+		signer, signKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key}
+}
+
+// days retorna tlsNow desplazado n días
+func days(n int) time.Time {
+	return tlsNow.Add(time.Duration(n) * 24 * time.Hour)
+}
+
+func pool(certs ...*testCert) *x509.CertPool {
+	p := x509.NewCertPool()
+	for _, c := range certs {
+		p.AddCert(c.cert)
+	}
+	return p
+}
+
+func TestVerifyChain(t *testing.T) {
+	root := issue(t, "Test Raíz", true, days(-365), days(3650), nil)
+	inter := issue(t, "Test Intermedia", true, days(-365), days(1825), root)
+	shortInter := issue(t, "Test Intermedia Corta", true, days(-365), days(5), root)
+	other := issue(t, "Otra Raíz", true, days(-365), days(3650), nil)
+
+	leaf := issue(t, "www.example.com", false, days(-10), days(80), inter)
+	soon := issue(t, "www.example.com", false, days(-80), days(10), inter)
+	expired := issue(t, "www.example.com", false, days(-90), days(-1), inter)
+	underShort := issue(t, "www.example.com", false, days(-10), days(60), shortInter)
+
+	for _, tc := range []struct {
+		name       string
+		chain      []*testCert
+		serverName string
+		roots      *x509.CertPool
+		want       string // vacío = válida
+	}{
+		{"válida", []*testCert{leaf, inter}, "www.example.com", pool(root), ""},
+		{"vence pronto", []*testCert{soon, inter}, "www.example.com", pool(root),
+			"el certificado vence en 10 días (2025-06-11)"},
+		{"vencido", []*testCert{expired, inter}, "www.example.com", pool(root),
+			"el certificado venció el 2025-05-31"},
+		{"otro nombre", []*testCert{leaf, inter}, "api.example.com", pool(root),
+			"el certificado no corresponde a api.example.com (válido para: [www.example.com])"},
+		{"raíz desconocida", []*testCert{leaf, inter}, "www.example.com", pool(other),
+			"cadena de certificados inválida: x509: certificate signed by unknown authority"},
+		{"falta la intermedia", []*testCert{leaf}, "www.example.com", pool(root),
+			"cadena de certificados inválida: x509: certificate signed by unknown authority"},
+		{"intermedia vence antes", []*testCert{underShort, shortInter}, "www.example.com", pool(root),
+			`el certificado intermedio "Test Intermedia Corta" vence en 5 días (2025-06-06)`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var certs []*x509.Certificate
+			for _, c := range tc.chain {
+				certs = append(certs, c.cert)
+			}
+			err := verifyChain(certs, tc.serverName, 14, tc.roots, tlsNow)
+			switch {
+			case tc.want == "" && err != nil:
+				t.Fatalf("error inesperado: %v", err)
+			case tc.want != "" && (err == nil || err.Error() != tc.want):
+				t.Fatalf("error = %v, se esperaba %q", err, tc.want)
+			}
+		})
+	}
+
+	// Una intermedia vencida invalida la cadena aunque la hoja siga vigente
+	oldInter := issue(t, "Test Intermedia Vencida", true, days(-365), days(-1), root)
+	orphan := issue(t, "www.example.com", false, days(-10), days(60), oldInter)
+	err := verifyChain([]*x509.Certificate{orphan.cert, oldInter.cert}, "www.example.com", 14, pool(root), tlsNow)
+	if err == nil || !strings.HasPrefix(err.Error(), "cadena de certificados inválida: x509: certificate has expired") {
+		t.Fatalf("error = %v, se esperaba cadena inválida", err)
+	}
+}
+
+func TestRunTLS(t *testing.T) {
+	root := issue(t, "Test Raíz", true, days(-365), days(3650), nil)
+	inter := issue(t, "Test Intermedia", true, days(-365), days(1825), root)
+	leaf := issue(t, "www.example.com", false, days(-10), days(80), inter)
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{{
+		Certificate: [][]byte{leaf.cert.Raw, inter.cert.Raw},
+		PrivateKey:  leaf.key,
+	}}})
+	if err != nil {
+		t.Skipf("no se pudo escuchar: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				conn.(*tls.Conn).Handshake()
+			}(conn)
+		}
+	}()
+
+	d := &Definition{Address: ln.Addr().String(), ServerName: "www.example.com", WarnDays: 14, roots: pool(root)}
+	if err := runTLS(context.Background(), d, tlsNow); err != nil {
+		t.Fatalf("runTLS: %v", err)
+	}
+	// La cadena servida se evalúa con la hora recibida, no con la del sistema
+	if err := runTLS(context.Background(), d, days(75)); err == nil || err.Error() != "el certificado vence en 5 días (2025-08-20)" {
+		t.Fatalf("runTLS en 75 días: %v", err)
+	}
+}
@@ -0,0 +1,16 @@
+package config
+
+// APIConfig define el servidor HTTP local de métricas y estado
+type APIConfig struct {
+	Addr string
+}
+
+// Enabled indica si se configuró una dirección de escucha
+func (c APIConfig) Enabled() bool {
+	return c.Addr != ""
+}
+
+func loadAPI() (APIConfig, error) {
+	// Ejemplo: ":9100" o "127.0.0.1:9100"; vacío deshabilita el servidor
+	return APIConfig{Addr: getEnv("HTTP_ADDR", "")}, nil
+}
@@ -0,0 +1,15 @@
+package config
+
+// ChecksConfig indica el archivo JSON con la lista de checks de servicios
+type ChecksConfig struct {
+	File string
+}
+
+// Enabled indica si se configuró un archivo de checks
+func (c ChecksConfig) Enabled() bool {
+	return c.File != ""
+}
+
+func loadChecks() (ChecksConfig, error) {
+	return ChecksConfig{File: getEnv("CHECKS_FILE", "")}, nil
+}
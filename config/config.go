@@ -4,19 +4,48 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type Config struct {
-	AppName         string
-	SMTPHost        string
-	SMTPPort        int
-	SMTPUser        string
-	SMTPPassword    string
-	EmailTo         string
-	HealthcheckURL  string
-	MonitorInterval time.Duration
-	StateFilePath   string
+	AppName           string
+	SMTPHost          string
+	SMTPPort          int
+	SMTPUser          string
+	SMTPPassword      string
+	EmailTo           string
+	MonitorInterval   time.Duration
+	StateFilePath     string
+	DKIMDomain        string
+	DKIMSelector      string
+	DKIMKeyFile       string
+	DeliveryModes     []string
+	ChannelDelivery   map[string][]string
+	MXPort            int
+	HeloName          string
+	IPv6Enabled       bool
+	IPv6PrefixLength  int
+	IPv6NotifyAddress bool
+	IPv4Providers     []string
+	IPv6Providers     []string
+	IPConsensusMin    int
+	DDNS              DDNSConfig
+	DNSCheck          DNSCheckConfig
+	Router            RouterConfig
+	Probe             ProbeConfig
+	Report            ReportConfig
+	SpeedTest         SpeedTestConfig
+	API               APIConfig
+	Checks            ChecksConfig
+	Resources         ResourcesConfig
+	Docker            DockerConfig
+	Systemd           SystemdConfig
+	UPS               UPSConfig
+	Shutdown          ShutdownConfig
+	Peer              PeerConfig
+	Pings             PingsConfig
+	Healthcheck       HealthcheckConfig
 }
 
 func Load() (*Config, error) {
@@ -27,7 +56,7 @@ func Load() (*Config, error) {
 
 	// SMTP Configuration
 	cfg.SMTPHost = getEnv("SMTP_HOST", "smtp.gmail.com")
-	
+
 	portStr := getEnv("SMTP_PORT", "587")
 	port, err := strconv.Atoi(portStr)
 	if err != nil {
@@ -40,8 +69,36 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("SMTP_USER es requerido")
 	}
 
+	// Modos de entrega en orden de preferencia: relay (smarthost) y/o mx (entrega directa)
+	cfg.DeliveryModes = getEnvList("EMAIL_DELIVERY_MODE", "relay")
+	if err := validateDeliveryModes("EMAIL_DELIVERY_MODE", cfg.DeliveryModes); err != nil {
+		return nil, err
+	}
+
+	// Cada canal puede reemplazar los modos globales, p. ej. EMAIL_DELIVERY_MODE_CONNECTION=mx
+	cfg.ChannelDelivery = make(map[string][]string)
+	for _, channel := range deliveryChannels {
+		key := "EMAIL_DELIVERY_MODE_" + strings.ToUpper(channel)
+		modes := getEnvList(key, "")
+		if len(modes) == 0 {
+			continue
+		}
+		if err := validateDeliveryModes(key, modes); err != nil {
+			return nil, err
+		}
+		cfg.ChannelDelivery[channel] = modes
+	}
+
+	mxPort, err := strconv.Atoi(getEnv("SMTP_MX_PORT", "25"))
+	if err != nil {
+		return nil, fmt.Errorf("SMTP_MX_PORT debe ser un número válido: %w", err)
+	}
+	cfg.MXPort = mxPort
+	cfg.HeloName = getEnv("SMTP_HELO_NAME", "")
+
+	// La contraseña solo es necesaria si se usa el smarthost
 	cfg.SMTPPassword = getEnv("SMTP_PASSWORD", "")
-	if cfg.SMTPPassword == "" {
+	if cfg.SMTPPassword == "" && cfg.UsesRelay() {
 		return nil, fmt.Errorf("SMTP_PASSWORD es requerido")
 	}
 
@@ -51,8 +108,10 @@ func Load() (*Config, error) {
 	}
 
 	// Optional configurations
-	cfg.HealthcheckURL = getEnv("HEALTHCHECK_URL", "")
-	
+	if cfg.Healthcheck, err = loadHealthcheck(); err != nil {
+		return nil, err
+	}
+
 	intervalStr := getEnv("MONITOR_INTERVAL", "60")
 	interval, err := strconv.Atoi(intervalStr)
 	if err != nil {
@@ -62,9 +121,140 @@ func Load() (*Config, error) {
 
 	cfg.StateFilePath = getEnv("STATE_FILE_PATH", "/tmp/orgmserver_state.json")
 
+	// IPv6: detección separada de IPv4, con seguimiento del prefijo delegado
+	if cfg.IPv6Enabled, err = getEnvBool("IPV6_ENABLED", true); err != nil {
+		return nil, err
+	}
+	if cfg.IPv6PrefixLength, err = getEnvInt("IPV6_PREFIX_LENGTH", 64); err != nil {
+		return nil, err
+	}
+	if cfg.IPv6PrefixLength < 1 || cfg.IPv6PrefixLength > 128 {
+		return nil, fmt.Errorf("IPV6_PREFIX_LENGTH debe estar entre 1 y 128")
+	}
+	if cfg.IPv6NotifyAddress, err = getEnvBool("IPV6_NOTIFY_ADDRESS_CHANGE", false); err != nil {
+		return nil, err
+	}
+
+	// Proveedores de IP externa (vacío = lista por defecto) y mínimo de coincidencias
+	cfg.IPv4Providers = getEnvList("IP_PROVIDERS_V4", "")
+	cfg.IPv6Providers = getEnvList("IP_PROVIDERS_V6", "")
+	if cfg.IPConsensusMin, err = getEnvInt("IP_CONSENSUS_MIN", 2); err != nil {
+		return nil, err
+	}
+
+	// DKIM (opcional): se habilita solo si se definen dominio, selector y clave
+	cfg.DKIMDomain = getEnv("DKIM_DOMAIN", "")
+	cfg.DKIMSelector = getEnv("DKIM_SELECTOR", "")
+	cfg.DKIMKeyFile = getEnv("DKIM_KEY_FILE", "")
+	if cfg.DKIMEnabled() && (cfg.DKIMDomain == "" || cfg.DKIMSelector == "" || cfg.DKIMKeyFile == "") {
+		return nil, fmt.Errorf("DKIM_DOMAIN, DKIM_SELECTOR y DKIM_KEY_FILE deben definirse juntos")
+	}
+
+	if cfg.DDNS, err = loadDDNS(); err != nil {
+		return nil, err
+	}
+
+	if cfg.DNSCheck, err = loadDNSCheck(); err != nil {
+		return nil, err
+	}
+
+	if cfg.Router, err = loadRouter(); err != nil {
+		return nil, err
+	}
+
+	if cfg.Probe, err = loadProbe(); err != nil {
+		return nil, err
+	}
+
+	if cfg.Report, err = loadReport(); err != nil {
+		return nil, err
+	}
+
+	if cfg.SpeedTest, err = loadSpeedTest(); err != nil {
+		return nil, err
+	}
+
+	if cfg.API, err = loadAPI(); err != nil {
+		return nil, err
+	}
+
+	if cfg.Checks, err = loadChecks(); err != nil {
+		return nil, err
+	}
+
+	if cfg.Resources, err = loadResources(); err != nil {
+		return nil, err
+	}
+
+	if cfg.Docker, err = loadDocker(); err != nil {
+		return nil, err
+	}
+
+	if cfg.Systemd, err = loadSystemd(); err != nil {
+		return nil, err
+	}
+
+	if cfg.UPS, err = loadUPS(); err != nil {
+		return nil, err
+	}
+
+	if cfg.Shutdown, err = loadShutdown(); err != nil {
+		return nil, err
+	}
+
+	// Los heartbeats de la otra instancia se reciben en su propio puerto para no
+	// exponer /status y /metrics a quien deba alcanzar el heartbeat
+	if cfg.Peer, err = loadPeer(); err != nil {
+		return nil, err
+	}
+	if cfg.Peer.Enabled() && cfg.API.Enabled() && cfg.Peer.Addr == cfg.API.Addr {
+		return nil, fmt.Errorf("PEER_ADDR debe ser distinta de HTTP_ADDR")
+	}
+
+	if cfg.Pings, err = loadPings(); err != nil {
+		return nil, err
+	}
+	if cfg.Pings.Enabled() && !cfg.API.Enabled() {
+		return nil, fmt.Errorf("PINGS_FILE requiere HTTP_ADDR para recibir los pings")
+	}
+
 	return cfg, nil
 }
 
+// DKIMEnabled indica si se configuró alguna opción de DKIM
+func (c *Config) DKIMEnabled() bool {
+	return c.DKIMDomain != "" || c.DKIMSelector != "" || c.DKIMKeyFile != ""
+}
+
+// UsesRelay indica si alguno de los modos de entrega usa el smarthost
+func (c *Config) UsesRelay() bool {
+	for _, channel := range deliveryChannels {
+		// Los canales sin modos propios heredan los globales
+		modes, ok := c.ChannelDelivery[channel]
+		if !ok {
+			modes = c.DeliveryModes
+		}
+		for _, mode := range modes {
+			if mode == "relay" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// deliveryChannels son los canales de notificación con modo de entrega propio
+var deliveryChannels = []string{"connection", "services", "system", "report"}
+
+func validateDeliveryModes(key string, modes []string) error {
+	for _, mode := range modes {
+		if mode != "relay" && mode != "mx" {
+			return fmt.Errorf("%s inválido: %s (valores: relay, mx)", key, mode)
+		}
+	}
+	return nil
+}
+
 func getEnv(key, defaultValue string) string {
 	value := os.Getenv(key)
 	if value == "" {
@@ -73,3 +263,52 @@ func getEnv(key, defaultValue string) string {
 	return value
 }
 
+// getEnvList retorna una lista separada por comas, sin elementos vacíos
+func getEnvList(key, defaultValue string) []string {
+	var list []string
+	for _, item := range strings.Split(getEnv(key, defaultValue), ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvInt retorna un entero, validando el formato
+func getEnvInt(key string, defaultValue int) (int, error) {
+	value, err := strconv.Atoi(getEnv(key, strconv.Itoa(defaultValue)))
+	if err != nil {
+		return 0, fmt.Errorf("%s debe ser un número válido: %w", key, err)
+	}
+	return value, nil
+}
+
+// getEnvFloat retorna un número decimal, validando el formato
+func getEnvFloat(key string, defaultValue float64) (float64, error) {
+	value, err := strconv.ParseFloat(getEnv(key, strconv.FormatFloat(defaultValue, 'f', -1, 64)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s debe ser un número válido: %w", key, err)
+	}
+	return value, nil
+}
+
+// getEnvSeconds retorna una duración expresada en segundos
+func getEnvSeconds(key string, defaultValue int) (time.Duration, error) {
+	value, err := getEnvInt(key, defaultValue)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(value) * time.Second, nil
+}
+
+// getEnvBool retorna un booleano (true/false, 1/0, yes/no)
+func getEnvBool(key string, defaultValue bool) (bool, error) {
+	switch strings.ToLower(getEnv(key, strconv.FormatBool(defaultValue))) {
+	case "true", "1", "yes", "si", "sí":
+		return true, nil
+	case "false", "0", "no":
+		return false, nil
+	}
+	return false, fmt.Errorf("%s debe ser true o false", key)
+}
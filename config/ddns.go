@@ -0,0 +1,99 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// DDNSConfig agrupa la configuración de actualización dinámica de DNS
+type DDNSConfig struct {
+	Providers      []string
+	VerifyInterval time.Duration
+
+	CloudflareAPIURL  string
+	CloudflareToken   string
+	CloudflareZoneID  string
+	CloudflareRecords []string
+
+	DuckDNSAPIURL  string
+	DuckDNSToken   string
+	DuckDNSDomains []string
+
+	DynDNS2URL       string
+	DynDNS2User      string
+	DynDNS2Password  string
+	DynDNS2Hostnames []string
+
+	RFC2136Server        string
+	RFC2136Zone          string
+	RFC2136Records       []string
+	RFC2136TTL           int
+	RFC2136TSIGKey       string
+	RFC2136TSIGSecret    string
+	RFC2136TSIGAlgorithm string
+}
+
+// Enabled indica si hay algún proveedor DDNS configurado
+func (c DDNSConfig) Enabled() bool {
+	return len(c.Providers) > 0
+}
+
+func loadDDNS() (DDNSConfig, error) {
+	var c DDNSConfig
+	var err error
+
+	c.Providers = getEnvList("DDNS_PROVIDERS", "")
+
+	if c.VerifyInterval, err = getEnvSeconds("DDNS_VERIFY_INTERVAL", 3600); err != nil {
+		return c, err
+	}
+
+	c.CloudflareAPIURL = getEnv("CLOUDFLARE_API_URL", "https://api.cloudflare.com/client/v4")
+	c.CloudflareToken = getEnv("CLOUDFLARE_API_TOKEN", "")
+	c.CloudflareZoneID = getEnv("CLOUDFLARE_ZONE_ID", "")
+	c.CloudflareRecords = getEnvList("CLOUDFLARE_RECORDS", "")
+
+	c.DuckDNSAPIURL = getEnv("DUCKDNS_API_URL", "https://www.duckdns.org/update")
+	c.DuckDNSToken = getEnv("DUCKDNS_TOKEN", "")
+	c.DuckDNSDomains = getEnvList("DUCKDNS_DOMAINS", "")
+
+	c.DynDNS2URL = getEnv("DYNDNS2_URL", "https://dynupdate.no-ip.com/nic/update")
+	c.DynDNS2User = getEnv("DYNDNS2_USER", "")
+	c.DynDNS2Password = getEnv("DYNDNS2_PASSWORD", "")
+	c.DynDNS2Hostnames = getEnvList("DYNDNS2_HOSTNAMES", "")
+
+	c.RFC2136Server = getEnv("RFC2136_SERVER", "")
+	c.RFC2136Zone = getEnv("RFC2136_ZONE", "")
+	c.RFC2136Records = getEnvList("RFC2136_RECORDS", "")
+	if c.RFC2136TTL, err = getEnvInt("RFC2136_TTL", 300); err != nil {
+		return c, err
+	}
+	c.RFC2136TSIGKey = getEnv("RFC2136_TSIG_KEY", "")
+	c.RFC2136TSIGSecret = getEnv("RFC2136_TSIG_SECRET", "")
+	c.RFC2136TSIGAlgorithm = getEnv("RFC2136_TSIG_ALGORITHM", "hmac-sha256")
+
+	for _, provider := range c.Providers {
+		switch provider {
+		case "cloudflare":
+			if c.CloudflareToken == "" || c.CloudflareZoneID == "" || len(c.CloudflareRecords) == 0 {
+				return c, fmt.Errorf("cloudflare requiere CLOUDFLARE_API_TOKEN, CLOUDFLARE_ZONE_ID y CLOUDFLARE_RECORDS")
+			}
+		case "duckdns":
+			if c.DuckDNSToken == "" || len(c.DuckDNSDomains) == 0 {
+				return c, fmt.Errorf("duckdns requiere DUCKDNS_TOKEN y DUCKDNS_DOMAINS")
+			}
+		case "dyndns2":
+			if c.DynDNS2User == "" || c.DynDNS2Password == "" || len(c.DynDNS2Hostnames) == 0 {
+				return c, fmt.Errorf("dyndns2 requiere DYNDNS2_USER, DYNDNS2_PASSWORD y DYNDNS2_HOSTNAMES")
+			}
+		case "rfc2136":
+			if c.RFC2136Server == "" || c.RFC2136Zone == "" || len(c.RFC2136Records) == 0 {
+				return c, fmt.Errorf("rfc2136 requiere RFC2136_SERVER, RFC2136_ZONE y RFC2136_RECORDS")
+			}
+		default:
+			return c, fmt.Errorf("DDNS_PROVIDERS contiene un proveedor desconocido: %s", provider)
+		}
+	}
+
+	return c, nil
+}
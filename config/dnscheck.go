@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DNSCheckConfig agrupa la verificación de que los nombres públicos resuelven a la IP externa
+type DNSCheckConfig struct {
+	Hosts     []DNSCheckHost
+	Resolvers []string
+	Interval  time.Duration
+	Grace     time.Duration
+}
+
+// DNSCheckHost es un nombre con los tipos de registro exigidos; sin tipos se exige A
+// y AAAA solo se compara si el nombre publica registros AAAA
+type DNSCheckHost struct {
+	Name  string
+	Types []string
+}
+
+func (h DNSCheckHost) String() string {
+	if len(h.Types) == 0 {
+		return h.Name
+	}
+	return h.Name + ":" + strings.Join(h.Types, "+")
+}
+
+// Enabled indica si hay nombres configurados para verificar
+func (c DNSCheckConfig) Enabled() bool {
+	return len(c.Hosts) > 0
+}
+
+func loadDNSCheck() (DNSCheckConfig, error) {
+	var c DNSCheckConfig
+	var err error
+
+	// Cada nombre acepta los tipos exigidos como sufijo: www.example.com:A, v6.example.com:A+AAAA
+	for _, spec := range getEnvList("DNS_CHECK_HOSTS", "") {
+		host, err := parseDNSCheckHost(spec)
+		if err != nil {
+			return c, err
+		}
+		c.Hosts = append(c.Hosts, host)
+	}
+	c.Resolvers = getEnvList("DNS_CHECK_RESOLVERS", "1.1.1.1,8.8.8.8")
+
+	if c.Interval, err = getEnvSeconds("DNS_CHECK_INTERVAL", 600); err != nil {
+		return c, err
+	}
+	// Margen tras un cambio de IP para que expire el TTL de los registros
+	if c.Grace, err = getEnvSeconds("DNS_CHECK_GRACE", 900); err != nil {
+		return c, err
+	}
+
+	return c, nil
+}
+
+func parseDNSCheckHost(spec string) (DNSCheckHost, error) {
+	name, types, found := strings.Cut(spec, ":")
+	host := DNSCheckHost{Name: name}
+	if !found {
+		return host, nil
+	}
+	for _, t := range strings.Split(types, "+") {
+		t = strings.ToUpper(strings.TrimSpace(t))
+		if t != "A" && t != "AAAA" {
+			return host, fmt.Errorf("DNS_CHECK_HOSTS: tipo de registro inválido en %s (valores: A, AAAA)", spec)
+		}
+		host.Types = append(host.Types, t)
+	}
+	return host, nil
+}
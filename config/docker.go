@@ -0,0 +1,46 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// DockerConfig agrupa la vigilancia de contenedores por el socket de Docker
+type DockerConfig struct {
+	Enabled       bool
+	Socket        string
+	Containers    []string
+	Labels        []string
+	Interval      time.Duration
+	RestartLimit  int
+	RestartWindow time.Duration
+}
+
+func loadDocker() (DockerConfig, error) {
+	var c DockerConfig
+	var err error
+
+	if c.Enabled, err = getEnvBool("DOCKER_MONITOR", false); err != nil {
+		return c, err
+	}
+	c.Socket = getEnv("DOCKER_SOCKET", "/var/run/docker.sock")
+
+	// Sin nombres ni etiquetas se vigilan todos los contenedores
+	c.Containers = getEnvList("DOCKER_CONTAINERS", "")
+	c.Labels = getEnvList("DOCKER_LABELS", "")
+
+	if c.Interval, err = getEnvSeconds("DOCKER_INTERVAL", 60); err != nil {
+		return c, err
+	}
+	if c.RestartLimit, err = getEnvInt("DOCKER_RESTART_LIMIT", 3); err != nil {
+		return c, err
+	}
+	if c.RestartWindow, err = getEnvSeconds("DOCKER_RESTART_WINDOW", 900); err != nil {
+		return c, err
+	}
+	if c.Enabled && c.RestartLimit > 0 && c.RestartWindow <= 0 {
+		return c, fmt.Errorf("DOCKER_RESTART_WINDOW debe ser mayor que 0")
+	}
+
+	return c, nil
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// HealthcheckConfig agrupa el envío de pings a servicios tipo healthchecks.io
+type HealthcheckConfig struct {
+	URLs       []string
+	Method     string
+	Headers    map[string]string
+	Retries    int
+	Signals    bool // /start, /fail y /<código> además del ping de éxito
+	Start      bool // /start al comenzar cada verificación
+	ExitStatus bool // /<código> en lugar de éxito o /fail
+}
+
+// Enabled indica si se configuró alguna URL
+func (c HealthcheckConfig) Enabled() bool {
+	return len(c.URLs) > 0
+}
+
+func loadHealthcheck() (HealthcheckConfig, error) {
+	var c HealthcheckConfig
+	var err error
+
+	c.URLs = getEnvList("HEALTHCHECK_URL", "")
+
+	c.Method = strings.ToUpper(getEnv("HEALTHCHECK_METHOD", http.MethodGet))
+	switch c.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut:
+	default:
+		return c, fmt.Errorf("HEALTHCHECK_METHOD inválido: %s (valores: GET, HEAD, POST, PUT)", c.Method)
+	}
+
+	// Formato "Nombre: valor", separados por coma
+	c.Headers = make(map[string]string)
+	for _, h := range getEnvList("HEALTHCHECK_HEADERS", "") {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return c, fmt.Errorf("HEALTHCHECK_HEADERS inválido: %q (formato Nombre: valor)", h)
+		}
+		c.Headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+
+	if c.Retries, err = getEnvInt("HEALTHCHECK_RETRIES", 3); err != nil {
+		return c, err
+	}
+	if c.Signals, err = getEnvBool("HEALTHCHECK_SIGNALS", false); err != nil {
+		return c, err
+	}
+	if c.Start, err = getEnvBool("HEALTHCHECK_START", false); err != nil {
+		return c, err
+	}
+	if c.ExitStatus, err = getEnvBool("HEALTHCHECK_EXIT_STATUS", false); err != nil {
+		return c, err
+	}
+
+	return c, nil
+}
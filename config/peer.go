@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// PeerConfig agrupa el heartbeat con otra instancia de orgmserver
+type PeerConfig struct {
+	URL      string
+	Name     string
+	Secret   string
+	Addr     string // dirección de escucha de los heartbeats, separada de HTTP_ADDR
+	Interval time.Duration
+	Missed   int
+}
+
+// Enabled indica si se configuró la otra instancia
+func (c PeerConfig) Enabled() bool {
+	return c.URL != ""
+}
+
+func loadPeer() (PeerConfig, error) {
+	var c PeerConfig
+	var err error
+
+	c.URL = getEnv("PEER_URL", "")
+	c.Secret = getEnv("PEER_SECRET", "")
+	c.Addr = getEnv("PEER_ADDR", ":9101")
+	if c.Interval, err = getEnvSeconds("PEER_INTERVAL", 60); err != nil {
+		return c, err
+	}
+	if c.Missed, err = getEnvInt("PEER_MISSED", 3); err != nil {
+		return c, err
+	}
+	if !c.Enabled() {
+		return c, nil
+	}
+
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return c, fmt.Errorf("PEER_URL inválida: %s", c.URL)
+	}
+	// El nombre por defecto es el host de la otra instancia
+	c.Name = getEnv("PEER_NAME", u.Hostname())
+
+	if len(c.Secret) < 16 {
+		return c, fmt.Errorf("PEER_SECRET debe tener al menos 16 caracteres")
+	}
+	if c.Addr == "" {
+		return c, fmt.Errorf("PEER_ADDR no puede estar vacío")
+	}
+	if c.Interval <= 0 || c.Missed < 1 {
+		return c, fmt.Errorf("PEER_INTERVAL y PEER_MISSED deben ser mayores que 0")
+	}
+
+	return c, nil
+}
@@ -0,0 +1,15 @@
+package config
+
+// PingsConfig indica el archivo JSON con los checks que reciben pings en /ping/<id>
+type PingsConfig struct {
+	File string
+}
+
+// Enabled indica si se configuró un archivo de pings
+func (c PingsConfig) Enabled() bool {
+	return c.File != ""
+}
+
+func loadPings() (PingsConfig, error) {
+	return PingsConfig{File: getEnv("PINGS_FILE", "")}, nil
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// ProbeConfig agrupa la medición de latencia, jitter y pérdida y los límites
+// que definen una conexión degradada
+type ProbeConfig struct {
+	Targets          []string
+	Count            int
+	Timeout          time.Duration
+	MaxRTT           time.Duration
+	MaxJitter        time.Duration
+	MaxLoss          float64
+	DegradedPeriod   time.Duration
+	DegradedFraction float64 // proporción de mediciones del período que deben superar los límites
+}
+
+// Enabled indica si hay destinos para medir
+func (c ProbeConfig) Enabled() bool {
+	return len(c.Targets) > 0
+}
+
+func loadProbe() (ProbeConfig, error) {
+	var c ProbeConfig
+	var err error
+
+	c.Targets = getEnvList("PROBE_TARGETS", "")
+	for _, target := range c.Targets {
+		if _, _, err := net.SplitHostPort(target); err != nil {
+			return c, fmt.Errorf("PROBE_TARGETS debe tener formato host:puerto: %s", target)
+		}
+	}
+
+	if c.Count, err = getEnvInt("PROBE_COUNT", 3); err != nil {
+		return c, err
+	}
+	if c.Count < 1 {
+		return c, fmt.Errorf("PROBE_COUNT debe ser mayor que 0")
+	}
+	if c.Timeout, err = getEnvSeconds("PROBE_TIMEOUT", 2); err != nil {
+		return c, err
+	}
+
+	// Límites (0 deshabilita cada uno)
+	maxRTT, err := getEnvInt("PROBE_MAX_RTT_MS", 150)
+	if err != nil {
+		return c, err
+	}
+	c.MaxRTT = time.Duration(maxRTT) * time.Millisecond
+	maxJitter, err := getEnvInt("PROBE_MAX_JITTER_MS", 30)
+	if err != nil {
+		return c, err
+	}
+	c.MaxJitter = time.Duration(maxJitter) * time.Millisecond
+	maxLoss, err := getEnvInt("PROBE_MAX_LOSS_PERCENT", 5)
+	if err != nil {
+		return c, err
+	}
+	c.MaxLoss = float64(maxLoss)
+
+	// Tiempo que los límites deben superarse de forma sostenida antes de alertar
+	if c.DegradedPeriod, err = getEnvSeconds("PROBE_DEGRADED_PERIOD", 600); err != nil {
+		return c, err
+	}
+	percent, err := getEnvInt("PROBE_DEGRADED_PERCENT", 100)
+	if err != nil {
+		return c, err
+	}
+	if percent < 1 || percent > 100 {
+		return c, fmt.Errorf("PROBE_DEGRADED_PERCENT debe estar entre 1 y 100")
+	}
+	c.DegradedFraction = float64(percent) / 100
+
+	return c, nil
+}
@@ -0,0 +1,25 @@
+package config
+
+import "time"
+
+// ReportConfig define el envío periódico del informe de estado
+type ReportConfig struct {
+	Interval time.Duration
+}
+
+// Enabled indica si el informe está habilitado
+func (c ReportConfig) Enabled() bool {
+	return c.Interval > 0
+}
+
+func loadReport() (ReportConfig, error) {
+	var c ReportConfig
+	var err error
+
+	// 0 deshabilita el informe
+	if c.Interval, err = getEnvSeconds("REPORT_INTERVAL", 0); err != nil {
+		return c, err
+	}
+
+	return c, nil
+}
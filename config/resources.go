@@ -0,0 +1,55 @@
+package config
+
+import "time"
+
+// ResourcesConfig agrupa el monitoreo de disco, memoria, carga y temperatura del host
+type ResourcesConfig struct {
+	Enabled       bool
+	DiskPaths     []string
+	MaxDiskPct    float64
+	MaxMemoryPct  float64
+	MaxLoadPerCPU float64
+	MaxTempC      float64
+	AlertSamples  int
+	Interval      time.Duration
+	ProcPath      string
+	SysPath       string
+}
+
+func loadResources() (ResourcesConfig, error) {
+	var c ResourcesConfig
+	var err error
+
+	if c.Enabled, err = getEnvBool("RESOURCE_MONITOR", false); err != nil {
+		return c, err
+	}
+	c.DiskPaths = getEnvList("RESOURCE_DISK_PATHS", "/")
+
+	// Límites (0 deshabilita cada uno)
+	if c.MaxDiskPct, err = getEnvFloat("RESOURCE_DISK_MAX_PERCENT", 90); err != nil {
+		return c, err
+	}
+	if c.MaxMemoryPct, err = getEnvFloat("RESOURCE_MEMORY_MAX_PERCENT", 90); err != nil {
+		return c, err
+	}
+	if c.MaxLoadPerCPU, err = getEnvFloat("RESOURCE_LOAD_MAX_PER_CPU", 2); err != nil {
+		return c, err
+	}
+	if c.MaxTempC, err = getEnvFloat("RESOURCE_TEMP_MAX", 80); err != nil {
+		return c, err
+	}
+
+	// Lecturas seguidas necesarias para abrir o cerrar una alerta
+	if c.AlertSamples, err = getEnvInt("RESOURCE_ALERT_SAMPLES", 3); err != nil {
+		return c, err
+	}
+	if c.Interval, err = getEnvSeconds("RESOURCE_INTERVAL", 60); err != nil {
+		return c, err
+	}
+
+	// Dentro de un contenedor se pueden montar los del host, por ejemplo /host/proc
+	c.ProcPath = getEnv("RESOURCE_PROC_PATH", "/proc")
+	c.SysPath = getEnv("RESOURCE_SYS_PATH", "/sys")
+
+	return c, nil
+}
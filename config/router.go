@@ -0,0 +1,45 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+// RouterConfig agrupa la consulta de la IP WAN al router (UPnP IGD, NAT-PMP o PCP)
+// para detectar CGNAT
+type RouterConfig struct {
+	Method   string
+	Gateway  netip.Addr
+	Interval time.Duration
+}
+
+// Enabled indica si se configuró un método de consulta al router
+func (c RouterConfig) Enabled() bool {
+	return c.Method != ""
+}
+
+func loadRouter() (RouterConfig, error) {
+	var c RouterConfig
+	var err error
+
+	c.Method = getEnv("ROUTER_METHOD", "")
+	switch c.Method {
+	case "", "upnp", "natpmp", "pcp", "auto":
+	default:
+		return c, fmt.Errorf("ROUTER_METHOD inválido: %s (valores: upnp, natpmp, pcp, auto)", c.Method)
+	}
+
+	// Vacío = puerta de enlace de la ruta por defecto
+	if gw := getEnv("ROUTER_GATEWAY", ""); gw != "" {
+		if c.Gateway, err = netip.ParseAddr(gw); err != nil || !c.Gateway.Is4() {
+			return c, fmt.Errorf("ROUTER_GATEWAY debe ser una dirección IPv4: %s", gw)
+		}
+	}
+
+	if c.Interval, err = getEnvSeconds("ROUTER_CHECK_INTERVAL", 3600); err != nil {
+		return c, err
+	}
+
+	return c, nil
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// ShutdownConfig agrupa el aviso de apagado ("last gasp") al recibir SIGTERM/SIGINT
+// o cuando el UPS informa batería baja, y el plazo para detener el monitor
+type ShutdownConfig struct {
+	Notify   bool
+	Timeout  time.Duration
+	Deadline time.Duration // espera máxima de la vuelta en curso y las notificaciones pendientes
+}
+
+func loadShutdown() (ShutdownConfig, error) {
+	var c ShutdownConfig
+	var err error
+
+	if c.Notify, err = getEnvBool("SHUTDOWN_NOTIFY", false); err != nil {
+		return c, err
+	}
+	// El envío no debe demorar el apagado más allá de este límite
+	if c.Timeout, err = getEnvSeconds("SHUTDOWN_NOTIFY_TIMEOUT", 10); err != nil {
+		return c, err
+	}
+	if c.Notify && c.Timeout <= 0 {
+		return c, fmt.Errorf("SHUTDOWN_NOTIFY_TIMEOUT debe ser mayor que 0")
+	}
+
+	if c.Deadline, err = getEnvSeconds("SHUTDOWN_DEADLINE", 5); err != nil {
+		return c, err
+	}
+	if c.Deadline <= 0 {
+		return c, fmt.Errorf("SHUTDOWN_DEADLINE debe ser mayor que 0")
+	}
+
+	return c, nil
+}
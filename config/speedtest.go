@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// SpeedTestConfig agrupa la medición periódica de ancho de banda
+type SpeedTestConfig struct {
+	DownloadURL     string
+	UploadURL       string
+	DownloadBytes   int64
+	UploadBytes     int64
+	Interval        time.Duration
+	Timeout         time.Duration
+	MinDownloadMbps float64
+	MinUploadMbps   float64
+	History         int
+}
+
+// Enabled indica si hay un servidor configurado para bajada o subida
+func (c SpeedTestConfig) Enabled() bool {
+	return c.DownloadURL != "" || c.UploadURL != ""
+}
+
+func loadSpeedTest() (SpeedTestConfig, error) {
+	var c SpeedTestConfig
+	var err error
+
+	c.DownloadURL = getEnv("SPEEDTEST_DOWNLOAD_URL", "")
+	c.UploadURL = getEnv("SPEEDTEST_UPLOAD_URL", "")
+
+	downloadMB, err := getEnvInt("SPEEDTEST_DOWNLOAD_MB", 25)
+	if err != nil {
+		return c, err
+	}
+	uploadMB, err := getEnvInt("SPEEDTEST_UPLOAD_MB", 10)
+	if err != nil {
+		return c, err
+	}
+	if downloadMB < 0 || uploadMB < 0 {
+		return c, fmt.Errorf("SPEEDTEST_DOWNLOAD_MB y SPEEDTEST_UPLOAD_MB no pueden ser negativos")
+	}
+	c.DownloadBytes = int64(downloadMB) << 20
+	c.UploadBytes = int64(uploadMB) << 20
+
+	if c.Interval, err = getEnvSeconds("SPEEDTEST_INTERVAL", 21600); err != nil {
+		return c, err
+	}
+	if c.Enabled() && c.Interval <= 0 {
+		return c, fmt.Errorf("SPEEDTEST_INTERVAL debe ser mayor que 0")
+	}
+	if c.Timeout, err = getEnvSeconds("SPEEDTEST_TIMEOUT", 120); err != nil {
+		return c, err
+	}
+
+	// Velocidades mínimas contratadas (0 = sin alerta)
+	minDown, err := getEnvInt("SPEEDTEST_MIN_DOWNLOAD_MBPS", 0)
+	if err != nil {
+		return c, err
+	}
+	minUp, err := getEnvInt("SPEEDTEST_MIN_UPLOAD_MBPS", 0)
+	if err != nil {
+		return c, err
+	}
+	c.MinDownloadMbps = float64(minDown)
+	c.MinUploadMbps = float64(minUp)
+
+	if c.History, err = getEnvInt("SPEEDTEST_HISTORY", 100); err != nil {
+		return c, err
+	}
+
+	return c, nil
+}
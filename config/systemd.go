@@ -0,0 +1,28 @@
+package config
+
+import "time"
+
+// SystemdConfig agrupa la vigilancia de unidades de systemd por D-Bus
+type SystemdConfig struct {
+	Units    []string
+	Address  string
+	Interval time.Duration
+}
+
+// Enabled indica si hay unidades para vigilar
+func (c SystemdConfig) Enabled() bool {
+	return len(c.Units) > 0
+}
+
+func loadSystemd() (SystemdConfig, error) {
+	var c SystemdConfig
+	var err error
+
+	c.Units = getEnvList("SYSTEMD_UNITS", "")
+	c.Address = getEnv("SYSTEMD_BUS_ADDRESS", "")
+	if c.Interval, err = getEnvSeconds("SYSTEMD_INTERVAL", 60); err != nil {
+		return c, err
+	}
+
+	return c, nil
+}
@@ -0,0 +1,30 @@
+package config
+
+import "time"
+
+// UPSConfig agrupa la consulta del UPS a través de upsd (Network UPS Tools)
+type UPSConfig struct {
+	Name     string // ups@host[:puerto]
+	Username string
+	Password string
+	Interval time.Duration
+}
+
+// Enabled indica si se configuró un UPS
+func (c UPSConfig) Enabled() bool {
+	return c.Name != ""
+}
+
+func loadUPS() (UPSConfig, error) {
+	var c UPSConfig
+	var err error
+
+	c.Name = getEnv("UPS_NAME", "")
+	c.Username = getEnv("UPS_USERNAME", "")
+	c.Password = getEnv("UPS_PASSWORD", "")
+	if c.Interval, err = getEnvSeconds("UPS_INTERVAL", 30); err != nil {
+		return c, err
+	}
+
+	return c, nil
+}
@@ -0,0 +1,337 @@
+// Package dbus implementa un cliente mínimo del protocolo D-Bus (especificación de
+// freedesktop.org) sobre sockets unix: autenticación EXTERNAL, llamadas a métodos
+// con argumentos de texto y decodificación de respuestas.
+package dbus
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	msgMethodCall   = 1
+	msgMethodReturn = 2
+	msgError        = 3
+
+	fieldPath        = 1
+	fieldInterface   = 2
+	fieldMember      = 3
+	fieldErrorName   = 4
+	fieldReplySerial = 5
+	fieldDestination = 6
+	fieldSignature   = 8
+)
+
+// DefaultSystemBus es la dirección del bus del sistema si no se define DBUS_SYSTEM_BUS_ADDRESS
+const DefaultSystemBus = "unix:path=/run/dbus/system_bus_socket"
+
+// Error es una respuesta de error de D-Bus
+type Error struct {
+	Name    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return e.Name
+	}
+	return e.Name + ": " + e.Message
+}
+
+// Conn es una conexión autenticada a un bus; las llamadas se serializan
+type Conn struct {
+	mu      sync.Mutex
+	conn    net.Conn
+	reader  *bufio.Reader
+	serial  uint32
+	timeout time.Duration
+}
+
+// SystemBus se conecta al bus del sistema
+func SystemBus(ctx context.Context) (*Conn, error) {
+	addr := os.Getenv("DBUS_SYSTEM_BUS_ADDRESS")
+	if addr == "" {
+		addr = DefaultSystemBus
+	}
+	return Dial(ctx, addr)
+}
+
+// Dial se conecta a una dirección D-Bus (unix:path=... o unix:abstract=...),
+// se autentica y registra la conexión con Hello
+func Dial(ctx context.Context, address string) (*Conn, error) {
+	path, err := parseAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := net.Dialer{Timeout: 5 * time.Second}
+	nc, err := dialer.DialContext(ctx, "unix", path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Conn{conn: nc, reader: bufio.NewReader(nc), timeout: 10 * time.Second}
+
+	if err := c.auth(ctx); err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("autenticación D-Bus: %w", err)
+	}
+	if _, err := c.Call(ctx, "org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "Hello"); err != nil {
+		nc.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// parseAddress toma la primera dirección unix de la lista
+func parseAddress(address string) (string, error) {
+	for _, entry := range strings.Split(address, ";") {
+		transport, params, ok := strings.Cut(entry, ":")
+		if !ok || transport != "unix" {
+			continue
+		}
+		for _, kv := range strings.Split(params, ",") {
+			key, value, _ := strings.Cut(kv, "=")
+			switch key {
+			case "path":
+				return unescape(value), nil
+			case "abstract":
+				return "@" + unescape(value), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("dirección D-Bus no soportada: %s", address)
+}
+
+// unescape decodifica los %xx de las direcciones D-Bus
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// auth usa el mecanismo EXTERNAL: el servidor verifica el uid por el socket
+func (c *Conn) auth(ctx context.Context) error {
+	defer c.watch(ctx)()
+
+	uid := hex.EncodeToString([]byte(strconv.Itoa(os.Getuid())))
+	if _, err := c.conn.Write([]byte("\x00AUTH EXTERNAL " + uid + "\r\n")); err != nil {
+		return err
+	}
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "OK ") {
+		return fmt.Errorf("el bus rechazó la autenticación: %s", strings.TrimSpace(line))
+	}
+	_, err = c.conn.Write([]byte("BEGIN\r\n"))
+	return err
+}
+
+// watch fija el plazo de la operación en curso: el menor entre ctx y el timeout de
+// la conexión. Si ctx se cancela, la lectura o escritura pendiente se interrumpe.
+// La función retornada restablece la conexión al terminar.
+func (c *Conn) watch(ctx context.Context) func() {
+	deadline := time.Now().Add(c.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	c.conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() {
+		c.conn.SetDeadline(time.Unix(1, 0))
+	})
+	return func() {
+		if stop() {
+			c.conn.SetDeadline(time.Time{})
+		}
+	}
+}
+
+// Close cierra la conexión
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// Call invoca un método con argumentos de tipo string y retorna los valores de la respuesta
+func (c *Conn) Call(ctx context.Context, dest string, path ObjectPath, iface, member string, args ...string) ([]interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.serial++
+	serial := c.serial
+
+	var body encoder
+	for _, a := range args {
+		body.string(a)
+	}
+
+	var e encoder
+	e.byte('l')
+	e.byte(msgMethodCall)
+	e.byte(0)
+	e.byte(1)
+	e.uint32(uint32(len(body.buf)))
+	e.uint32(serial)
+
+	// Campos del encabezado: arreglo de (byte, variant)
+	var fields encoder
+	fields.buf = make([]byte, 0, 128)
+	field := func(code byte, sig string, write func()) {
+		fields.align(8)
+		fields.byte(code)
+		fields.signature(sig)
+		write()
+	}
+	// La alineación de los campos es relativa al mensaje: empiezan en el byte 16
+	fields.buf = append(fields.buf, make([]byte, 16)...)
+	field(fieldPath, "o", func() { fields.string(string(path)) })
+	field(fieldInterface, "s", func() { fields.string(iface) })
+	field(fieldMember, "s", func() { fields.string(member) })
+	field(fieldDestination, "s", func() { fields.string(dest) })
+	if len(args) > 0 {
+		field(fieldSignature, "g", func() { fields.signature(strings.Repeat("s", len(args))) })
+	}
+	fieldBytes := fields.buf[16:]
+
+	e.uint32(uint32(len(fieldBytes)))
+	e.buf = append(e.buf, fieldBytes...)
+	e.align(8)
+	e.buf = append(e.buf, body.buf...)
+
+	defer c.watch(ctx)()
+
+	if _, err := c.conn.Write(e.buf); err != nil {
+		return nil, interrupted(ctx, err)
+	}
+
+	// Descartar señales y otros mensajes hasta recibir la respuesta
+	for {
+		msg, err := c.readMessage()
+		if err != nil {
+			return nil, interrupted(ctx, err)
+		}
+		if msg.replySerial != serial {
+			continue
+		}
+		switch msg.kind {
+		case msgMethodReturn:
+			return msg.body, nil
+		case msgError:
+			e := &Error{Name: msg.errorName}
+			if len(msg.body) > 0 {
+				e.Message, _ = msg.body[0].(string)
+			}
+			return nil, e
+		}
+	}
+}
+
+// interrupted reemplaza el error de E/S por el de ctx si la operación se canceló
+func interrupted(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+type message struct {
+	kind        byte
+	replySerial uint32
+	errorName   string
+	body        []interface{}
+}
+
+// readMessage lee un mensaje completo del socket
+func (c *Conn) readMessage() (*message, error) {
+	head := make([]byte, 16)
+	if _, err := io.ReadFull(c.reader, head); err != nil {
+		return nil, err
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if head[0] == 'B' {
+		order = binary.BigEndian
+	} else if head[0] != 'l' {
+		return nil, fmt.Errorf("orden de bytes desconocido: %q", head[0])
+	}
+	if order == binary.BigEndian {
+		return nil, fmt.Errorf("mensajes big-endian no soportados")
+	}
+
+	bodyLen := order.Uint32(head[4:])
+	fieldsLen := order.Uint32(head[12:])
+	if bodyLen > 1<<26 || fieldsLen > 1<<26 {
+		return nil, fmt.Errorf("mensaje D-Bus demasiado grande")
+	}
+	headerLen := 16 + int(fieldsLen)
+	padded := (headerLen + 7) &^ 7
+	rest := make([]byte, padded-16+int(bodyLen))
+	if _, err := io.ReadFull(c.reader, rest); err != nil {
+		return nil, err
+	}
+	buf := append(head, rest...)
+
+	msg := &message{kind: head[1]}
+	d := &decoder{buf: buf[:headerLen], pos: 12}
+	fields, err := d.decode("a(yv)")
+	if err != nil {
+		return nil, err
+	}
+
+	var sig string
+	for _, f := range fields.([]interface{}) {
+		pair := f.([]interface{})
+		code := pair[0].(byte)
+		value := pair[1].(Variant).Value
+		switch code {
+		case fieldReplySerial:
+			msg.replySerial, _ = value.(uint32)
+		case fieldErrorName:
+			msg.errorName, _ = value.(string)
+		case fieldSignature:
+			s, _ := value.(Signature)
+			sig = string(s)
+		}
+	}
+
+	body := &decoder{buf: buf, pos: padded}
+	if msg.body, err = body.decodeAll(sig); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// GetProperty lee una propiedad con org.freedesktop.DBus.Properties.Get
+func (c *Conn) GetProperty(ctx context.Context, dest string, path ObjectPath, iface, name string) (interface{}, error) {
+	values, err := c.Call(ctx, dest, path, "org.freedesktop.DBus.Properties", "Get", iface, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 1 {
+		return nil, fmt.Errorf("respuesta inesperada a Get %s", name)
+	}
+	v, ok := values[0].(Variant)
+	if !ok {
+		return nil, fmt.Errorf("respuesta inesperada a Get %s", name)
+	}
+	return v.Value, nil
+}
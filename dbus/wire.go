@@ -0,0 +1,264 @@
+package dbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// ObjectPath es un valor de tipo 'o'
+type ObjectPath string
+
+// Signature es un valor de tipo 'g'
+type Signature string
+
+// Variant es un valor de tipo 'v' con su firma
+type Variant struct {
+	Sig   Signature
+	Value interface{}
+}
+
+// encoder escribe valores alineados en little-endian
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) align(n int) {
+	for len(e.buf)%n != 0 {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *encoder) byte(b byte) {
+	e.buf = append(e.buf, b)
+}
+
+func (e *encoder) uint32(v uint32) {
+	e.align(4)
+	e.buf = binary.LittleEndian.AppendUint32(e.buf, v)
+}
+
+func (e *encoder) string(s string) {
+	e.uint32(uint32(len(s)))
+	e.buf = append(e.buf, s...)
+	e.buf = append(e.buf, 0)
+}
+
+func (e *encoder) signature(s string) {
+	e.byte(byte(len(s)))
+	e.buf = append(e.buf, s...)
+	e.buf = append(e.buf, 0)
+}
+
+// decoder lee valores según su firma. Las posiciones se cuentan desde el inicio
+// del mensaje porque la alineación es relativa a él.
+type decoder struct {
+	buf []byte
+	pos int
+}
+
+func (d *decoder) align(n int) error {
+	for d.pos%n != 0 {
+		d.pos++
+	}
+	if d.pos > len(d.buf) {
+		return errShort
+	}
+	return nil
+}
+
+var errShort = fmt.Errorf("mensaje D-Bus truncado")
+
+func (d *decoder) take(n int) ([]byte, error) {
+	if n < 0 || d.pos+n > len(d.buf) {
+		return nil, errShort
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *decoder) fixed(size int) ([]byte, error) {
+	if err := d.align(size); err != nil {
+		return nil, err
+	}
+	return d.take(size)
+}
+
+func (d *decoder) uint32() (uint32, error) {
+	b, err := d.fixed(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+// alignment retorna la alineación del tipo que empieza en sig[0]
+func alignment(c byte) int {
+	switch c {
+	case 'n', 'q':
+		return 2
+	case 'b', 'i', 'u', 's', 'o', 'a', 'h':
+		return 4
+	case 'x', 't', 'd', '(', '{':
+		return 8
+	}
+	return 1
+}
+
+// nextType retorna el largo del primer tipo completo de sig
+func nextType(sig string) (int, error) {
+	if sig == "" {
+		return 0, fmt.Errorf("firma vacía")
+	}
+	switch sig[0] {
+	case 'a':
+		n, err := nextType(sig[1:])
+		return n + 1, err
+	case '(', '{':
+		closing := byte(')')
+		if sig[0] == '{' {
+			closing = '}'
+		}
+		i := 1
+		for i < len(sig) && sig[i] != closing {
+			n, err := nextType(sig[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+		if i >= len(sig) {
+			return 0, fmt.Errorf("firma incompleta: %s", sig)
+		}
+		return i + 1, nil
+	}
+	return 1, nil
+}
+
+// decodeAll lee una secuencia de valores con la firma dada
+func (d *decoder) decodeAll(sig string) ([]interface{}, error) {
+	var values []interface{}
+	for sig != "" {
+		n, err := nextType(sig)
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.decode(sig[:n])
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+		sig = sig[n:]
+	}
+	return values, nil
+}
+
+// decode lee un único valor de tipo completo sig
+func (d *decoder) decode(sig string) (interface{}, error) {
+	switch sig[0] {
+	case 'y':
+		b, err := d.take(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0], nil
+	case 'b':
+		v, err := d.uint32()
+		return v != 0, err
+	case 'n', 'q':
+		b, err := d.fixed(2)
+		if err != nil {
+			return nil, err
+		}
+		v := binary.LittleEndian.Uint16(b)
+		if sig[0] == 'n' {
+			return int16(v), nil
+		}
+		return v, nil
+	case 'i', 'h':
+		v, err := d.uint32()
+		return int32(v), err
+	case 'u':
+		return d.uint32()
+	case 'x', 't', 'd':
+		b, err := d.fixed(8)
+		if err != nil {
+			return nil, err
+		}
+		v := binary.LittleEndian.Uint64(b)
+		switch sig[0] {
+		case 'x':
+			return int64(v), nil
+		case 'd':
+			return math.Float64frombits(v), nil
+		}
+		return v, nil
+	case 's', 'o':
+		n, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.take(int(n) + 1)
+		if err != nil {
+			return nil, err
+		}
+		if sig[0] == 'o' {
+			return ObjectPath(b[:n]), nil
+		}
+		return string(b[:n]), nil
+	case 'g':
+		s, err := d.sig()
+		return Signature(s), err
+	case 'v':
+		s, err := d.sig()
+		if err != nil {
+			return nil, err
+		}
+		if n, err := nextType(s); err != nil || n != len(s) {
+			return nil, fmt.Errorf("firma de variant inválida: %q", s)
+		}
+		v, err := d.decode(s)
+		return Variant{Sig: Signature(s), Value: v}, err
+	case 'a':
+		n, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+		elem := sig[1:]
+		if err := d.align(alignment(elem[0])); err != nil {
+			return nil, err
+		}
+		end := d.pos + int(n)
+		if end > len(d.buf) {
+			return nil, errShort
+		}
+		var list []interface{}
+		for d.pos < end {
+			v, err := d.decode(elem)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case '(', '{':
+		if err := d.align(8); err != nil {
+			return nil, err
+		}
+		return d.decodeAll(sig[1 : len(sig)-1])
+	}
+	return nil, fmt.Errorf("tipo D-Bus no soportado: %c", sig[0])
+}
+
+func (d *decoder) sig() (string, error) {
+	b, err := d.take(1)
+	if err != nil {
+		return "", err
+	}
+	s, err := d.take(int(b[0]) + 1)
+	if err != nil {
+		return "", err
+	}
+	return string(s[:b[0]]), nil
+}
@@ -0,0 +1,221 @@
+package dbus
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	var e encoder
+	e.byte(7)
+	e.string("hola")
+	e.uint32(42)
+	e.signature("a(yv)")
+	e.string("/org/freedesktop/systemd1")
+
+	d := &decoder{buf: e.buf}
+	values, err := d.decodeAll("ysugo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{byte(7), "hola", uint32(42), Signature("a(yv)"), ObjectPath("/org/freedesktop/systemd1")}
+	if !reflect.DeepEqual(values, want) {
+		t.Fatalf("valores = %#v, se esperaba %#v", values, want)
+	}
+	if d.pos != len(e.buf) {
+		t.Errorf("quedaron %d bytes sin leer", len(e.buf)-d.pos)
+	}
+}
+
+func TestDecodeAlignment(t *testing.T) {
+	// byte, relleno hasta 8, uint64; luego variant "b" y arreglo de int32
+	var e encoder
+	e.byte(1)
+	e.align(8)
+	e.buf = binary.LittleEndian.AppendUint64(e.buf, 1700000000000000)
+	e.signature("b")
+	e.uint32(1)
+	e.uint32(8)
+	e.uint32(0xffffffff)
+	e.uint32(3)
+
+	values, err := (&decoder{buf: e.buf}).decodeAll("ytvai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{byte(1), uint64(1700000000000000), Variant{Sig: "b", Value: true}, []interface{}{int32(-1), int32(3)}}
+	if !reflect.DeepEqual(values, want) {
+		t.Fatalf("valores = %#v, se esperaba %#v", values, want)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	var str encoder
+	str.string("systemd")
+
+	var badVariant encoder
+	badVariant.signature("ss")
+	badVariant.string("a")
+	badVariant.string("b")
+
+	var longArray encoder
+	longArray.uint32(64)
+	longArray.uint32(1)
+
+	for _, tc := range []struct {
+		name string
+		buf  []byte
+		sig  string
+	}{
+		{"texto truncado", str.buf[:6], "s"},
+		{"sin terminador", str.buf[:len(str.buf)-1], "s"},
+		{"entero incompleto", []byte{1, 2}, "u"},
+		{"variant con dos tipos", badVariant.buf, "v"},
+		{"arreglo más largo que el mensaje", longArray.buf, "au"},
+		{"firma incompleta", make([]byte, 16), "(yu"},
+		{"tipo no soportado", make([]byte, 8), "z"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if values, err := (&decoder{buf: tc.buf}).decodeAll(tc.sig); err == nil {
+				t.Errorf("se esperaba error, valores = %#v", values)
+			}
+		})
+	}
+}
+
+func TestNextType(t *testing.T) {
+	for sig, want := range map[string]int{
+		"s":        1,
+		"as":       2,
+		"a(yv)s":   5,
+		"a{sv}":    5,
+		"(s(ub))u": 7,
+		"aas":      3,
+	} {
+		if n, err := nextType(sig); err != nil || n != want {
+			t.Errorf("nextType(%q) = %d, %v; se esperaba %d", sig, n, err, want)
+		}
+	}
+	for _, sig := range []string{"", "(su", "a{s"} {
+		if _, err := nextType(sig); err == nil {
+			t.Errorf("nextType(%q) debía fallar", sig)
+		}
+	}
+}
+
+// readCall lee una llamada del lado del bus y retorna su serial, encabezado y argumentos
+func readCall(t *testing.T, r io.Reader) (uint32, map[byte]interface{}, []interface{}) {
+	head := make([]byte, 16)
+	if _, err := io.ReadFull(r, head); err != nil {
+		t.Error(err)
+		return 0, nil, nil
+	}
+	bodyLen := binary.LittleEndian.Uint32(head[4:])
+	headerLen := 16 + int(binary.LittleEndian.Uint32(head[12:]))
+	padded := (headerLen + 7) &^ 7
+	rest := make([]byte, padded-16+int(bodyLen))
+	if _, err := io.ReadFull(r, rest); err != nil {
+		t.Error(err)
+		return 0, nil, nil
+	}
+	buf := append(head, rest...)
+
+	fields, err := (&decoder{buf: buf[:headerLen], pos: 12}).decode("a(yv)")
+	if err != nil {
+		t.Error(err)
+		return 0, nil, nil
+	}
+	header := make(map[byte]interface{})
+	for _, f := range fields.([]interface{}) {
+		pair := f.([]interface{})
+		header[pair[0].(byte)] = pair[1].(Variant).Value
+	}
+	sig, _ := header[fieldSignature].(Signature)
+	args, err := (&decoder{buf: buf, pos: padded}).decodeAll(string(sig))
+	if err != nil {
+		t.Error(err)
+	}
+	return binary.LittleEndian.Uint32(head[8:]), header, args
+}
+
+// reply arma la respuesta a serial; body se escribe con la firma sig
+func reply(kind byte, serial uint32, sig string, errName string, body func(e *encoder)) []byte {
+	var b encoder
+	if body != nil {
+		body(&b)
+	}
+	var fields encoder
+	fields.buf = make([]byte, 16)
+	field := func(code byte, s string, write func()) {
+		fields.align(8)
+		fields.byte(code)
+		fields.signature(s)
+		write()
+	}
+	field(fieldReplySerial, "u", func() { fields.uint32(serial) })
+	if errName != "" {
+		field(fieldErrorName, "s", func() { fields.string(errName) })
+	}
+	if sig != "" {
+		field(fieldSignature, "g", func() { fields.signature(sig) })
+	}
+
+	var e encoder
+	e.buf = []byte{'l', kind, 0, 1}
+	e.uint32(uint32(len(b.buf)))
+	e.uint32(1000 + serial)
+	e.uint32(uint32(len(fields.buf) - 16))
+	e.buf = append(e.buf, fields.buf[16:]...)
+	e.align(8)
+	return append(e.buf, b.buf...)
+}
+
+func TestCallRoundTrip(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	c := &Conn{conn: client, reader: bufio.NewReader(client), timeout: 5 * time.Second}
+	defer c.Close()
+
+	go func() {
+		// Primera llamada: Get de una propiedad, precedida por una señal ajena
+		serial, header, args := readCall(t, server)
+		if header[fieldPath] != ObjectPath("/org/freedesktop/systemd1/unit/ssh_2eservice") ||
+			header[fieldInterface] != "org.freedesktop.DBus.Properties" || header[fieldMember] != "Get" ||
+			header[fieldDestination] != "org.freedesktop.systemd1" ||
+			!reflect.DeepEqual(args, []interface{}{"org.freedesktop.systemd1.Service", "NRestarts"}) {
+			t.Errorf("llamada = %v %v", header, args)
+		}
+		server.Write(reply(msgMethodReturn, 999, "s", "", func(e *encoder) { e.string("ruido") }))
+		server.Write(reply(msgMethodReturn, serial, "v", "", func(e *encoder) {
+			e.signature("u")
+			e.uint32(4)
+		}))
+
+		// Segunda llamada: error de D-Bus
+		serial, _, _ = readCall(t, server)
+		server.Write(reply(msgError, serial, "s", "org.freedesktop.systemd1.NoSuchUnit", func(e *encoder) {
+			e.string("Unit nada.service not loaded.")
+		}))
+	}()
+
+	ctx := context.Background()
+	v, err := c.GetProperty(ctx, "org.freedesktop.systemd1", "/org/freedesktop/systemd1/unit/ssh_2eservice",
+		"org.freedesktop.systemd1.Service", "NRestarts")
+	if err != nil || v != uint32(4) {
+		t.Fatalf("GetProperty = %#v, %v", v, err)
+	}
+
+	_, err = c.Call(ctx, "org.freedesktop.systemd1", "/org/freedesktop/systemd1",
+		"org.freedesktop.systemd1.Manager", "GetUnit", "nada.service")
+	var dbusErr *Error
+	if !errors.As(err, &dbusErr) || dbusErr.Name != "org.freedesktop.systemd1.NoSuchUnit" || dbusErr.Message != "Unit nada.service not loaded." {
+		t.Fatalf("error = %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package ddns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+// Cloudflare actualiza registros mediante la API v4 de Cloudflare
+type Cloudflare struct {
+	client  *http.Client
+	baseURL string
+	token   string
+	zoneID  string
+	records []string
+}
+
+func NewCloudflare(client *http.Client, baseURL, token, zoneID string, records []string) *Cloudflare {
+	return &Cloudflare{
+		client:  client,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		zoneID:  zoneID,
+		records: records,
+	}
+}
+
+func (c *Cloudflare) Name() string {
+	return "cloudflare"
+}
+
+type cloudflareRecord struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl"`
+	Proxied bool   `json:"proxied"`
+}
+
+type cloudflareResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result json.RawMessage `json:"result"`
+}
+
+// Update crea o actualiza cada registro; si ya tiene la IP no hace cambios
+func (c *Cloudflare) Update(ctx context.Context, ip netip.Addr) error {
+	rtype := recordType(ip)
+	for _, name := range c.records {
+		existing, err := c.findRecord(ctx, rtype, name)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		switch {
+		case existing == nil:
+			record := cloudflareRecord{Type: rtype, Name: name, Content: ip.String(), TTL: 1}
+			if err := c.do(ctx, http.MethodPost, "/zones/"+c.zoneID+"/dns_records", record, nil); err != nil {
+				return fmt.Errorf("%s: error creando registro: %w", name, err)
+			}
+		case existing.Content != ip.String():
+			// Conservar TTL y proxy del registro existente
+			existing.Content = ip.String()
+			if err := c.do(ctx, http.MethodPut, "/zones/"+c.zoneID+"/dns_records/"+existing.ID, existing, nil); err != nil {
+				return fmt.Errorf("%s: error actualizando registro: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Cloudflare) findRecord(ctx context.Context, rtype, name string) (*cloudflareRecord, error) {
+	query := url.Values{"type": {rtype}, "name": {name}}
+	var records []cloudflareRecord
+	if err := c.do(ctx, http.MethodGet, "/zones/"+c.zoneID+"/dns_records?"+query.Encode(), nil, &records); err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return &records[0], nil
+}
+
+func (c *Cloudflare) do(ctx context.Context, method, path string, body, result interface{}) error {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var parsed cloudflareResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return fmt.Errorf("respuesta inválida (status %d): %w", resp.StatusCode, err)
+	}
+	if !parsed.Success {
+		if len(parsed.Errors) > 0 {
+			return fmt.Errorf("API error %d: %s", parsed.Errors[0].Code, parsed.Errors[0].Message)
+		}
+		return fmt.Errorf("API respondió status %d", resp.StatusCode)
+	}
+	if result != nil {
+		return json.Unmarshal(parsed.Result, result)
+	}
+	return nil
+}
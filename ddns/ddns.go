@@ -0,0 +1,141 @@
+package ddns
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"orgmserver/config"
+	"orgmserver/utils"
+	"strings"
+	"time"
+)
+
+const userAgent = "ORGMServer-DDNS/1.0"
+
+// Provider actualiza los registros DNS de un servicio con la IP dada
+type Provider interface {
+	Name() string
+	Update(ctx context.Context, ip netip.Addr) error
+}
+
+// Result es el resultado de la actualización en un proveedor
+type Result struct {
+	Provider string
+	IP       string
+	Err      error
+}
+
+// Manager ejecuta la actualización en todos los proveedores configurados
+type Manager struct {
+	providers []Provider
+	timeout   time.Duration
+	debug     bool
+}
+
+func NewManager(providers []Provider, debug bool) *Manager {
+	return &Manager{
+		providers: providers,
+		timeout:   60 * time.Second,
+		debug:     debug,
+	}
+}
+
+// NewManagerFromConfig crea los proveedores a partir de la configuración
+func NewManagerFromConfig(cfg config.DDNSConfig, debug bool) (*Manager, error) {
+	client := &http.Client{Timeout: 15 * time.Second}
+
+	var providers []Provider
+	for _, name := range cfg.Providers {
+		switch name {
+		case "cloudflare":
+			providers = append(providers, NewCloudflare(client, cfg.CloudflareAPIURL, cfg.CloudflareToken, cfg.CloudflareZoneID, cfg.CloudflareRecords))
+		case "duckdns":
+			providers = append(providers, NewDuckDNS(client, cfg.DuckDNSAPIURL, cfg.DuckDNSToken, cfg.DuckDNSDomains))
+		case "dyndns2":
+			providers = append(providers, NewDynDNS2(client, cfg.DynDNS2URL, cfg.DynDNS2User, cfg.DynDNS2Password, cfg.DynDNS2Hostnames))
+		case "rfc2136":
+			p, err := NewRFC2136(cfg.RFC2136Server, cfg.RFC2136Zone, cfg.RFC2136Records, cfg.RFC2136TTL,
+				cfg.RFC2136TSIGKey, cfg.RFC2136TSIGSecret, cfg.RFC2136TSIGAlgorithm)
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, p)
+		default:
+			return nil, fmt.Errorf("proveedor DDNS desconocido: %s", name)
+		}
+	}
+
+	return NewManager(providers, debug), nil
+}
+
+// Enabled indica si hay proveedores configurados
+func (m *Manager) Enabled() bool {
+	return m != nil && len(m.providers) > 0
+}
+
+// Update actualiza la IP en todos los proveedores y retorna un resultado por proveedor.
+// Cancelar ctx interrumpe la actualización en curso.
+func (m *Manager) Update(ctx context.Context, ip string) []Result {
+	if !m.Enabled() {
+		return nil
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		utils.WriteLog(fmt.Sprintf("[DDNS] IP inválida, no se actualiza: %s", ip), m.debug)
+		return []Result{{Provider: "ddns", IP: ip, Err: fmt.Errorf("IP inválida: %w", err)}}
+	}
+	addr = addr.Unmap()
+
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	results := make([]Result, 0, len(m.providers))
+	for _, p := range m.providers {
+		utils.WriteLog(fmt.Sprintf("[DDNS] Actualizando %s con IP %s", p.Name(), addr), m.debug)
+		err := p.Update(ctx, addr)
+		if err != nil {
+			utils.WriteLog(fmt.Sprintf("[DDNS] Error actualizando %s: %v", p.Name(), err), m.debug)
+		} else {
+			utils.WriteLog(fmt.Sprintf("[DDNS] %s actualizado correctamente", p.Name()), m.debug)
+		}
+		results = append(results, Result{Provider: p.Name(), IP: addr.String(), Err: err})
+	}
+	return results
+}
+
+// Summary retorna un resumen legible de los resultados para las notificaciones
+func Summary(results []Result) string {
+	if len(results) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Fprintf(&b, "- %s: ERROR (%v)\n", r.Provider, r.Err)
+		} else {
+			fmt.Fprintf(&b, "- %s: OK (%s)\n", r.Provider, r.IP)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Failed indica si algún proveedor falló
+func Failed(results []Result) bool {
+	for _, r := range results {
+		if r.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// recordType retorna A o AAAA según la familia de la IP
+func recordType(ip netip.Addr) string {
+	if ip.Is4() {
+		return "A"
+	}
+	return "AAAA"
+}
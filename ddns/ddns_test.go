@@ -0,0 +1,263 @@
+package ddns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var (
+	testIPv4 = netip.MustParseAddr("203.0.113.7")
+	testIPv6 = netip.MustParseAddr("2001:db8::7")
+)
+
+func TestDynDNS2(t *testing.T) {
+	cases := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"good", 200, "good 203.0.113.7\nnochg 203.0.113.7\n", ""},
+		{"un host falla", 200, "good 203.0.113.7\nnohost\n", "b.example.com: nohost"},
+		{"cuerpo vacío", 200, "", "a.example.com: respuesta vacía"},
+		{"línea en blanco", 200, "good 203.0.113.7\n   \nnochg 203.0.113.7", "b.example.com: respuesta vacía"},
+		{"badauth", 401, "badauth", "badauth"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				user, pass, ok := r.BasicAuth()
+				if !ok || user != "user" || pass != "secret" {
+					t.Errorf("credenciales = %q/%q", user, pass)
+				}
+				if got := r.URL.Query().Get("hostname"); got != "a.example.com,b.example.com" {
+					t.Errorf("hostname = %q", got)
+				}
+				if got := r.URL.Query().Get("myip"); got != "203.0.113.7" {
+					t.Errorf("myip = %q", got)
+				}
+				if got := r.URL.Query().Get("system"); got != "dyndns" {
+					t.Errorf("se perdió el parámetro de la URL: system = %q", got)
+				}
+				w.WriteHeader(tc.status)
+				io.WriteString(w, tc.body)
+			}))
+			defer srv.Close()
+
+			p := NewDynDNS2(srv.Client(), srv.URL+"/nic/update?system=dyndns", "user", "secret",
+				[]string{"a.example.com", "b.example.com"})
+			err := p.Update(context.Background(), testIPv4)
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Fatalf("error inesperado: %v", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Fatalf("error = %v, esperado %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestDuckDNS(t *testing.T) {
+	cases := []struct {
+		name    string
+		ip      netip.Addr
+		param   string
+		status  int
+		body    string
+		wantErr bool
+	}{
+		{"ipv4", testIPv4, "ip", 200, "OK", false},
+		{"ipv6", testIPv6, "ipv6", 200, "OK\n", false},
+		{"KO", testIPv4, "ip", 200, "KO", true},
+		{"status", testIPv4, "ip", 500, "", true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				q := r.URL.Query()
+				if q.Get("token") != "tok" || q.Get("domains") != "casa,oficina" {
+					t.Errorf("query = %s", r.URL.RawQuery)
+				}
+				if got := q.Get(tc.param); got != tc.ip.String() {
+					t.Errorf("%s = %q", tc.param, got)
+				}
+				w.WriteHeader(tc.status)
+				io.WriteString(w, tc.body)
+			}))
+			defer srv.Close()
+
+			err := NewDuckDNS(srv.Client(), srv.URL, "tok", []string{"casa", "oficina"}).Update(context.Background(), tc.ip)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("error = %v, esperado error: %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+// fakeCloudflare simula la API de registros DNS de una zona
+type fakeCloudflare struct {
+	t       *testing.T
+	mu      sync.Mutex
+	records map[string]cloudflareRecord
+	calls   []string
+	nextID  int
+}
+
+func (f *fakeCloudflare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, r.Method)
+
+	if r.Header.Get("Authorization") != "Bearer tok" {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, `{"success":false,"errors":[{"code":9109,"message":"Invalid access token"}]}`)
+		return
+	}
+
+	const prefix = "/client/v4/zones/zone1/dns_records"
+	reply := func(result interface{}) {
+		data, _ := json.Marshal(result)
+		fmt.Fprintf(w, `{"success":true,"errors":[],"result":%s}`, data)
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == prefix:
+		found := []cloudflareRecord{}
+		for _, rec := range f.records {
+			if rec.Type == r.URL.Query().Get("type") && rec.Name == r.URL.Query().Get("name") {
+				found = append(found, rec)
+			}
+		}
+		reply(found)
+	case r.Method == http.MethodPost && r.URL.Path == prefix:
+		var rec cloudflareRecord
+		json.NewDecoder(r.Body).Decode(&rec)
+		f.nextID++
+		rec.ID = fmt.Sprintf("id%d", f.nextID)
+		f.records[rec.ID] = rec
+		reply(rec)
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, prefix+"/"):
+		var rec cloudflareRecord
+		json.NewDecoder(r.Body).Decode(&rec)
+		id := strings.TrimPrefix(r.URL.Path, prefix+"/")
+		if _, ok := f.records[id]; !ok || rec.ID != id {
+			f.t.Errorf("PUT a registro inexistente %s", id)
+		}
+		f.records[id] = rec
+		reply(rec)
+	default:
+		f.t.Errorf("petición inesperada %s %s", r.Method, r.URL)
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestCloudflare(t *testing.T) {
+	fake := &fakeCloudflare{t: t, records: map[string]cloudflareRecord{
+		"old": {ID: "old", Type: "A", Name: "www.example.com", Content: "198.51.100.1", TTL: 120, Proxied: true},
+	}, nextID: 1}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	p := NewCloudflare(srv.Client(), srv.URL+"/client/v4/", "tok", "zone1", []string{"www.example.com", "vpn.example.com"})
+	if err := p.Update(context.Background(), testIPv4); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	// Se actualiza el registro existente conservando TTL y proxy, y se crea el que faltaba
+	if got := fake.records["old"]; got.Content != "203.0.113.7" || got.TTL != 120 || !got.Proxied {
+		t.Errorf("registro actualizado: %+v", got)
+	}
+	if got := fake.records["id2"]; got.Name != "vpn.example.com" || got.Type != "A" || got.Content != "203.0.113.7" {
+		t.Errorf("registro creado: %+v", got)
+	}
+
+	// Con la IP ya publicada no se hacen cambios
+	fake.calls = nil
+	if err := p.Update(context.Background(), testIPv4); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if strings.Join(fake.calls, ",") != "GET,GET" {
+		t.Errorf("llamadas sin cambios = %v", fake.calls)
+	}
+
+	// IPv6 usa registros AAAA independientes
+	if err := p.Update(context.Background(), testIPv6); err != nil {
+		t.Fatalf("Update IPv6: %v", err)
+	}
+	if fake.records["old"].Content != "203.0.113.7" || len(fake.records) != 4 {
+		t.Errorf("registros tras IPv6: %+v", fake.records)
+	}
+}
+
+func TestCloudflareAPIError(t *testing.T) {
+	srv := httptest.NewServer(&fakeCloudflare{t: t, records: map[string]cloudflareRecord{}})
+	defer srv.Close()
+
+	err := NewCloudflare(srv.Client(), srv.URL+"/client/v4", "otro", "zone1", []string{"www.example.com"}).
+		Update(context.Background(), testIPv4)
+	if err == nil || !strings.Contains(err.Error(), "Invalid access token") {
+		t.Fatalf("error = %v", err)
+	}
+}
+
+type fakeProvider struct {
+	name string
+	err  error
+	got  netip.Addr
+	ctx  context.Context
+}
+
+func (f *fakeProvider) Name() string { return f.name }
+
+func (f *fakeProvider) Update(ctx context.Context, ip netip.Addr) error {
+	f.got = ip
+	f.ctx = ctx
+	return f.err
+}
+
+func TestManagerUpdate(t *testing.T) {
+	ok := &fakeProvider{name: "uno"}
+	bad := &fakeProvider{name: "dos", err: errors.New("sin red")}
+	m := NewManager([]Provider{ok, bad}, false)
+
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "padre")
+	results := m.Update(ctx, "::ffff:203.0.113.7")
+	if len(results) != 2 || results[0].Err != nil || results[1].Err == nil {
+		t.Fatalf("resultados: %+v", results)
+	}
+	if ok.got != testIPv4 {
+		t.Errorf("IP mapeada no normalizada: %v", ok.got)
+	}
+	if ok.ctx.Value(key{}) != "padre" {
+		t.Error("el contexto del proveedor no deriva del recibido")
+	}
+	if _, has := ok.ctx.Deadline(); !has {
+		t.Error("el contexto del proveedor no tiene plazo")
+	}
+	if !Failed(results) {
+		t.Error("Failed debería ser true")
+	}
+	want := "- uno: OK (203.0.113.7)\n- dos: ERROR (sin red)"
+	if got := Summary(results); got != want {
+		t.Errorf("Summary = %q", got)
+	}
+
+	if results := m.Update(ctx, "no-es-ip"); len(results) != 1 || results[0].Err == nil {
+		t.Errorf("IP inválida: %+v", results)
+	}
+	if (*Manager)(nil).Update(ctx, "203.0.113.7") != nil {
+		t.Error("un manager nil no debe actualizar")
+	}
+}
@@ -0,0 +1,69 @@
+package ddns
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+// DuckDNS actualiza subdominios de duckdns.org
+type DuckDNS struct {
+	client  *http.Client
+	baseURL string
+	token   string
+	domains []string
+}
+
+func NewDuckDNS(client *http.Client, baseURL, token string, domains []string) *DuckDNS {
+	return &DuckDNS{
+		client:  client,
+		baseURL: baseURL,
+		token:   token,
+		domains: domains,
+	}
+}
+
+func (d *DuckDNS) Name() string {
+	return "duckdns"
+}
+
+// Update envía la IP explícitamente; DuckDNS responde "OK" o "KO"
+func (d *DuckDNS) Update(ctx context.Context, ip netip.Addr) error {
+	query := url.Values{
+		"domains": {strings.Join(d.domains, ",")},
+		"token":   {d.token},
+	}
+	if ip.Is4() {
+		query.Set("ip", ip.String())
+	} else {
+		query.Set("ipv6", ip.String())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.baseURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status code: %d", resp.StatusCode)
+	}
+	if answer := strings.TrimSpace(string(body)); !strings.HasPrefix(answer, "OK") {
+		return fmt.Errorf("respuesta de DuckDNS: %s", answer)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package ddns
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+// DynDNS2 implementa el protocolo dyndns2 usado por No-IP, DynDNS y compatibles
+type DynDNS2 struct {
+	client    *http.Client
+	updateURL string
+	user      string
+	password  string
+	hostnames []string
+}
+
+func NewDynDNS2(client *http.Client, updateURL, user, password string, hostnames []string) *DynDNS2 {
+	return &DynDNS2{
+		client:    client,
+		updateURL: updateURL,
+		user:      user,
+		password:  password,
+		hostnames: hostnames,
+	}
+}
+
+func (d *DynDNS2) Name() string {
+	return "dyndns2"
+}
+
+// Update envía una línea de respuesta por hostname: "good <ip>" o "nochg <ip>" indican éxito
+func (d *DynDNS2) Update(ctx context.Context, ip netip.Addr) error {
+	query := url.Values{
+		"hostname": {strings.Join(d.hostnames, ",")},
+		"myip":     {ip.String()},
+	}
+
+	sep := "?"
+	if strings.Contains(d.updateURL, "?") {
+		sep = "&"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.updateURL+sep+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(d.user, d.password)
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("credenciales rechazadas (badauth)")
+	}
+
+	var failures []string
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	for i, line := range lines {
+		host := "?"
+		if i < len(d.hostnames) {
+			host = d.hostnames[i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			failures = append(failures, fmt.Sprintf("%s: respuesta vacía", host))
+			continue
+		}
+		if fields[0] == "good" || fields[0] == "nochg" {
+			continue
+		}
+		failures = append(failures, fmt.Sprintf("%s: %s", host, strings.TrimSpace(line)))
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("respuesta dyndns2: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
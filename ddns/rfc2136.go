@@ -0,0 +1,109 @@
+package ddns
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/netip"
+	"orgmserver/dnsmsg"
+	"strings"
+	"time"
+)
+
+// RFC2136 actualiza registros con DNS UPDATE firmado con TSIG
+type RFC2136 struct {
+	server  string
+	zone    string
+	records []string
+	ttl     uint32
+	key     *dnsmsg.TSIGKey
+	now     func() time.Time
+}
+
+func NewRFC2136(server, zone string, records []string, ttl int, keyName, secret, algorithm string) (*RFC2136, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+
+	r := &RFC2136{
+		server:  server,
+		zone:    fqdn(zone),
+		records: records,
+		ttl:     uint32(ttl),
+		now:     time.Now,
+	}
+
+	if keyName != "" {
+		decoded, err := base64.StdEncoding.DecodeString(secret)
+		if err != nil {
+			return nil, fmt.Errorf("RFC2136_TSIG_SECRET debe estar en base64: %w", err)
+		}
+		r.key = &dnsmsg.TSIGKey{Name: fqdn(keyName), Algorithm: algorithm, Secret: decoded}
+	}
+	return r, nil
+}
+
+func (r *RFC2136) Name() string {
+	return "rfc2136"
+}
+
+// Update reemplaza el RRset A o AAAA de cada registro en una sola transacción
+func (r *RFC2136) Update(ctx context.Context, ip netip.Addr) error {
+	rr := dnsmsg.AddrRR("", r.ttl, ip)
+
+	msg := &dnsmsg.Message{
+		ID:        dnsmsg.NewID(),
+		Opcode:    dnsmsg.OpcodeUpdate,
+		Questions: []dnsmsg.Question{{Name: r.zone, Type: dnsmsg.TypeSOA, Class: dnsmsg.ClassINET}},
+	}
+	for _, name := range r.records {
+		name = r.absolute(name)
+		// Borrar el RRset existente (clase ANY, RFC 2136 sección 2.5.2) y agregar el nuevo
+		msg.Authority = append(msg.Authority,
+			dnsmsg.RR{Name: name, Type: rr.Type, Class: dnsmsg.ClassANY},
+			dnsmsg.RR{Name: name, Type: rr.Type, Class: dnsmsg.ClassINET, TTL: r.ttl, Data: rr.Data},
+		)
+	}
+
+	var wire []byte
+	var err error
+	if r.key != nil {
+		wire, err = dnsmsg.SignTSIG(msg, *r.key, r.now())
+	} else {
+		wire, err = msg.Pack()
+	}
+	if err != nil {
+		return err
+	}
+
+	resp, err := dnsmsg.ExchangeWire(ctx, r.server, wire, msg.ID)
+	if err != nil {
+		return err
+	}
+	if resp.Rcode != dnsmsg.RcodeSuccess {
+		return fmt.Errorf("servidor respondió %s", dnsmsg.RcodeString(resp.Rcode))
+	}
+	return nil
+}
+
+// absolute completa nombres relativos con la zona
+func (r *RFC2136) absolute(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	if name == "@" {
+		return r.zone
+	}
+	if strings.HasSuffix(fqdn(name), r.zone) {
+		return fqdn(name)
+	}
+	return name + "." + r.zone
+}
+
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
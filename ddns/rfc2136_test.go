@@ -0,0 +1,161 @@
+package ddns
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"net"
+	"orgmserver/dnsmsg"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeDNSServer recibe un UPDATE por UDP y responde con el rcode indicado
+type fakeDNSServer struct {
+	conn     net.PacketConn
+	rcode    int
+	received chan []byte
+}
+
+func newFakeDNSServer(t *testing.T, rcode int) *fakeDNSServer {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("sin UDP: %v", err)
+	}
+	s := &fakeDNSServer{conn: conn, rcode: rcode, received: make(chan []byte, 1)}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 4096)
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		wire := append([]byte(nil), buf[:n]...)
+		s.received <- wire
+
+		// Respuesta mínima: misma cabecera con QR y el rcode, sin secciones
+		resp := make([]byte, 12)
+		copy(resp, wire[:4])
+		resp[2] |= 0x80
+		resp[3] = byte(s.rcode)
+		conn.WriteTo(resp, addr)
+	}()
+	return s
+}
+
+func (s *fakeDNSServer) addr() string {
+	return s.conn.LocalAddr().String()
+}
+
+// wireName codifica un nombre como etiquetas, sin compresión
+func wireName(name string) []byte {
+	var b []byte
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0)
+}
+
+// verifyTSIG recalcula el MAC del registro TSIG (RFC 8945, sección 4.3.3) sin usar dnsmsg
+func verifyTSIG(t *testing.T, wire []byte, keyName string, secret []byte) {
+	t.Helper()
+
+	owner := append(wireName(keyName), 0x00, 0xFA, 0x00, 0xFF) // tipo TSIG, clase ANY
+	start := bytes.LastIndex(wire, owner)
+	if start < 0 {
+		t.Fatal("el mensaje no tiene registro TSIG")
+	}
+	rdata := wire[start+len(owner)+6:] // TTL y largo de datos
+	alg := wireName("hmac-sha256.")
+	if !bytes.HasPrefix(rdata, alg) {
+		t.Fatalf("algoritmo TSIG inesperado: %x", rdata)
+	}
+	timers := rdata[len(alg) : len(alg)+8] // tiempo firmado (48 bits) y fudge
+	macLen := int(binary.BigEndian.Uint16(rdata[len(alg)+8:]))
+	mac := rdata[len(alg)+10 : len(alg)+10+macLen]
+
+	// El MAC cubre el mensaje sin TSIG, con ARCOUNT original
+	unsigned := append([]byte(nil), wire[:start]...)
+	binary.BigEndian.PutUint16(unsigned[10:], binary.BigEndian.Uint16(unsigned[10:])-1)
+
+	h := hmac.New(sha256.New, secret)
+	h.Write(unsigned)
+	h.Write(wireName(keyName))
+	h.Write([]byte{0x00, 0xFF, 0, 0, 0, 0})
+	h.Write(alg)
+	h.Write(timers)
+	h.Write([]byte{0, 0, 0, 0})
+	if !hmac.Equal(h.Sum(nil), mac) {
+		t.Fatal("MAC TSIG inválido")
+	}
+}
+
+func TestRFC2136Update(t *testing.T) {
+	srv := newFakeDNSServer(t, dnsmsg.RcodeSuccess)
+
+	p, err := NewRFC2136(srv.addr(), "example.com", []string{"@", "www", "vpn.example.com", "otro.example.org."}, 300,
+		"ddns-key", "c2VjcmV0LWtleS1mb3ItdGVzdHM=", "hmac-sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.now = func() time.Time { return time.Unix(1700000000, 0) }
+
+	if err := p.Update(context.Background(), testIPv4); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	wire := <-srv.received
+	verifyTSIG(t, wire, "ddns-key.", []byte("secret-key-for-tests"))
+
+	msg, err := dnsmsg.Unpack(wire)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Opcode != dnsmsg.OpcodeUpdate {
+		t.Errorf("opcode = %d", msg.Opcode)
+	}
+	if len(msg.Questions) != 1 || msg.Questions[0].Name != "example.com." || msg.Questions[0].Type != dnsmsg.TypeSOA {
+		t.Errorf("zona: %+v", msg.Questions)
+	}
+
+	names := []string{"example.com.", "www.example.com.", "vpn.example.com.", "otro.example.org."}
+	if len(msg.Authority) != 2*len(names) {
+		t.Fatalf("sección de actualización: %+v", msg.Authority)
+	}
+	for i, name := range names {
+		del, add := msg.Authority[2*i], msg.Authority[2*i+1]
+		if del.Name != name || del.Type != dnsmsg.TypeA || del.Class != dnsmsg.ClassANY || len(del.Data) != 0 {
+			t.Errorf("borrado de %s: %+v", name, del)
+		}
+		if addr, ok := add.Addr(); add.Name != name || add.Class != dnsmsg.ClassINET || add.TTL != 300 || !ok || addr != testIPv4 {
+			t.Errorf("alta de %s: %+v", name, add)
+		}
+	}
+}
+
+func TestRFC2136Errors(t *testing.T) {
+	srv := newFakeDNSServer(t, dnsmsg.RcodeNotAuth)
+	p, err := NewRFC2136(srv.addr(), "example.com.", []string{"www"}, 60, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Update(context.Background(), testIPv6)
+	if err == nil || !strings.Contains(err.Error(), "NOTAUTH") {
+		t.Fatalf("error = %v", err)
+	}
+
+	// Sin clave TSIG se envía el UPDATE sin firmar
+	msg, _ := dnsmsg.Unpack(<-srv.received)
+	if len(msg.Additional) != 0 || msg.Authority[1].Type != dnsmsg.TypeAAAA {
+		t.Errorf("UPDATE sin firma: %+v", msg)
+	}
+
+	if _, err := NewRFC2136("ns1:53", "example.com", nil, 60, "k", "no base64!", ""); err == nil {
+		t.Error("se esperaba error con secreto inválido")
+	}
+}
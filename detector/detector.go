@@ -2,9 +2,8 @@ package detector
 
 import (
 	"fmt"
+	"orgmserver/ups"
 	"orgmserver/utils"
-	"os"
-	"strings"
 	"time"
 )
 
@@ -16,17 +15,29 @@ const (
 	CauseInternetLoss CauseType = "internet_loss"
 )
 
+// StateStore es el estado guardado que consulta el detector; lo implementa
+// *utils.StateStore
+type StateStore interface {
+	Get() utils.State
+	Loaded() bool
+}
+
 type Detector struct {
-	stateFilePath string
-	startTime     time.Time
-	debug         bool
+	store     StateStore
+	clock     utils.Clock
+	uptime    func() (time.Duration, error)
+	startTime time.Time
+	debug     bool
+	confirmed bool
 }
 
-func NewDetector(stateFilePath string, debug bool) *Detector {
+func NewDetector(store StateStore, clock utils.Clock, debug bool) *Detector {
 	return &Detector{
-		stateFilePath: stateFilePath,
-		startTime:     time.Now(),
-		debug:         debug,
+		store:     store,
+		clock:     clock,
+		uptime:    utils.SystemUptime,
+		startTime: clock.Now(),
+		debug:     debug,
 	}
 }
 
@@ -35,10 +46,7 @@ func (d *Detector) DetectStartupCause() (CauseType, error) {
 	utils.WriteLog("[DETECTOR] Detectando causa del inicio del servicio", d.debug)
 
 	// Método 1: Verificar archivo de estado
-	stateExists, stateTime, err := d.checkStateFile()
-	if err != nil {
-		utils.WriteLog(fmt.Sprintf("[DETECTOR] Error verificando archivo de estado: %v", err), d.debug)
-	}
+	stateExists, stateTime := d.checkStateFile()
 
 	// Método 2: Verificar uptime del sistema
 	systemUptime, err := d.getSystemUptime()
@@ -50,6 +58,14 @@ func (d *Detector) DetectStartupCause() (CauseType, error) {
 	utils.WriteLog(fmt.Sprintf("[DETECTOR] Uptime del sistema: %v", systemUptime), d.debug)
 	utils.WriteLog(fmt.Sprintf("[DETECTOR] Tiempo de inicio del proceso: %v", d.startTime), d.debug)
 
+	// Método 3: Eventos de energía registrados por el UPS
+	// Si el equipo arrancó estando el UPS en batería, la pérdida de energía está confirmada
+	if d.checkPowerEvents(systemUptime) {
+		d.confirmed = true
+		utils.WriteLog("[DETECTOR] Causa detectada: PÉRDIDA DE ENERGÍA (confirmada por el UPS)", d.debug)
+		return CausePowerLoss, nil
+	}
+
 	// Si no existe archivo de estado, es inicio normal o pérdida de energía
 	if !stateExists {
 		// Si el uptime del sistema es muy corto (< 5 minutos), probablemente pérdida de energía
@@ -62,7 +78,7 @@ func (d *Detector) DetectStartupCause() (CauseType, error) {
 	}
 
 	// Si existe archivo de estado, verificar cuándo fue la última actualización
-	timeSinceStateUpdate := time.Since(stateTime)
+	timeSinceStateUpdate := d.clock.Now().Sub(stateTime)
 	
 	// Si el archivo de estado es muy antiguo (> 1 hora), probablemente pérdida de energía
 	if timeSinceStateUpdate > 1*time.Hour {
@@ -87,14 +103,11 @@ func (d *Detector) DetectStartupCause() (CauseType, error) {
 }
 
 // checkStateFile verifica si existe el archivo de estado y su timestamp
-func (d *Detector) checkStateFile() (bool, time.Time, error) {
-	state, err := utils.LoadState(d.stateFilePath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return false, time.Time{}, nil
-		}
-		return false, time.Time{}, err
+func (d *Detector) checkStateFile() (bool, time.Time) {
+	if !d.store.Loaded() {
+		return false, time.Time{}
 	}
+	state := d.store.Get()
 
 	// Retornar el tiempo más reciente entre LastConnected y StartTime
 	var latestTime time.Time
@@ -104,34 +117,48 @@ func (d *Detector) checkStateFile() (bool, time.Time, error) {
 		latestTime = state.StartTime
 	}
 
-	return true, latestTime, nil
+	return true, latestTime
+}
+
+// checkPowerEvents indica si el último evento del UPS anterior al arranque del sistema
+// dejó el equipo en batería (sin un "power_restored" posterior). Solo cuentan los
+// eventos posteriores al último inicio registrado del servicio: uno anterior ya lo
+// vio un arranque previo y no explica este.
+func (d *Detector) checkPowerEvents(systemUptime time.Duration) bool {
+	state := d.store.Get()
+
+	bootTime := d.clock.Now().Add(-systemUptime)
+	var last *utils.PowerEvent
+	for i := range state.PowerEvents {
+		e := &state.PowerEvents[i]
+		if e.Time.Before(bootTime) && !e.Time.Before(state.StartTime) {
+			last = e
+		}
+	}
+	if last == nil {
+		return false
+	}
+
+	utils.WriteLog(fmt.Sprintf("[DETECTOR] Último evento de energía antes del arranque: %s (%v)", last.Type, last.Time), d.debug)
+	return last.Type != ups.EventPowerRestored
+}
+
+// Confirmed indica si la última causa detectada se confirmó con eventos del UPS
+// en lugar de inferirse del uptime y del archivo de estado
+func (d *Detector) Confirmed() bool {
+	return d.confirmed
 }
 
 // getSystemUptime obtiene el uptime del sistema
 func (d *Detector) getSystemUptime() (time.Duration, error) {
-	// En Linux, leer /proc/uptime
-	data, err := os.ReadFile("/proc/uptime")
+	uptime, err := d.uptime()
 	if err != nil {
 		// Si no está disponible (no es Linux o no se puede leer), usar una aproximación
 		// basada en el tiempo de inicio del proceso
 		utils.WriteLog("[DETECTOR] No se pudo leer /proc/uptime, usando aproximación", d.debug)
-		return time.Since(d.startTime), nil
+		return d.clock.Now().Sub(d.startTime), nil
 	}
-
-	// /proc/uptime contiene dos valores: uptime total y tiempo idle
-	// Solo necesitamos el primero
-	fields := strings.Fields(string(data))
-	if len(fields) == 0 {
-		return time.Since(d.startTime), nil
-	}
-
-	var uptimeSeconds float64
-	_, err = fmt.Sscanf(fields[0], "%f", &uptimeSeconds)
-	if err != nil {
-		return time.Since(d.startTime), nil
-	}
-
-	return time.Duration(uptimeSeconds) * time.Second, nil
+	return uptime.Truncate(time.Second), nil
 }
 
 // GetCauseDescription retorna una descripción legible de la causa
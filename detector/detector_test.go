@@ -0,0 +1,75 @@
+package detector
+
+import (
+	"orgmserver/ups"
+	"orgmserver/utils"
+	"orgmserver/utils/clocktest"
+	"testing"
+	"time"
+)
+
+type fakeStore struct {
+	state  utils.State
+	loaded bool
+}
+
+func (s fakeStore) Get() utils.State {
+	return s.state
+}
+
+func (s fakeStore) Loaded() bool {
+	return s.loaded
+}
+
+func TestDetectStartupCause(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	saved := func(lastConnected time.Duration, events ...utils.PowerEvent) fakeStore {
+		return fakeStore{loaded: true, state: utils.State{
+			StartTime:     now.Add(-30 * 24 * time.Hour),
+			LastConnected: now.Add(-lastConnected),
+			PowerEvents:   events,
+		}}
+	}
+	event := func(ago time.Duration, kind string) utils.PowerEvent {
+		return utils.PowerEvent{Time: now.Add(-ago), Type: kind}
+	}
+
+	tests := []struct {
+		name      string
+		store     fakeStore
+		uptime    time.Duration
+		want      CauseType
+		confirmed bool
+	}{
+		{"sin estado, equipo recién encendido", fakeStore{}, 2 * time.Minute, CausePowerLoss, false},
+		{"sin estado, equipo encendido hace horas", fakeStore{}, 3 * time.Hour, CauseNormal, false},
+		{"estado antiguo y equipo reiniciado", saved(3 * time.Hour), 10 * time.Minute, CausePowerLoss, false},
+		{"estado reciente, solo se reinició el proceso", saved(2 * time.Minute), 5 * 24 * time.Hour, CauseInternetLoss, false},
+		{"servicio detenido un rato", saved(30 * time.Minute), 5 * 24 * time.Hour, CauseNormal, false},
+		{"UPS en batería antes del arranque", saved(20*time.Minute, event(40*time.Minute, ups.EventOnBattery)), 5 * time.Minute, CausePowerLoss, true},
+		{"energía restablecida antes del arranque", saved(20*time.Minute,
+			event(40*time.Minute, ups.EventOnBattery), event(35*time.Minute, ups.EventPowerRestored)), 10 * time.Minute, CauseNormal, false},
+		{"corte posterior al arranque", saved(2*time.Minute, event(time.Minute, ups.EventOnBattery)), 5 * 24 * time.Hour, CauseInternetLoss, false},
+		// El corte ya lo vio el arranque anterior: el reinicio planificado posterior es normal
+		{"corte antiguo y reinicio limpio posterior", fakeStore{loaded: true, state: utils.State{
+			StartTime:     now.Add(-2 * time.Hour),
+			LastConnected: now.Add(-time.Minute),
+			PowerEvents:   []utils.PowerEvent{event(3*time.Hour, ups.EventOnBattery)},
+		}}, 30 * time.Second, CauseNormal, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDetector(tt.store, clocktest.New(now), false)
+			d.uptime = func() (time.Duration, error) { return tt.uptime, nil }
+
+			cause, err := d.DetectStartupCause()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cause != tt.want || d.Confirmed() != tt.confirmed {
+				t.Errorf("causa = %s (confirmada %v), se esperaba %s (confirmada %v)", cause, d.Confirmed(), tt.want, tt.confirmed)
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+// Package discovery obtiene la IP pública consultando varios proveedores (HTTP,
+// DNS, STUN y el router local) y aplicando una regla de mayoría.
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/netip"
+	"orgmserver/gateway"
+	"orgmserver/utils"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Servicios por defecto para cada familia; el dialer fuerza tcp4 o tcp6, así que
+// los servicios dual-stack también devuelven la dirección de la familia pedida
+var (
+	DefaultIPv4Providers = []string{
+		"https://api.ipify.org?format=text",
+		"https://ipv4.icanhazip.com",
+		"https://ifconfig.me/ip",
+		"https://api.ip.sb/ip",
+	}
+	DefaultIPv6Providers = []string{
+		"https://api6.ipify.org?format=text",
+		"https://ipv6.icanhazip.com",
+		"https://ifconfig.me/ip",
+		"https://api.ip.sb/ip",
+	}
+)
+
+// ErrNoConsensus indica que hubo respuestas válidas pero los proveedores no coincidieron
+var ErrNoConsensus = errors.New("sin consenso entre proveedores de IP")
+
+// IPProvider obtiene la IP pública vista desde fuera para una familia (tcp4 o tcp6)
+type IPProvider interface {
+	Name() string
+	Lookup(ctx context.Context, network string) (netip.Addr, error)
+}
+
+// IPDiscovery consulta varios proveedores en paralelo y aplica una regla de mayoría
+type IPDiscovery struct {
+	IPv4Providers []IPProvider
+	IPv6Providers []IPProvider
+	Quorum        int // mínimo de proveedores que deben coincidir
+	Timeout       time.Duration
+
+	mu   sync.Mutex
+	last map[string]netip.Addr // última IP con consenso por familia
+}
+
+var (
+	discoveryMu      sync.RWMutex
+	defaultDiscovery *IPDiscovery
+)
+
+// ConfigureIPDiscovery reemplaza los proveedores usados por GetExternalIPs
+func ConfigureIPDiscovery(d *IPDiscovery) {
+	discoveryMu.Lock()
+	defer discoveryMu.Unlock()
+	defaultDiscovery = d
+}
+
+// currentDiscovery retorna la configuración vigente; la de los proveedores por
+// defecto se crea una sola vez para conservar la última IP con consenso
+func currentDiscovery() *IPDiscovery {
+	discoveryMu.Lock()
+	defer discoveryMu.Unlock()
+	if defaultDiscovery == nil {
+		v4, _ := ParseIPProviders(DefaultIPv4Providers)
+		v6, _ := ParseIPProviders(DefaultIPv6Providers)
+		defaultDiscovery = &IPDiscovery{IPv4Providers: v4, IPv6Providers: v6, Quorum: 2, Timeout: 10 * time.Second}
+	}
+	return defaultDiscovery
+}
+
+// ParseIPProviders crea proveedores a partir de su especificación:
+//   - https://... o http://...: servicio HTTP que responde la IP en texto plano
+//   - dns:opendns, dns:google, dns:cloudflare: consulta DNS directa (opcionalmente dns:google@host:puerto)
+//   - stun:host:puerto: solicitud Binding de STUN
+func ParseIPProviders(specs []string) ([]IPProvider, error) {
+	var providers []IPProvider
+	for _, spec := range specs {
+		switch {
+		case strings.HasPrefix(spec, "http://"), strings.HasPrefix(spec, "https://"):
+			providers = append(providers, &HTTPIPProvider{URL: spec})
+		case strings.HasPrefix(spec, "dns:"):
+			method, server, _ := strings.Cut(strings.TrimPrefix(spec, "dns:"), "@")
+			if _, ok := dnsIPMethods[method]; !ok {
+				return nil, fmt.Errorf("método DNS desconocido: %s (valores: opendns, google, cloudflare)", method)
+			}
+			providers = append(providers, &DNSIPProvider{Method: method, Server: server})
+		case strings.HasPrefix(spec, "stun:"):
+			server := strings.TrimPrefix(spec, "stun:")
+			if _, _, err := net.SplitHostPort(server); err != nil {
+				server = net.JoinHostPort(server, "3478")
+			}
+			providers = append(providers, &STUNIPProvider{Server: server})
+		case strings.HasPrefix(spec, "router:"):
+			method, gw, _ := strings.Cut(strings.TrimPrefix(spec, "router:"), "@")
+			if !gateway.ValidMethod(method) {
+				return nil, fmt.Errorf("método de router desconocido: %s (valores: upnp, natpmp, pcp, auto)", method)
+			}
+			p := &RouterIPProvider{Method: method}
+			if gw != "" {
+				addr, err := netip.ParseAddr(gw)
+				if err != nil || !addr.Is4() {
+					return nil, fmt.Errorf("gateway inválido en %s", spec)
+				}
+				p.Client.Gateway = addr
+			}
+			providers = append(providers, p)
+		default:
+			return nil, fmt.Errorf("proveedor de IP no soportado: %s", spec)
+		}
+	}
+	return providers, nil
+}
+
+// GetExternalIPs obtiene las IP externas de cada familia en paralelo.
+// Solo retorna error si ningún proveedor respondió con una IP válida; una familia
+// con respuestas en desacuerdo cuenta como conectada y conserva la última IP con
+// consenso (vacía si nunca la hubo).
+func GetExternalIPs(ctx context.Context, withIPv6 bool) (utils.ExternalIPs, error) {
+	return currentDiscovery().GetExternalIPs(ctx, withIPv6)
+}
+
+// GetExternalIPs consulta ambas familias en paralelo
+func (d *IPDiscovery) GetExternalIPs(ctx context.Context, withIPv6 bool) (utils.ExternalIPs, error) {
+	var ips utils.ExternalIPs
+	var errV4, errV6 error
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		var addr netip.Addr
+		if addr, errV4 = d.lookupKeep(ctx, "tcp4"); addr.IsValid() {
+			ips.IPv4 = addr.String()
+		}
+	}()
+
+	if withIPv6 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var addr netip.Addr
+			if addr, errV6 = d.lookupKeep(ctx, "tcp6"); addr.IsValid() {
+				ips.IPv6 = addr.String()
+			}
+		}()
+	}
+	wg.Wait()
+
+	reachable := errV4 == nil || errors.Is(errV4, ErrNoConsensus) ||
+		(withIPv6 && (errV6 == nil || errors.Is(errV6, ErrNoConsensus)))
+	if !reachable {
+		if withIPv6 {
+			return ips, fmt.Errorf("no se pudo obtener la IP externa: IPv4: %v; IPv6: %v", errV4, errV6)
+		}
+		return ips, errV4
+	}
+	return ips, nil
+}
+
+// lookupKeep consulta la familia y recuerda la IP con consenso. Sin consenso retorna
+// la última conocida junto con ErrNoConsensus, para no perderla por un desacuerdo
+// pasajero entre proveedores.
+func (d *IPDiscovery) lookupKeep(ctx context.Context, network string) (netip.Addr, error) {
+	addr, err := d.Lookup(ctx, network)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch {
+	case err == nil:
+		if d.last == nil {
+			d.last = make(map[string]netip.Addr)
+		}
+		d.last[network] = addr
+	case errors.Is(err, ErrNoConsensus):
+		if prev, ok := d.last[network]; ok {
+			log.Printf("[DEBUG] Sin consenso (%s), se conserva la IP anterior %s", network, prev)
+			return prev, err
+		}
+	}
+	return addr, err
+}
+
+type providerAnswer struct {
+	provider string
+	addr     netip.Addr
+	err      error
+}
+
+// Lookup consulta todos los proveedores de la familia en paralelo y retorna la IP
+// en la que coincide la mayoría de las respuestas válidas. El timeout de la consulta
+// se aplica dentro de ctx.
+func (d *IPDiscovery) Lookup(ctx context.Context, network string) (netip.Addr, error) {
+	providers := d.IPv4Providers
+	if network == "tcp6" {
+		providers = d.IPv6Providers
+	}
+	if len(providers) == 0 {
+		return netip.Addr{}, fmt.Errorf("no hay proveedores de IP configurados para %s", network)
+	}
+
+	timeout := d.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	answers := make([]providerAnswer, len(providers))
+	var wg sync.WaitGroup
+	for i, p := range providers {
+		wg.Add(1)
+		go func(i int, p IPProvider) {
+			defer wg.Done()
+			addr, err := p.Lookup(ctx, network)
+			if err == nil {
+				err = validateAnswer(addr, network)
+				addr = addr.Unmap()
+			}
+			answers[i] = providerAnswer{provider: p.Name(), addr: addr, err: err}
+		}(i, p)
+	}
+	wg.Wait()
+
+	return consensus(network, answers, d.Quorum)
+}
+
+// consensus aplica la regla de mayoría: la IP ganadora debe tener más de la mitad
+// de las respuestas válidas y al menos quorum votos
+func consensus(network string, answers []providerAnswer, quorum int) (netip.Addr, error) {
+	votes := make(map[netip.Addr][]string)
+	valid := 0
+	for _, a := range answers {
+		if a.err != nil {
+			log.Printf("[DEBUG] Error obteniendo IP (%s) desde %s: %v", network, a.provider, a.err)
+			continue
+		}
+		valid++
+		votes[a.addr] = append(votes[a.addr], a.provider)
+	}
+
+	if valid == 0 {
+		return netip.Addr{}, fmt.Errorf("no se pudo obtener la IP externa (%s) desde ningún servicio", network)
+	}
+
+	// Con menos proveedores configurados que el quórum, exigir todos
+	if quorum > len(answers) {
+		quorum = len(answers)
+	}
+	if quorum < 1 {
+		quorum = 1
+	}
+
+	var winner netip.Addr
+	best := 0
+	for addr, providers := range votes {
+		if len(providers) > best {
+			winner, best = addr, len(providers)
+		}
+	}
+
+	if len(votes) > 1 {
+		log.Printf("[DEBUG] Proveedores de IP (%s) en desacuerdo: %s", network, describeVotes(votes))
+	}
+
+	if best*2 <= valid || best < quorum {
+		return netip.Addr{}, fmt.Errorf("%w (%s): %d de %d respuestas válidas coinciden, se requieren %d",
+			ErrNoConsensus, network, best, valid, quorum)
+	}
+
+	log.Printf("[DEBUG] IP externa (%s) por consenso %d/%d: %s", network, best, valid, winner)
+	return winner, nil
+}
+
+func describeVotes(votes map[netip.Addr][]string) string {
+	parts := make([]string, 0, len(votes))
+	for addr, providers := range votes {
+		parts = append(parts, fmt.Sprintf("%s=[%s]", addr, strings.Join(providers, ", ")))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, " ")
+}
+
+// validateAnswer rechaza direcciones que no son públicas o no son de la familia pedida.
+// Una IPv4 mapeada en IPv6 (::ffff:a.b.c.d) cuenta como IPv4.
+func validateAnswer(addr netip.Addr, network string) error {
+	if (network == "tcp4") != addr.Unmap().Is4() {
+		return fmt.Errorf("IP %s no corresponde a %s", addr, network)
+	}
+	if !utils.IsPublicIP(addr) {
+		return fmt.Errorf("IP %s no es una dirección pública", addr)
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// Las direcciones de documentación no son públicas, así que los tests usan
+// direcciones reales de resolvers públicos
+var (
+	ipA = netip.MustParseAddr("8.8.8.8")
+	ipB = netip.MustParseAddr("1.1.1.1")
+	ipC = netip.MustParseAddr("9.9.9.9")
+)
+
+func answer(addr netip.Addr) providerAnswer {
+	return providerAnswer{provider: "p-" + addr.String(), addr: addr}
+}
+
+func failed() providerAnswer {
+	return providerAnswer{provider: "caído", err: errors.New("timeout")}
+}
+
+func TestConsensus(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		answers   []providerAnswer
+		quorum    int
+		want      netip.Addr
+		noQuorum  bool // se espera ErrNoConsensus
+		allFailed bool // se espera un error que no es ErrNoConsensus
+	}{
+		{name: "unánime", answers: []providerAnswer{answer(ipA), answer(ipA), answer(ipA)}, quorum: 2, want: ipA},
+		{name: "mayoría con un disidente", answers: []providerAnswer{answer(ipA), answer(ipB), answer(ipA)}, quorum: 2, want: ipA},
+		{name: "errores no cuentan", answers: []providerAnswer{answer(ipA), failed(), answer(ipA), failed()}, quorum: 2, want: ipA},
+		{name: "quórum no alcanzado", answers: []providerAnswer{answer(ipA), failed(), failed()}, quorum: 2, noQuorum: true},
+		{name: "empate", answers: []providerAnswer{answer(ipA), answer(ipB), answer(ipA), answer(ipB)}, quorum: 2, noQuorum: true},
+		{name: "sin mayoría absoluta", answers: []providerAnswer{answer(ipA), answer(ipA), answer(ipB), answer(ipC)}, quorum: 2, noQuorum: true},
+		{name: "todos distintos", answers: []providerAnswer{answer(ipA), answer(ipB), answer(ipC)}, quorum: 1, noQuorum: true},
+		{name: "quórum mayor que los proveedores", answers: []providerAnswer{answer(ipA), answer(ipA)}, quorum: 5, want: ipA},
+		{name: "quórum cero", answers: []providerAnswer{answer(ipA)}, quorum: 0, want: ipA},
+		{name: "ninguna respuesta válida", answers: []providerAnswer{failed(), failed()}, quorum: 2, allFailed: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := consensus("tcp4", tc.answers, tc.quorum)
+			switch {
+			case tc.noQuorum:
+				if !errors.Is(err, ErrNoConsensus) {
+					t.Fatalf("error = %v, se esperaba ErrNoConsensus", err)
+				}
+			case tc.allFailed:
+				if err == nil || errors.Is(err, ErrNoConsensus) {
+					t.Fatalf("error = %v, se esperaba falla sin consenso posible", err)
+				}
+			default:
+				if err != nil || got != tc.want {
+					t.Fatalf("consensus = %s, %v; se esperaba %s", got, err, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateAnswer(t *testing.T) {
+	for _, tc := range []struct {
+		addr    string
+		network string
+		ok      bool
+	}{
+		{"8.8.8.8", "tcp4", true},
+		{"2606:4700:4700::1111", "tcp6", true},
+		{"::ffff:8.8.8.8", "tcp4", true},  // mapeada: es IPv4
+		{"::ffff:8.8.8.8", "tcp6", false}, // ...y no una IPv6
+		{"8.8.8.8", "tcp6", false},
+		{"2606:4700:4700::1111", "tcp4", false},
+		{"192.168.1.10", "tcp4", false},
+		{"10.0.0.1", "tcp4", false},
+		{"100.64.12.34", "tcp4", false}, // CGNAT
+		{"::ffff:100.64.12.34", "tcp4", false},
+		{"127.0.0.1", "tcp4", false},
+		{"169.254.1.1", "tcp4", false},
+		{"fd00::1", "tcp6", false},
+		{"fe80::1", "tcp6", false},
+		{"2001:db8::1", "tcp6", false},
+	} {
+		err := validateAnswer(netip.MustParseAddr(tc.addr), tc.network)
+		if (err == nil) != tc.ok {
+			t.Errorf("validateAnswer(%s, %s) = %v", tc.addr, tc.network, err)
+		}
+	}
+	if validateAnswer(netip.Addr{}, "tcp6") == nil {
+		t.Error("una dirección vacía no es válida")
+	}
+}
+
+func TestParseIPProviders(t *testing.T) {
+	providers, err := ParseIPProviders([]string{
+		"https://api.ipify.org",
+		"dns:google@216.239.34.10:53",
+		"stun:stun.example.com",
+		"stun:stun.example.com:19302",
+		"router:natpmp@192.168.1.1",
+		"router:auto",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := providers[0].(*HTTPIPProvider); p.URL != "https://api.ipify.org" {
+		t.Errorf("http = %+v", p)
+	}
+	if p := providers[1].(*DNSIPProvider); p.Method != "google" || p.Server != "216.239.34.10:53" {
+		t.Errorf("dns = %+v", p)
+	}
+	if p := providers[2].(*STUNIPProvider); p.Server != "stun.example.com:3478" {
+		t.Errorf("stun sin puerto = %+v", p)
+	}
+	if p := providers[3].(*STUNIPProvider); p.Server != "stun.example.com:19302" {
+		t.Errorf("stun = %+v", p)
+	}
+	if p := providers[4].(*RouterIPProvider); p.Method != "natpmp" || p.Client.Gateway != netip.MustParseAddr("192.168.1.1") {
+		t.Errorf("router = %+v", p)
+	}
+	if p := providers[5].(*RouterIPProvider); p.Method != "auto" || p.Client.Gateway.IsValid() {
+		t.Errorf("router auto = %+v", p)
+	}
+
+	for spec, msg := range map[string]string{
+		"ftp://example.com":        "no soportado",
+		"api.ipify.org":            "no soportado",
+		"dns:quad9":                "método DNS desconocido",
+		"router:igd":               "método de router desconocido",
+		"router:upnp@router.local": "gateway inválido",
+		"router:upnp@fe80::1":      "gateway inválido",
+	} {
+		if _, err := ParseIPProviders([]string{"https://api.ipify.org", spec}); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("%s: error = %v, se esperaba %q", spec, err, msg)
+		}
+	}
+}
+
+// fakeProvider responde la dirección configurada o un error
+type fakeProvider struct {
+	name string
+	addr netip.Addr
+	err  error
+}
+
+func (p *fakeProvider) Name() string { return p.name }
+
+func (p *fakeProvider) Lookup(ctx context.Context, network string) (netip.Addr, error) {
+	return p.addr, p.err
+}
+
+func TestNoConsensusKeepsPreviousIP(t *testing.T) {
+	a, b := &fakeProvider{name: "a", addr: ipA}, &fakeProvider{name: "b", addr: ipA}
+	d := &IPDiscovery{IPv4Providers: []IPProvider{a, b}, Quorum: 2}
+	ctx := context.Background()
+
+	// Desacuerdo sin IP anterior: conectado pero sin IP
+	b.addr = ipB
+	ips, err := d.GetExternalIPs(ctx, false)
+	if err != nil || ips.IPv4 != "" {
+		t.Fatalf("sin consenso inicial = %+v, %v", ips, err)
+	}
+
+	b.addr = ipA
+	if ips, err = d.GetExternalIPs(ctx, false); err != nil || ips.IPv4 != ipA.String() {
+		t.Fatalf("con consenso = %+v, %v", ips, err)
+	}
+
+	// Un desacuerdo posterior conserva la última IP con consenso
+	a.addr, b.addr = ipB, ipC
+	if ips, err = d.GetExternalIPs(ctx, false); err != nil || ips.IPv4 != ipA.String() {
+		t.Fatalf("sin consenso = %+v, %v; se esperaba conservar %s", ips, err, ipA)
+	}
+
+	// Sin respuestas no se informa la IP anterior: es una desconexión
+	a.err, b.err = errors.New("timeout"), errors.New("timeout")
+	if ips, err = d.GetExternalIPs(ctx, false); err == nil || ips.IPv4 != "" {
+		t.Fatalf("sin respuestas = %+v, %v", ips, err)
+	}
+}
+
+func TestLookupUnmapsAnswers(t *testing.T) {
+	mapped := netip.AddrFrom16(ipA.As16())
+	d := &IPDiscovery{IPv4Providers: []IPProvider{
+		&fakeProvider{name: "a", addr: mapped},
+		&fakeProvider{name: "b", addr: ipA},
+		&fakeProvider{name: "privada", addr: netip.MustParseAddr("192.168.0.1")},
+	}, Quorum: 2}
+	addr, err := d.Lookup(context.Background(), "tcp4")
+	if err != nil || addr != ipA {
+		t.Fatalf("Lookup = %s, %v", addr, err)
+	}
+}
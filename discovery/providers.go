@@ -0,0 +1,335 @@
+package discovery
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"orgmserver/dnsmsg"
+	"orgmserver/gateway"
+	"strings"
+	"time"
+)
+
+// HTTPIPProvider obtiene la IP desde un servicio HTTP que responde la IP en texto plano
+type HTTPIPProvider struct {
+	URL string
+}
+
+func (p *HTTPIPProvider) Name() string {
+	return p.URL
+}
+
+// Lookup realiza la consulta forzando la familia con un dialer tcp4 o tcp6
+func (p *HTTPIPProvider) Lookup(ctx context.Context, network string) (netip.Addr, error) {
+	client := newFamilyClient(network)
+	defer client.CloseIdleConnections()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL, nil)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	req.Header.Set("User-Agent", "ORGMServer/1.0")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return netip.Addr{}, fmt.Errorf("status code: %d", resp.StatusCode)
+	}
+
+	// Una IP nunca supera unas decenas de bytes; un cuerpo mayor es una página de error o portal cautivo
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if err != nil {
+		return netip.Addr{}, err
+	}
+
+	// Limpiar espacios y saltos de línea
+	ip := strings.TrimSpace(string(body))
+	if ip == "" {
+		return netip.Addr{}, fmt.Errorf("respuesta vacía")
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		if len(ip) > 40 {
+			ip = ip[:40] + "..."
+		}
+		return netip.Addr{}, fmt.Errorf("respuesta no es una IP: %q", ip)
+	}
+	return addr.Unmap(), nil
+}
+
+// newFamilyClient crea un cliente HTTP cuyas conexiones usan solo tcp4 o tcp6
+func newFamilyClient(network string) *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, addr)
+			},
+			TLSHandshakeTimeout: 5 * time.Second,
+		},
+	}
+}
+
+// dnsIPMethod describe cómo un servicio DNS revela la IP de quien consulta
+type dnsIPMethod struct {
+	name    string
+	qtype   uint16
+	qclass  uint16
+	server4 string
+	server6 string
+}
+
+var dnsIPMethods = map[string]dnsIPMethod{
+	// myip.opendns.com responde con la IP de origen de la consulta
+	"opendns": {name: "myip.opendns.com", qtype: 0, qclass: dnsmsg.ClassINET,
+		server4: "208.67.222.222:53", server6: "[2620:119:35::35]:53"},
+	// o-o.myaddr.l.google.com responde un TXT con la IP de origen
+	"google": {name: "o-o.myaddr.l.google.com", qtype: dnsmsg.TypeTXT, qclass: dnsmsg.ClassINET,
+		server4: "216.239.32.10:53", server6: "[2001:4860:4802:32::a]:53"},
+	// whoami.cloudflare en clase CHAOS responde un TXT con la IP de origen
+	"cloudflare": {name: "whoami.cloudflare", qtype: dnsmsg.TypeTXT, qclass: dnsmsg.ClassCHAOS,
+		server4: "1.1.1.1:53", server6: "[2606:4700:4700::1111]:53"},
+}
+
+// DNSIPProvider obtiene la IP consultando directamente a un servidor DNS autoritativo
+// que responde con la dirección de origen de la consulta
+type DNSIPProvider struct {
+	Method string // opendns, google o cloudflare
+	Server string // opcional: reemplaza el servidor por defecto (host:puerto)
+}
+
+func (p *DNSIPProvider) Name() string {
+	return "dns:" + p.Method
+}
+
+// Lookup envía la consulta por la familia pedida, ya que la respuesta refleja la IP de origen
+func (p *DNSIPProvider) Lookup(ctx context.Context, network string) (netip.Addr, error) {
+	method, ok := dnsIPMethods[p.Method]
+	if !ok {
+		return netip.Addr{}, fmt.Errorf("método DNS desconocido: %s", p.Method)
+	}
+
+	server := method.server4
+	qtype := method.qtype
+	if network == "tcp6" {
+		server = method.server6
+	}
+	if p.Server != "" {
+		server = p.Server
+	}
+	if qtype == 0 {
+		qtype = dnsmsg.TypeA
+		if network == "tcp6" {
+			qtype = dnsmsg.TypeAAAA
+		}
+	}
+
+	msg := dnsmsg.NewQuery(dnsmsg.NewID(), method.name, qtype, method.qclass)
+	resp, err := dnsmsg.Exchange(ctx, server, msg)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return dnsAnswerAddr(resp)
+}
+
+// dnsAnswerAddr extrae la IP de la respuesta: un registro A/AAAA o el primer TXT
+// que sea una dirección
+func dnsAnswerAddr(resp *dnsmsg.Message) (netip.Addr, error) {
+	if resp.Rcode != dnsmsg.RcodeSuccess {
+		return netip.Addr{}, fmt.Errorf("servidor respondió %s", dnsmsg.RcodeString(resp.Rcode))
+	}
+
+	for _, rr := range resp.Answers {
+		if addr, ok := rr.Addr(); ok {
+			return addr.Unmap(), nil
+		}
+		if rr.Type == dnsmsg.TypeTXT {
+			// Google agrega un segundo TXT con la subred EDNS; se toma el primero que sea una IP
+			for _, txt := range rr.TXT() {
+				if addr, err := netip.ParseAddr(strings.TrimSpace(txt)); err == nil {
+					return addr.Unmap(), nil
+				}
+			}
+		}
+	}
+	return netip.Addr{}, fmt.Errorf("respuesta DNS sin dirección")
+}
+
+// STUNIPProvider obtiene la IP mapeada con una solicitud Binding de STUN (RFC 5389)
+type STUNIPProvider struct {
+	Server string // host:puerto
+}
+
+func (p *STUNIPProvider) Name() string {
+	return "stun:" + p.Server
+}
+
+const (
+	stunMagicCookie      = 0x2112A442
+	stunBindingRequest   = 0x0001
+	stunBindingSuccess   = 0x0101
+	stunMappedAddress    = 0x0001
+	stunXORMappedAddress = 0x0020
+)
+
+// Lookup envía una solicitud Binding por udp4 o udp6 y retorna la dirección reflejada
+func (p *STUNIPProvider) Lookup(ctx context.Context, network string) (netip.Addr, error) {
+	udpNetwork := "udp4"
+	if network == "tcp6" {
+		udpNetwork = "udp6"
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, udpNetwork, p.Server)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+	}
+	defer context.AfterFunc(ctx, func() { conn.Close() })()
+
+	var txID [12]byte
+	if _, err := rand.Read(txID[:]); err != nil {
+		return netip.Addr{}, err
+	}
+	req := make([]byte, 20)
+	binary.BigEndian.PutUint16(req[0:], stunBindingRequest)
+	binary.BigEndian.PutUint16(req[2:], 0)
+	binary.BigEndian.PutUint32(req[4:], stunMagicCookie)
+	copy(req[8:], txID[:])
+
+	if _, err := conn.Write(req); err != nil {
+		return netip.Addr{}, err
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return netip.Addr{}, err
+		}
+		addr, err := parseSTUNResponse(buf[:n], txID)
+		if err == errSTUNMismatch {
+			// Respuesta a otra transacción; seguir esperando
+			continue
+		}
+		return addr, err
+	}
+}
+
+var errSTUNMismatch = fmt.Errorf("respuesta STUN de otra transacción")
+
+// parseSTUNResponse extrae XOR-MAPPED-ADDRESS (o MAPPED-ADDRESS en servidores antiguos)
+func parseSTUNResponse(b []byte, txID [12]byte) (netip.Addr, error) {
+	if len(b) < 20 {
+		return netip.Addr{}, fmt.Errorf("respuesta STUN truncada")
+	}
+	if binary.BigEndian.Uint32(b[4:]) != stunMagicCookie || string(b[8:20]) != string(txID[:]) {
+		return netip.Addr{}, errSTUNMismatch
+	}
+	if msgType := binary.BigEndian.Uint16(b[0:]); msgType != stunBindingSuccess {
+		return netip.Addr{}, fmt.Errorf("respuesta STUN inesperada: 0x%04x", msgType)
+	}
+
+	length := int(binary.BigEndian.Uint16(b[2:]))
+	if 20+length > len(b) {
+		return netip.Addr{}, fmt.Errorf("respuesta STUN truncada")
+	}
+	attrs := b[20 : 20+length]
+
+	var mapped netip.Addr
+	for len(attrs) >= 4 {
+		attrType := binary.BigEndian.Uint16(attrs[0:])
+		attrLen := int(binary.BigEndian.Uint16(attrs[2:]))
+		if 4+attrLen > len(attrs) {
+			break
+		}
+		value := attrs[4 : 4+attrLen]
+
+		switch attrType {
+		case stunXORMappedAddress:
+			if addr, ok := decodeSTUNAddress(value, b[4:20]); ok {
+				return addr, nil
+			}
+		case stunMappedAddress:
+			if addr, ok := decodeSTUNAddress(value, nil); ok {
+				mapped = addr
+			}
+		}
+
+		// Los atributos se alinean a 4 bytes
+		next := 4 + (attrLen+3)&^3
+		if next > len(attrs) {
+			break
+		}
+		attrs = attrs[next:]
+	}
+
+	if mapped.IsValid() {
+		return mapped, nil
+	}
+	return netip.Addr{}, fmt.Errorf("respuesta STUN sin dirección mapeada")
+}
+
+// decodeSTUNAddress decodifica un atributo de dirección; xorKey es cookie+transacción si está ofuscado
+func decodeSTUNAddress(value []byte, xorKey []byte) (netip.Addr, bool) {
+	if len(value) < 4 {
+		return netip.Addr{}, false
+	}
+	family := value[1]
+	raw := value[4:]
+
+	var ip []byte
+	switch {
+	case family == 0x01 && len(raw) >= 4:
+		ip = append([]byte(nil), raw[:4]...)
+	case family == 0x02 && len(raw) >= 16:
+		ip = append([]byte(nil), raw[:16]...)
+	default:
+		return netip.Addr{}, false
+	}
+
+	if xorKey != nil {
+		for i := range ip {
+			ip[i] ^= xorKey[i]
+		}
+	}
+
+	addr, ok := netip.AddrFromSlice(ip)
+	return addr.Unmap(), ok
+}
+
+// RouterIPProvider pregunta al router local la IP de su interfaz WAN. Solo
+// responde IPv4; detrás de CGNAT la respuesta es privada y la validación la descarta.
+type RouterIPProvider struct {
+	Method string // upnp, natpmp, pcp o auto
+	Client gateway.Client
+}
+
+func (p *RouterIPProvider) Name() string {
+	return "router:" + p.Method
+}
+
+func (p *RouterIPProvider) Lookup(ctx context.Context, network string) (netip.Addr, error) {
+	if network != "tcp4" {
+		return netip.Addr{}, fmt.Errorf("el router solo informa la IP WAN IPv4")
+	}
+	addr, _, err := p.Client.ExternalIP(ctx, p.Method)
+	return addr, err
+}
@@ -0,0 +1,245 @@
+package discovery
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"orgmserver/dnsmsg"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Transacción y atributos de los vectores de prueba de RFC 5769 (secciones 2.2 y 2.3)
+var (
+	rfcTxID = [12]byte{0xb7, 0xe7, 0xa7, 0x01, 0xbc, 0x34, 0xd6, 0x86, 0xfa, 0x87, 0xdf, 0xae}
+
+	// XOR-MAPPED-ADDRESS de 192.0.2.1:32853
+	rfcXORv4 = []byte{0x00, 0x20, 0x00, 0x08, 0x00, 0x01, 0xa1, 0x47, 0xe1, 0x12, 0xa6, 0x43}
+	// XOR-MAPPED-ADDRESS de [2001:db8:1234:5678:11:2233:4455:6677]:32853
+	rfcXORv6 = []byte{0x00, 0x20, 0x00, 0x14, 0x00, 0x02, 0xa1, 0x47,
+		0x01, 0x13, 0xa9, 0xfa, 0xa5, 0xd3, 0xf1, 0x79, 0xbc, 0x25, 0xf4, 0xb5, 0xbe, 0xd2, 0xb9, 0xd9}
+	// SOFTWARE "test vector" (11 bytes, con relleno hasta 12)
+	software = []byte{0x80, 0x22, 0x00, 0x0b, 't', 'e', 's', 't', ' ', 'v', 'e', 'c', 't', 'o', 'r', 0x20}
+	// MAPPED-ADDRESS sin ofuscar de 198.51.100.7:3478
+	mappedV4 = []byte{0x00, 0x01, 0x00, 0x08, 0x00, 0x01, 0x0d, 0x96, 198, 51, 100, 7}
+)
+
+// stunResponse arma una respuesta Binding con los atributos dados
+func stunResponse(msgType uint16, txID [12]byte, attrs ...[]byte) []byte {
+	b := make([]byte, 20)
+	binary.BigEndian.PutUint16(b[0:], msgType)
+	binary.BigEndian.PutUint32(b[4:], stunMagicCookie)
+	copy(b[8:], txID[:])
+	for _, a := range attrs {
+		b = append(b, a...)
+	}
+	binary.BigEndian.PutUint16(b[2:], uint16(len(b)-20))
+	return b
+}
+
+func TestParseSTUNResponse(t *testing.T) {
+	otherTx := rfcTxID
+	otherTx[11] ^= 0xff
+
+	badCookie := stunResponse(stunBindingSuccess, rfcTxID, rfcXORv4)
+	badCookie[4] = 0
+
+	longLength := stunResponse(stunBindingSuccess, rfcTxID, rfcXORv4)
+	binary.BigEndian.PutUint16(longLength[2:], 40)
+
+	for _, tc := range []struct {
+		name string
+		resp []byte
+		want string // dirección esperada o fragmento del error
+	}{
+		{"XOR-MAPPED IPv4 tras un atributo con relleno", stunResponse(stunBindingSuccess, rfcTxID, software, rfcXORv4), "192.0.2.1"},
+		{"XOR-MAPPED IPv6 usa la transacción", stunResponse(stunBindingSuccess, rfcTxID, software, rfcXORv6), "2001:db8:1234:5678:11:2233:4455:6677"},
+		{"MAPPED en servidores antiguos", stunResponse(stunBindingSuccess, rfcTxID, mappedV4), "198.51.100.7"},
+		{"XOR-MAPPED tiene prioridad", stunResponse(stunBindingSuccess, rfcTxID, mappedV4, rfcXORv4), "192.0.2.1"},
+		{"otra transacción", stunResponse(stunBindingSuccess, otherTx, rfcXORv4), "otra transacción"},
+		{"sin magic cookie", badCookie, "otra transacción"},
+		{"respuesta de error", stunResponse(0x0111, rfcTxID), "0x0111"},
+		{"encabezado truncado", stunResponse(stunBindingSuccess, rfcTxID)[:19], "truncada"},
+		{"largo mayor que el mensaje", longLength, "truncada"},
+		{"atributo truncado", stunResponse(stunBindingSuccess, rfcTxID, rfcXORv6[:12]), "sin dirección"},
+		{"IPv6 demasiado corta", stunResponse(stunBindingSuccess, rfcTxID,
+			[]byte{0x00, 0x20, 0x00, 0x08, 0x00, 0x02, 0xa1, 0x47, 0x01, 0x13, 0xa9, 0xfa}), "sin dirección"},
+		{"sin atributos", stunResponse(stunBindingSuccess, rfcTxID), "sin dirección"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			addr, err := parseSTUNResponse(tc.resp, rfcTxID)
+			got := addr.String()
+			if err != nil {
+				got = err.Error()
+			}
+			if (err == nil && got != tc.want) || (err != nil && !strings.Contains(got, tc.want)) {
+				t.Errorf("parseSTUNResponse = %s, se esperaba %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDecodeSTUNAddress(t *testing.T) {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint32(key, stunMagicCookie)
+	copy(key[4:], rfcTxID[:])
+
+	if addr, ok := decodeSTUNAddress(rfcXORv6[4:], key); !ok || addr != netip.MustParseAddr("2001:db8:1234:5678:11:2233:4455:6677") {
+		t.Errorf("IPv6 = %s, %v", addr, ok)
+	}
+	// Con otra transacción la IPv6 decodificada es otra; la IPv4 solo usa la cookie
+	other := append([]byte(nil), key...)
+	other[15] ^= 0xff
+	if addr, _ := decodeSTUNAddress(rfcXORv6[4:], other); addr == netip.MustParseAddr("2001:db8:1234:5678:11:2233:4455:6677") {
+		t.Error("la IPv6 debe ofuscarse también con la transacción")
+	}
+	if addr, ok := decodeSTUNAddress(rfcXORv4[4:], other); !ok || addr != netip.MustParseAddr("192.0.2.1") {
+		t.Errorf("IPv4 = %s, %v", addr, ok)
+	}
+
+	// Una IPv4 mapeada informada como IPv6 se normaliza
+	mapped := append([]byte{0x00, 0x02, 0x0d, 0x96}, netip.MustParseAddr("::ffff:198.51.100.7").AsSlice()...)
+	if addr, ok := decodeSTUNAddress(mapped, nil); !ok || addr != netip.MustParseAddr("198.51.100.7") {
+		t.Errorf("mapeada = %s, %v", addr, ok)
+	}
+
+	for name, value := range map[string][]byte{
+		"vacío":               nil,
+		"sin dirección":       {0x00, 0x01, 0x0d, 0x96},
+		"familia desconocida": {0x00, 0x03, 0x0d, 0x96, 1, 2, 3, 4},
+		"IPv4 incompleta":     {0x00, 0x01, 0x0d, 0x96, 198, 51},
+	} {
+		if addr, ok := decodeSTUNAddress(value, nil); ok {
+			t.Errorf("%s: se decodificó %s", name, addr)
+		}
+	}
+}
+
+func TestSTUNLookupIgnoresOtherTransactions(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("sin UDP: %v", err)
+	}
+	defer conn.Close()
+	go func() {
+		buf := make([]byte, 1500)
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil || n != 20 || binary.BigEndian.Uint16(buf) != stunBindingRequest {
+			t.Errorf("solicitud inválida: %x, %v", buf[:n], err)
+			return
+		}
+		var txID, other [12]byte
+		copy(txID[:], buf[8:20])
+		other = txID
+		other[0] ^= 0xff
+		// Primero una respuesta atrasada de otra transacción, luego la propia con la IPv4 XOR
+		conn.WriteTo(stunResponse(stunBindingSuccess, other, mappedV4), addr)
+		conn.WriteTo(stunResponse(stunBindingSuccess, txID, rfcXORv4), addr)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	addr, err := (&STUNIPProvider{Server: conn.LocalAddr().String()}).Lookup(ctx, "tcp4")
+	if err != nil || addr != netip.MustParseAddr("192.0.2.1") {
+		t.Fatalf("Lookup = %s, %v", addr, err)
+	}
+}
+
+func txtRR(strs ...string) dnsmsg.RR {
+	var data []byte
+	for _, s := range strs {
+		data = append(data, byte(len(s)))
+		data = append(data, s...)
+	}
+	return dnsmsg.RR{Name: "o-o.myaddr.l.google.com", Type: dnsmsg.TypeTXT, Class: dnsmsg.ClassINET, Data: data}
+}
+
+func TestDNSAnswerAddr(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		resp dnsmsg.Message
+		want string
+	}{
+		{"A", dnsmsg.Message{Answers: []dnsmsg.RR{dnsmsg.AddrRR("myip.opendns.com", 0, netip.MustParseAddr("8.8.4.4"))}}, "8.8.4.4"},
+		{"AAAA", dnsmsg.Message{Answers: []dnsmsg.RR{dnsmsg.AddrRR("myip.opendns.com", 0, netip.MustParseAddr("2606:4700::1"))}}, "2606:4700::1"},
+		{"CNAME antes del A", dnsmsg.Message{Answers: []dnsmsg.RR{
+			{Type: dnsmsg.TypeCNAME, Class: dnsmsg.ClassINET, Data: []byte{3, 'f', 'o', 'o', 0}},
+			dnsmsg.AddrRR("foo", 0, netip.MustParseAddr("8.8.4.4")),
+		}}, "8.8.4.4"},
+		{"TXT de Google con la subred EDNS", dnsmsg.Message{Answers: []dnsmsg.RR{
+			txtRR("edns0-client-subnet 8.8.4.0/24"), txtRR("8.8.4.4"),
+		}}, "8.8.4.4"},
+		{"TXT con espacios", dnsmsg.Message{Answers: []dnsmsg.RR{txtRR(" 2606:4700::1 ")}}, "2606:4700::1"},
+		{"TXT mapeado", dnsmsg.Message{Answers: []dnsmsg.RR{txtRR("::ffff:8.8.4.4")}}, "8.8.4.4"},
+		{"A con largo incorrecto", dnsmsg.Message{Answers: []dnsmsg.RR{{Type: dnsmsg.TypeA, Data: []byte{8, 8, 4}}}}, "sin dirección"},
+		{"TXT truncado", dnsmsg.Message{Answers: []dnsmsg.RR{{Type: dnsmsg.TypeTXT, Data: []byte{20, '8', '.', '8'}}}}, "sin dirección"},
+		{"TXT sin IP", dnsmsg.Message{Answers: []dnsmsg.RR{txtRR("<html>error</html>")}}, "sin dirección"},
+		{"sin respuestas", dnsmsg.Message{}, "sin dirección"},
+		{"NXDOMAIN", dnsmsg.Message{Rcode: dnsmsg.RcodeNXDomain}, dnsmsg.RcodeString(dnsmsg.RcodeNXDomain)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			addr, err := dnsAnswerAddr(&tc.resp)
+			got := addr.String()
+			if err != nil {
+				got = err.Error()
+			}
+			if (err == nil && got != tc.want) || (err != nil && !strings.Contains(got, tc.want)) {
+				t.Errorf("dnsAnswerAddr = %s, se esperaba %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDNSLookupQuestion(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("sin UDP: %v", err)
+	}
+	defer conn.Close()
+	questions := make(chan dnsmsg.Question, 4)
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			q, err := dnsmsg.Unpack(buf[:n])
+			if err != nil {
+				t.Errorf("consulta inválida: %v", err)
+				return
+			}
+			questions <- q.Questions[0]
+			resp := &dnsmsg.Message{ID: q.ID, Response: true, Questions: q.Questions,
+				Answers: []dnsmsg.RR{txtRR("8.8.4.4")}}
+			if q.Questions[0].Type == dnsmsg.TypeAAAA {
+				resp.Answers = []dnsmsg.RR{dnsmsg.AddrRR(q.Questions[0].Name, 0, netip.MustParseAddr("2606:4700::1"))}
+			}
+			out, _ := resp.Pack()
+			conn.WriteTo(out, addr)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server := conn.LocalAddr().String()
+	for _, tc := range []struct {
+		method, network string
+		want            dnsmsg.Question
+		addr            string
+	}{
+		{"opendns", "tcp6", dnsmsg.Question{Name: "myip.opendns.com.", Type: dnsmsg.TypeAAAA, Class: dnsmsg.ClassINET}, "2606:4700::1"},
+		{"cloudflare", "tcp4", dnsmsg.Question{Name: "whoami.cloudflare.", Type: dnsmsg.TypeTXT, Class: dnsmsg.ClassCHAOS}, "8.8.4.4"},
+	} {
+		addr, err := (&DNSIPProvider{Method: tc.method, Server: server}).Lookup(ctx, tc.network)
+		if err != nil || addr.String() != tc.addr {
+			t.Errorf("%s: Lookup = %s, %v", tc.method, addr, err)
+			continue
+		}
+		if q := <-questions; q != tc.want {
+			t.Errorf("%s: pregunta = %+v, se esperaba %+v", tc.method, q, tc.want)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package dnscheck
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"orgmserver/dnsmsg"
+	"orgmserver/utils"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SystemResolver indica usar el resolver del sistema en lugar de consultar un servidor directo
+const SystemResolver = "system"
+
+// Host es un nombre a verificar con los tipos de registro que debe publicar
+type Host struct {
+	Name string
+	// Types lista los registros exigidos ("A", "AAAA"). Vacío verifica A y solo
+	// compara AAAA si el nombre publica registros AAAA.
+	Types []string
+}
+
+// requires indica si el nombre debe publicar el tipo de registro dado
+func (h Host) requires(rtype string) bool {
+	if len(h.Types) == 0 {
+		return rtype == "A"
+	}
+	for _, t := range h.Types {
+		if t == rtype {
+			return true
+		}
+	}
+	return false
+}
+
+// checks indica si se compara el tipo de registro dado
+func (h Host) checks(rtype string) bool {
+	return len(h.Types) == 0 || h.requires(rtype)
+}
+
+// Mismatch describe un nombre que no resuelve a la IP esperada en un resolver
+type Mismatch struct {
+	Host     string
+	Resolver string
+	Type     string // A o AAAA
+	Expected string
+	Got      []string
+}
+
+func (m Mismatch) key() string {
+	return m.Host + "|" + m.Resolver + "|" + m.Type
+}
+
+func (m Mismatch) String() string {
+	got := "sin registros"
+	if len(m.Got) > 0 {
+		got = strings.Join(m.Got, ", ")
+	}
+	return fmt.Sprintf("%s %s @%s: esperado %s, obtenido %s", m.Host, m.Type, m.Resolver, m.Expected, got)
+}
+
+// Checker resuelve periódicamente los nombres configurados y compara con la IP externa
+type Checker struct {
+	hosts     []Host
+	resolvers []string
+	grace     time.Duration
+	timeout   time.Duration
+	clock     utils.Clock
+	debug     bool
+
+	firstSeen map[string]time.Time
+	alerted   map[string]bool
+}
+
+func NewChecker(hosts []Host, resolvers []string, grace time.Duration, clock utils.Clock, debug bool) *Checker {
+	normalized := make([]string, 0, len(resolvers))
+	for _, r := range resolvers {
+		if r != SystemResolver {
+			if _, _, err := net.SplitHostPort(r); err != nil {
+				r = net.JoinHostPort(r, "53")
+			}
+		}
+		normalized = append(normalized, r)
+	}
+
+	return &Checker{
+		hosts:     hosts,
+		resolvers: normalized,
+		grace:     grace,
+		timeout:   5 * time.Second,
+		clock:     clock,
+		debug:     debug,
+		firstSeen: make(map[string]time.Time),
+		alerted:   make(map[string]bool),
+	}
+}
+
+// Enabled indica si hay nombres configurados
+func (c *Checker) Enabled() bool {
+	return c != nil && len(c.hosts) > 0
+}
+
+// Check resuelve todos los nombres y retorna las discrepancias que superaron el período
+// de gracia y aún no se notificaron. recovered es true cuando todas las discrepancias
+// notificadas desaparecieron. Si ctx se cancela a mitad de la verificación no se
+// modifica el estado.
+func (c *Checker) Check(ctx context.Context, expected []netip.Addr) (alerts []Mismatch, recovered bool) {
+	now := c.clock.Now()
+	current := make(map[string]Mismatch)
+	errored := make(map[string]bool)
+
+	for _, host := range c.hosts {
+		for _, resolver := range c.resolvers {
+			for _, want := range expected {
+				if !host.checks(typeName(want)) {
+					continue
+				}
+				if ctx.Err() != nil {
+					return nil, false
+				}
+				mismatch, err := c.checkOne(ctx, host, resolver, want)
+				if err != nil {
+					// Un error del resolver no es una discrepancia; se reintenta en el próximo ciclo
+					utils.WriteLog(fmt.Sprintf("[DNSCHECK] Error resolviendo %s en %s: %v", host.Name, resolver, err), c.debug)
+					errored[keyFor(host.Name, resolver, want)] = true
+					continue
+				}
+				if mismatch != nil {
+					current[mismatch.key()] = *mismatch
+				}
+			}
+		}
+	}
+
+	hadAlerts := len(c.alerted) > 0
+
+	// Olvidar discrepancias resueltas; las consultas con error conservan su estado
+	for key := range c.firstSeen {
+		if _, ok := current[key]; !ok && !errored[key] {
+			delete(c.firstSeen, key)
+			delete(c.alerted, key)
+		}
+	}
+
+	for key, mismatch := range current {
+		first, ok := c.firstSeen[key]
+		if !ok {
+			first = now
+			c.firstSeen[key] = now
+			utils.WriteLog(fmt.Sprintf("[DNSCHECK] Discrepancia detectada: %s", mismatch), c.debug)
+		}
+		if !c.alerted[key] && now.Sub(first) >= c.grace {
+			c.alerted[key] = true
+			alerts = append(alerts, mismatch)
+		}
+	}
+
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].key() < alerts[j].key() })
+	return alerts, hadAlerts && len(c.alerted) == 0
+}
+
+func keyFor(host, resolver string, want netip.Addr) string {
+	return host + "|" + resolver + "|" + typeName(want)
+}
+
+func typeName(addr netip.Addr) string {
+	if addr.Is4() {
+		return "A"
+	}
+	return "AAAA"
+}
+
+// checkOne retorna una discrepancia si el nombre no incluye la IP esperada
+func (c *Checker) checkOne(ctx context.Context, host Host, resolver string, want netip.Addr) (*Mismatch, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	addrs, err := c.resolve(ctx, host.Name, resolver, want.Is4())
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 && !host.requires(typeName(want)) {
+		// El nombre no publica este tipo de registro y no se exige
+		return nil, nil
+	}
+
+	var got []string
+	for _, addr := range addrs {
+		if addr == want {
+			return nil, nil
+		}
+		got = append(got, addr.String())
+	}
+
+	return &Mismatch{
+		Host:     host.Name,
+		Resolver: resolver,
+		Type:     typeName(want),
+		Expected: want.String(),
+		Got:      got,
+	}, nil
+}
+
+// resolve consulta registros A o AAAA; NXDOMAIN se trata como lista vacía
+func (c *Checker) resolve(ctx context.Context, host, resolver string, ipv4 bool) ([]netip.Addr, error) {
+	if resolver == SystemResolver {
+		network := "ip6"
+		if ipv4 {
+			network = "ip4"
+		}
+		addrs, err := net.DefaultResolver.LookupNetIP(ctx, network, host)
+		if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+			return nil, nil
+		}
+		for i := range addrs {
+			addrs[i] = addrs[i].Unmap()
+		}
+		return addrs, err
+	}
+
+	qtype := dnsmsg.TypeAAAA
+	if ipv4 {
+		qtype = dnsmsg.TypeA
+	}
+	resp, err := dnsmsg.Exchange(ctx, resolver, dnsmsg.NewQuery(dnsmsg.NewID(), host, qtype, dnsmsg.ClassINET))
+	if err != nil {
+		return nil, err
+	}
+	if resp.Rcode == dnsmsg.RcodeNXDomain {
+		return nil, nil
+	}
+	if resp.Rcode != dnsmsg.RcodeSuccess {
+		return nil, fmt.Errorf("resolver respondió %s", dnsmsg.RcodeString(resp.Rcode))
+	}
+
+	var addrs []netip.Addr
+	for _, rr := range resp.Answers {
+		if rr.Type != qtype {
+			continue
+		}
+		if addr, ok := rr.Addr(); ok {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs, nil
+}
@@ -0,0 +1,204 @@
+package dnscheck
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"orgmserver/dnsmsg"
+	"orgmserver/utils/clocktest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeResolver responde consultas A/AAAA por UDP a partir de un mapa nombre → direcciones
+type fakeResolver struct {
+	conn net.PacketConn
+
+	mu       sync.Mutex
+	records  map[string][]string // "nombre|A" o "nombre|AAAA"
+	servfail bool
+}
+
+func newFakeResolver(t *testing.T) *fakeResolver {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("sin UDP: %v", err)
+	}
+	r := &fakeResolver{conn: conn, records: map[string][]string{}}
+	t.Cleanup(func() { conn.Close() })
+	go r.serve()
+	return r
+}
+
+func (r *fakeResolver) set(key string, addrs ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records[key] = addrs
+}
+
+func (r *fakeResolver) setServfail(v bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.servfail = v
+}
+
+func (r *fakeResolver) serve() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := r.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		q, err := dnsmsg.Unpack(buf[:n])
+		if err != nil || len(q.Questions) != 1 {
+			continue
+		}
+		question := q.Questions[0]
+		resp := &dnsmsg.Message{ID: q.ID, Response: true, Questions: q.Questions}
+
+		rtype := "A"
+		if question.Type == dnsmsg.TypeAAAA {
+			rtype = "AAAA"
+		}
+		r.mu.Lock()
+		if r.servfail {
+			resp.Rcode = 2
+		}
+		for _, a := range r.records[question.Name+"|"+rtype] {
+			resp.Answers = append(resp.Answers, dnsmsg.AddrRR(question.Name, 60, netip.MustParseAddr(a)))
+		}
+		r.mu.Unlock()
+
+		out, _ := resp.Pack()
+		r.conn.WriteTo(out, addr)
+	}
+}
+
+func (r *fakeResolver) addr() string {
+	return r.conn.LocalAddr().String()
+}
+
+var (
+	ipv4 = netip.MustParseAddr("203.0.113.7")
+	ipv6 = netip.MustParseAddr("2001:db8::7")
+)
+
+func newTestChecker(t *testing.T, hosts ...Host) (*Checker, *fakeResolver, *clocktest.Clock) {
+	t.Helper()
+	resolver := newFakeResolver(t)
+	clock := clocktest.New(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	return NewChecker(hosts, []string{resolver.addr()}, 15*time.Minute, clock, false), resolver, clock
+}
+
+func TestCheckGraceAndRecovery(t *testing.T) {
+	c, resolver, clock := newTestChecker(t, Host{Name: "www.example.com"})
+	resolver.set("www.example.com.|A", "198.51.100.1")
+
+	// La discrepancia se notifica solo después del período de gracia, una sola vez
+	if alerts, _ := c.Check(context.Background(), []netip.Addr{ipv4}); len(alerts) != 0 {
+		t.Fatalf("alerta antes del período de gracia: %v", alerts)
+	}
+	clock.Advance(14 * time.Minute)
+	if alerts, _ := c.Check(context.Background(), []netip.Addr{ipv4}); len(alerts) != 0 {
+		t.Fatalf("alerta antes del período de gracia: %v", alerts)
+	}
+	clock.Advance(time.Minute)
+	alerts, _ := c.Check(context.Background(), []netip.Addr{ipv4})
+	if len(alerts) != 1 || alerts[0].Type != "A" || alerts[0].Got[0] != "198.51.100.1" {
+		t.Fatalf("alertas = %v", alerts)
+	}
+	clock.Advance(time.Hour)
+	if alerts, _ := c.Check(context.Background(), []netip.Addr{ipv4}); len(alerts) != 0 {
+		t.Fatalf("alerta repetida: %v", alerts)
+	}
+
+	// Un error del resolver no cuenta como recuperación
+	resolver.setServfail(true)
+	if _, recovered := c.Check(context.Background(), []netip.Addr{ipv4}); recovered {
+		t.Fatal("SERVFAIL no debe contar como recuperación")
+	}
+	resolver.setServfail(false)
+
+	resolver.set("www.example.com.|A", "198.51.100.1", "203.0.113.7")
+	if _, recovered := c.Check(context.Background(), []netip.Addr{ipv4}); !recovered {
+		t.Fatal("se esperaba recuperación cuando el nombre incluye la IP")
+	}
+}
+
+func TestCheckCanceledKeepsState(t *testing.T) {
+	c, resolver, clock := newTestChecker(t, Host{Name: "www.example.com"})
+	resolver.set("www.example.com.|A", "198.51.100.1")
+
+	c.Check(context.Background(), []netip.Addr{ipv4})
+	clock.Advance(15 * time.Minute)
+	if alerts, _ := c.Check(context.Background(), []netip.Addr{ipv4}); len(alerts) != 1 {
+		t.Fatalf("alertas = %v", alerts)
+	}
+
+	// Una verificación cancelada no cuenta como recuperación ni borra lo notificado
+	resolver.set("www.example.com.|A", "203.0.113.7")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if alerts, recovered := c.Check(ctx, []netip.Addr{ipv4}); len(alerts) != 0 || recovered {
+		t.Fatalf("verificación cancelada: alertas = %v, recuperado = %v", alerts, recovered)
+	}
+	if _, recovered := c.Check(context.Background(), []netip.Addr{ipv4}); !recovered {
+		t.Fatal("se esperaba recuperación tras la verificación cancelada")
+	}
+}
+
+func TestCheckAAAAOnlyWhenPublished(t *testing.T) {
+	c, resolver, clock := newTestChecker(t,
+		Host{Name: "solo4.example.com"},
+		Host{Name: "dual.example.com"},
+	)
+	resolver.set("solo4.example.com.|A", "203.0.113.7")
+	resolver.set("dual.example.com.|A", "203.0.113.7")
+	resolver.set("dual.example.com.|AAAA", "2001:db8::1")
+
+	c.Check(context.Background(), []netip.Addr{ipv4, ipv6})
+	clock.Advance(time.Hour)
+	alerts, _ := c.Check(context.Background(), []netip.Addr{ipv4, ipv6})
+
+	// solo4 no publica AAAA y no se exige; dual publica un AAAA desactualizado
+	if len(alerts) != 1 || alerts[0].Host != "dual.example.com" || alerts[0].Type != "AAAA" {
+		t.Fatalf("alertas = %v", alerts)
+	}
+}
+
+func TestCheckExplicitTypes(t *testing.T) {
+	c, resolver, clock := newTestChecker(t,
+		Host{Name: "v6.example.com", Types: []string{"AAAA"}},
+		Host{Name: "v4.example.com", Types: []string{"A"}},
+	)
+	// v6 exige AAAA aunque no tenga registros y no compara A; v4 ignora IPv6
+	resolver.set("v6.example.com.|A", "198.51.100.1")
+	resolver.set("v4.example.com.|A", "203.0.113.7")
+	resolver.set("v4.example.com.|AAAA", "2001:db8::1")
+
+	c.Check(context.Background(), []netip.Addr{ipv4, ipv6})
+	clock.Advance(time.Hour)
+	alerts, _ := c.Check(context.Background(), []netip.Addr{ipv4, ipv6})
+
+	if len(alerts) != 1 || alerts[0].Host != "v6.example.com" || alerts[0].Type != "AAAA" || len(alerts[0].Got) != 0 {
+		t.Fatalf("alertas = %v", alerts)
+	}
+	if got := alerts[0].String(); got != "v6.example.com AAAA @"+resolver.addr()+": esperado 2001:db8::7, obtenido sin registros" {
+		t.Errorf("String = %q", got)
+	}
+}
+
+func TestNewCheckerDefaultPort(t *testing.T) {
+	c := NewChecker(nil, []string{"1.1.1.1", "[2606:4700::1111]:5353", SystemResolver}, 0, clocktest.New(time.Time{}), false)
+	want := []string{"1.1.1.1:53", "[2606:4700::1111]:5353", SystemResolver}
+	for i, r := range c.resolvers {
+		if r != want[i] {
+			t.Errorf("resolver %d = %s, esperado %s", i, r, want[i])
+		}
+	}
+	if c.Enabled() {
+		t.Error("sin nombres no debería estar habilitado")
+	}
+}
@@ -0,0 +1,121 @@
+package dnsmsg
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+const defaultTimeout = 5 * time.Second
+
+// Exchange envía el mensaje al servidor (host:puerto) por UDP y repite por TCP
+// si la respuesta llega truncada
+func Exchange(ctx context.Context, server string, msg *Message) (*Message, error) {
+	wire, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+	return ExchangeWire(ctx, server, wire, msg.ID)
+}
+
+// ExchangeWire envía un mensaje ya serializado (por ejemplo, firmado con TSIG)
+func ExchangeWire(ctx context.Context, server string, wire []byte, id uint16) (*Message, error) {
+	resp, err := exchangeUDP(ctx, server, wire, id)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Truncated {
+		return exchangeTCP(ctx, server, wire, id)
+	}
+	return resp, nil
+}
+
+// NewID genera un identificador de mensaje aleatorio
+func NewID() uint16 {
+	var b [2]byte
+	rand.Read(b[:])
+	return binary.BigEndian.Uint16(b[:])
+}
+
+func deadline(ctx context.Context) time.Time {
+	if d, ok := ctx.Deadline(); ok {
+		return d
+	}
+	return time.Now().Add(defaultTimeout)
+}
+
+// interrupted reemplaza el error de E/S por el de ctx si la consulta se canceló
+func interrupted(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func exchangeUDP(ctx context.Context, server string, wire []byte, id uint16) (*Message, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(deadline(ctx))
+	// Cancelar ctx cierra la conexión e interrumpe la lectura en curso
+	defer context.AfterFunc(ctx, func() { conn.Close() })()
+
+	if _, err := conn.Write(wire); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, interrupted(ctx, err)
+		}
+		resp, err := Unpack(buf[:n])
+		if err != nil || resp.ID != id || !resp.Response {
+			// Ignorar respuestas que no corresponden a la consulta
+			continue
+		}
+		return resp, nil
+	}
+}
+
+func exchangeTCP(ctx context.Context, server string, wire []byte, id uint16) (*Message, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(deadline(ctx))
+	// Cancelar ctx cierra la conexión e interrumpe la lectura en curso
+	defer context.AfterFunc(ctx, func() { conn.Close() })()
+
+	framed := binary.BigEndian.AppendUint16(nil, uint16(len(wire)))
+	if _, err := conn.Write(append(framed, wire...)); err != nil {
+		return nil, err
+	}
+
+	var lenBuf [2]byte
+	if _, err := io.ReadFull(conn, lenBuf[:]); err != nil {
+		return nil, interrupted(ctx, err)
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(lenBuf[:]))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, interrupted(ctx, err)
+	}
+	resp, err := Unpack(buf)
+	if err != nil {
+		return nil, err
+	}
+	if resp.ID != id {
+		return nil, fmt.Errorf("ID de respuesta no coincide: %d != %d", resp.ID, id)
+	}
+	return resp, nil
+}
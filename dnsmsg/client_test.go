@@ -0,0 +1,123 @@
+package dnsmsg
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+// answer responde la consulta con un registro A fijo
+func answer(t *testing.T, wire []byte, truncated bool) []byte {
+	t.Helper()
+	q, err := Unpack(wire)
+	if err != nil {
+		t.Errorf("consulta inválida: %v", err)
+		return nil
+	}
+	resp := &Message{ID: q.ID, Response: true, Truncated: truncated, Questions: q.Questions}
+	if !truncated {
+		resp.Answers = []RR{AddrRR(q.Questions[0].Name, 60, netip.MustParseAddr("192.0.2.1"))}
+	}
+	out, err := resp.Pack()
+	if err != nil {
+		t.Error(err)
+	}
+	return out
+}
+
+func TestExchangeFallsBackToTCP(t *testing.T) {
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("sin UDP: %v", err)
+	}
+	defer udp.Close()
+	tcp, err := net.Listen("tcp", udp.LocalAddr().String())
+	if err != nil {
+		t.Skipf("puerto TCP ocupado: %v", err)
+	}
+	defer tcp.Close()
+
+	go func() {
+		buf := make([]byte, 512)
+		n, addr, err := udp.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		// Primero una respuesta con otro ID, que debe ignorarse
+		other := answer(t, buf[:n], true)
+		binary.BigEndian.PutUint16(other, binary.BigEndian.Uint16(other)+1)
+		udp.WriteTo(other, addr)
+		udp.WriteTo(answer(t, buf[:n], true), addr)
+	}()
+	go func() {
+		conn, err := tcp.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var lenBuf [2]byte
+		if _, err := io.ReadFull(conn, lenBuf[:]); err != nil {
+			return
+		}
+		buf := make([]byte, binary.BigEndian.Uint16(lenBuf[:]))
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return
+		}
+		out := answer(t, buf, false)
+		conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(out))), out...))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := Exchange(ctx, udp.LocalAddr().String(), NewQuery(NewID(), "example.com.", TypeA, ClassINET))
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if resp.Truncated || len(resp.Answers) != 1 {
+		t.Fatalf("se esperaba la respuesta completa por TCP: %+v", resp)
+	}
+}
+
+func TestExchangeCanceled(t *testing.T) {
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("sin UDP: %v", err)
+	}
+	defer udp.Close()
+
+	// El servidor nunca responde; el plazo del contexto corta la espera
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := Exchange(ctx, udp.LocalAddr().String(), NewQuery(1, "example.com.", TypeA, ClassINET)); err == nil {
+		t.Fatal("se esperaba error por timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Exchange tardó %v pese al plazo del contexto", elapsed)
+	}
+}
+
+func TestExchangeCancelInterruptsRead(t *testing.T) {
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("sin UDP: %v", err)
+	}
+	defer udp.Close()
+
+	// Sin plazo en el contexto la espera es de 5s; cancelar debe cortarla antes
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err = Exchange(ctx, udp.LocalAddr().String(), NewQuery(1, "example.com.", TypeA, ClassINET))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, esperado context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Exchange tardó %v pese a la cancelación", elapsed)
+	}
+}
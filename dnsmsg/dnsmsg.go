@@ -0,0 +1,338 @@
+// Package dnsmsg implementa el formato de mensajes DNS (RFC 1035) necesario
+// para consultas directas a servidores, actualizaciones dinámicas (RFC 2136)
+// y firmas TSIG (RFC 8945), sin dependencias externas.
+package dnsmsg
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// Tipos de registro
+const (
+	TypeA     uint16 = 1
+	TypeCNAME uint16 = 5
+	TypeSOA   uint16 = 6
+	TypeTXT   uint16 = 16
+	TypeAAAA  uint16 = 28
+	TypeTSIG  uint16 = 250
+	TypeANY   uint16 = 255
+)
+
+// Clases
+const (
+	ClassINET  uint16 = 1
+	ClassCHAOS uint16 = 3
+	ClassNONE  uint16 = 254
+	ClassANY   uint16 = 255
+)
+
+// Opcodes
+const (
+	OpcodeQuery  = 0
+	OpcodeUpdate = 5
+)
+
+// Códigos de respuesta
+const (
+	RcodeSuccess  = 0
+	RcodeNXDomain = 3
+	RcodeRefused  = 5
+	RcodeNotAuth  = 9
+)
+
+var rcodeNames = map[int]string{
+	0:  "NOERROR",
+	1:  "FORMERR",
+	2:  "SERVFAIL",
+	3:  "NXDOMAIN",
+	4:  "NOTIMP",
+	5:  "REFUSED",
+	6:  "YXDOMAIN",
+	7:  "YXRRSET",
+	8:  "NXRRSET",
+	9:  "NOTAUTH",
+	10: "NOTZONE",
+}
+
+// RcodeString retorna el nombre del código de respuesta
+func RcodeString(rcode int) string {
+	if name, ok := rcodeNames[rcode]; ok {
+		return name
+	}
+	return fmt.Sprintf("RCODE%d", rcode)
+}
+
+var errShort = errors.New("mensaje DNS truncado")
+
+// Question es una entrada de la sección de preguntas (o zona en un UPDATE)
+type Question struct {
+	Name  string
+	Type  uint16
+	Class uint16
+}
+
+// RR es un registro de recurso con sus datos sin interpretar
+type RR struct {
+	Name  string
+	Type  uint16
+	Class uint16
+	TTL   uint32
+	Data  []byte
+}
+
+// Message es un mensaje DNS
+type Message struct {
+	ID                 uint16
+	Response           bool
+	Opcode             int
+	Authoritative      bool
+	Truncated          bool
+	RecursionDesired   bool
+	RecursionAvailable bool
+	Rcode              int
+	Questions          []Question
+	Answers            []RR
+	Authority          []RR
+	Additional         []RR
+}
+
+// NewQuery crea una consulta recursiva para name/type/class
+func NewQuery(id uint16, name string, qtype, qclass uint16) *Message {
+	return &Message{
+		ID:               id,
+		RecursionDesired: true,
+		Questions:        []Question{{Name: name, Type: qtype, Class: qclass}},
+	}
+}
+
+// AddrRR crea un registro A o AAAA según la familia de la dirección
+func AddrRR(name string, ttl uint32, addr netip.Addr) RR {
+	rr := RR{Name: name, Class: ClassINET, TTL: ttl}
+	if addr.Is4() {
+		rr.Type = TypeA
+		b := addr.As4()
+		rr.Data = b[:]
+	} else {
+		rr.Type = TypeAAAA
+		b := addr.As16()
+		rr.Data = b[:]
+	}
+	return rr
+}
+
+// Addr interpreta los datos de un registro A o AAAA
+func (rr RR) Addr() (netip.Addr, bool) {
+	switch {
+	case rr.Type == TypeA && len(rr.Data) == 4:
+		return netip.AddrFrom4([4]byte(rr.Data)), true
+	case rr.Type == TypeAAAA && len(rr.Data) == 16:
+		return netip.AddrFrom16([16]byte(rr.Data)), true
+	}
+	return netip.Addr{}, false
+}
+
+// TXT interpreta los datos de un registro TXT como lista de cadenas
+func (rr RR) TXT() []string {
+	var out []string
+	data := rr.Data
+	for len(data) > 0 {
+		n := int(data[0])
+		if 1+n > len(data) {
+			break
+		}
+		out = append(out, string(data[1:1+n]))
+		data = data[1+n:]
+	}
+	return out
+}
+
+// Pack serializa el mensaje en formato wire (sin compresión de nombres)
+func (m *Message) Pack() ([]byte, error) {
+	b := make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(b[0:], m.ID)
+
+	var flags uint16
+	if m.Response {
+		flags |= 1 << 15
+	}
+	flags |= uint16(m.Opcode&0xF) << 11
+	if m.Authoritative {
+		flags |= 1 << 10
+	}
+	if m.Truncated {
+		flags |= 1 << 9
+	}
+	if m.RecursionDesired {
+		flags |= 1 << 8
+	}
+	if m.RecursionAvailable {
+		flags |= 1 << 7
+	}
+	flags |= uint16(m.Rcode & 0xF)
+	binary.BigEndian.PutUint16(b[2:], flags)
+	binary.BigEndian.PutUint16(b[4:], uint16(len(m.Questions)))
+	binary.BigEndian.PutUint16(b[6:], uint16(len(m.Answers)))
+	binary.BigEndian.PutUint16(b[8:], uint16(len(m.Authority)))
+	binary.BigEndian.PutUint16(b[10:], uint16(len(m.Additional)))
+
+	var err error
+	for _, q := range m.Questions {
+		if b, err = appendName(b, q.Name); err != nil {
+			return nil, err
+		}
+		b = binary.BigEndian.AppendUint16(b, q.Type)
+		b = binary.BigEndian.AppendUint16(b, q.Class)
+	}
+	for _, section := range [][]RR{m.Answers, m.Authority, m.Additional} {
+		for _, rr := range section {
+			if b, err = appendRR(b, rr); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return b, nil
+}
+
+func appendRR(b []byte, rr RR) ([]byte, error) {
+	b, err := appendName(b, rr.Name)
+	if err != nil {
+		return nil, err
+	}
+	b = binary.BigEndian.AppendUint16(b, rr.Type)
+	b = binary.BigEndian.AppendUint16(b, rr.Class)
+	b = binary.BigEndian.AppendUint32(b, rr.TTL)
+	if len(rr.Data) > 0xFFFF {
+		return nil, fmt.Errorf("datos de registro demasiado largos")
+	}
+	b = binary.BigEndian.AppendUint16(b, uint16(len(rr.Data)))
+	return append(b, rr.Data...), nil
+}
+
+// appendName codifica un nombre de dominio como secuencia de etiquetas
+func appendName(b []byte, name string) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			if label == "" || len(label) > 63 {
+				return nil, fmt.Errorf("etiqueta inválida en nombre %q", name)
+			}
+			b = append(b, byte(len(label)))
+			b = append(b, label...)
+		}
+	}
+	return append(b, 0), nil
+}
+
+// Unpack interpreta un mensaje en formato wire
+func Unpack(b []byte) (*Message, error) {
+	if len(b) < 12 {
+		return nil, errShort
+	}
+	m := &Message{ID: binary.BigEndian.Uint16(b[0:])}
+	flags := binary.BigEndian.Uint16(b[2:])
+	m.Response = flags&(1<<15) != 0
+	m.Opcode = int(flags>>11) & 0xF
+	m.Authoritative = flags&(1<<10) != 0
+	m.Truncated = flags&(1<<9) != 0
+	m.RecursionDesired = flags&(1<<8) != 0
+	m.RecursionAvailable = flags&(1<<7) != 0
+	m.Rcode = int(flags & 0xF)
+
+	counts := [4]int{
+		int(binary.BigEndian.Uint16(b[4:])),
+		int(binary.BigEndian.Uint16(b[6:])),
+		int(binary.BigEndian.Uint16(b[8:])),
+		int(binary.BigEndian.Uint16(b[10:])),
+	}
+
+	off := 12
+	for i := 0; i < counts[0]; i++ {
+		name, n, err := readName(b, off)
+		if err != nil {
+			return nil, err
+		}
+		off = n
+		if off+4 > len(b) {
+			return nil, errShort
+		}
+		m.Questions = append(m.Questions, Question{
+			Name:  name,
+			Type:  binary.BigEndian.Uint16(b[off:]),
+			Class: binary.BigEndian.Uint16(b[off+2:]),
+		})
+		off += 4
+	}
+
+	sections := []*[]RR{&m.Answers, &m.Authority, &m.Additional}
+	for s, section := range sections {
+		for i := 0; i < counts[s+1]; i++ {
+			rr, n, err := readRR(b, off)
+			if err != nil {
+				return nil, err
+			}
+			off = n
+			*section = append(*section, rr)
+		}
+	}
+	return m, nil
+}
+
+func readRR(b []byte, off int) (RR, int, error) {
+	name, off, err := readName(b, off)
+	if err != nil {
+		return RR{}, 0, err
+	}
+	if off+10 > len(b) {
+		return RR{}, 0, errShort
+	}
+	rr := RR{
+		Name:  name,
+		Type:  binary.BigEndian.Uint16(b[off:]),
+		Class: binary.BigEndian.Uint16(b[off+2:]),
+		TTL:   binary.BigEndian.Uint32(b[off+4:]),
+	}
+	length := int(binary.BigEndian.Uint16(b[off+8:]))
+	off += 10
+	if off+length > len(b) {
+		return RR{}, 0, errShort
+	}
+	rr.Data = append([]byte(nil), b[off:off+length]...)
+	return rr, off + length, nil
+}
+
+// readName decodifica un nombre, siguiendo punteros de compresión
+func readName(b []byte, off int) (string, int, error) {
+	var labels []string
+	end := -1
+	for hops := 0; ; hops++ {
+		if off >= len(b) || hops > 127 {
+			return "", 0, errShort
+		}
+		n := int(b[off])
+		switch {
+		case n == 0:
+			if end < 0 {
+				end = off + 1
+			}
+			return strings.Join(labels, ".") + ".", end, nil
+		case n&0xC0 == 0xC0:
+			if off+1 >= len(b) {
+				return "", 0, errShort
+			}
+			if end < 0 {
+				end = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(b[off:]) & 0x3FFF)
+		default:
+			if off+1+n > len(b) {
+				return "", 0, errShort
+			}
+			labels = append(labels, string(b[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package dnsmsg
+
+import (
+	"encoding/hex"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPackUnpackRoundTrip(t *testing.T) {
+	msg := &Message{
+		ID:                 0xBEEF,
+		Response:           true,
+		Opcode:             OpcodeUpdate,
+		Authoritative:      true,
+		RecursionDesired:   true,
+		RecursionAvailable: true,
+		Rcode:              RcodeNotAuth,
+		Questions:          []Question{{Name: "example.com.", Type: TypeSOA, Class: ClassINET}},
+		Answers: []RR{
+			AddrRR("www.example.com.", 300, netip.MustParseAddr("203.0.113.7")),
+			AddrRR("www.example.com.", 300, netip.MustParseAddr("2001:db8::7")),
+		},
+		Authority:  []RR{{Name: "www.example.com.", Type: TypeA, Class: ClassANY}},
+		Additional: []RR{{Name: "txt.example.com.", Type: TypeTXT, Class: ClassINET, TTL: 60, Data: []byte("\x03uno\x03dos")}},
+	}
+
+	wire, err := msg.Pack()
+	if err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+	got, err := Unpack(wire)
+	if err != nil {
+		t.Fatalf("Unpack: %v", err)
+	}
+	if !reflect.DeepEqual(got, msg) {
+		t.Fatalf("round-trip distinto:\n got  %+v\n want %+v", got, msg)
+	}
+
+	// Cualquier prefijo del mensaje debe rechazarse sin entrar en pánico
+	for i := 0; i < len(wire); i++ {
+		if _, err := Unpack(wire[:i]); err == nil {
+			t.Fatalf("Unpack aceptó un mensaje truncado a %d bytes", i)
+		}
+	}
+}
+
+func TestPackHeader(t *testing.T) {
+	wire, err := NewQuery(0x1234, "example.com", TypeTXT, ClassCHAOS).Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "1234" + "0100" + "0001000000000000" + "076578616d706c6503636f6d00" + "0010" + "0003"
+	if got := hex.EncodeToString(wire); got != want {
+		t.Fatalf("wire = %s, esperado %s", got, want)
+	}
+}
+
+func TestUnpackCompressedNames(t *testing.T) {
+	// Respuesta a "www.example.com. A" con el nombre de la respuesta comprimido (puntero a offset 12)
+	raw := "abcd" + "8180" + "0001000100000000" +
+		"03777777076578616d706c6503636f6d00" + "0001" + "0001" +
+		"c00c" + "0001" + "0001" + "0000012c" + "0004" + "cb007107"
+	wire, err := hex.DecodeString(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := Unpack(wire)
+	if err != nil {
+		t.Fatalf("Unpack: %v", err)
+	}
+	if !msg.Response || !msg.RecursionAvailable || msg.ID != 0xABCD {
+		t.Errorf("cabecera inesperada: %+v", msg)
+	}
+	if len(msg.Answers) != 1 || msg.Answers[0].Name != "www.example.com." || msg.Answers[0].TTL != 300 {
+		t.Fatalf("respuestas: %+v", msg.Answers)
+	}
+	if addr, ok := msg.Answers[0].Addr(); !ok || addr != netip.MustParseAddr("203.0.113.7") {
+		t.Errorf("Addr = %v, %v", addr, ok)
+	}
+}
+
+func TestUnpackPointerLoop(t *testing.T) {
+	// Pregunta cuyo nombre apunta a sí mismo
+	wire, _ := hex.DecodeString("0000" + "0000" + "0001000000000000" + "c00c" + "00010001")
+	if _, err := Unpack(wire); err == nil {
+		t.Fatal("se esperaba error con puntero circular")
+	}
+}
+
+func TestRRData(t *testing.T) {
+	txt := RR{Type: TypeTXT, Data: []byte("\x05hola \x05mundo\x09")}
+	if got := txt.TXT(); !reflect.DeepEqual(got, []string{"hola ", "mundo"}) {
+		t.Errorf("TXT = %q", got)
+	}
+	if _, ok := (RR{Type: TypeA, Data: []byte{1, 2, 3}}).Addr(); ok {
+		t.Error("Addr aceptó un registro A de 3 bytes")
+	}
+	if _, ok := (RR{Type: TypeTXT, Data: make([]byte, 4)}).Addr(); ok {
+		t.Error("Addr aceptó un registro TXT")
+	}
+}
+
+func TestPackInvalidName(t *testing.T) {
+	for _, name := range []string{"a..example.com", strings.Repeat("x", 64) + ".com"} {
+		if _, err := NewQuery(1, name, TypeA, ClassINET).Pack(); err == nil {
+			t.Errorf("Pack aceptó el nombre %q", name)
+		}
+	}
+}
+
+func TestRcodeString(t *testing.T) {
+	if got := RcodeString(RcodeRefused); got != "REFUSED" {
+		t.Errorf("RcodeString(5) = %s", got)
+	}
+	if got := RcodeString(23); got != "RCODE23" {
+		t.Errorf("RcodeString(23) = %s", got)
+	}
+}
@@ -0,0 +1,94 @@
+package dnsmsg
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"strings"
+	"time"
+)
+
+// tsigFudge es el margen de reloj permitido por el servidor, en segundos
+const tsigFudge = 300
+
+// TSIGKey es una clave compartida para firmar mensajes (RFC 8945)
+type TSIGKey struct {
+	Name      string // nombre de la clave, por ejemplo "ddns-key."
+	Algorithm string // hmac-sha256, hmac-sha512 o hmac-sha1
+	Secret    []byte
+}
+
+func (k TSIGKey) hash() (func() hash.Hash, string, error) {
+	switch strings.TrimSuffix(strings.ToLower(k.Algorithm), ".") {
+	case "", "hmac-sha256":
+		return sha256.New, "hmac-sha256.", nil
+	case "hmac-sha512":
+		return sha512.New, "hmac-sha512.", nil
+	case "hmac-sha1":
+		return sha1.New, "hmac-sha1.", nil
+	}
+	return nil, "", fmt.Errorf("algoritmo TSIG no soportado: %s", k.Algorithm)
+}
+
+// SignTSIG serializa el mensaje y le agrega un registro TSIG en la sección adicional
+func SignTSIG(m *Message, key TSIGKey, now time.Time) ([]byte, error) {
+	newHash, algorithm, err := key.hash()
+	if err != nil {
+		return nil, err
+	}
+
+	wire, err := m.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	keyName, err := appendName(nil, strings.ToLower(key.Name))
+	if err != nil {
+		return nil, err
+	}
+	algName, _ := appendName(nil, algorithm)
+	signed := uint64(now.Unix())
+
+	// Variables TSIG incluidas en el cálculo del MAC (RFC 8945, sección 4.3.3)
+	mac := hmac.New(newHash, key.Secret)
+	mac.Write(wire)
+	mac.Write(keyName)
+	mac.Write(binary.BigEndian.AppendUint16(nil, ClassANY))
+	mac.Write(binary.BigEndian.AppendUint32(nil, 0))
+	mac.Write(algName)
+	mac.Write(appendUint48(nil, signed))
+	mac.Write(binary.BigEndian.AppendUint16(nil, tsigFudge))
+	mac.Write(binary.BigEndian.AppendUint16(nil, 0)) // error
+	mac.Write(binary.BigEndian.AppendUint16(nil, 0)) // other len
+	sum := mac.Sum(nil)
+
+	rdata := append([]byte(nil), algName...)
+	rdata = appendUint48(rdata, signed)
+	rdata = binary.BigEndian.AppendUint16(rdata, tsigFudge)
+	rdata = binary.BigEndian.AppendUint16(rdata, uint16(len(sum)))
+	rdata = append(rdata, sum...)
+	rdata = binary.BigEndian.AppendUint16(rdata, m.ID)
+	rdata = binary.BigEndian.AppendUint16(rdata, 0) // error
+	rdata = binary.BigEndian.AppendUint16(rdata, 0) // other len
+
+	wire, err = appendRR(wire, RR{
+		Name:  strings.ToLower(key.Name),
+		Type:  TypeTSIG,
+		Class: ClassANY,
+		TTL:   0,
+		Data:  rdata,
+	})
+	if err != nil {
+		return nil, err
+	}
+	binary.BigEndian.PutUint16(wire[10:], uint16(len(m.Additional)+1))
+	return wire, nil
+}
+
+func appendUint48(b []byte, v uint64) []byte {
+	return append(b, byte(v>>40), byte(v>>32), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
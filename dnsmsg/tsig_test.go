@@ -0,0 +1,75 @@
+package dnsmsg
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestSignTSIGVector(t *testing.T) {
+	secret, _ := base64.StdEncoding.DecodeString("c2VjcmV0LWtleS1mb3ItdGVzdHM=")
+	key := TSIGKey{Name: "ddns-key.", Algorithm: "hmac-sha256", Secret: secret}
+
+	rr := AddrRR("www.example.com.", 300, netip.MustParseAddr("203.0.113.7"))
+	msg := &Message{
+		ID:        0x1234,
+		Opcode:    OpcodeUpdate,
+		Questions: []Question{{Name: "example.com.", Type: TypeSOA, Class: ClassINET}},
+		Authority: []RR{
+			{Name: "www.example.com.", Type: TypeA, Class: ClassANY},
+			rr,
+		},
+	}
+
+	wire, err := SignTSIG(msg, key, time.Unix(1700000000, 0))
+	if err != nil {
+		t.Fatalf("SignTSIG: %v", err)
+	}
+
+	// Vector calculado con una implementación independiente del formato de RFC 8945, sección 4.3.3
+	const want = "123428000001000000020001" +
+		"076578616d706c6503636f6d0000060001" +
+		"03777777076578616d706c6503636f6d00000100ff" + "00000000" + "0000" +
+		"03777777076578616d706c6503636f6d00000100010000012c0004cb007107" +
+		"0864646e732d6b65790000fa00ff00000000003d" +
+		"0b686d61632d73686132353600" + "00006553f100" + "012c" + "0020" +
+		"667bdae80fd1275e54591364d5ecddb1b7f9c65425047294e398f0d4a566a618" +
+		"1234" + "0000" + "0000"
+	if got := hex.EncodeToString(wire); got != want {
+		t.Fatalf("wire firmado:\n got  %s\n want %s", got, want)
+	}
+
+	signed, err := Unpack(wire)
+	if err != nil {
+		t.Fatalf("Unpack: %v", err)
+	}
+	if len(signed.Additional) != 1 || signed.Additional[0].Type != TypeTSIG {
+		t.Fatalf("sección adicional: %+v", signed.Additional)
+	}
+}
+
+func TestSignTSIGAlgorithms(t *testing.T) {
+	msg := NewQuery(1, "example.com.", TypeSOA, ClassINET)
+	for alg, macLen := range map[string]int{"": 32, "hmac-sha1": 20, "HMAC-SHA512.": 64} {
+		wire, err := SignTSIG(msg, TSIGKey{Name: "k.", Algorithm: alg, Secret: []byte("x")}, time.Unix(0, 0))
+		if err != nil {
+			t.Fatalf("%q: %v", alg, err)
+		}
+		signed, err := Unpack(wire)
+		if err != nil {
+			t.Fatalf("%q: %v", alg, err)
+		}
+		data := signed.Additional[0].Data
+		// Nombre del algoritmo, 6 bytes de tiempo y 2 de fudge preceden al largo del MAC
+		algLen := int(data[0]) + 2
+		if got := int(data[algLen+8])<<8 | int(data[algLen+9]); got != macLen {
+			t.Errorf("%q: largo del MAC = %d, esperado %d", alg, got, macLen)
+		}
+	}
+
+	if _, err := SignTSIG(msg, TSIGKey{Name: "k.", Algorithm: "hmac-md5", Secret: []byte("x")}, time.Now()); err == nil {
+		t.Error("se esperaba error con hmac-md5")
+	}
+}
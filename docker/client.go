@@ -0,0 +1,123 @@
+// Package docker vigila contenedores a través de la API del daemon de Docker
+// (HTTP sobre el socket unix), sin depender del cliente oficial.
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const DefaultSocket = "/var/run/docker.sock"
+
+// Client habla con el daemon por el socket unix
+type Client struct {
+	socket string
+	http   *http.Client
+}
+
+func NewClient(socket string) *Client {
+	if socket == "" {
+		socket = DefaultSocket
+	}
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	return &Client{
+		socket: socket,
+		http: &http.Client{
+			Timeout: 15 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return dialer.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+}
+
+// ContainerSummary es un elemento de GET /containers/json
+type ContainerSummary struct {
+	ID     string            `json:"Id"`
+	Names  []string          `json:"Names"`
+	State  string            `json:"State"`
+	Status string            `json:"Status"`
+	Labels map[string]string `json:"Labels"`
+}
+
+// Name retorna el nombre sin la barra inicial
+func (c ContainerSummary) Name() string {
+	if len(c.Names) == 0 {
+		return c.ID
+	}
+	return strings.TrimPrefix(c.Names[0], "/")
+}
+
+// ContainerInfo es la parte usada de GET /containers/{id}/json
+type ContainerInfo struct {
+	ID           string `json:"Id"`
+	Name         string `json:"Name"`
+	RestartCount int    `json:"RestartCount"`
+	State        struct {
+		Status     string    `json:"Status"`
+		Running    bool      `json:"Running"`
+		Restarting bool      `json:"Restarting"`
+		OOMKilled  bool      `json:"OOMKilled"`
+		ExitCode   int       `json:"ExitCode"`
+		Error      string    `json:"Error"`
+		StartedAt  time.Time `json:"StartedAt"`
+		FinishedAt time.Time `json:"FinishedAt"`
+		Health     *struct {
+			Status        string `json:"Status"`
+			FailingStreak int    `json:"FailingStreak"`
+			Log           []struct {
+				ExitCode int    `json:"ExitCode"`
+				Output   string `json:"Output"`
+			} `json:"Log"`
+		} `json:"Health"`
+	} `json:"State"`
+}
+
+// List retorna todos los contenedores (incluidos los detenidos) que tengan las etiquetas indicadas
+func (c *Client) List(ctx context.Context, labels []string) ([]ContainerSummary, error) {
+	query := url.Values{"all": {"1"}}
+	if len(labels) > 0 {
+		filters, _ := json.Marshal(map[string][]string{"label": labels})
+		query.Set("filters", string(filters))
+	}
+
+	var list []ContainerSummary
+	err := c.get(ctx, "/containers/json?"+query.Encode(), &list)
+	return list, err
+}
+
+// Inspect retorna el detalle de un contenedor
+func (c *Client) Inspect(ctx context.Context, id string) (ContainerInfo, error) {
+	var info ContainerInfo
+	err := c.get(ctx, "/containers/"+url.PathEscape(id)+"/json", &info)
+	return info, err
+}
+
+func (c *Client) get(ctx context.Context, path string, out interface{}) error {
+	// El host es ignorado por el dialer; solo se usa el socket
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker"+path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("error conectando a %s: %w", c.socket, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("docker respondió %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
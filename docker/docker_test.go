@@ -0,0 +1,262 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"orgmserver/utils/clocktest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDaemon simula la API de Docker sobre un socket unix
+type fakeDaemon struct {
+	mu         sync.Mutex
+	containers map[string]fakeContainer
+}
+
+type fakeContainer struct {
+	name     string
+	labels   map[string]string
+	status   string
+	health   string
+	restarts int
+	gone     bool // aparece en la lista pero Inspect responde 404
+}
+
+func (f *fakeDaemon) set(id string, c fakeContainer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.containers[id] = c
+}
+
+func (f *fakeDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.URL.Path == "/containers/json" {
+		if r.URL.Query().Get("all") != "1" {
+			http.Error(w, "se esperaba all=1", http.StatusBadRequest)
+			return
+		}
+		var want []string
+		if raw := r.URL.Query().Get("filters"); raw != "" {
+			var filters map[string][]string
+			json.Unmarshal([]byte(raw), &filters)
+			want = filters["label"]
+		}
+
+		var list []map[string]interface{}
+		for id, c := range f.containers {
+			match := true
+			for _, l := range want {
+				k, v, _ := strings.Cut(l, "=")
+				if c.labels[k] != v {
+					match = false
+				}
+			}
+			if match {
+				list = append(list, map[string]interface{}{
+					"Id": id, "Names": []string{"/" + c.name}, "State": c.status, "Labels": c.labels,
+				})
+			}
+		}
+		json.NewEncoder(w).Encode(list)
+		return
+	}
+
+	id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/containers/"), "/json")
+	c, ok := f.containers[id]
+	if !ok || c.gone {
+		http.Error(w, `{"message":"No such container"}`, http.StatusNotFound)
+		return
+	}
+	state := map[string]interface{}{
+		"Status":     c.status,
+		"Running":    c.status == "running",
+		"Restarting": c.status == "restarting",
+	}
+	if c.status == "exited" {
+		state["ExitCode"] = 137
+		state["OOMKilled"] = true
+	}
+	if c.health != "" {
+		state["Health"] = map[string]interface{}{
+			"Status":        c.health,
+			"FailingStreak": 3,
+			"Log":           []map[string]interface{}{{"ExitCode": 1, "Output": "connection refused\n"}},
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"Id": id, "Name": "/" + c.name, "RestartCount": c.restarts, "State": state,
+	})
+}
+
+func startFakeDaemon(t *testing.T) (*fakeDaemon, string) {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	daemon := &fakeDaemon{containers: make(map[string]fakeContainer)}
+	srv := &http.Server{Handler: daemon}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	return daemon, socket
+}
+
+func eventKeys(events []Event) []string {
+	var keys []string
+	for _, e := range events {
+		key := e.Container + ":" + e.Problem
+		if e.Resolved {
+			key += ":resuelto"
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func TestWatcherEvents(t *testing.T) {
+	daemon, socket := startFakeDaemon(t)
+	monitored := map[string]string{"monitor": "true"}
+	daemon.set("a1", fakeContainer{name: "web", labels: monitored, status: "running", health: "healthy"})
+	daemon.set("b2", fakeContainer{name: "db", labels: monitored, status: "exited"})
+	daemon.set("c3", fakeContainer{name: "worker", labels: monitored, status: "running"})
+	daemon.set("d4", fakeContainer{name: "other", status: "exited"})
+
+	w := &Watcher{
+		Client:        NewClient(socket),
+		Names:         []string{"cache"},
+		Labels:        []string{"monitor=true"},
+		RestartLimit:  3,
+		RestartWindow: 10 * time.Minute,
+	}
+	ctx := context.Background()
+
+	events, err := w.Check(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(eventKeys(events)), "[cache:missing db:stopped]"; got != want {
+		t.Fatalf("primera verificación: %s, se esperaba %s", got, want)
+	}
+	if !strings.Contains(events[1].Detail, "137") || !strings.Contains(events[1].Detail, "OOM") {
+		t.Errorf("detalle sin código de salida ni OOM: %q", events[1].Detail)
+	}
+
+	// Sin cambios no se repiten avisos
+	if events, _ := w.Check(ctx); len(events) != 0 {
+		t.Fatalf("se repitieron avisos: %v", eventKeys(events))
+	}
+
+	daemon.set("a1", fakeContainer{name: "web", labels: monitored, status: "running", health: "unhealthy"})
+	daemon.set("b2", fakeContainer{name: "db", labels: monitored, status: "running"})
+	daemon.set("c3", fakeContainer{name: "worker", labels: monitored, status: "restarting", restarts: 3})
+	daemon.set("e5", fakeContainer{name: "cache", status: "running"})
+
+	events, err = w.Check(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[cache:missing:resuelto db:stopped:resuelto web:unhealthy worker:restart_loop]"
+	if got := fmt.Sprint(eventKeys(events)); got != want {
+		t.Fatalf("segunda verificación: %s, se esperaba %s", got, want)
+	}
+	if !strings.Contains(events[2].Detail, "connection refused") {
+		t.Errorf("detalle sin salida del healthcheck: %q", events[2].Detail)
+	}
+
+	states := w.States()
+	if len(states) != 4 {
+		t.Fatalf("estados: %+v", states)
+	}
+}
+
+func TestWatcherRestartWindow(t *testing.T) {
+	daemon, socket := startFakeDaemon(t)
+	clock := clocktest.New(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+	w := &Watcher{Client: NewClient(socket), Names: []string{"web"}, RestartLimit: 3, RestartWindow: 10 * time.Minute, Clock: clock}
+	ctx := context.Background()
+	restart := func(after time.Duration, count int, want string) []Event {
+		t.Helper()
+		clock.Advance(after)
+		daemon.set("a1", fakeContainer{name: "web", status: "running", restarts: count})
+		events, err := w.Check(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprint(eventKeys(events)); got != want {
+			t.Fatalf("%s: eventos = %s, se esperaba %s", clock.Now().Format("15:04"), got, want)
+		}
+		if st := w.States()[0]; !st.CheckedAt.Equal(clock.Now()) {
+			t.Fatalf("hora de la consulta = %s, esperado %s", st.CheckedAt, clock.Now())
+		}
+		return events
+	}
+
+	// El contador inicial no cuenta como reinicios recientes
+	restart(0, 5, "[]")
+	restart(4*time.Minute, 6, "[]")
+	restart(4*time.Minute, 7, "[]")
+	// El reinicio de las 12:04 ya salió de la ventana de 10 minutos
+	restart(6*time.Minute, 8, "[]")
+	events := restart(time.Minute, 9, "[web:restart_loop]")
+	if events[0].Detail != "3 reinicios en los últimos 10m0s (total 9)" {
+		t.Errorf("detalle = %q", events[0].Detail)
+	}
+	// Se resuelve cuando el reinicio de las 12:08 sale de la ventana
+	restart(2*time.Minute, 9, "[]")
+	restart(time.Minute, 9, "[web:restart_loop:resuelto]")
+}
+
+func TestWatcherDaemonError(t *testing.T) {
+	w := &Watcher{Client: NewClient(filepath.Join(t.TempDir(), "missing.sock"))}
+	if _, err := w.Check(context.Background()); err == nil {
+		t.Fatal("se esperaba error sin daemon")
+	}
+}
+
+func TestWatcherInspectErrorSkipsContainer(t *testing.T) {
+	daemon, socket := startFakeDaemon(t)
+	monitored := map[string]string{"monitor": "true"}
+	daemon.set("a1", fakeContainer{name: "web", labels: monitored, status: "exited"})
+	daemon.set("b2", fakeContainer{name: "db", labels: monitored, status: "running"})
+
+	w := &Watcher{Client: NewClient(socket), Labels: []string{"monitor=true"}}
+	ctx := context.Background()
+	if events, err := w.Check(ctx); err != nil || fmt.Sprint(eventKeys(events)) != "[web:stopped]" {
+		t.Fatalf("eventos = %v, %v", eventKeys(events), err)
+	}
+
+	// docker compose recrea web: el contenedor viejo sigue en la lista pero ya no existe.
+	// La consulta continúa con los demás y web conserva su alerta sin resolverla.
+	daemon.set("a1", fakeContainer{name: "web", labels: monitored, status: "exited", gone: true})
+	daemon.set("b2", fakeContainer{name: "db", labels: monitored, status: "exited"})
+	events, err := w.Check(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(eventKeys(events)); got != "[db:stopped]" {
+		t.Fatalf("eventos = %s, se esperaba [db:stopped]", got)
+	}
+	states := w.States()
+	if len(states) != 2 || states[1].Name != "web" || !strings.Contains(states[1].Error, "404") || states[1].Status != "exited" {
+		t.Fatalf("estados = %+v", states)
+	}
+
+	// Cuando vuelve a responder se evalúa normalmente
+	daemon.set("a1", fakeContainer{name: "web", labels: monitored, status: "running"})
+	if events, err = w.Check(ctx); err != nil || fmt.Sprint(eventKeys(events)) != "[web:stopped:resuelto]" {
+		t.Fatalf("eventos = %v, %v", eventKeys(events), err)
+	}
+	if st := w.States()[1]; st.Error != "" {
+		t.Errorf("el error debía borrarse: %+v", st)
+	}
+}
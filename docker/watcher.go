@@ -0,0 +1,302 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"orgmserver/utils"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Tipos de problema de un contenedor
+const (
+	ProblemMissing     = "missing"
+	ProblemStopped     = "stopped"
+	ProblemUnhealthy   = "unhealthy"
+	ProblemRestartLoop = "restart_loop"
+)
+
+// Event es un problema nuevo o resuelto de un contenedor
+type Event struct {
+	Container string
+	Problem   string
+	Resolved  bool
+	Detail    string
+}
+
+// Describe retorna el problema en texto
+func (e Event) Describe() string {
+	switch e.Problem {
+	case ProblemMissing:
+		return "no existe"
+	case ProblemStopped:
+		return "detenido"
+	case ProblemUnhealthy:
+		return "no saludable (healthcheck)"
+	case ProblemRestartLoop:
+		return "reiniciándose en bucle"
+	}
+	return e.Problem
+}
+
+// ContainerState es el último estado conocido de un contenedor vigilado
+type ContainerState struct {
+	Name         string    `json:"name"`
+	Status       string    `json:"status"`
+	Health       string    `json:"health,omitempty"`
+	RestartCount int       `json:"restart_count"`
+	Problems     []string  `json:"problems,omitempty"`
+	Error        string    `json:"error,omitempty"` // la última consulta del contenedor falló
+	CheckedAt    time.Time `json:"checked_at"`
+}
+
+// Watcher vigila los contenedores por nombre y/o etiqueta
+type Watcher struct {
+	Client        *Client
+	Names         []string
+	Labels        []string
+	RestartLimit  int // reinicios dentro de RestartWindow que se consideran bucle
+	RestartWindow time.Duration
+	Clock         utils.Clock // hora de las consultas; nil usa el reloj del sistema
+
+	restarts map[string][]time.Time
+	counts   map[string]int
+	active   map[string]map[string]bool
+	states   map[string]ContainerState
+}
+
+func (w *Watcher) now() time.Time {
+	if w.Clock == nil {
+		return utils.SystemClock.Now()
+	}
+	return w.Clock.Now()
+}
+
+// Check consulta el daemon y retorna los problemas nuevos y los resueltos.
+// Si el daemon no responde se retorna error y se conserva el estado anterior.
+func (w *Watcher) Check(ctx context.Context) ([]Event, error) {
+	if w.active == nil {
+		w.restarts = make(map[string][]time.Time)
+		w.counts = make(map[string]int)
+		w.active = make(map[string]map[string]bool)
+		w.states = make(map[string]ContainerState)
+	}
+
+	var list []ContainerSummary
+	var err error
+	if len(w.Labels) > 0 || len(w.Names) == 0 {
+		if list, err = w.Client.List(ctx, w.Labels); err != nil {
+			return nil, err
+		}
+	}
+	if len(w.Names) > 0 {
+		// Los nombres se buscan entre todos los contenedores, tengan o no las etiquetas
+		all, err := w.Client.List(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range all {
+			if contains(w.Names, c.Name()) && !containsSummary(list, c.ID) {
+				list = append(list, c)
+			}
+		}
+	}
+
+	now := w.now()
+	current := make(map[string]map[string]string) // contenedor -> problema -> detalle
+	seen := make(map[string]bool)
+
+	for _, summary := range list {
+		name := summary.Name()
+		seen[name] = true
+		info, err := w.Client.Inspect(ctx, summary.ID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			// El contenedor pudo eliminarse entre List e Inspect (por ejemplo, al
+			// recrearlo docker compose): conserva sus problemas y se marca el error
+			current[name] = w.keep(name, err, now)
+			continue
+		}
+		current[name] = w.evaluate(name, info, now)
+	}
+
+	for _, name := range w.Names {
+		if !seen[name] {
+			current[name] = map[string]string{ProblemMissing: "el contenedor no existe en el daemon"}
+			w.states[name] = ContainerState{Name: name, Status: "missing", Problems: []string{ProblemMissing}, CheckedAt: now}
+		}
+	}
+
+	for name := range w.states {
+		if _, ok := current[name]; !ok {
+			delete(w.states, name)
+			delete(w.counts, name)
+			delete(w.restarts, name)
+		}
+	}
+
+	return w.diff(current), nil
+}
+
+// keep conserva los problemas ya notificados de un contenedor que no se pudo
+// consultar, sin levantar ni resolver alertas
+func (w *Watcher) keep(name string, err error, now time.Time) map[string]string {
+	problems := make(map[string]string)
+	for p := range w.active[name] {
+		problems[p] = ""
+	}
+	state := w.states[name]
+	state.Name = name
+	state.Error = err.Error()
+	state.CheckedAt = now
+	w.states[name] = state
+	return problems
+}
+
+// evaluate determina los problemas actuales de un contenedor
+func (w *Watcher) evaluate(name string, info ContainerInfo, now time.Time) map[string]string {
+	problems := make(map[string]string)
+
+	// Un contenedor en "restarting" se cuenta como reinicio, no como detenido, para
+	// no alternar avisos de caída y recuperación en cada vuelta del bucle
+	if !info.State.Running && !info.State.Restarting {
+		detail := fmt.Sprintf("estado %s, código de salida %d", info.State.Status, info.State.ExitCode)
+		if info.State.OOMKilled {
+			detail += ", terminado por falta de memoria (OOM)"
+		}
+		if info.State.Error != "" {
+			detail += ", error: " + info.State.Error
+		}
+		if !info.State.FinishedAt.IsZero() && info.State.FinishedAt.Year() > 1 {
+			detail += ", detenido el " + info.State.FinishedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		problems[ProblemStopped] = detail
+	}
+
+	health := ""
+	if info.State.Health != nil {
+		health = info.State.Health.Status
+		if health == "unhealthy" {
+			detail := fmt.Sprintf("%d healthchecks fallidos seguidos", info.State.Health.FailingStreak)
+			if n := len(info.State.Health.Log); n > 0 {
+				out := strings.TrimSpace(info.State.Health.Log[n-1].Output)
+				if len(out) > 200 {
+					out = out[:200] + "..."
+				}
+				if out != "" {
+					detail += ": " + out
+				}
+			}
+			problems[ProblemUnhealthy] = detail
+		}
+	}
+
+	// Reinicios: se registra la hora de cada incremento del contador; si el
+	// contenedor se recreó el contador vuelve a cero y se toma como nuevo
+	if prev, ok := w.counts[name]; ok && info.RestartCount > prev {
+		for i := 0; i < info.RestartCount-prev; i++ {
+			w.restarts[name] = append(w.restarts[name], now)
+		}
+	}
+	w.counts[name] = info.RestartCount
+
+	var recent []time.Time
+	for _, t := range w.restarts[name] {
+		if now.Sub(t) < w.RestartWindow {
+			recent = append(recent, t)
+		}
+	}
+	w.restarts[name] = recent
+	if w.RestartLimit > 0 && len(recent) >= w.RestartLimit {
+		problems[ProblemRestartLoop] = fmt.Sprintf("%d reinicios en los últimos %s (total %d)",
+			len(recent), w.RestartWindow, info.RestartCount)
+	}
+
+	state := ContainerState{
+		Name:         name,
+		Status:       info.State.Status,
+		Health:       health,
+		RestartCount: info.RestartCount,
+		CheckedAt:    now,
+	}
+	for p := range problems {
+		state.Problems = append(state.Problems, p)
+	}
+	sort.Strings(state.Problems)
+	w.states[name] = state
+
+	return problems
+}
+
+// diff compara los problemas actuales con los ya notificados
+func (w *Watcher) diff(current map[string]map[string]string) []Event {
+	var events []Event
+
+	for name, problems := range current {
+		if w.active[name] == nil {
+			w.active[name] = make(map[string]bool)
+		}
+		for problem, detail := range problems {
+			if !w.active[name][problem] {
+				w.active[name][problem] = true
+				events = append(events, Event{Container: name, Problem: problem, Detail: detail})
+			}
+		}
+	}
+
+	for name, active := range w.active {
+		for problem := range active {
+			if _, ok := current[name][problem]; ok {
+				continue
+			}
+			delete(active, problem)
+			// Un contenedor que desapareció por una etiqueta no vigilada ya no se reporta
+			if _, watched := current[name]; !watched {
+				continue
+			}
+			events = append(events, Event{Container: name, Problem: problem, Resolved: true, Detail: w.states[name].Status})
+		}
+		if len(active) == 0 {
+			delete(w.active, name)
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].Container != events[j].Container {
+			return events[i].Container < events[j].Container
+		}
+		return events[i].Problem < events[j].Problem
+	})
+	return events
+}
+
+// States retorna el último estado de los contenedores vigilados
+func (w *Watcher) States() []ContainerState {
+	list := make([]ContainerState, 0, len(w.states))
+	for _, s := range w.states {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsSummary(list []ContainerSummary, id string) bool {
+	for _, c := range list {
+		if c.ID == id {
+			return true
+		}
+	}
+	return false
+}
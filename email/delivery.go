@@ -0,0 +1,253 @@
+package email
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"orgmserver/utils"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Modos de entrega soportados
+const (
+	DeliveryRelay = "relay" // Smarthost autenticado (SMTP_HOST)
+	DeliveryMX    = "mx"    // Entrega directa al MX del destinatario
+)
+
+// Plazos de las conexiones SMTP: un servidor que acepta la conexión y deja de
+// responder no debe bloquear el envío de los demás avisos
+var (
+	dialTimeout = 30 * time.Second
+	smtpTimeout = 2 * time.Minute
+)
+
+// Canales de notificación; cada uno puede tener sus propios modos de entrega
+const (
+	ChannelConnection = "connection" // Conectividad: reconexión, IP, DNS, CGNAT, degradación y velocidad
+	ChannelServices   = "services"   // Checks, pings, certificados, contenedores, unidades y peer
+	ChannelSystem     = "system"     // Inicio, apagado, recursos y UPS
+	ChannelReport     = "report"     // Reporte periódico
+)
+
+// Channels lista los canales de notificación conocidos
+var Channels = []string{ChannelConnection, ChannelServices, ChannelSystem, ChannelReport}
+
+var errStartTLS = errors.New("STARTTLS falló")
+
+// SetDelivery configura los modos de entrega en orden de preferencia.
+// Si un modo falla se intenta el siguiente.
+func (e *EmailService) SetDelivery(modes []string, mxPort int, heloName string) {
+	e.deliveryModes = modes
+	e.mxPort = mxPort
+	e.heloName = heloName
+}
+
+// SetChannelDelivery reemplaza los modos de entrega de un canal.
+// Los canales sin modos propios usan los definidos en SetDelivery.
+func (e *EmailService) SetChannelDelivery(channel string, modes []string) {
+	if e.channelModes == nil {
+		e.channelModes = make(map[string][]string)
+	}
+	e.channelModes[channel] = modes
+}
+
+// deliver intenta entregar el mensaje con cada modo configurado para el canal
+func (e *EmailService) deliver(channel string, msg []byte) error {
+	modes := e.channelModes[channel]
+	if len(modes) == 0 {
+		modes = e.deliveryModes
+	}
+	if len(modes) == 0 {
+		modes = []string{DeliveryRelay}
+	}
+
+	var lastErr error
+	for _, mode := range modes {
+		var err error
+		switch mode {
+		case DeliveryMX:
+			err = e.deliverMX(msg)
+		default:
+			err = e.deliverRelay(msg)
+		}
+		if err == nil {
+			utils.WriteLog(fmt.Sprintf("[EMAIL] Correo entregado usando modo %s", mode), e.debug)
+			return nil
+		}
+		utils.WriteLog(fmt.Sprintf("[EMAIL] Falló entrega en modo %s: %v", mode, err), e.debug)
+		lastErr = err
+	}
+	return lastErr
+}
+
+// deliverRelay envía el mensaje a través del smarthost con autenticación. Sigue los
+// pasos de smtp.SendMail (STARTTLS y AUTH si el servidor los anuncia) pero con
+// plazo en la conexión.
+func (e *EmailService) deliverRelay(msg []byte) error {
+	c, err := dialSMTP(net.JoinHostPort(e.host, strconv.Itoa(e.port)), e.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if err := c.Hello(e.helo()); err != nil {
+		return err
+	}
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: e.host}); err != nil {
+			return err
+		}
+	}
+	if ok, _ := c.Extension("AUTH"); ok && e.user != "" {
+		if err := c.Auth(smtp.PlainAuth("", e.user, e.password, e.host)); err != nil {
+			return err
+		}
+	}
+	return e.transfer(c, msg)
+}
+
+// dialSMTP abre una conexión SMTP con plazo para toda la transacción
+func dialSMTP(addr, host string) (*smtp.Client, error) {
+	conn, err := net.DialTimeout("tcp", addr, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// transfer envía el sobre y el mensaje y cierra la sesión
+func (e *EmailService) transfer(c *smtp.Client, msg []byte) error {
+	if err := c.Mail(e.user); err != nil {
+		return err
+	}
+	if err := c.Rcpt(e.to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// deliverMX resuelve los registros MX del destinatario y entrega directamente,
+// recorriendo los servidores en orden de prioridad
+func (e *EmailService) deliverMX(msg []byte) error {
+	at := strings.LastIndex(e.to, "@")
+	if at < 0 {
+		return fmt.Errorf("destinatario sin dominio: %s", e.to)
+	}
+	domain := e.to[at+1:]
+
+	hosts, err := e.mxHosts(domain)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for _, host := range hosts {
+		err := e.sendToMX(host, msg, true)
+		if errors.Is(err, errStartTLS) {
+			// TLS oportunista: si el cifrado falla, reintentar en texto plano
+			utils.WriteLog(fmt.Sprintf("[EMAIL] STARTTLS falló con %s, reintentando sin TLS: %v", host, err), e.debug)
+			err = e.sendToMX(host, msg, false)
+		}
+		if err == nil {
+			return nil
+		}
+		utils.WriteLog(fmt.Sprintf("[EMAIL] Error entregando a MX %s: %v", host, err), e.debug)
+		lastErr = err
+	}
+	return fmt.Errorf("ningún MX de %s aceptó el correo: %w", domain, lastErr)
+}
+
+// mxHosts retorna los MX ordenados por prioridad; sin MX se usa el dominio (RFC 5321, sección 5.1)
+func (e *EmailService) mxHosts(domain string) ([]string, error) {
+	lookup := e.lookupMX
+	if lookup == nil {
+		lookup = net.LookupMX
+	}
+
+	records, err := lookup(domain)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return []string{domain}, nil
+		}
+		return nil, fmt.Errorf("error resolviendo MX de %s: %w", domain, err)
+	}
+
+	// net.LookupMX ya ordena por preferencia; se reordena por si el resolvedor no lo hace
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Pref < records[j].Pref })
+
+	var hosts []string
+	for _, mx := range records {
+		host := strings.TrimSuffix(mx.Host, ".")
+		if host == "" {
+			// MX nulo (RFC 7505): el dominio no acepta correo
+			return nil, fmt.Errorf("el dominio %s no acepta correo (MX nulo)", domain)
+		}
+		hosts = append(hosts, host)
+	}
+	if len(hosts) == 0 {
+		hosts = []string{domain}
+	}
+	return hosts, nil
+}
+
+// sendToMX realiza una transacción SMTP sin autenticación contra un MX
+func (e *EmailService) sendToMX(host string, msg []byte, useTLS bool) error {
+	port := e.mxPort
+	if port == 0 {
+		port = 25
+	}
+
+	c, err := dialSMTP(net.JoinHostPort(host, strconv.Itoa(port)), host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if err := c.Hello(e.helo()); err != nil {
+		return err
+	}
+
+	if ok, _ := c.Extension("STARTTLS"); ok && useTLS {
+		// Cifrado oportunista (RFC 7435): no se valida el certificado del MX
+		if err := c.StartTLS(&tls.Config{ServerName: host, InsecureSkipVerify: true}); err != nil {
+			return fmt.Errorf("%w: %v", errStartTLS, err)
+		}
+		utils.WriteLog(fmt.Sprintf("[EMAIL] Conexión con %s cifrada con STARTTLS", host), e.debug)
+	}
+	return e.transfer(c, msg)
+}
+
+// helo retorna el nombre usado en EHLO, por defecto el hostname del equipo
+func (e *EmailService) helo() string {
+	if e.heloName != "" {
+		return e.heloName
+	}
+	if name, err := os.Hostname(); err == nil && strings.Contains(name, ".") {
+		return name
+	}
+	return "localhost"
+}
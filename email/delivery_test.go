@@ -0,0 +1,318 @@
+package email
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"orgmserver/utils/clocktest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMTP es un servidor SMTP mínimo que registra los mensajes recibidos
+type fakeSMTP struct {
+	ln         net.Listener
+	startTLS   bool // anuncia STARTTLS y lo rechaza al pedirlo
+	rejectRcpt bool // rechaza el destinatario con 550
+
+	mu       sync.Mutex
+	tlsTries int
+	messages []string
+}
+
+func newFakeSMTP(t *testing.T, addr string) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("no se pudo escuchar en %s: %v", addr, err)
+	}
+	s := &fakeSMTP{ln: ln}
+	t.Cleanup(func() { ln.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *fakeSMTP) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTP) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.messages...)
+}
+
+func (s *fakeSMTP) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeSMTP) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			reply("250-fake")
+			if s.startTLS {
+				reply("250-STARTTLS")
+			}
+			reply("250 AUTH PLAIN")
+		case strings.HasPrefix(cmd, "HELO"):
+			reply("250 fake")
+		case cmd == "STARTTLS":
+			s.mu.Lock()
+			s.tlsTries++
+			s.mu.Unlock()
+			reply("454 4.7.0 TLS no disponible")
+		case strings.HasPrefix(cmd, "AUTH"):
+			reply("235 2.7.0 autenticado")
+		case strings.HasPrefix(cmd, "RCPT"):
+			if s.rejectRcpt {
+				reply("550 5.1.1 destinatario rechazado")
+			} else {
+				reply("250 ok")
+			}
+		case cmd == "DATA":
+			reply("354 fin con .")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, data.String())
+			s.mu.Unlock()
+			reply("250 ok")
+		case cmd == "QUIT":
+			reply("221 adiós")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func newMXTestService(port int, records []*net.MX) *EmailService {
+	svc := NewEmailService("Test", "localhost", port, "alertas@example.com", "", "ops@example.net", false)
+	svc.SetDelivery([]string{DeliveryMX}, port, "test.example.com")
+	svc.lookupMX = func(name string) ([]*net.MX, error) {
+		if name != "example.net" {
+			return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+		}
+		return records, nil
+	}
+	return svc
+}
+
+func TestDeliverMXPriorityFallback(t *testing.T) {
+	backup := newFakeSMTP(t, "127.0.0.1:0")
+	primary := newFakeSMTP(t, "127.0.0.2:"+strconv.Itoa(backup.port()))
+	primary.rejectRcpt = true
+
+	// El resolvedor entrega los MX desordenados; el de menor preferencia va primero
+	svc := newMXTestService(backup.port(), []*net.MX{
+		{Host: "127.0.0.1.", Pref: 20},
+		{Host: "127.0.0.2.", Pref: 10},
+	})
+
+	if err := svc.sendEmail(ChannelConnection, "Cambio de IP", "IP Nueva: 203.0.113.7"); err != nil {
+		t.Fatalf("sendEmail: %v", err)
+	}
+	if got := len(primary.received()); got != 0 {
+		t.Errorf("el MX primario no debía recibir mensajes, recibió %d", got)
+	}
+	msgs := backup.received()
+	if len(msgs) != 1 || !strings.Contains(msgs[0], "IP Nueva: 203.0.113.7") {
+		t.Fatalf("mensajes en MX de respaldo: %q", msgs)
+	}
+}
+
+func TestDeliverMXStartTLSFallback(t *testing.T) {
+	srv := newFakeSMTP(t, "127.0.0.1:0")
+	srv.startTLS = true
+
+	svc := newMXTestService(srv.port(), []*net.MX{{Host: "127.0.0.1.", Pref: 10}})
+	if err := svc.sendEmail(ChannelConnection, "Conexión Restaurada", "ok"); err != nil {
+		t.Fatalf("sendEmail: %v", err)
+	}
+
+	srv.mu.Lock()
+	tries := srv.tlsTries
+	srv.mu.Unlock()
+	if tries != 1 {
+		t.Errorf("intentos de STARTTLS = %d, esperado 1", tries)
+	}
+	if got := len(srv.received()); got != 1 {
+		t.Errorf("mensajes recibidos en texto plano = %d, esperado 1", got)
+	}
+}
+
+func TestDeliverNullMX(t *testing.T) {
+	svc := newMXTestService(25, []*net.MX{{Host: ".", Pref: 0}})
+	if err := svc.deliver(ChannelConnection, []byte("Subject: x\r\n\r\nx\r\n")); err == nil {
+		t.Fatal("se esperaba error con MX nulo")
+	}
+}
+
+func TestDeliverFallsBackToRelay(t *testing.T) {
+	relay := newFakeSMTP(t, "127.0.0.1:0")
+
+	svc := newMXTestService(relay.port(), nil)
+	svc.SetDelivery([]string{DeliveryMX, DeliveryRelay}, relay.port(), "test.example.com")
+	svc.lookupMX = func(name string) ([]*net.MX, error) {
+		return nil, errors.New("servidor DNS no disponible")
+	}
+
+	if err := svc.sendEmail(ChannelSystem, "Servidor Iniciado", "hola"); err != nil {
+		t.Fatalf("sendEmail: %v", err)
+	}
+	if got := len(relay.received()); got != 1 {
+		t.Fatalf("mensajes en relay = %d, esperado 1", got)
+	}
+}
+
+func TestDeliverPerChannel(t *testing.T) {
+	relay := newFakeSMTP(t, "127.0.0.1:0")
+
+	svc := newMXTestService(relay.port(), nil)
+	svc.SetDelivery([]string{DeliveryRelay}, relay.port(), "test.example.com")
+	svc.SetChannelDelivery(ChannelConnection, []string{DeliveryMX})
+	lookups := 0
+	svc.lookupMX = func(name string) ([]*net.MX, error) {
+		lookups++
+		return nil, errors.New("servidor DNS no disponible")
+	}
+
+	// El canal de conectividad solo usa MX y no cae al relay
+	if err := svc.sendEmail(ChannelConnection, "Cambio de IP", "x"); err == nil {
+		t.Fatal("se esperaba error en el canal connection")
+	}
+	if lookups != 1 || len(relay.received()) != 0 {
+		t.Fatalf("lookups = %d, mensajes en relay = %d", lookups, len(relay.received()))
+	}
+
+	// Los demás canales heredan el modo global
+	if err := svc.sendEmail(ChannelServices, "Check Caído", "x"); err != nil {
+		t.Fatalf("sendEmail: %v", err)
+	}
+	if lookups != 1 || len(relay.received()) != 1 {
+		t.Fatalf("lookups = %d, mensajes en relay = %d", lookups, len(relay.received()))
+	}
+}
+
+func TestDeliverRelayDeadline(t *testing.T) {
+	// Un servidor que acepta la conexión y nunca saluda
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("no se pudo escuchar: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	defer func(d time.Duration) { smtpTimeout = d }(smtpTimeout)
+	smtpTimeout = 200 * time.Millisecond
+
+	svc := NewEmailService("Test", "localhost", ln.Addr().(*net.TCPAddr).Port, "alertas@example.com", "", "ops@example.net", false)
+	start := time.Now()
+	if err := svc.deliverRelay([]byte("Subject: x\r\n\r\nx\r\n")); err == nil {
+		t.Fatal("se esperaba error con un servidor que no responde")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("el envío tardó %s, el plazo no se aplicó", elapsed)
+	}
+}
+
+// header retorna el valor de la cabecera name del mensaje
+func header(msg, name string) string {
+	for _, line := range strings.Split(msg, "\r\n") {
+		if line == "" {
+			break
+		}
+		if v, ok := strings.CutPrefix(line, name+": "); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+func TestReportDatesFollowClock(t *testing.T) {
+	relay := newFakeSMTP(t, "127.0.0.1:0")
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := newDKIMSignerFromKey("example.com", "mail", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewEmailService("Test", "localhost", relay.port(), "alertas@example.com", "", "ops@example.net", false)
+	svc.SetDKIMSigner(signer)
+	clock := clocktest.New(time.Date(2025, 6, 1, 12, 30, 0, 0, time.UTC))
+	svc.SetClock(clock)
+
+	start := clock.Now()
+	clock.Advance(24 * time.Hour)
+	if err := svc.SendReportEmail("203.0.113.7", start, nil); err != nil {
+		t.Fatalf("SendReportEmail: %v", err)
+	}
+	clock.Advance(time.Hour)
+	if err := svc.SendReportEmail("203.0.113.7", start, nil); err != nil {
+		t.Fatalf("SendReportEmail: %v", err)
+	}
+
+	msgs := relay.received()
+	if len(msgs) != 2 {
+		t.Fatalf("mensajes en relay = %d, esperado 2", len(msgs))
+	}
+	for i, sent := range []time.Time{start.Add(24 * time.Hour), start.Add(25 * time.Hour)} {
+		msg := msgs[i]
+		if got := header(msg, "Date"); got != sent.Format(time.RFC1123Z) {
+			t.Errorf("mensaje %d: Date = %q", i+1, got)
+		}
+		if want := fmt.Sprintf("t=%d;", sent.Unix()); !strings.Contains(header(msg, "DKIM-Signature"), want) {
+			t.Errorf("mensaje %d: la firma no tiene %s: %s", i+1, want, header(msg, "DKIM-Signature"))
+		}
+		want := "Desde: 2025-06-01 12:30:00\r\nHasta: " + sent.Format("2006-01-02 15:04:05")
+		if !strings.Contains(msg, want) {
+			t.Errorf("mensaje %d: falta %q:\n%s", i+1, want, msg)
+		}
+	}
+	if header(msgs[0], "Message-ID") == header(msgs[1], "Message-ID") {
+		t.Errorf("Message-ID repetido: %s", header(msgs[0], "Message-ID"))
+	}
+}
@@ -0,0 +1,255 @@
+package email
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// dkimSignedHeaders son las cabeceras que se incluyen en la firma DKIM
+var dkimSignedHeaders = []string{
+	"From",
+	"To",
+	"Subject",
+	"Date",
+	"Message-ID",
+	"MIME-Version",
+	"Content-Type",
+}
+
+// DKIMSigner firma mensajes según RFC 6376 (rsa-sha256) y RFC 8463 (ed25519-sha256)
+type DKIMSigner struct {
+	domain    string
+	selector  string
+	signer    crypto.Signer
+	algorithm string
+	now       func() time.Time
+}
+
+// NewDKIMSigner crea un firmante DKIM a partir de una clave privada en formato PEM
+func NewDKIMSigner(domain, selector, keyFile string) (*DKIMSigner, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo clave DKIM: %w", err)
+	}
+
+	key, err := parseDKIMKey(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return newDKIMSignerFromKey(domain, selector, key)
+}
+
+func newDKIMSignerFromKey(domain, selector string, key crypto.Signer) (*DKIMSigner, error) {
+	if domain == "" || selector == "" {
+		return nil, fmt.Errorf("dominio y selector DKIM son requeridos")
+	}
+
+	var algorithm string
+	switch key.(type) {
+	case *rsa.PrivateKey:
+		algorithm = "rsa-sha256"
+	case ed25519.PrivateKey:
+		algorithm = "ed25519-sha256"
+	default:
+		return nil, fmt.Errorf("tipo de clave DKIM no soportado: %T", key)
+	}
+
+	return &DKIMSigner{
+		domain:    domain,
+		selector:  selector,
+		signer:    key,
+		algorithm: algorithm,
+		now:       time.Now,
+	}, nil
+}
+
+// parseDKIMKey acepta claves RSA en PKCS#1 y claves RSA o Ed25519 en PKCS#8
+func parseDKIMKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("clave DKIM no está en formato PEM")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("error parseando clave RSA: %w", err)
+		}
+		return key, nil
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("error parseando clave PKCS#8: %w", err)
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("tipo de clave DKIM no soportado: %T", key)
+		}
+		return signer, nil
+	default:
+		return nil, fmt.Errorf("bloque PEM no soportado: %s", block.Type)
+	}
+}
+
+// Sign retorna el mensaje con la cabecera DKIM-Signature antepuesta
+func (s *DKIMSigner) Sign(msg []byte) ([]byte, error) {
+	headerPart, body := splitMessage(msg)
+	headers := parseHeaders(headerPart)
+
+	bodyHash := sha256.Sum256(canonicalBodyRelaxed(body))
+
+	// Solo se firman las cabeceras presentes en el mensaje
+	var signed []string
+	for _, name := range dkimSignedHeaders {
+		if _, ok := lastHeader(headers, name); ok {
+			signed = append(signed, name)
+		}
+	}
+
+	sigValue := fmt.Sprintf("v=1; a=%s; c=relaxed/relaxed; d=%s; s=%s; t=%d; h=%s; bh=%s; b=",
+		s.algorithm, s.domain, s.selector, s.now().Unix(),
+		strings.ToLower(strings.Join(signed, ":")),
+		base64.StdEncoding.EncodeToString(bodyHash[:]))
+
+	var data bytes.Buffer
+	for _, name := range signed {
+		h, _ := lastHeader(headers, name)
+		data.WriteString(canonicalHeaderRelaxed(h))
+		data.WriteString("\r\n")
+	}
+	// La propia cabecera DKIM-Signature se firma con b= vacío y sin CRLF final
+	data.WriteString(canonicalHeaderRelaxed("DKIM-Signature: " + sigValue))
+
+	hashed := sha256.Sum256(data.Bytes())
+
+	var sig []byte
+	var err error
+	switch s.algorithm {
+	case "ed25519-sha256":
+		// RFC 8463: Ed25519 puro sobre el hash SHA-256
+		sig, err = s.signer.Sign(rand.Reader, hashed[:], crypto.Hash(0))
+	default:
+		sig, err = s.signer.Sign(rand.Reader, hashed[:], crypto.SHA256)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error firmando DKIM: %w", err)
+	}
+
+	header := "DKIM-Signature: " + sigValue + foldBase64(base64.StdEncoding.EncodeToString(sig))
+
+	out := make([]byte, 0, len(header)+2+len(msg))
+	out = append(out, header...)
+	out = append(out, "\r\n"...)
+	out = append(out, msg...)
+	return out, nil
+}
+
+// splitMessage separa cabeceras y cuerpo en la primera línea vacía
+func splitMessage(msg []byte) (string, []byte) {
+	if i := bytes.Index(msg, []byte("\r\n\r\n")); i >= 0 {
+		return string(msg[:i+2]), msg[i+4:]
+	}
+	return string(msg), nil
+}
+
+// parseHeaders retorna las cabeceras en orden, conservando los pliegues originales
+func parseHeaders(headerPart string) []string {
+	var headers []string
+	for _, line := range strings.SplitAfter(headerPart, "\r\n") {
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(headers) > 0 {
+			headers[len(headers)-1] += line
+			continue
+		}
+		headers = append(headers, line)
+	}
+	for i, h := range headers {
+		headers[i] = strings.TrimSuffix(h, "\r\n")
+	}
+	return headers
+}
+
+// lastHeader busca la última instancia de la cabecera (RFC 6376, sección 5.4.2)
+func lastHeader(headers []string, name string) (string, bool) {
+	for i := len(headers) - 1; i >= 0; i-- {
+		colon := strings.IndexByte(headers[i], ':')
+		if colon < 0 {
+			continue
+		}
+		if strings.EqualFold(strings.TrimSpace(headers[i][:colon]), name) {
+			return headers[i], true
+		}
+	}
+	return "", false
+}
+
+// canonicalHeaderRelaxed aplica la canonicalización "relaxed" de cabeceras (RFC 6376, sección 3.4.2)
+func canonicalHeaderRelaxed(header string) string {
+	colon := strings.IndexByte(header, ':')
+	if colon < 0 {
+		return header
+	}
+	name := strings.ToLower(strings.TrimSpace(header[:colon]))
+	value := header[colon+1:]
+	value = strings.ReplaceAll(value, "\r\n", "")
+	value = strings.Join(strings.FieldsFunc(value, isWSP), " ")
+	return name + ":" + value
+}
+
+// canonicalBodyRelaxed aplica la canonicalización "relaxed" del cuerpo (RFC 6376, sección 3.4.4)
+func canonicalBodyRelaxed(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+
+	var out strings.Builder
+	for _, line := range lines {
+		fields := strings.FieldsFunc(line, isWSP)
+		trimmed := strings.Join(fields, " ")
+		if len(fields) > 0 && isWSP(rune(line[0])) {
+			trimmed = " " + trimmed
+		}
+		out.WriteString(trimmed)
+		out.WriteString("\r\n")
+	}
+
+	// Eliminar líneas vacías al final del cuerpo
+	result := out.String()
+	for strings.HasSuffix(result, "\r\n\r\n") {
+		result = strings.TrimSuffix(result, "\r\n")
+	}
+	if result == "\r\n" {
+		return nil
+	}
+	return []byte(result)
+}
+
+func isWSP(r rune) bool {
+	return r == ' ' || r == '\t'
+}
+
+// foldBase64 parte la firma en líneas para no superar el largo máximo de cabecera
+func foldBase64(s string) string {
+	const width = 72
+	var b strings.Builder
+	for len(s) > width {
+		b.WriteString(s[:width])
+		b.WriteString("\r\n\t")
+		s = s[width:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
@@ -1,9 +1,12 @@
 package email
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
-	"net/smtp"
+	"net"
 	"orgmserver/utils"
+	"strings"
 	"time"
 )
 
@@ -14,7 +17,15 @@ type EmailService struct {
 	user     string
 	password string
 	to       string
+	dkim     *DKIMSigner
 	debug    bool
+
+	deliveryModes []string
+	channelModes  map[string][]string
+	mxPort        int
+	heloName      string
+	lookupMX      func(name string) ([]*net.MX, error)
+	clock         utils.Clock
 }
 
 func NewEmailService(appName string, host string, port int, user, password, to string, debug bool) *EmailService {
@@ -26,11 +37,28 @@ func NewEmailService(appName string, host string, port int, user, password, to s
 		password: password,
 		to:       to,
 		debug:    debug,
+		clock:    utils.SystemClock,
+	}
+}
+
+// SetDKIMSigner habilita la firma DKIM de los correos salientes
+func (e *EmailService) SetDKIMSigner(signer *DKIMSigner) {
+	e.dkim = signer
+	e.dkim.now = e.clock.Now
+}
+
+// SetClock reemplaza el reloj usado en las fechas de los avisos, el encabezado Date
+// y la firma DKIM
+func (e *EmailService) SetClock(clock utils.Clock) {
+	e.clock = clock
+	if e.dkim != nil {
+		e.dkim.now = clock.Now
 	}
 }
 
-// SendStartupEmail envía correo cuando el servicio inicia
-func (e *EmailService) SendStartupEmail(ip string) error {
+// SendStartupEmail envía correo cuando el servicio inicia.
+// cause describe el motivo del inicio cuando está confirmado (vacío si no).
+func (e *EmailService) SendStartupEmail(ip string, cause string) error {
 	subject := fmt.Sprintf("Servidor %s Iniciado", e.appName)
 	
 	body := fmt.Sprintf(`Servidor %s iniciado correctamente.
@@ -40,13 +68,18 @@ IP Externa: %s
 Fecha/Hora: %s
 
 El servicio está monitoreando la conexión a internet cada minuto.`, 
-		e.appName, ip, time.Now().Format("2006-01-02 15:04:05"))
+		e.appName, ip, e.clock.Now().Format("2006-01-02 15:04:05"))
+
+	if cause != "" {
+		body += fmt.Sprintf("\n\nCausa del inicio: %s", cause)
+	}
 
-	return e.sendEmail(subject, body)
+	return e.sendEmail(ChannelSystem, subject, body)
 }
 
-// SendReconnectionEmail envía correo cuando se restaura la conexión
-func (e *EmailService) SendReconnectionEmail(ip string, duration time.Duration) error {
+// SendReconnectionEmail envía correo cuando se restaura la conexión.
+// quality contiene las mediciones de latencia y pérdida (vacío si no están habilitadas).
+func (e *EmailService) SendReconnectionEmail(ip string, duration time.Duration, quality string) error {
 	subject := fmt.Sprintf("Conexión Restaurada - %s", e.appName)
 	
 	minutes := int(duration.Minutes())
@@ -56,45 +89,535 @@ func (e *EmailService) SendReconnectionEmail(ip string, duration time.Duration)
 
 IP Externa: %s
 Duración de desconexión: %d minutos y %d segundos
-Fecha/Hora de restauración: %s
+Fecha/Hora de restauración: %s`, 
+		ip, minutes, seconds, e.clock.Now().Format("2006-01-02 15:04:05"))
+
+	body += qualitySection(quality)
+	body += "\n\nEl servicio continúa monitoreando la conexión."
+
+	return e.sendEmail(ChannelConnection, subject, body)
+}
+
+func qualitySection(quality string) string {
+	if quality == "" {
+		return ""
+	}
+	return fmt.Sprintf("\n\nCalidad de la conexión:\n%s", quality)
+}
+
+// SendDegradedEmail envía correo cuando la latencia, el jitter o la pérdida superan
+// los límites durante todo el período de evaluación
+func (e *EmailService) SendDegradedEmail(ip string, period time.Duration, reasons string, stats string) error {
+	subject := fmt.Sprintf("Conexión Degradada - %s", e.appName)
+
+	body := fmt.Sprintf(`La calidad de la conexión superó los límites configurados de forma sostenida.
+
+IP Externa: %s
+Período evaluado: %s
+Fecha/Hora: %s
+
+Límites superados: %s
+
+Mediciones del período:
+%s
+
+El servicio continúa midiendo la conexión.`,
+		ip, period, e.clock.Now().Format("2006-01-02 15:04:05"), reasons, stats)
+
+	return e.sendEmail(ChannelConnection, subject, body)
+}
+
+// SendDegradationClearedEmail envía correo cuando la calidad vuelve a estar dentro de los límites
+func (e *EmailService) SendDegradationClearedEmail(ip string, stats string) error {
+	subject := fmt.Sprintf("Calidad de Conexión Normal - %s", e.appName)
+
+	body := fmt.Sprintf(`La calidad de la conexión volvió a estar dentro de los límites.
+
+IP Externa: %s
+Fecha/Hora: %s
+
+Mediciones recientes:
+%s`,
+		ip, e.clock.Now().Format("2006-01-02 15:04:05"), stats)
+
+	return e.sendEmail(ChannelConnection, subject, body)
+}
+
+// SendSlowSpeedEmail envía correo cuando la prueba de velocidad queda por debajo del mínimo
+func (e *EmailService) SendSlowSpeedEmail(ip string, below string, result string) error {
+	subject := fmt.Sprintf("Velocidad Baja - %s", e.appName)
+
+	body := fmt.Sprintf(`La prueba de velocidad quedó por debajo del mínimo configurado.
+
+IP Externa: %s
+Fecha/Hora: %s
+
+Por debajo del mínimo: %s
+
+Resultado:
+%s`,
+		ip, e.clock.Now().Format("2006-01-02 15:04:05"), below, result)
+
+	return e.sendEmail(ChannelConnection, subject, body)
+}
+
+// SendSpeedRestoredEmail envía correo cuando la velocidad vuelve a superar el mínimo
+func (e *EmailService) SendSpeedRestoredEmail(ip string, result string) error {
+	subject := fmt.Sprintf("Velocidad Normal - %s", e.appName)
+
+	body := fmt.Sprintf(`La prueba de velocidad volvió a superar el mínimo configurado.
+
+IP Externa: %s
+Fecha/Hora: %s
+
+Resultado:
+%s`,
+		ip, e.clock.Now().Format("2006-01-02 15:04:05"), result)
+
+	return e.sendEmail(ChannelConnection, subject, body)
+}
+
+// SendCheckDownEmail envía correo cuando un check de servicio supera su umbral de fallos
+func (e *EmailService) SendCheckDownEmail(name, checkType, target, errMsg string, since time.Time) error {
+	subject := fmt.Sprintf("Servicio Caído: %s - %s", name, e.appName)
+
+	body := fmt.Sprintf(`El check %s reporta el servicio caído.
+
+Tipo: %s
+Destino: %s
+Error: %s
+Caído desde: %s
+
+El servicio continúa verificando el check.`,
+		name, checkType, target, errMsg, since.Format("2006-01-02 15:04:05"))
+
+	return e.sendEmail(ChannelServices, subject, body)
+}
+
+// SendCheckUpEmail envía correo cuando un check de servicio vuelve a responder
+func (e *EmailService) SendCheckUpEmail(name, checkType, target string, downtime time.Duration) error {
+	subject := fmt.Sprintf("Servicio Restablecido: %s - %s", name, e.appName)
+
+	body := fmt.Sprintf(`El check %s reporta el servicio funcionando nuevamente.
+
+Tipo: %s
+Destino: %s
+Fecha/Hora: %s`,
+		name, checkType, target, e.clock.Now().Format("2006-01-02 15:04:05"))
+
+	if downtime > 0 {
+		body += fmt.Sprintf("\nDuración de la caída: %s", downtime.Round(time.Second))
+	}
+
+	return e.sendEmail(ChannelServices, subject, body)
+}
+
+// SendCertificateAlertEmail envía correo cuando un certificado TLS está por vencer,
+// no corresponde al nombre o su cadena no es válida
+func (e *EmailService) SendCertificateAlertEmail(name, target, problem string) error {
+	subject := fmt.Sprintf("Certificado TLS: %s - %s", name, e.appName)
+
+	body := fmt.Sprintf(`Se detectó un problema con el certificado TLS de %s.
+
+Servidor: %s
+Problema: %s
+Fecha/Hora: %s
+
+Renueve o corrija el certificado antes de que los clientes lo rechacen.`,
+		name, target, problem, e.clock.Now().Format("2006-01-02 15:04:05"))
+
+	return e.sendEmail(ChannelServices, subject, body)
+}
+
+// SendCertificateOKEmail envía correo cuando el certificado vuelve a ser válido
+func (e *EmailService) SendCertificateOKEmail(name, target string) error {
+	subject := fmt.Sprintf("Certificado TLS Correcto: %s - %s", name, e.appName)
+
+	body := fmt.Sprintf(`El certificado TLS de %s vuelve a ser válido.
+
+Servidor: %s
+Fecha/Hora: %s`,
+		name, target, e.clock.Now().Format("2006-01-02 15:04:05"))
+
+	return e.sendEmail(ChannelServices, subject, body)
+}
+
+// SendResourceAlertEmail envía correo cuando un recurso del host supera su límite
+func (e *EmailService) SendResourceAlertEmail(alert string, snapshot string) error {
+	subject := fmt.Sprintf("Alerta de Recursos - %s", e.appName)
+
+	body := fmt.Sprintf(`Un recurso del servidor superó el límite configurado.
+
+Alerta: %s
+Fecha/Hora: %s
+
+Estado actual del host:
+%s`,
+		alert, e.clock.Now().Format("2006-01-02 15:04:05"), snapshot)
+
+	return e.sendEmail(ChannelSystem, subject, body)
+}
+
+// SendResourceRecoveredEmail envía correo cuando un recurso vuelve a estar bajo su límite
+func (e *EmailService) SendResourceRecoveredEmail(alert string, snapshot string) error {
+	subject := fmt.Sprintf("Recursos Normalizados - %s", e.appName)
+
+	body := fmt.Sprintf(`Un recurso del servidor volvió a estar dentro del límite.
+
+Alerta resuelta: %s
+Fecha/Hora: %s
+
+Estado actual del host:
+%s`,
+		alert, e.clock.Now().Format("2006-01-02 15:04:05"), snapshot)
+
+	return e.sendEmail(ChannelSystem, subject, body)
+}
+
+// SendContainerAlertEmail envía correo cuando un contenedor se detiene, deja de estar
+// saludable o se reinicia en bucle
+func (e *EmailService) SendContainerAlertEmail(container, problem, detail string) error {
+	subject := fmt.Sprintf("Contenedor %s: %s - %s", container, problem, e.appName)
+
+	body := fmt.Sprintf(`Se detectó un problema en el contenedor %s.
+
+Problema: %s
+Detalle: %s
+Fecha/Hora: %s`,
+		container, problem, detail, e.clock.Now().Format("2006-01-02 15:04:05"))
+
+	return e.sendEmail(ChannelServices, subject, body)
+}
+
+// SendContainerRecoveredEmail envía correo cuando se resuelve el problema de un contenedor
+func (e *EmailService) SendContainerRecoveredEmail(container, problem, status string) error {
+	subject := fmt.Sprintf("Contenedor %s Recuperado - %s", container, e.appName)
+
+	body := fmt.Sprintf(`El contenedor %s ya no está %s.
+
+Estado actual: %s
+Fecha/Hora: %s`,
+		container, problem, status, e.clock.Now().Format("2006-01-02 15:04:05"))
+
+	return e.sendEmail(ChannelServices, subject, body)
+}
+
+// SendUnitAlertEmail envía correo cuando una unidad de systemd falla, no existe o se reinicia
+func (e *EmailService) SendUnitAlertEmail(unit, problem, detail string) error {
+	subject := fmt.Sprintf("Unidad %s %s - %s", unit, problem, e.appName)
+
+	body := fmt.Sprintf(`Se detectó un evento en la unidad de systemd %s.
+
+Evento: %s
+Detalle: %s
+Fecha/Hora: %s`,
+		unit, problem, detail, e.clock.Now().Format("2006-01-02 15:04:05"))
+
+	return e.sendEmail(ChannelServices, subject, body)
+}
+
+// SendUnitRecoveredEmail envía correo cuando una unidad de systemd sale del estado de fallo
+func (e *EmailService) SendUnitRecoveredEmail(unit, problem, state string) error {
+	subject := fmt.Sprintf("Unidad %s Recuperada - %s", unit, e.appName)
+
+	body := fmt.Sprintf(`La unidad de systemd %s ya no está %s.
+
+Estado actual: %s
+Fecha/Hora: %s`,
+		unit, problem, state, e.clock.Now().Format("2006-01-02 15:04:05"))
+
+	return e.sendEmail(ChannelServices, subject, body)
+}
+
+// SendOnBatteryEmail envía correo cuando el UPS pasa a alimentarse de la batería
+func (e *EmailService) SendOnBatteryEmail(ups, status string, at time.Time) error {
+	subject := fmt.Sprintf("UPS en Batería - %s", e.appName)
+
+	body := fmt.Sprintf(`Se cortó la alimentación eléctrica: el UPS %s está funcionando con batería.
+
+Batería: %s
+Fecha/Hora: %s`,
+		ups, status, at.Format("2006-01-02 15:04:05"))
+
+	return e.sendEmail(ChannelSystem, subject, body)
+}
+
+// SendLowBatteryEmail envía correo cuando el UPS informa batería baja
+func (e *EmailService) SendLowBatteryEmail(ups, status string, at time.Time) error {
+	subject := fmt.Sprintf("UPS con Batería Baja - %s", e.appName)
+
+	body := fmt.Sprintf(`El UPS %s informa batería baja; el equipo puede apagarse en breve.
 
-El servicio continúa monitoreando la conexión.`, 
-		ip, minutes, seconds, time.Now().Format("2006-01-02 15:04:05"))
+Batería: %s
+Fecha/Hora: %s`,
+		ups, status, at.Format("2006-01-02 15:04:05"))
 
-	return e.sendEmail(subject, body)
+	return e.sendEmail(ChannelSystem, subject, body)
 }
 
-// SendIPChangeEmail envía correo cuando cambia la IP externa
-func (e *EmailService) SendIPChangeEmail(newIP string, oldIP string) error {
-	subject := fmt.Sprintf("Cambio de IP Externa - %s", e.appName)
+// SendPowerRestoredEmail envía correo cuando vuelve la alimentación eléctrica
+func (e *EmailService) SendPowerRestoredEmail(ups, status string, onBattery time.Duration, at time.Time) error {
+	subject := fmt.Sprintf("Energía Restaurada - %s", e.appName)
+
+	body := fmt.Sprintf(`Volvió la alimentación eléctrica del UPS %s.
+
+Tiempo en batería: %s
+Batería: %s
+Fecha/Hora: %s`,
+		ups, onBattery.Round(time.Second), status, at.Format("2006-01-02 15:04:05"))
+
+	return e.sendEmail(ChannelSystem, subject, body)
+}
+
+// SendShutdownEmail envía el aviso de apagado inminente. Como el equipo puede
+// perder la energía o la red en cualquier momento, el envío se abandona tras timeout.
+func (e *EmailService) SendShutdownEmail(reason, ip string, uptime, hostUptime, timeout time.Duration) error {
+	subject := fmt.Sprintf("Servidor %s Apagándose", e.appName)
+
+	host := "no disponible"
+	if hostUptime > 0 {
+		host = hostUptime.Round(time.Second).String()
+	}
+
+	body := fmt.Sprintf(`El servidor %s se está apagando.
+
+Motivo: %s
+IP Externa: %s
+Tiempo en servicio: %s
+Uptime del equipo: %s
+Fecha/Hora: %s`,
+		e.appName, reason, ip, uptime.Round(time.Second), host, e.clock.Now().Format("2006-01-02 15:04:05"))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- e.sendEmail(ChannelSystem, subject, body)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("no se pudo enviar el aviso de apagado en %s", timeout)
+	}
+}
+
+// SendPeerSilentEmail envía correo cuando la otra instancia deja de enviar heartbeats.
+// last describe el último estado que reportó (vacío si nunca se recibió uno).
+func (e *EmailService) SendPeerSilentEmail(peer string, silence time.Duration, last string) error {
+	subject := fmt.Sprintf("Instancia %s Sin Reportarse - %s", peer, e.appName)
+
+	if last == "" {
+		last = "No se recibió ningún heartbeat desde el inicio del servicio."
+	}
+
+	body := fmt.Sprintf(`La instancia %s dejó de enviar heartbeats: puede haber perdido la energía o la conexión a internet.
+
+Tiempo sin reportarse: %s
+Fecha/Hora: %s
+
+Último estado reportado:
+%s`,
+		peer, silence.Round(time.Second), e.clock.Now().Format("2006-01-02 15:04:05"), last)
+
+	return e.sendEmail(ChannelServices, subject, body)
+}
+
+// SendPeerRecoveredEmail envía correo cuando la otra instancia vuelve a reportarse
+func (e *EmailService) SendPeerRecoveredEmail(peer string, silence time.Duration, current string) error {
+	subject := fmt.Sprintf("Instancia %s Reportándose - %s", peer, e.appName)
+
+	body := fmt.Sprintf(`La instancia %s volvió a enviar heartbeats.
+
+Tiempo sin reportarse: %s
+Fecha/Hora: %s
+
+Estado reportado:
+%s`,
+		peer, silence.Round(time.Second), e.clock.Now().Format("2006-01-02 15:04:05"), current)
+
+	return e.sendEmail(ChannelServices, subject, body)
+}
+
+// SendPingDownEmail envía correo cuando un check de ping se atrasa o el trabajo informa un fallo.
+// output es el cuerpo del último ping recibido (vacío si no tenía).
+func (e *EmailService) SendPingDownEmail(name, id, reason string, lastPing time.Time, output string) error {
+	subject := fmt.Sprintf("Ping %s Atrasado o Fallido - %s", name, e.appName)
+
+	last := "nunca"
+	if !lastPing.IsZero() {
+		last = lastPing.Format("2006-01-02 15:04:05")
+	}
+
+	body := fmt.Sprintf(`El check de ping %s (%s) está caído.
+
+Motivo: %s
+Último ping: %s
+Fecha/Hora: %s`,
+		name, id, reason, last, e.clock.Now().Format("2006-01-02 15:04:05"))
+
+	if output != "" {
+		body += fmt.Sprintf("\n\nSalida informada:\n%s", output)
+	}
+
+	return e.sendEmail(ChannelServices, subject, body)
+}
+
+// SendPingUpEmail envía correo cuando un check de ping vuelve a recibir pings correctos
+func (e *EmailService) SendPingUpEmail(name, id string, downtime time.Duration) error {
+	subject := fmt.Sprintf("Ping %s Restablecido - %s", name, e.appName)
+
+	body := fmt.Sprintf(`El check de ping %s (%s) volvió a recibir pings correctos.
+
+Tiempo caído: %s
+Fecha/Hora: %s`,
+		name, id, downtime.Round(time.Second), e.clock.Now().Format("2006-01-02 15:04:05"))
+
+	return e.sendEmail(ChannelServices, subject, body)
+}
+
+// ReportSection es un bloque con título dentro del informe periódico
+type ReportSection struct {
+	Title string
+	Body  string
+}
+
+// SendReportEmail envía el informe periódico de estado con las secciones indicadas
+func (e *EmailService) SendReportEmail(ip string, since time.Time, sections []ReportSection) error {
+	subject := fmt.Sprintf("Informe de Estado - %s", e.appName)
+
+	now := e.clock.Now()
+	body := fmt.Sprintf(`Informe de estado de %s.
+
+IP Externa: %s
+Desde: %s
+Hasta: %s`,
+		e.appName, ip, since.Format("2006-01-02 15:04:05"), now.Format("2006-01-02 15:04:05"))
+
+	for _, section := range sections {
+		body += fmt.Sprintf("\n\n%s:\n%s", section.Title, section.Body)
+	}
+
+	return e.sendEmail(ChannelReport, subject, body)
+}
+
+// SendIPChangeEmail envía correo cuando cambia la IP externa de una familia (IPv4 o IPv6).
+// ddnsReport contiene el resultado de la actualización de DNS dinámico (vacío si no está configurado).
+func (e *EmailService) SendIPChangeEmail(family string, newIP string, oldIP string, ddnsReport string) error {
+	subject := fmt.Sprintf("Cambio de %s Externa - %s", family, e.appName)
 	
-	body := fmt.Sprintf(`Se ha detectado un cambio en la IP externa.
+	body := fmt.Sprintf(`Se ha detectado un cambio en la %s externa.
 
 IP Anterior: %s
 IP Nueva: %s
+Fecha/Hora: %s`, 
+		family, oldIP, newIP, e.clock.Now().Format("2006-01-02 15:04:05"))
+
+	body += ddnsSection(ddnsReport)
+	body += "\n\nEl servicio continúa monitoreando la conexión."
+
+	return e.sendEmail(ChannelConnection, subject, body)
+}
+
+// SendIPv6PrefixChangeEmail envía correo cuando cambia el prefijo IPv6 delegado
+func (e *EmailService) SendIPv6PrefixChangeEmail(newPrefix string, oldPrefix string, newIP string, ddnsReport string) error {
+	subject := fmt.Sprintf("Cambio de Prefijo IPv6 - %s", e.appName)
+
+	body := fmt.Sprintf(`Se ha detectado un cambio en el prefijo IPv6 delegado.
+
+Prefijo Anterior: %s
+Prefijo Nuevo: %s
+IPv6 Actual: %s
+Fecha/Hora: %s`,
+		oldPrefix, newPrefix, newIP, e.clock.Now().Format("2006-01-02 15:04:05"))
+
+	body += ddnsSection(ddnsReport)
+	body += "\n\nLas reglas de firewall y registros que usen el prefijo anterior deben actualizarse."
+
+	return e.sendEmail(ChannelConnection, subject, body)
+}
+
+func ddnsSection(report string) string {
+	if report == "" {
+		return ""
+	}
+	return fmt.Sprintf("\n\nActualización de DNS dinámico:\n%s", report)
+}
+
+// SendDNSMismatchEmail envía correo cuando los nombres públicos no resuelven a la IP externa
+func (e *EmailService) SendDNSMismatchEmail(ip string, details string) error {
+	subject := fmt.Sprintf("DNS Desactualizado - %s", e.appName)
+
+	body := fmt.Sprintf(`Los siguientes nombres no resuelven a la IP externa actual.
+
+IP Externa: %s
 Fecha/Hora: %s
 
-El servicio continúa monitoreando la conexión.`, 
-		oldIP, newIP, time.Now().Format("2006-01-02 15:04:05"))
+Discrepancias:
+%s
+
+El servicio continúa verificando la resolución DNS.`,
+		ip, e.clock.Now().Format("2006-01-02 15:04:05"), details)
 
-	return e.sendEmail(subject, body)
+	return e.sendEmail(ChannelConnection, subject, body)
 }
 
-func (e *EmailService) sendEmail(subject, body string) error {
-	utils.WriteLog(fmt.Sprintf("[EMAIL] Intentando enviar correo a %s: %s", e.to, subject), e.debug)
-	
-	addr := fmt.Sprintf("%s:%d", e.host, e.port)
-	auth := smtp.PlainAuth("", e.user, e.password, e.host)
+// SendDNSResolvedEmail envía correo cuando todos los nombres vuelven a resolver a la IP externa
+func (e *EmailService) SendDNSResolvedEmail(ip string) error {
+	subject := fmt.Sprintf("DNS Correcto - %s", e.appName)
 
-	msg := []byte(fmt.Sprintf("To: %s\r\n"+
-		"Subject: %s\r\n"+
-		"MIME-Version: 1.0\r\n"+
-		"Content-Type: text/plain; charset=UTF-8\r\n"+
-		"\r\n"+
-		"%s\r\n", e.to, subject, body))
+	body := fmt.Sprintf(`Todos los nombres verificados resuelven nuevamente a la IP externa.
+
+IP Externa: %s
+Fecha/Hora: %s`,
+		ip, e.clock.Now().Format("2006-01-02 15:04:05"))
+
+	return e.sendEmail(ChannelConnection, subject, body)
+}
+
+// SendCGNATEmail envía correo cuando la IP WAN del router no coincide con la IP pública
+func (e *EmailService) SendCGNATEmail(routerIP, publicIP, reason string) error {
+	subject := fmt.Sprintf("Conexión detrás de CGNAT - %s", e.appName)
+
+	if publicIP == "" {
+		publicIP = "No disponible"
+	}
+
+	body := fmt.Sprintf(`Se detectó que la conexión está detrás de NAT del proveedor (CGNAT).
 
-	err := smtp.SendMail(addr, auth, e.user, []string{e.to}, msg)
+IP WAN del router: %s
+IP pública: %s
+Fecha/Hora: %s
+
+%s
+Los puertos abiertos en el router no serán accesibles desde internet.`,
+		routerIP, publicIP, e.clock.Now().Format("2006-01-02 15:04:05"), reason)
+
+	return e.sendEmail(ChannelConnection, subject, body)
+}
+
+// SendCGNATClearedEmail envía correo cuando la IP WAN del router vuelve a ser la IP pública
+func (e *EmailService) SendCGNATClearedEmail(ip string) error {
+	subject := fmt.Sprintf("Conexión sin CGNAT - %s", e.appName)
+
+	body := fmt.Sprintf(`La IP WAN del router coincide nuevamente con la IP pública.
+
+IP Externa: %s
+Fecha/Hora: %s`,
+		ip, e.clock.Now().Format("2006-01-02 15:04:05"))
+
+	return e.sendEmail(ChannelConnection, subject, body)
+}
+
+func (e *EmailService) sendEmail(channel, subject, body string) error {
+	utils.WriteLog(fmt.Sprintf("[EMAIL] Intentando enviar correo a %s: %s", e.to, subject), e.debug)
+
+	msg, err := e.buildMessage(subject, body)
 	if err != nil {
+		utils.WriteLog(fmt.Sprintf("[EMAIL] Error construyendo correo: %v", err), e.debug)
+		return fmt.Errorf("error construyendo correo: %w", err)
+	}
+
+	if err := e.deliver(channel, msg); err != nil {
 		utils.WriteLog(fmt.Sprintf("[EMAIL] Error enviando correo: %v", err), e.debug)
 		return fmt.Errorf("error enviando correo: %w", err)
 	}
@@ -103,3 +626,38 @@ func (e *EmailService) sendEmail(subject, body string) error {
 	return nil
 }
 
+// buildMessage arma el mensaje RFC 5322 y lo firma con DKIM si está configurado
+func (e *EmailService) buildMessage(subject, body string) ([]byte, error) {
+	// Normalizar saltos de línea a CRLF para que la firma coincida con lo transmitido
+	body = strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n")
+
+	msg := []byte(fmt.Sprintf("From: %s\r\n"+
+		"To: %s\r\n"+
+		"Subject: %s\r\n"+
+		"Date: %s\r\n"+
+		"Message-ID: %s\r\n"+
+		"MIME-Version: 1.0\r\n"+
+		"Content-Type: text/plain; charset=UTF-8\r\n"+
+		"\r\n"+
+		"%s\r\n", e.user, e.to, subject, e.clock.Now().Format(time.RFC1123Z), e.messageID(), body))
+
+	if e.dkim == nil {
+		return msg, nil
+	}
+	return e.dkim.Sign(msg)
+}
+
+// messageID genera un Message-ID único usando el dominio del remitente
+func (e *EmailService) messageID() string {
+	domain := "localhost"
+	if at := strings.LastIndex(e.user, "@"); at >= 0 {
+		domain = e.user[at+1:]
+	}
+	if e.dkim != nil {
+		domain = e.dkim.domain
+	}
+
+	buf := make([]byte, 12)
+	rand.Read(buf)
+	return fmt.Sprintf("<%d.%s@%s>", e.clock.Now().UnixNano(), hex.EncodeToString(buf), domain)
+}
@@ -0,0 +1,135 @@
+// Package gateway consulta al router local la dirección IP de su interfaz WAN
+// mediante UPnP IGD, NAT-PMP (RFC 6886) o PCP (RFC 6887).
+package gateway
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Métodos soportados
+const (
+	MethodUPnP   = "upnp"
+	MethodNATPMP = "natpmp"
+	MethodPCP    = "pcp"
+	MethodAuto   = "auto"
+)
+
+// ValidMethod indica si el método es conocido
+func ValidMethod(method string) bool {
+	switch method {
+	case MethodUPnP, MethodNATPMP, MethodPCP, MethodAuto:
+		return true
+	}
+	return false
+}
+
+// Client consulta al router; si Gateway no es válido se usa la ruta por defecto
+type Client struct {
+	Gateway   netip.Addr
+	Port      int    // puerto NAT-PMP/PCP (por defecto 5351)
+	SSDPAddr  string // destino de M-SEARCH (por defecto 239.255.255.250:1900)
+	routeFile string
+
+	// Asignación PCP vigente; ver pcpExternalIP
+	pcpMu      sync.Mutex
+	pcpNonce   [12]byte
+	pcpAddr    netip.Addr
+	pcpExpires time.Time
+}
+
+// ExternalIP obtiene la IP WAN del router con el método indicado.
+// Con "auto" prueba NAT-PMP y UPnP en ese orden, que son consultas de solo lectura;
+// PCP crea una asignación en el router y solo se usa si se pide explícitamente.
+func (c *Client) ExternalIP(ctx context.Context, method string) (netip.Addr, string, error) {
+	methods := []string{method}
+	if method == MethodAuto || method == "" {
+		methods = []string{MethodNATPMP, MethodUPnP}
+	}
+
+	var errs []string
+	for _, m := range methods {
+		var addr netip.Addr
+		var err error
+		switch m {
+		case MethodUPnP:
+			addr, err = c.upnpExternalIP(ctx)
+		case MethodNATPMP:
+			addr, err = c.natpmpExternalIP(ctx)
+		case MethodPCP:
+			addr, err = c.pcpExternalIP(ctx)
+		default:
+			err = fmt.Errorf("método desconocido")
+		}
+		if err == nil {
+			return addr.Unmap(), m, nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", m, err))
+	}
+	return netip.Addr{}, "", fmt.Errorf("no se pudo consultar el router: %s", strings.Join(errs, "; "))
+}
+
+// gateway retorna la IP del router configurado o la de la ruta por defecto
+func (c *Client) gateway() (netip.Addr, error) {
+	if c.Gateway.IsValid() {
+		return c.Gateway, nil
+	}
+	path := c.routeFile
+	if path == "" {
+		path = "/proc/net/route"
+	}
+	return DefaultGateway(path)
+}
+
+func (c *Client) port() int {
+	if c.Port > 0 {
+		return c.Port
+	}
+	return 5351
+}
+
+// DefaultGateway lee la puerta de enlace IPv4 por defecto desde /proc/net/route
+func DefaultGateway(routeFile string) (netip.Addr, error) {
+	f, err := os.Open(routeFile)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // cabecera
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[1] != "00000000" {
+			continue
+		}
+		raw, err := hex.DecodeString(fields[2])
+		if err != nil || len(raw) != 4 {
+			continue
+		}
+		// El kernel escribe la dirección en orden de host (little-endian)
+		var ip [4]byte
+		binary.BigEndian.PutUint32(ip[:], binary.LittleEndian.Uint32(raw))
+		addr := netip.AddrFrom4(ip)
+		if !addr.IsUnspecified() {
+			return addr, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return netip.Addr{}, err
+	}
+	return netip.Addr{}, fmt.Errorf("no se encontró ruta por defecto")
+}
+
+// IsCGNAT indica si la dirección pertenece al espacio compartido 100.64.0.0/10 (RFC 6598)
+func IsCGNAT(addr netip.Addr) bool {
+	return netip.MustParsePrefix("100.64.0.0/10").Contains(addr.Unmap())
+}
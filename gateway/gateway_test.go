@@ -0,0 +1,339 @@
+package gateway
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDefaultGateway(t *testing.T) {
+	route := "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n" +
+		"eth0\t0000A8C0\t00000000\t0001\t0\t0\t0\t00FFFFFF\t0\t0\t0\n" +
+		"eth0\t00000000\t0101A8C0\t0003\t0\t0\t100\t00000000\t0\t0\t0\n"
+	path := filepath.Join(t.TempDir(), "route")
+	if err := os.WriteFile(path, []byte(route), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Client{routeFile: path}
+	gw, err := c.gateway()
+	if err != nil {
+		t.Fatalf("gateway: %v", err)
+	}
+	if gw != netip.MustParseAddr("192.168.1.1") {
+		t.Errorf("gateway = %s", gw)
+	}
+
+	// Sin ruta por defecto
+	if err := os.WriteFile(path, []byte(strings.Join(strings.Split(route, "\n")[:2], "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.gateway(); err == nil {
+		t.Error("se esperaba error sin ruta por defecto")
+	}
+
+	// Un gateway configurado tiene prioridad sobre la tabla de rutas
+	c.Gateway = netip.MustParseAddr("10.0.0.1")
+	if gw, _ := c.gateway(); gw != c.Gateway {
+		t.Errorf("gateway configurado = %s", gw)
+	}
+}
+
+// fakeRouter responde NAT-PMP y/o PCP por UDP en 127.0.0.1
+type fakeRouter struct {
+	conn   net.PacketConn
+	natpmp bool // responde solicitudes NAT-PMP
+	pcp    bool // responde solicitudes PCP; si no, contesta "versión no soportada" en NAT-PMP
+
+	mu       sync.Mutex
+	external netip.Addr
+	requests [][]byte
+}
+
+func newFakeRouter(t *testing.T, natpmp, pcp bool) (*fakeRouter, *Client) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("sin UDP: %v", err)
+	}
+	r := &fakeRouter{conn: conn, natpmp: natpmp, pcp: pcp, external: netip.MustParseAddr("198.51.100.20")}
+	t.Cleanup(func() { conn.Close() })
+	go r.serve()
+
+	client := &Client{
+		Gateway: netip.MustParseAddr("127.0.0.1"),
+		Port:    conn.LocalAddr().(*net.UDPAddr).Port,
+	}
+	return r, client
+}
+
+func (r *fakeRouter) requestsCopy() [][]byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][]byte(nil), r.requests...)
+}
+
+func (r *fakeRouter) serve() {
+	buf := make([]byte, 1100)
+	for {
+		n, addr, err := r.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		req := append([]byte(nil), buf[:n]...)
+		r.mu.Lock()
+		r.requests = append(r.requests, req)
+		external := r.external
+		r.mu.Unlock()
+
+		var resp []byte
+		switch {
+		case req[0] == natpmpVersion && r.natpmp:
+			resp = make([]byte, 12)
+			resp[1] = 128
+			binary.BigEndian.PutUint32(resp[4:], 1234) // segundos desde el inicio
+			ip := external.As4()
+			copy(resp[8:], ip[:])
+		case req[0] == pcpVersion && r.pcp:
+			resp = make([]byte, 60)
+			resp[0] = pcpVersion
+			resp[1] = pcpResponse | req[1]
+			binary.BigEndian.PutUint32(resp[4:], 600) // otorga menos de lo pedido
+			copy(resp[24:48], req[24:48])             // nonce, protocolo y puertos
+			binary.BigEndian.PutUint16(resp[42:], 40000)
+			ip := external.As16()
+			copy(resp[44:], ip[:])
+		default:
+			// Router solo NAT-PMP ante una solicitud PCP: versión no soportada
+			resp = []byte{natpmpVersion, 128 + req[1], 0, 1, 0, 0, 0, 0}
+		}
+		r.conn.WriteTo(resp, addr)
+	}
+}
+
+func TestNATPMPExternalIP(t *testing.T) {
+	_, client := newFakeRouter(t, true, false)
+
+	addr, method, err := client.ExternalIP(context.Background(), MethodNATPMP)
+	if err != nil {
+		t.Fatalf("ExternalIP: %v", err)
+	}
+	if addr != netip.MustParseAddr("198.51.100.20") || method != MethodNATPMP {
+		t.Errorf("ExternalIP = %s (%s)", addr, method)
+	}
+}
+
+func TestNATPMPResultCode(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("sin UDP: %v", err)
+	}
+	defer conn.Close()
+	go func() {
+		buf := make([]byte, 64)
+		_, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		conn.WriteTo([]byte{0, 128, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0}, addr)
+	}()
+
+	client := &Client{Gateway: netip.MustParseAddr("127.0.0.1"), Port: conn.LocalAddr().(*net.UDPAddr).Port}
+	_, err = client.natpmpExternalIP(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "no autorizado") {
+		t.Fatalf("error = %v", err)
+	}
+}
+
+func TestPCPReusesMapping(t *testing.T) {
+	router, client := newFakeRouter(t, false, true)
+
+	addr, err := client.pcpExternalIP(context.Background())
+	if err != nil {
+		t.Fatalf("pcpExternalIP: %v", err)
+	}
+	if addr != netip.MustParseAddr("198.51.100.20") {
+		t.Errorf("IP externa = %s", addr)
+	}
+
+	// Mientras la asignación está vigente no se vuelve a consultar al router
+	if _, err := client.pcpExternalIP(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(router.requestsCopy()); got != 1 {
+		t.Fatalf("solicitudes PCP = %d, esperado 1", got)
+	}
+	if remaining := time.Until(client.pcpExpires); remaining <= 4*time.Minute || remaining > 5*time.Minute {
+		t.Errorf("la caché debería vencer a la mitad de los 600s otorgados, vence en %v", remaining)
+	}
+
+	// Al vencer se renueva la misma asignación y se obtiene la IP actual
+	router.mu.Lock()
+	router.external = netip.MustParseAddr("198.51.100.21")
+	router.mu.Unlock()
+	client.pcpExpires = time.Now().Add(-time.Second)
+	addr, err = client.pcpExternalIP(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr != netip.MustParseAddr("198.51.100.21") {
+		t.Errorf("IP tras renovar = %s", addr)
+	}
+
+	reqs := router.requestsCopy()
+	if len(reqs) != 2 {
+		t.Fatalf("solicitudes PCP = %d, esperado 2", len(reqs))
+	}
+	for i, req := range reqs {
+		if req[0] != pcpVersion || req[1] != pcpOpcodeMAP || len(req) != 60 {
+			t.Errorf("solicitud %d: cabecera %x", i, req[:4])
+		}
+		// Nunca se elimina la asignación (lifetime 0); expira sola si se deja de renovar
+		if lifetime := binary.BigEndian.Uint32(req[4:]); lifetime != uint32(pcpLifetime/time.Second) {
+			t.Errorf("solicitud %d: lifetime = %d", i, lifetime)
+		}
+		if netip.AddrFrom16([16]byte(req[8:24])).Unmap() != netip.MustParseAddr("127.0.0.1") {
+			t.Errorf("solicitud %d: IP del cliente = %x", i, req[8:24])
+		}
+	}
+	if string(reqs[0][24:44]) != string(reqs[1][24:44]) {
+		t.Error("la renovación debe usar el mismo nonce, protocolo y puerto interno")
+	}
+}
+
+func TestAutoDoesNotUsePCP(t *testing.T) {
+	router, client := newFakeRouter(t, true, true)
+
+	addr, method, err := client.ExternalIP(context.Background(), MethodAuto)
+	if err != nil {
+		t.Fatalf("ExternalIP: %v", err)
+	}
+	if method != MethodNATPMP || addr != netip.MustParseAddr("198.51.100.20") {
+		t.Errorf("ExternalIP = %s (%s)", addr, method)
+	}
+	// Aunque el router soporte PCP, "auto" no debe crear una asignación MAP
+	for _, req := range router.requestsCopy() {
+		if req[0] != natpmpVersion || req[1] != 0 {
+			t.Errorf("solicitud inesperada: %x", req[:2])
+		}
+	}
+}
+
+const deviceXML = `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:InternetGatewayDevice:1</deviceType>
+    <serviceList>
+      <service><serviceType>urn:schemas-upnp-org:service:Layer3Forwarding:1</serviceType><controlURL>/l3f</controlURL></service>
+    </serviceList>
+    <deviceList>
+      <device>
+        <deviceType>urn:schemas-upnp-org:device:WANDevice:1</deviceType>
+        <deviceList>
+          <device>
+            <deviceType>urn:schemas-upnp-org:device:WANConnectionDevice:1</deviceType>
+            <serviceList>
+              <service><serviceType>urn:schemas-upnp-org:service:WANIPConnection:1</serviceType><controlURL>ctl/IPConn</controlURL></service>
+            </serviceList>
+          </device>
+        </deviceList>
+      </device>
+    </deviceList>
+  </device>
+</root>`
+
+// fakeIGD sirve la descripción y el control SOAP de un IGD, y responde M-SEARCH por UDP
+func fakeIGD(t *testing.T, soapResponse string) *Client {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/upnp/desc.xml", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, deviceXML)
+	})
+	mux.HandleFunc("/upnp/ctl/IPConn", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("SOAPAction"); got != `"urn:schemas-upnp-org:service:WANIPConnection:1#GetExternalIPAddress"` {
+			t.Errorf("SOAPAction = %s", got)
+		}
+		io.WriteString(w, soapResponse)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("sin UDP: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if !strings.HasPrefix(string(buf[:n]), "M-SEARCH * HTTP/1.1\r\n") ||
+				!strings.Contains(string(buf[:n]), "MAN: \"ssdp:discover\"") {
+				t.Errorf("M-SEARCH inválido: %q", buf[:n])
+				continue
+			}
+			// Una respuesta sin Location se ignora
+			conn.WriteTo([]byte("HTTP/1.1 200 OK\r\nST: upnp:rootdevice\r\n\r\n"), addr)
+			conn.WriteTo([]byte(fmt.Sprintf("HTTP/1.1 200 OK\r\nCACHE-CONTROL: max-age=120\r\n"+
+				"LOCATION: %s/upnp/desc.xml\r\nST: urn:schemas-upnp-org:device:InternetGatewayDevice:1\r\n\r\n", srv.URL)), addr)
+		}
+	}()
+
+	return &Client{SSDPAddr: conn.LocalAddr().String()}
+}
+
+func TestUPnPExternalIP(t *testing.T) {
+	client := fakeIGD(t, `<?xml version="1.0"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>
+<u:GetExternalIPAddressResponse xmlns:u="urn:schemas-upnp-org:service:WANIPConnection:1">
+<NewExternalIPAddress>100.72.1.9</NewExternalIPAddress>
+</u:GetExternalIPAddressResponse></s:Body></s:Envelope>`)
+
+	addr, method, err := client.ExternalIP(context.Background(), MethodUPnP)
+	if err != nil {
+		t.Fatalf("ExternalIP: %v", err)
+	}
+	if addr != netip.MustParseAddr("100.72.1.9") || method != MethodUPnP {
+		t.Errorf("ExternalIP = %s (%s)", addr, method)
+	}
+	if !IsCGNAT(addr) {
+		t.Error("100.72.1.9 debería detectarse como CGNAT")
+	}
+}
+
+func TestUPnPFault(t *testing.T) {
+	client := fakeIGD(t, `<?xml version="1.0"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>
+<s:Fault><faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring></s:Fault>
+</s:Body></s:Envelope>`)
+
+	_, _, err := client.ExternalIP(context.Background(), MethodUPnP)
+	if err == nil || !strings.Contains(err.Error(), "UPnPError") {
+		t.Fatalf("error = %v", err)
+	}
+}
+
+func TestValidMethod(t *testing.T) {
+	for _, m := range []string{MethodUPnP, MethodNATPMP, MethodPCP, MethodAuto} {
+		if !ValidMethod(m) {
+			t.Errorf("ValidMethod(%s) = false", m)
+		}
+	}
+	if ValidMethod("igd") {
+		t.Error("ValidMethod(igd) = true")
+	}
+}
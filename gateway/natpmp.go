@@ -0,0 +1,187 @@
+package gateway
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"time"
+)
+
+const (
+	natpmpVersion = 0
+	pcpVersion    = 2
+	pcpOpcodeMAP  = 1
+	pcpResponse   = 0x80
+)
+
+var natpmpResultCodes = map[uint16]string{
+	1: "versión no soportada",
+	2: "no autorizado",
+	3: "falla de red",
+	4: "sin recursos",
+	5: "opcode no soportado",
+}
+
+var pcpResultCodes = map[byte]string{
+	1:  "versión no soportada",
+	2:  "no autorizado",
+	3:  "solicitud mal formada",
+	4:  "opcode no soportado",
+	5:  "opción no soportada",
+	6:  "opción mal formada",
+	7:  "falla de red",
+	8:  "sin recursos",
+	9:  "protocolo no soportado",
+	10: "cuota excedida",
+	11: "no se puede asignar el puerto externo",
+	12: "dirección no coincide",
+	13: "demasiados peers",
+}
+
+// exchangeUDP envía una solicitud al router y espera la respuesta, con reintentos
+// de intervalo creciente como indican NAT-PMP y PCP
+func (c *Client) exchangeUDP(ctx context.Context, req []byte, valid func([]byte) bool) ([]byte, error) {
+	gw, err := c.gateway()
+	if err != nil {
+		return nil, err
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp4", net.JoinHostPort(gw.String(), strconv.Itoa(c.port())))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	// Cancelar ctx cierra la conexión e interrumpe la espera de la respuesta
+	defer context.AfterFunc(ctx, func() { conn.Close() })()
+
+	buf := make([]byte, 1100)
+	wait := 250 * time.Millisecond
+	for attempt := 0; attempt < 4; attempt++ {
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+
+		deadline := time.Now().Add(wait)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		conn.SetReadDeadline(deadline)
+
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				break
+			}
+			if valid(buf[:n]) {
+				return buf[:n], nil
+			}
+		}
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		wait *= 2
+	}
+	return nil, fmt.Errorf("sin respuesta de %s", gw)
+}
+
+// natpmpExternalIP envía la solicitud "external address" de NAT-PMP (opcode 0)
+func (c *Client) natpmpExternalIP(ctx context.Context) (netip.Addr, error) {
+	req := []byte{natpmpVersion, 0}
+
+	resp, err := c.exchangeUDP(ctx, req, func(b []byte) bool {
+		return len(b) >= 12 && b[0] == natpmpVersion && b[1] == 128
+	})
+	if err != nil {
+		return netip.Addr{}, err
+	}
+
+	if code := binary.BigEndian.Uint16(resp[2:]); code != 0 {
+		if msg, ok := natpmpResultCodes[code]; ok {
+			return netip.Addr{}, fmt.Errorf("router respondió: %s", msg)
+		}
+		return netip.Addr{}, fmt.Errorf("router respondió código %d", code)
+	}
+	return netip.AddrFrom4([4]byte(resp[8:12])), nil
+}
+
+// pcpLifetime es la duración pedida para la asignación MAP usada para conocer la IP externa
+const pcpLifetime = 20 * time.Minute
+
+// pcpExternalIP consulta la IP externa mediante una asignación MAP. PCP no tiene una
+// consulta de solo lectura, así que se mantiene una única asignación (mismo nonce y
+// puerto interno) que se renueva al vencer la mitad de su duración; mientras tanto se
+// responde con la IP en caché. La asignación expira sola si se deja de renovar.
+func (c *Client) pcpExternalIP(ctx context.Context) (netip.Addr, error) {
+	c.pcpMu.Lock()
+	defer c.pcpMu.Unlock()
+
+	if c.pcpAddr.IsValid() && time.Now().Before(c.pcpExpires) {
+		return c.pcpAddr, nil
+	}
+
+	if c.pcpNonce == ([12]byte{}) {
+		if _, err := rand.Read(c.pcpNonce[:]); err != nil {
+			return netip.Addr{}, err
+		}
+	}
+	nonce := c.pcpNonce
+
+	// La dirección del cliente debe ser la IP local usada para llegar al router
+	gw, err := c.gateway()
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	probe, err := net.Dial("udp4", net.JoinHostPort(gw.String(), strconv.Itoa(c.port())))
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	local := probe.LocalAddr().(*net.UDPAddr).AddrPort().Addr()
+	probe.Close()
+	clientIP := local.Unmap().As16()
+
+	// El puerto interno solo identifica la asignación; se usa uno alto fijo
+	const internalPort = 49152 + 5351
+
+	req := make([]byte, 60)
+	req[0] = pcpVersion
+	req[1] = pcpOpcodeMAP
+	binary.BigEndian.PutUint32(req[4:], uint32(pcpLifetime/time.Second))
+	copy(req[8:24], clientIP[:])
+	copy(req[24:36], nonce[:])
+	req[36] = 17 // UDP
+	binary.BigEndian.PutUint16(req[40:], internalPort)
+	// Puerto y dirección externa sugeridos en cero: el router elige
+
+	resp, err := c.exchangeUDP(ctx, req, func(b []byte) bool {
+		// Un router solo NAT-PMP responde con versión 0 y "versión no soportada"
+		if len(b) >= 4 && b[0] == natpmpVersion {
+			return true
+		}
+		return len(b) >= 60 && b[0] == pcpVersion && b[1] == pcpResponse|pcpOpcodeMAP &&
+			string(b[24:36]) == string(nonce[:])
+	})
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	if resp[0] == natpmpVersion {
+		return netip.Addr{}, fmt.Errorf("el router no soporta PCP (solo NAT-PMP)")
+	}
+	if code := resp[3]; code != 0 {
+		if msg, ok := pcpResultCodes[code]; ok {
+			return netip.Addr{}, fmt.Errorf("router respondió: %s", msg)
+		}
+		return netip.Addr{}, fmt.Errorf("router respondió código %d", code)
+	}
+
+	// El router puede otorgar menos tiempo del pedido
+	granted := time.Duration(binary.BigEndian.Uint32(resp[4:])) * time.Second
+	c.pcpAddr = netip.AddrFrom16([16]byte(resp[44:60])).Unmap()
+	c.pcpExpires = time.Now().Add(granted / 2)
+	return c.pcpAddr, nil
+}
@@ -0,0 +1,217 @@
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const ssdpMulticast = "239.255.255.250:1900"
+
+// Tipos de búsqueda SSDP, del más específico al más general
+var ssdpSearchTargets = []string{
+	"urn:schemas-upnp-org:device:InternetGatewayDevice:1",
+	"urn:schemas-upnp-org:device:InternetGatewayDevice:2",
+	"urn:schemas-upnp-org:service:WANIPConnection:1",
+}
+
+// Servicios que implementan GetExternalIPAddress
+var wanServiceTypes = []string{
+	"urn:schemas-upnp-org:service:WANIPConnection:2",
+	"urn:schemas-upnp-org:service:WANIPConnection:1",
+	"urn:schemas-upnp-org:service:WANPPPConnection:1",
+}
+
+// upnpDevice es la parte necesaria de la descripción XML del dispositivo
+type upnpDevice struct {
+	URLBase string      `xml:"URLBase"`
+	Device  upnpDevNode `xml:"device"`
+}
+
+type upnpDevNode struct {
+	Services []upnpService `xml:"serviceList>service"`
+	Devices  []upnpDevNode `xml:"deviceList>device"`
+}
+
+type upnpService struct {
+	ServiceType string `xml:"serviceType"`
+	ControlURL  string `xml:"controlURL"`
+}
+
+// findService busca recursivamente el servicio del tipo indicado
+func (d upnpDevNode) findService(serviceType string) (upnpService, bool) {
+	for _, s := range d.Services {
+		if s.ServiceType == serviceType {
+			return s, true
+		}
+	}
+	for _, child := range d.Devices {
+		if s, ok := child.findService(serviceType); ok {
+			return s, true
+		}
+	}
+	return upnpService{}, false
+}
+
+// upnpExternalIP descubre el IGD por SSDP y llama GetExternalIPAddress
+func (c *Client) upnpExternalIP(ctx context.Context) (netip.Addr, error) {
+	location, err := c.ssdpDiscover(ctx)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+
+	controlURL, serviceType, err := c.findWANService(ctx, location)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+
+	return soapGetExternalIP(ctx, controlURL, serviceType)
+}
+
+// ssdpDiscover envía M-SEARCH y retorna la URL de descripción del primer IGD que responde
+func (c *Client) ssdpDiscover(ctx context.Context) (string, error) {
+	dest := c.SSDPAddr
+	if dest == "" {
+		dest = ssdpMulticast
+	}
+	addr, err := net.ResolveUDPAddr("udp4", dest)
+	if err != nil {
+		return "", err
+	}
+
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	for _, st := range ssdpSearchTargets {
+		msg := "M-SEARCH * HTTP/1.1\r\n" +
+			"HOST: " + ssdpMulticast + "\r\n" +
+			"MAN: \"ssdp:discover\"\r\n" +
+			"MX: 2\r\n" +
+			"ST: " + st + "\r\n\r\n"
+		if _, err := conn.WriteToUDP([]byte(msg), addr); err != nil {
+			return "", err
+		}
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetReadDeadline(deadline)
+	defer context.AfterFunc(ctx, func() { conn.Close() })()
+
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return "", fmt.Errorf("ningún IGD respondió a SSDP: %w", err)
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		if location := resp.Header.Get("Location"); location != "" {
+			return location, nil
+		}
+	}
+}
+
+// findWANService descarga la descripción del dispositivo y retorna la URL de control
+func (c *Client) findWANService(ctx context.Context, location string) (string, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return "", "", err
+	}
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	var desc upnpDevice
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&desc); err != nil {
+		return "", "", fmt.Errorf("descripción UPnP inválida: %w", err)
+	}
+
+	base, err := url.Parse(location)
+	if err != nil {
+		return "", "", err
+	}
+	if desc.URLBase != "" {
+		if b, err := url.Parse(desc.URLBase); err == nil {
+			base = b
+		}
+	}
+
+	for _, st := range wanServiceTypes {
+		if svc, ok := desc.Device.findService(st); ok {
+			ref, err := url.Parse(svc.ControlURL)
+			if err != nil {
+				return "", "", err
+			}
+			return base.ResolveReference(ref).String(), st, nil
+		}
+	}
+	return "", "", fmt.Errorf("el IGD no expone WANIPConnection ni WANPPPConnection")
+}
+
+type soapEnvelope struct {
+	Body struct {
+		Response struct {
+			ExternalIP string `xml:"NewExternalIPAddress"`
+		} `xml:"GetExternalIPAddressResponse"`
+		Fault *struct {
+			String string `xml:"faultstring"`
+		} `xml:"Fault"`
+	} `xml:"Body"`
+}
+
+// soapGetExternalIP invoca la acción GetExternalIPAddress del servicio WAN
+func soapGetExternalIP(ctx context.Context, controlURL, serviceType string) (netip.Addr, error) {
+	body := `<?xml version="1.0"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">` +
+		`<s:Body><u:GetExternalIPAddress xmlns:u="` + serviceType + `"></u:GetExternalIPAddress></s:Body>` +
+		`</s:Envelope>`
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, controlURL, strings.NewReader(body))
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", `"`+serviceType+`#GetExternalIPAddress"`)
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	defer resp.Body.Close()
+
+	var env soapEnvelope
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&env); err != nil {
+		return netip.Addr{}, fmt.Errorf("respuesta SOAP inválida (status %d): %w", resp.StatusCode, err)
+	}
+	if env.Body.Fault != nil {
+		return netip.Addr{}, fmt.Errorf("el IGD respondió error: %s", env.Body.Fault.String)
+	}
+
+	addr, err := netip.ParseAddr(strings.TrimSpace(env.Body.Response.ExternalIP))
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("el IGD no reportó una IP externa válida: %q", env.Body.Response.ExternalIP)
+	}
+	return addr, nil
+}
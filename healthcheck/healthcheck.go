@@ -1,58 +1,362 @@
 package healthcheck
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"orgmserver/utils"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
+// Tipos de señal compatibles con healthchecks.io
+const (
+	SignalSuccess = "success"
+	SignalStart   = "start"
+	SignalFail    = "fail"
+	SignalExit    = "exit"
+)
+
+// maxQueue limita las señales pendientes por URL mientras el destino no responde
+const maxQueue = 50
+
+// Espera entre rondas de reintento de un destino que no responde; se duplica en
+// cada ronda hasta maxRetryWait
+var (
+	retryWait    = 30 * time.Second
+	maxRetryWait = 5 * time.Minute
+)
+
+// Options configura el envío de healthchecks
+type Options struct {
+	URLs    []string
+	Method  string            // GET, HEAD, POST o PUT; el cuerpo solo se envía con POST y PUT
+	Headers map[string]string // encabezados adicionales
+	Retries int               // intentos por envío antes de esperar la siguiente ronda
+	// Signals agrega /start, /fail y /<código> a la URL; deshabilitado solo se
+	// envían pings de éxito a la URL tal cual
+	Signals bool
+}
+
+// Signal es una señal a enviar con su cuerpo de diagnóstico
+type Signal struct {
+	Kind       string
+	ExitStatus int // solo para SignalExit
+	Body       string
+	Time       time.Time
+}
+
+// path retorna el sufijo de la URL para la señal
+func (s Signal) path() string {
+	switch s.Kind {
+	case SignalStart:
+		return "/start"
+	case SignalFail:
+		return "/fail"
+	case SignalExit:
+		return "/" + strconv.Itoa(s.ExitStatus)
+	}
+	return ""
+}
+
 type HealthcheckService struct {
-	url    string
-	client *http.Client
-	debug  bool
+	opts    Options
+	client  *http.Client
+	debug   bool
+	targets []*target
+
+	// ctx cancela los envíos en curso cuando vence el plazo de Close
+	ctx     context.Context
+	cancel  context.CancelFunc
+	mu      sync.Mutex
+	closing chan struct{}
+	closed  bool
+	wg      sync.WaitGroup
 }
 
-func NewHealthcheckService(url string, debug bool) *HealthcheckService {
-	return &HealthcheckService{
-		url: url,
+// target es una URL con su cola de señales pendientes, atendida por su propia
+// goroutine para que un destino caído no demore a los demás
+type target struct {
+	url     string
+	mu      sync.Mutex
+	queue   []Signal
+	wake    chan struct{}
+	started bool
+}
+
+func NewHealthcheckService(opts Options, debug bool) *HealthcheckService {
+	if opts.Method == "" {
+		opts.Method = http.MethodGet
+	}
+	if opts.Retries <= 0 {
+		opts.Retries = 1
+	}
+	h := &HealthcheckService{
+		opts: opts,
 		client: &http.Client{
-			Timeout: 5 * time.Second,
+			Timeout: 10 * time.Second,
 		},
-		debug: debug,
+		debug:   debug,
+		closing: make(chan struct{}),
+	}
+	h.ctx, h.cancel = context.WithCancel(context.Background())
+	for _, u := range opts.URLs {
+		h.targets = append(h.targets, &target{url: u, wake: make(chan struct{}, 1)})
+	}
+	return h
+}
+
+// Enabled indica si hay URLs configuradas
+func (h *HealthcheckService) Enabled() bool {
+	return len(h.targets) > 0
+}
+
+// Send encola una señal para todas las URLs. Las señales se entregan en orden; si un
+// destino no responde quedan pendientes y se reintentan con espera creciente, de
+// modo que un /fail registrado sin internet llega cuando se recupera la conexión.
+func (h *HealthcheckService) Send(s Signal) {
+	if !h.opts.Signals {
+		// Sin señales solo se informa el éxito, como un ping simple
+		if s.Kind != SignalSuccess && !(s.Kind == SignalExit && s.ExitStatus == 0) {
+			return
+		}
+		s.Kind = SignalSuccess
+	}
+	if s.Time.IsZero() {
+		s.Time = time.Now()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	for _, t := range h.targets {
+		if !t.started {
+			t.started = true
+			h.wg.Add(1)
+			go h.run(t)
+		}
+		t.enqueue(s)
+	}
+}
+
+// Close deja de aceptar señales y espera que cada destino entregue las pendientes
+// hasta que venza ctx. Al cerrar, cada señal se intenta una sola vez: las que fallan
+// o quedan sin enviar al vencer el plazo se descartan.
+func (h *HealthcheckService) Close(ctx context.Context) error {
+	h.mu.Lock()
+	if !h.closed {
+		h.closed = true
+		close(h.closing)
+	}
+	h.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		h.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// enqueue agrega la señal; las repetidas consecutivas se agrupan para que una caída
+// larga no llene la cola. De un /fail repetido se conserva el primero, que marca el
+// inicio del problema.
+func (t *target) enqueue(s Signal) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if n := len(t.queue); n > 0 {
+		last := t.queue[n-1]
+		if last.Kind == s.Kind && last.ExitStatus == s.ExitStatus {
+			if s.Kind != SignalFail {
+				t.queue[n-1] = s
+			}
+			return
+		}
+	}
+	// /start solo tiene sentido si se entrega enseguida
+	if s.Kind == SignalStart && len(t.queue) > 0 {
+		return
+	}
+
+	t.queue = append(t.queue, s)
+	if len(t.queue) > maxQueue {
+		t.queue = t.queue[len(t.queue)-maxQueue:]
+	}
+
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (t *target) head() (Signal, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.queue) == 0 {
+		return Signal{}, false
+	}
+	return t.queue[0], true
+}
+
+func (t *target) pop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.queue) > 0 {
+		t.queue = t.queue[1:]
+	}
+}
+
+// run entrega la cola de un destino hasta que se cierra el servicio
+func (h *HealthcheckService) run(t *target) {
+	defer h.wg.Done()
+
+	wait := retryWait
+	for {
+		s, ok := t.head()
+		if !ok {
+			select {
+			case <-t.wake:
+				continue
+			case <-h.closing:
+				return
+			}
+		}
+
+		err := h.deliver(t.url, s)
+		if err == nil {
+			t.pop()
+			wait = retryWait
+			continue
+		}
+		if permanent, ok := err.(permanentError); ok {
+			// Un 4xx no se corrige reintentando: se descarta para no bloquear la cola
+			utils.WriteLog(fmt.Sprintf("[HEALTHCHECK] Señal %s descartada para %s: %v", s.Kind, t.url, permanent.err), h.debug)
+			t.pop()
+			continue
+		}
+
+		select {
+		case <-h.closing:
+			utils.WriteLog(fmt.Sprintf("[HEALTHCHECK] Señal %s descartada al cerrar, %s no responde: %v", s.Kind, t.url, err), h.debug)
+			t.pop()
+			continue
+		default:
+		}
+
+		utils.WriteLog(fmt.Sprintf("[HEALTHCHECK] %s no responde, se reintentará en %s: %v", t.url, wait, err), h.debug)
+		select {
+		case <-time.After(wait):
+		case <-t.wake:
+			// Llegó una señal nueva: se reintenta enseguida, respetando el orden
+		case <-h.closing:
+		}
+		if wait < maxRetryWait {
+			wait *= 2
+		}
+	}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+// deliver envía una señal con reintentos y espera exponencial (1s, 2s, 4s...)
+func (h *HealthcheckService) deliver(base string, s Signal) error {
+	var err error
+	backoff := time.Second
+	for attempt := 1; attempt <= h.opts.Retries; attempt++ {
+		if err = h.send(base, s); err == nil {
+			return nil
+		}
+		if _, ok := err.(permanentError); ok {
+			return err
+		}
+		if attempt < h.opts.Retries {
+			select {
+			case <-time.After(backoff):
+			case <-h.closing:
+				return err
+			}
+			backoff *= 2
+		}
 	}
+	return err
 }
 
-// SendHealthcheck envía una solicitud HTTP al healthcheck URL
-func (h *HealthcheckService) SendHealthcheck() error {
-	if h.url == "" {
-		return nil // No configurado, no hacer nada
+// send hace una solicitud HTTP
+func (h *HealthcheckService) send(base string, s Signal) error {
+	target, err := signalURL(base, s)
+	if err != nil {
+		return permanentError{err}
 	}
 
-	utils.WriteLog(fmt.Sprintf("[HEALTHCHECK] Enviando healthcheck a %s", h.url), h.debug)
+	utils.WriteLog(fmt.Sprintf("[HEALTHCHECK] Enviando %s a %s", s.Kind, target), h.debug)
 
-	req, err := http.NewRequest("GET", h.url, nil)
+	var body io.Reader
+	if h.opts.Method == http.MethodPost || h.opts.Method == http.MethodPut {
+		body = strings.NewReader(s.Body)
+	}
+	req, err := http.NewRequestWithContext(h.ctx, h.opts.Method, target, body)
 	if err != nil {
-		utils.WriteLog(fmt.Sprintf("[HEALTHCHECK] Error creando request: %v", err), h.debug)
-		return fmt.Errorf("error creando request: %w", err)
+		return permanentError{fmt.Errorf("error creando request: %w", err)}
 	}
 
 	// Agregar User-Agent
 	req.Header.Set("User-Agent", "ORGMServer-Healthcheck/1.0")
+	if body != nil {
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	for k, v := range h.opts.Headers {
+		req.Header.Set(k, v)
+	}
 
 	resp, err := h.client.Do(req)
 	if err != nil {
-		utils.WriteLog(fmt.Sprintf("[HEALTHCHECK] Error enviando request: %v", err), h.debug)
 		return fmt.Errorf("error enviando healthcheck: %w", err)
 	}
 	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
 
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
 		utils.WriteLog(fmt.Sprintf("[HEALTHCHECK] Healthcheck exitoso: status %d", resp.StatusCode), h.debug)
 		return nil
 	}
 
-	utils.WriteLog(fmt.Sprintf("[HEALTHCHECK] Healthcheck recibió status code: %d", resp.StatusCode), h.debug)
-	return fmt.Errorf("healthcheck recibió status code: %d", resp.StatusCode)
+	err = fmt.Errorf("healthcheck recibió status code: %d", resp.StatusCode)
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+		return permanentError{err}
+	}
+	return err
 }
 
+// signalURL agrega el sufijo de la señal a la ruta, conservando la query
+func signalURL(base string, s Signal) (string, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("URL de healthcheck inválida: %w", err)
+	}
+	if suffix := s.path(); suffix != "" {
+		u.Path = strings.TrimRight(u.Path, "/") + suffix
+		u.RawPath = ""
+	}
+	return u.String(), nil
+}
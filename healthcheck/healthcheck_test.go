@@ -0,0 +1,256 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// endpoint simula healthchecks.io: registra las solicitudes y responde con los
+// códigos configurados por ruta (el último se repite; sin códigos responde 200)
+type endpoint struct {
+	mu     sync.Mutex
+	status map[string][]int
+	got    []string // ruta y cuerpo de cada solicitud
+	ok     []string // rutas entregadas con éxito, en orden
+}
+
+func (e *endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.got = append(e.got, r.URL.Path+" "+string(body))
+	code := http.StatusOK
+	if seq := e.status[r.URL.Path]; len(seq) > 0 {
+		code = seq[0]
+		if len(seq) > 1 {
+			e.status[r.URL.Path] = seq[1:]
+		}
+	}
+	if code < 300 {
+		e.ok = append(e.ok, r.URL.Path)
+	}
+	w.WriteHeader(code)
+}
+
+func (e *endpoint) set(path string, codes ...int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.status[path] = codes
+}
+
+func (e *endpoint) requests() ([]string, []string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.got...), append([]string(nil), e.ok...)
+}
+
+func newEndpoint(t *testing.T) (*endpoint, string) {
+	t.Helper()
+	e := &endpoint{status: make(map[string][]int)}
+	srv := httptest.NewServer(e)
+	t.Cleanup(srv.Close)
+
+	old := retryWait
+	retryWait = 10 * time.Millisecond
+	t.Cleanup(func() { retryWait = old })
+	return e, srv.URL + "/ping/abc"
+}
+
+// waitFor espera hasta 5 segundos que se cumpla cond
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("tiempo agotado esperando %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func closeService(t *testing.T, h *HealthcheckService) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
+
+func TestEnqueueCoalesces(t *testing.T) {
+	at := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tg := &target{wake: make(chan struct{}, 1)}
+	kinds := func() string {
+		var list []string
+		for _, s := range tg.queue {
+			list = append(list, s.Kind+s.path()+" "+s.Body)
+		}
+		return strings.Join(list, ", ")
+	}
+
+	// De un /fail repetido se conserva el primero; del éxito, el último
+	tg.enqueue(Signal{Kind: SignalFail, Body: "primero", Time: at})
+	tg.enqueue(Signal{Kind: SignalFail, Body: "segundo", Time: at.Add(time.Minute)})
+	tg.enqueue(Signal{Kind: SignalSuccess, Body: "a"})
+	tg.enqueue(Signal{Kind: SignalSuccess, Body: "b"})
+	// /start detrás de una cola pendiente llegaría tarde
+	tg.enqueue(Signal{Kind: SignalStart, Body: "inicio"})
+	tg.enqueue(Signal{Kind: SignalExit, ExitStatus: 3, Body: "x"})
+	tg.enqueue(Signal{Kind: SignalExit, ExitStatus: 3, Body: "y"})
+	tg.enqueue(Signal{Kind: SignalExit, ExitStatus: 4, Body: "z"})
+
+	if got, want := kinds(), "fail/fail primero, success b, exit/3 y, exit/4 z"; got != want {
+		t.Fatalf("cola = %s, se esperaba %s", got, want)
+	}
+	if !tg.queue[0].Time.Equal(at) {
+		t.Errorf("hora del /fail = %s, se esperaba la del primero", tg.queue[0].Time)
+	}
+
+	// Con la cola vacía /start se encola
+	tg.queue = nil
+	tg.enqueue(Signal{Kind: SignalStart})
+	if kinds() != "start/start " {
+		t.Errorf("cola = %s", kinds())
+	}
+
+	// La cola conserva las señales más recientes
+	tg.queue = nil
+	for i := 0; i < maxQueue+10; i++ {
+		tg.enqueue(Signal{Kind: SignalExit, ExitStatus: i % 256, Body: fmt.Sprint(i)})
+	}
+	if len(tg.queue) != maxQueue || tg.queue[0].Body != "10" || tg.queue[maxQueue-1].Body != fmt.Sprint(maxQueue+9) {
+		t.Errorf("cola de %d señales, desde %s hasta %s", len(tg.queue), tg.queue[0].Body, tg.queue[len(tg.queue)-1].Body)
+	}
+}
+
+func TestDeliveryInOrderAfterOutage(t *testing.T) {
+	e, url := newEndpoint(t)
+	e.set("/ping/abc/fail", http.StatusServiceUnavailable)
+	h := NewHealthcheckService(Options{URLs: []string{url}, Method: http.MethodPost, Signals: true}, false)
+
+	h.Send(Signal{Kind: SignalFail, Body: "sin internet"})
+	waitFor(t, "el primer intento", func() bool { got, _ := e.requests(); return len(got) > 0 })
+	h.Send(Signal{Kind: SignalSuccess, Body: "recuperado"})
+	h.Send(Signal{Kind: SignalExit, ExitStatus: 2, Body: "código 2"})
+
+	// Mientras el destino falla no se entrega nada detrás del /fail
+	time.Sleep(50 * time.Millisecond)
+	if _, ok := e.requests(); len(ok) != 0 {
+		t.Fatalf("entregadas durante la caída: %v", ok)
+	}
+
+	e.set("/ping/abc/fail", http.StatusOK)
+	waitFor(t, "la entrega de la cola", func() bool { _, ok := e.requests(); return len(ok) == 3 })
+	got, ok := e.requests()
+	if want := "[/ping/abc/fail /ping/abc /ping/abc/2]"; fmt.Sprint(ok) != want {
+		t.Fatalf("orden de entrega = %v, se esperaba %s", ok, want)
+	}
+	if last := got[len(got)-1]; last != "/ping/abc/2 código 2" {
+		t.Errorf("última solicitud = %q", last)
+	}
+	for _, r := range got {
+		if strings.HasPrefix(r, "/ping/abc/fail ") && r != "/ping/abc/fail sin internet" {
+			t.Errorf("reintento con otro cuerpo: %q", r)
+		}
+	}
+	closeService(t, h)
+}
+
+func TestPermanentErrorsAreDropped(t *testing.T) {
+	e, url := newEndpoint(t)
+	e.set("/ping/abc/start", http.StatusNotFound)
+	e.set("/ping/abc/fail", http.StatusInternalServerError, http.StatusTooManyRequests, http.StatusOK)
+	h := NewHealthcheckService(Options{URLs: []string{url}, Signals: true}, false)
+
+	// Un 4xx se descarta sin reintentar; 5xx y 429 se reintentan
+	h.Send(Signal{Kind: SignalStart})
+	waitFor(t, "el /start", func() bool { got, _ := e.requests(); return len(got) == 1 })
+	h.Send(Signal{Kind: SignalFail})
+	waitFor(t, "el /fail", func() bool { _, ok := e.requests(); return len(ok) == 1 })
+	h.Send(Signal{Kind: SignalSuccess})
+	waitFor(t, "el éxito", func() bool { _, ok := e.requests(); return len(ok) == 2 })
+
+	got, ok := e.requests()
+	if want := "[/ping/abc/start  /ping/abc/fail  /ping/abc/fail  /ping/abc/fail  /ping/abc ]"; fmt.Sprint(got) != want {
+		t.Errorf("solicitudes = %v, se esperaba %s", got, want)
+	}
+	if fmt.Sprint(ok) != "[/ping/abc/fail /ping/abc]" {
+		t.Errorf("entregadas = %v", ok)
+	}
+	closeService(t, h)
+}
+
+func TestSendWithoutSignals(t *testing.T) {
+	e, url := newEndpoint(t)
+	h := NewHealthcheckService(Options{URLs: []string{url + "?rid=1"}, Method: http.MethodHead}, false)
+
+	h.Send(Signal{Kind: SignalStart})
+	h.Send(Signal{Kind: SignalFail})
+	h.Send(Signal{Kind: SignalExit, ExitStatus: 1})
+	h.Send(Signal{Kind: SignalExit, ExitStatus: 0, Body: "sin cuerpo con HEAD"})
+	closeService(t, h)
+
+	// Solo el código 0 se envía, como éxito a la URL tal cual y sin cuerpo
+	if got, _ := e.requests(); fmt.Sprint(got) != "[/ping/abc ]" {
+		t.Fatalf("solicitudes = %v", got)
+	}
+}
+
+func TestCloseDeadline(t *testing.T) {
+	received := make(chan struct{}, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		// Un destino que acepta la conexión y no responde
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	h := NewHealthcheckService(Options{URLs: []string{srv.URL}, Signals: true}, false)
+	h.Send(Signal{Kind: SignalFail})
+	h.Send(Signal{Kind: SignalSuccess})
+	<-received
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := h.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Close = %v, se esperaba el vencimiento del plazo", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Close tardó %s", elapsed)
+	}
+
+	// Cerrado, las señales nuevas se ignoran y las pendientes no se envían
+	h.Send(Signal{Kind: SignalSuccess})
+	time.Sleep(50 * time.Millisecond)
+	if n := len(received); n != 0 {
+		t.Errorf("%d solicitudes después de cerrar", n)
+	}
+	if err := h.Close(context.Background()); err != nil {
+		t.Errorf("segundo Close = %v", err)
+	}
+}
+
+func TestCloseDeliversPending(t *testing.T) {
+	e, url := newEndpoint(t)
+	e.set("/ping/abc/fail", http.StatusBadGateway)
+	h := NewHealthcheckService(Options{URLs: []string{url}, Signals: true, Retries: 3}, false)
+
+	h.Send(Signal{Kind: SignalFail})
+	waitFor(t, "el primer intento", func() bool { got, _ := e.requests(); return len(got) > 0 })
+	h.Send(Signal{Kind: SignalSuccess})
+
+	// Al cerrar cada señal se intenta una vez: el /fail se descarta y el éxito se entrega
+	closeService(t, h)
+	if _, ok := e.requests(); fmt.Sprint(ok) != "[/ping/abc]" {
+		t.Fatalf("entregadas = %v", ok)
+	}
+}
@@ -1,12 +1,29 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
+	"orgmserver/api"
+	"orgmserver/checks"
 	"orgmserver/config"
+	"orgmserver/ddns"
+	"orgmserver/detector"
+	"orgmserver/discovery"
+	"orgmserver/dnscheck"
+	"orgmserver/docker"
 	"orgmserver/email"
+	"orgmserver/gateway"
+	"orgmserver/metrics"
 	"orgmserver/monitor"
+	"orgmserver/peer"
+	"orgmserver/pings"
+	"orgmserver/probe"
+	"orgmserver/resources"
+	"orgmserver/speedtest"
+	"orgmserver/systemd"
+	"orgmserver/ups"
 	"orgmserver/utils"
 	"os"
 	"os/signal"
@@ -28,8 +45,28 @@ func main() {
 	utils.WriteLog(fmt.Sprintf("[MAIN] Iniciando %s", cfg.AppName), *debug)
 	utils.WriteLog("[MAIN] Configuración cargada correctamente", *debug)
 
-	// Obtener IP externa
-	ip, err := utils.GetExternalIP()
+	// Estado persistente; el bloqueo impide dos instancias sobre el mismo archivo
+	store, err := utils.OpenStateStore(cfg.StateFilePath, *debug)
+	if err != nil {
+		log.Fatalf("Error abriendo archivo de estado: %v", err)
+	}
+	defer store.Close()
+
+	// Notificación a systemd (Type=notify); fuera de systemd no hace nada
+	notifier := systemd.NewNotifier()
+	if notifier.Enabled() {
+		notifier.Status("Iniciando")
+		utils.WriteLog(fmt.Sprintf("[MAIN] Notificación a systemd habilitada (watchdog: %s)", notifier.WatchdogInterval()), *debug)
+	}
+
+	// Proveedores de IP externa consultados en paralelo con regla de mayoría
+	if err := configureIPDiscovery(cfg); err != nil {
+		log.Fatalf("Error configurando proveedores de IP: %v", err)
+	}
+
+	// Obtener IP externa (IPv4 e IPv6 por separado)
+	ips, err := discovery.GetExternalIPs(context.Background(), cfg.IPv6Enabled)
+	ip := ips.String()
 	if err != nil {
 		utils.WriteLog("[MAIN] Error obteniendo IP externa, continuando sin IP", *debug)
 		ip = "No disponible"
@@ -48,45 +85,261 @@ func main() {
 		*debug,
 	)
 
-	// Enviar correo de inicio (siempre "iniciado")
-	if err := emailSvc.SendStartupEmail(ip); err != nil {
+	emailSvc.SetDelivery(cfg.DeliveryModes, cfg.MXPort, cfg.HeloName)
+	for channel, modes := range cfg.ChannelDelivery {
+		emailSvc.SetChannelDelivery(channel, modes)
+	}
+
+	// Firma DKIM opcional de los correos salientes
+	if cfg.DKIMEnabled() {
+		signer, err := email.NewDKIMSigner(cfg.DKIMDomain, cfg.DKIMSelector, cfg.DKIMKeyFile)
+		if err != nil {
+			log.Fatalf("Error configurando DKIM: %v", err)
+		}
+		emailSvc.SetDKIMSigner(signer)
+		utils.WriteLog(fmt.Sprintf("[MAIN] Firma DKIM habilitada (d=%s, s=%s)", cfg.DKIMDomain, cfg.DKIMSelector), *debug)
+	}
+
+	// Con UPS, la causa del inicio se confirma con los eventos de energía registrados;
+	// sin esa confirmación el correo de inicio es siempre "iniciado"
+	startupCause := ""
+	if cfg.UPS.Enabled() {
+		det := detector.NewDetector(store, utils.SystemClock, *debug)
+		if cause, err := det.DetectStartupCause(); err == nil && det.Confirmed() {
+			startupCause = detector.GetCauseDescription(cause) + " (confirmada por el UPS)"
+		}
+	}
+
+	// Enviar correo de inicio
+	if err := emailSvc.SendStartupEmail(ip, startupCause); err != nil {
 		utils.WriteLog("[MAIN] Error enviando correo de inicio: "+err.Error(), *debug)
 		// No fatal, continuar ejecución
 	}
 
 	// Inicializar estado - limpiar cualquier desconexión previa
 	// Esto asegura que si se para e inicia manualmente, no se detecte como pérdida de internet
-	state, err := utils.LoadState(cfg.StateFilePath)
-	if err != nil {
-		utils.WriteLog("[MAIN] Error cargando estado inicial, creando nuevo", *debug)
-		state = &utils.State{
-			StartTime:     utils.GetCurrentTime(),
-			LastConnected: utils.GetCurrentTime(),
-			IsConnected:   true,
-			LastIP:        ip, // Guardar IP inicial
-		}
-	} else {
+	err = store.Update(func(state *utils.State) {
 		// Limpiar estado de desconexión al iniciar (reinicio manual)
 		state.IsConnected = true
-		state.LastConnected = utils.GetCurrentTime()
+		state.StartTime = utils.GetCurrentTime()
+		state.LastConnected = state.StartTime
 		state.LastDisconnected = time.Time{} // Limpiar desconexión previa
-		state.LastIP = ip // Actualizar IP inicial
-	}
 
-	if err := utils.SaveState(cfg.StateFilePath, state); err != nil {
+		// Guardar IP inicial por familia; una familia no disponible conserva el último valor
+		if ips.Primary() != "" {
+			state.LastIP = ips.Primary()
+		}
+		if ips.IPv4 != "" {
+			state.LastIPv4 = ips.IPv4
+		}
+		if ips.IPv6 != "" {
+			state.LastIPv6 = ips.IPv6
+			state.LastIPv6Prefix, _ = utils.IPv6Prefix(ips.IPv6, cfg.IPv6PrefixLength)
+		}
+	})
+	if err != nil {
 		utils.WriteLog("[MAIN] Error guardando estado inicial: "+err.Error(), *debug)
 	}
 
 	// Inicializar monitor
-	mon := monitor.NewMonitor(cfg, emailSvc, *debug)
+	mon := monitor.NewMonitor(cfg, emailSvc, store, *debug)
+	mon.SetNotifier(notifier)
+
+	// Aviso de apagado al recibir SIGTERM/SIGINT o con batería baja del UPS (opcional)
+	if cfg.Shutdown.Notify {
+		mon.SetShutdownNotice(cfg.Shutdown.Timeout)
+	}
+
+	// DNS dinámico opcional
+	if cfg.DDNS.Enabled() {
+		ddnsMgr, err := ddns.NewManagerFromConfig(cfg.DDNS, *debug)
+		if err != nil {
+			log.Fatalf("Error configurando DDNS: %v", err)
+		}
+		mon.SetDDNS(ddnsMgr, cfg.DDNS.VerifyInterval)
+		utils.WriteLog(fmt.Sprintf("[MAIN] DNS dinámico habilitado: %v", cfg.DDNS.Providers), *debug)
+	}
+
+	// Verificación de resolución DNS opcional
+	if cfg.DNSCheck.Enabled() {
+		hosts := make([]dnscheck.Host, 0, len(cfg.DNSCheck.Hosts))
+		for _, h := range cfg.DNSCheck.Hosts {
+			hosts = append(hosts, dnscheck.Host{Name: h.Name, Types: h.Types})
+		}
+		checker := dnscheck.NewChecker(hosts, cfg.DNSCheck.Resolvers, cfg.DNSCheck.Grace, utils.SystemClock, *debug)
+		mon.SetDNSCheck(checker, cfg.DNSCheck.Interval)
+		utils.WriteLog(fmt.Sprintf("[MAIN] Verificación DNS habilitada: %v", cfg.DNSCheck.Hosts), *debug)
+	}
+
+	// Consulta de IP WAN al router para detectar CGNAT (opcional)
+	if cfg.Router.Enabled() {
+		mon.SetRouterCheck(&gateway.Client{Gateway: cfg.Router.Gateway}, cfg.Router.Method, cfg.Router.Interval)
+		utils.WriteLog(fmt.Sprintf("[MAIN] Consulta al router habilitada (%s)", cfg.Router.Method), *debug)
+	}
+
+	// Medición de latencia, jitter y pérdida (opcional)
+	if cfg.Probe.Enabled() {
+		prober := &probe.Prober{Targets: cfg.Probe.Targets, Count: cfg.Probe.Count, Timeout: cfg.Probe.Timeout, Clock: utils.SystemClock}
+		// El historial debe cubrir el período de degradación y el del informe
+		retention := cfg.Probe.DegradedPeriod
+		if cfg.Report.Interval > retention {
+			retention = cfg.Report.Interval
+		}
+		probeDetector := &probe.Detector{
+			History: probe.NewHistory(retention + cfg.MonitorInterval),
+			Thresholds: probe.Thresholds{
+				MaxRTT:    cfg.Probe.MaxRTT,
+				MaxJitter: cfg.Probe.MaxJitter,
+				MaxLoss:   cfg.Probe.MaxLoss,
+			},
+			Period:   cfg.Probe.DegradedPeriod,
+			Fraction: cfg.Probe.DegradedFraction,
+		}
+		mon.SetProbe(prober, probeDetector)
+		utils.WriteLog(fmt.Sprintf("[MAIN] Medición de calidad habilitada: %v", cfg.Probe.Targets), *debug)
+	}
+
+	// Informe periódico de estado (opcional)
+	if cfg.Report.Enabled() {
+		mon.SetReport(cfg.Report.Interval)
+		utils.WriteLog(fmt.Sprintf("[MAIN] Informe de estado cada %s", cfg.Report.Interval), *debug)
+	}
+
+	// Prueba periódica de velocidad (opcional)
+	if cfg.SpeedTest.Enabled() {
+		tester := &speedtest.Tester{
+			DownloadURL:   cfg.SpeedTest.DownloadURL,
+			UploadURL:     cfg.SpeedTest.UploadURL,
+			DownloadBytes: cfg.SpeedTest.DownloadBytes,
+			UploadBytes:   cfg.SpeedTest.UploadBytes,
+			Timeout:       cfg.SpeedTest.Timeout,
+		}
+		mon.SetSpeedTest(tester, cfg.SpeedTest.Interval, cfg.SpeedTest.MinDownloadMbps, cfg.SpeedTest.MinUploadMbps, cfg.SpeedTest.History)
+		utils.WriteLog(fmt.Sprintf("[MAIN] Prueba de velocidad cada %s", cfg.SpeedTest.Interval), *debug)
+	}
+
+	// Checks de servicios definidos en un archivo JSON (opcional)
+	if cfg.Checks.Enabled() {
+		defs, err := checks.Load(cfg.Checks.File)
+		if err != nil {
+			log.Fatalf("Error cargando checks: %v", err)
+		}
+		mon.SetChecks(checks.NewManager(defs))
+		utils.WriteLog(fmt.Sprintf("[MAIN] %d checks de servicios cargados desde %s", len(defs), cfg.Checks.File), *debug)
+	}
+
+	// Recursos del host: disco, memoria, carga y temperatura
+	if cfg.Resources.Enabled {
+		collector := &resources.Collector{
+			DiskPaths: cfg.Resources.DiskPaths,
+			ProcPath:  cfg.Resources.ProcPath,
+			SysPath:   cfg.Resources.SysPath,
+		}
+		tracker := &resources.Tracker{
+			Thresholds: resources.Thresholds{
+				DiskPct:    cfg.Resources.MaxDiskPct,
+				MemoryPct:  cfg.Resources.MaxMemoryPct,
+				LoadPerCPU: cfg.Resources.MaxLoadPerCPU,
+				TempC:      cfg.Resources.MaxTempC,
+			},
+			Samples: cfg.Resources.AlertSamples,
+		}
+		mon.SetResources(collector, tracker, cfg.Resources.Interval)
+		utils.WriteLog(fmt.Sprintf("[MAIN] Monitoreo de recursos habilitado: %v", cfg.Resources.DiskPaths), *debug)
+	}
+
+	// Vigilancia de contenedores Docker (opcional)
+	if cfg.Docker.Enabled {
+		watcher := &docker.Watcher{
+			Client:        docker.NewClient(cfg.Docker.Socket),
+			Names:         cfg.Docker.Containers,
+			Labels:        cfg.Docker.Labels,
+			RestartLimit:  cfg.Docker.RestartLimit,
+			RestartWindow: cfg.Docker.RestartWindow,
+			Clock:         utils.SystemClock,
+		}
+		mon.SetDocker(watcher, cfg.Docker.Interval)
+		utils.WriteLog(fmt.Sprintf("[MAIN] Vigilancia de Docker habilitada (%s)", cfg.Docker.Socket), *debug)
+	}
+
+	// Vigilancia de unidades de systemd por D-Bus (opcional)
+	if cfg.Systemd.Enabled() {
+		watcher := &systemd.Watcher{
+			Units:   cfg.Systemd.Units,
+			Address: cfg.Systemd.Address,
+			Clock:   utils.SystemClock,
+		}
+		mon.SetSystemd(watcher, cfg.Systemd.Interval)
+		utils.WriteLog(fmt.Sprintf("[MAIN] Vigilancia de systemd habilitada: %v", cfg.Systemd.Units), *debug)
+	}
+
+	// Consulta del UPS por upsd de Network UPS Tools (opcional)
+	if cfg.UPS.Enabled() {
+		name, addr, err := ups.ParseName(cfg.UPS.Name)
+		if err != nil {
+			log.Fatalf("Error configurando UPS: %v", err)
+		}
+		watcher := &ups.Watcher{
+			Client: &ups.Client{
+				Addr:     addr,
+				Username: cfg.UPS.Username,
+				Password: cfg.UPS.Password,
+			},
+			UPS:   name,
+			Clock: utils.SystemClock,
+		}
+		mon.SetUPS(watcher, cfg.UPS.Interval)
+		utils.WriteLog(fmt.Sprintf("[MAIN] Consulta del UPS habilitada: %s en %s", name, addr), *debug)
+	}
+
+	// Servidor HTTP local con métricas y estado (opcional)
+	if cfg.API.Enabled() {
+		registry := metrics.NewRegistry()
+		mon.SetMetrics(registry)
+
+		server := api.NewServer(cfg.API.Addr, *debug)
+		server.Handle("/metrics", registry.Handler())
+		server.Handle("/status", mon.StatusHandler())
+
+		// Checks que reciben pings de trabajos programados (opcional)
+		if cfg.Pings.Enabled() {
+			defs, err := pings.Load(cfg.Pings.File)
+			if err != nil {
+				log.Fatalf("Error cargando pings: %v", err)
+			}
+			receiver := pings.NewRegistry(defs, utils.SystemClock)
+			server.Handle(pings.Prefix, receiver.Handler())
+			mon.SetPings(receiver)
+			utils.WriteLog(fmt.Sprintf("[MAIN] %d checks de ping en %s<id>", len(defs), pings.Prefix), *debug)
+		}
+		if err := server.Start(); err != nil {
+			log.Fatalf("Error iniciando servidor HTTP: %v", err)
+		}
+	}
+
+	// Heartbeat con otra instancia (opcional). Se recibe en un puerto propio que solo
+	// atiende el heartbeat firmado, para no exponer el estado sin autenticación.
+	if cfg.Peer.Enabled() {
+		client := &peer.Client{URL: cfg.Peer.URL, Secret: cfg.Peer.Secret}
+		tracker := &peer.Tracker{Secret: cfg.Peer.Secret, Interval: cfg.Peer.Interval, Missed: cfg.Peer.Missed, Clock: utils.SystemClock}
+		server := api.NewServer(cfg.Peer.Addr, *debug)
+		server.Handle(peer.Path, tracker.Handler())
+		if err := server.Start(); err != nil {
+			log.Fatalf("Error iniciando servidor de heartbeats: %v", err)
+		}
+		mon.SetPeer(cfg.Peer.Name, client, tracker, cfg.Peer.Interval)
+		utils.WriteLog(fmt.Sprintf("[MAIN] Heartbeat con la instancia %s (%s)", cfg.Peer.Name, cfg.Peer.URL), *debug)
+	}
 
 	// Manejar señales para shutdown graceful
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
-	// Iniciar monitor en goroutine
+	// Iniciar monitor en goroutine; se detiene al cancelar el contexto
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	go func() {
-		if err := mon.Start(); err != nil {
+		if err := mon.Start(ctx); err != nil {
 			utils.WriteLog("[MAIN] Error en monitor: "+err.Error(), *debug)
 			log.Fatal(err)
 		}
@@ -95,15 +348,59 @@ func main() {
 	utils.WriteLog("[MAIN] Servicio iniciado y monitoreando", *debug)
 
 	// Esperar señal de terminación
-	<-sigChan
+	sig := <-sigChan
 	utils.WriteLog("[MAIN] Recibida señal de terminación, cerrando...", *debug)
+	notifier.Stopping("Deteniendo")
+
+	if err := mon.NotifyShutdown(shutdownReason(sig)); err != nil {
+		utils.WriteLog("[MAIN] Error enviando aviso de apagado: "+err.Error(), *debug)
+	}
 
-	// Guardar estado final
-	state.IsConnected = false
-	if err := utils.SaveState(cfg.StateFilePath, state); err != nil {
-		utils.WriteLog("[MAIN] Error guardando estado final: "+err.Error(), *debug)
+	// Detener el monitor: termina la vuelta en curso, guarda su estado final y
+	// entrega las notificaciones pendientes dentro del plazo
+	cancel()
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.Shutdown.Deadline)
+	defer cancelShutdown()
+	if err := mon.Shutdown(shutdownCtx); err != nil {
+		utils.WriteLog("[MAIN] Error deteniendo el monitor: "+err.Error(), *debug)
 	}
 
 	utils.WriteLog("[MAIN] Servicio detenido", *debug)
 }
 
+// shutdownReason describe la señal que detiene el servicio para el aviso de apagado
+func shutdownReason(sig os.Signal) string {
+	if sig == syscall.SIGTERM {
+		return "señal SIGTERM (apagado del equipo o detención del servicio)"
+	}
+	return "señal SIGINT (interrupción manual)"
+}
+
+// configureIPDiscovery aplica la lista de proveedores de IP de la configuración
+func configureIPDiscovery(cfg *config.Config) error {
+	v4Specs := cfg.IPv4Providers
+	if len(v4Specs) == 0 {
+		v4Specs = discovery.DefaultIPv4Providers
+	}
+	v6Specs := cfg.IPv6Providers
+	if len(v6Specs) == 0 {
+		v6Specs = discovery.DefaultIPv6Providers
+	}
+
+	v4, err := discovery.ParseIPProviders(v4Specs)
+	if err != nil {
+		return err
+	}
+	v6, err := discovery.ParseIPProviders(v6Specs)
+	if err != nil {
+		return err
+	}
+
+	discovery.ConfigureIPDiscovery(&discovery.IPDiscovery{
+		IPv4Providers: v4,
+		IPv6Providers: v6,
+		Quorum:        cfg.IPConsensusMin,
+		Timeout:       10 * time.Second,
+	})
+	return nil
+}
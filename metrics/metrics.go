@@ -0,0 +1,123 @@
+// Package metrics mantiene valores numéricos del servicio y los expone en el
+// formato de texto de Prometheus.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type family struct {
+	help   string
+	kind   string
+	values map[string]float64 // clave: etiquetas ya formateadas
+}
+
+// Registry guarda gauges por nombre y etiquetas. Un Registry nil ignora las
+// actualizaciones, así los componentes no necesitan comprobar si está habilitado.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// Set fija el valor de un gauge; labels se pasa como pares nombre, valor
+func (r *Registry) Set(name, help string, value float64, labels ...string) {
+	r.set(name, help, "gauge", value, labels)
+}
+
+// Add incrementa un contador
+func (r *Registry) Add(name, help string, delta float64, labels ...string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	f := r.family(name, help, "counter")
+	f.values[formatLabels(labels)] += delta
+	r.mu.Unlock()
+}
+
+func (r *Registry) set(name, help, kind string, value float64, labels []string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.family(name, help, kind).values[formatLabels(labels)] = value
+	r.mu.Unlock()
+}
+
+// Delete elimina una serie, por ejemplo de un check que ya no existe
+func (r *Registry) Delete(name string, labels ...string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	if f, ok := r.families[name]; ok {
+		delete(f.values, formatLabels(labels))
+	}
+	r.mu.Unlock()
+}
+
+func (r *Registry) family(name, help, kind string) *family {
+	f, ok := r.families[name]
+	if !ok {
+		f = &family{help: help, kind: kind, values: make(map[string]float64)}
+		r.families[name] = f
+	}
+	return f
+}
+
+func formatLabels(labels []string) string {
+	if len(labels) < 2 {
+		return ""
+	}
+	parts := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		parts = append(parts, fmt.Sprintf("%s=%q", labels[i], labels[i+1]))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// WriteText escribe todas las series ordenadas por nombre
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		f := r.families[name]
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, f.help, name, f.kind)
+		keys := make([]string, 0, len(f.values))
+		for k := range f.values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&b, "%s%s %s\n", name, k, strconv.FormatFloat(f.values[k], 'g', -1, 64))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Handler sirve las métricas en GET /metrics
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteText(w)
+	})
+}
@@ -0,0 +1,253 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"orgmserver/docker"
+	"orgmserver/peer"
+	"orgmserver/resources"
+	"orgmserver/speedtest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// setFailing simula que el servidor SMTP no está disponible
+func (s *scenario) setFailing(failing bool) {
+	s.notifier.mu.Lock()
+	defer s.notifier.mu.Unlock()
+	s.notifier.failing = failing
+}
+
+func writeMeminfo(t *testing.T, dir string, availableKB int) {
+	t.Helper()
+	data := fmt.Sprintf("MemTotal:       1000000 kB\nMemAvailable:   %d kB\n", availableKB)
+	if err := os.WriteFile(filepath.Join(dir, "meminfo"), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResourceAlertRetriedAfterFailedSend(t *testing.T) {
+	s := newScenario(t)
+	proc := t.TempDir()
+	if err := os.WriteFile(filepath.Join(proc, "loadavg"), []byte("0.10 0.20 0.30 1/100 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	writeMeminfo(t, proc, 50000)
+	s.mon.SetResources(&resources.Collector{ProcPath: proc, SysPath: t.TempDir()},
+		&resources.Tracker{Thresholds: resources.Thresholds{MemoryPct: 90}, Samples: 1}, time.Minute)
+
+	// La alerta se levanta sin salida al servidor SMTP: no se pierde
+	s.setFailing(true)
+	s.tick()
+	s.expect()
+
+	s.setFailing(false)
+	s.tick()
+	s.expect("resource_alert Memoria al 95.0% (límite 90%)")
+	s.tick()
+	s.expect()
+
+	// La recuperación tampoco se pierde
+	writeMeminfo(t, proc, 500000)
+	s.setFailing(true)
+	s.tick()
+	s.expect()
+	s.setFailing(false)
+	s.tick()
+	s.expect("resource_recovered Memoria al 95.0% (límite 90%)")
+}
+
+// fakeDocker responde como el daemon con un único contenedor "web"
+type fakeDocker struct {
+	mu     sync.Mutex
+	status string
+}
+
+func (d *fakeDocker) setStatus(status string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.status = status
+}
+
+func (d *fakeDocker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if r.URL.Path == "/containers/json" {
+		json.NewEncoder(w).Encode([]map[string]interface{}{{"Id": "a1", "Names": []string{"/web"}, "State": d.status}})
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, "/containers/a1/") {
+		http.NotFound(w, r)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"Id": "a1", "Name": "/web",
+		"State": map[string]interface{}{"Status": d.status, "Running": d.status == "running", "ExitCode": 1},
+	})
+}
+
+func startFakeDocker(t *testing.T) (*fakeDocker, string) {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &fakeDocker{status: "running"}
+	srv := &http.Server{Handler: d}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	return d, socket
+}
+
+func TestContainerAlertRetriedAfterFailedSend(t *testing.T) {
+	s := newScenario(t)
+	daemon, socket := startFakeDocker(t)
+	s.mon.SetDocker(&docker.Watcher{Client: docker.NewClient(socket), Names: []string{"web"}}, time.Minute)
+	s.tick()
+	s.expect()
+
+	// El contenedor se detiene sin salida al servidor SMTP: el aviso queda pendiente
+	daemon.setStatus("exited")
+	s.setFailing(true)
+	s.tick()
+	s.expect()
+
+	s.setFailing(false)
+	s.tick()
+	s.expect("container_alert web detenido")
+	s.tick()
+	s.expect()
+
+	daemon.setStatus("running")
+	s.setFailing(true)
+	s.tick()
+	s.expect()
+	s.setFailing(false)
+	s.tick()
+	s.expect("container_recovered web detenido")
+}
+
+func TestPeerAlertRetriedAfterFailedSend(t *testing.T) {
+	s := newScenario(t)
+	const secret = "secreto-compartido-de-prueba"
+
+	// La otra instancia solo recibe nuestros heartbeats
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer other.Close()
+	tracker := &peer.Tracker{Secret: secret, Interval: time.Minute, Missed: 2, Clock: s.clock}
+	s.mon.SetPeer("sitio-b", &peer.Client{URL: other.URL, Secret: secret}, tracker, time.Minute)
+
+	// El silencio se detecta sin salida al servidor SMTP: el aviso no se pierde
+	s.setFailing(true)
+	for i := 0; i < 3; i++ {
+		s.tick()
+	}
+	s.expect()
+	s.setFailing(false)
+	s.tick()
+	s.expect("peer_silent sitio-b 3m0s")
+
+	// Vuelve a reportarse y la recuperación también se reintenta
+	local := httptest.NewServer(tracker.Handler())
+	defer local.Close()
+	hb := peer.Heartbeat{Instance: "sitio-b", Time: s.clock.Now(), IntervalSeconds: 60, Connected: true}
+	if err := (&peer.Client{URL: local.URL, Secret: secret}).Send(context.Background(), hb); err != nil {
+		t.Fatal(err)
+	}
+	s.setFailing(true)
+	s.tick()
+	s.expect()
+	s.setFailing(false)
+	s.tick()
+	s.expect("peer_recovered sitio-b 4m0s")
+}
+
+// slowServer sirve la descarga de la prueba de velocidad, lenta o sin demora
+type slowServer struct {
+	mu   sync.Mutex
+	slow bool
+}
+
+func (d *slowServer) setSlow(slow bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.slow = slow
+}
+
+func (d *slowServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	slow := d.slow
+	d.mu.Unlock()
+
+	w.WriteHeader(http.StatusOK)
+	chunk := make([]byte, 1000)
+	for i := 0; i < 4; i++ {
+		w.Write(chunk)
+		if slow {
+			// 4000 bytes en unos 150 ms: alrededor de 0,2 Mbps
+			w.(http.Flusher).Flush()
+			if i < 3 {
+				time.Sleep(50 * time.Millisecond)
+			}
+		}
+	}
+}
+
+func TestSpeedAlertRetriedAndPersisted(t *testing.T) {
+	s := newScenario(t)
+	server := &slowServer{slow: true}
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+	tester := &speedtest.Tester{DownloadURL: srv.URL, DownloadBytes: 4000, Timeout: 5 * time.Second}
+	enable := func() { s.mon.SetSpeedTest(tester, time.Minute, 1, 0, 10) }
+	enable()
+
+	// La velocidad baja se detecta sin salida al servidor SMTP: el aviso no se pierde
+	s.setFailing(true)
+	s.tick()
+	s.expect()
+	if s.store.Get().SpeedLow {
+		t.Fatal("el aviso no enviado no debe guardarse")
+	}
+	s.setFailing(false)
+	s.tick()
+	sent := s.notifier.take()
+	if len(sent) != 1 || !strings.HasPrefix(sent[0], "slow_speed bajada ") || !strings.HasSuffix(sent[0], " < 1.00 Mbps") {
+		t.Fatalf("avisos = %q", sent)
+	}
+	if !s.store.Get().SpeedLow {
+		t.Fatal("el aviso enviado debe guardarse en el estado")
+	}
+
+	// Tras reiniciar no se repite el aviso de una velocidad que sigue baja
+	s.restart()
+	enable()
+	s.tick()
+	s.expect()
+
+	// La recuperación también se reintenta
+	server.setSlow(false)
+	s.setFailing(true)
+	s.tick()
+	s.expect()
+	s.setFailing(false)
+	s.tick()
+	s.expect("speed_restored")
+	if s.store.Get().SpeedLow {
+		t.Error("la recuperación debe guardarse en el estado")
+	}
+	if tests := s.store.Get().SpeedTests; len(tests) != 5 {
+		t.Errorf("pruebas guardadas = %d, se esperaban 5", len(tests))
+	}
+}
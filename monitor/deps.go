@@ -0,0 +1,68 @@
+package monitor
+
+import (
+	"context"
+	"orgmserver/discovery"
+	"orgmserver/email"
+	"orgmserver/utils"
+	"time"
+)
+
+// IPResolver obtiene las IP externas; un error indica que no hay conexión a internet.
+// Cancelar ctx interrumpe las consultas en curso.
+type IPResolver interface {
+	GetExternalIPs(ctx context.Context, withIPv6 bool) (utils.ExternalIPs, error)
+}
+
+// IPResolverFunc adapta una función como IPResolver
+type IPResolverFunc func(ctx context.Context, withIPv6 bool) (utils.ExternalIPs, error)
+
+func (f IPResolverFunc) GetExternalIPs(ctx context.Context, withIPv6 bool) (utils.ExternalIPs, error) {
+	return f(ctx, withIPv6)
+}
+
+// StateStore es el estado persistente del monitor; lo implementa *utils.StateStore
+type StateStore interface {
+	Get() utils.State
+	Update(fn func(*utils.State)) error
+}
+
+// Notifier envía los avisos del monitor; lo implementa *email.EmailService
+type Notifier interface {
+	SendReconnectionEmail(ip string, duration time.Duration, quality string) error
+	SendIPChangeEmail(family string, newIP string, oldIP string, ddnsReport string) error
+	SendIPv6PrefixChangeEmail(newPrefix string, oldPrefix string, newIP string, ddnsReport string) error
+	SendDNSMismatchEmail(ip string, details string) error
+	SendDNSResolvedEmail(ip string) error
+	SendCGNATEmail(routerIP, publicIP, reason string) error
+	SendCGNATClearedEmail(ip string) error
+	SendDegradedEmail(ip string, period time.Duration, reasons string, stats string) error
+	SendDegradationClearedEmail(ip string, stats string) error
+	SendReportEmail(ip string, since time.Time, sections []email.ReportSection) error
+	SendSlowSpeedEmail(ip string, below string, result string) error
+	SendSpeedRestoredEmail(ip string, result string) error
+	SendCheckDownEmail(name, checkType, target, errMsg string, since time.Time) error
+	SendCheckUpEmail(name, checkType, target string, downtime time.Duration) error
+	SendCertificateAlertEmail(name, target, problem string) error
+	SendCertificateOKEmail(name, target string) error
+	SendPingDownEmail(name, id, reason string, lastPing time.Time, output string) error
+	SendPingUpEmail(name, id string, downtime time.Duration) error
+	SendResourceAlertEmail(alert string, snapshot string) error
+	SendResourceRecoveredEmail(alert string, snapshot string) error
+	SendContainerAlertEmail(container, problem, detail string) error
+	SendContainerRecoveredEmail(container, problem, status string) error
+	SendUnitAlertEmail(unit, problem, detail string) error
+	SendUnitRecoveredEmail(unit, problem, state string) error
+	SendOnBatteryEmail(ups, status string, at time.Time) error
+	SendLowBatteryEmail(ups, status string, at time.Time) error
+	SendPowerRestoredEmail(ups, status string, onBattery time.Duration, at time.Time) error
+	SendShutdownEmail(reason, ip string, uptime, hostUptime, timeout time.Duration) error
+	SendPeerSilentEmail(peer string, silence time.Duration, last string) error
+	SendPeerRecoveredEmail(peer string, silence time.Duration, current string) error
+}
+
+var (
+	_ Notifier   = (*email.EmailService)(nil)
+	_ StateStore = (*utils.StateStore)(nil)
+	_ IPResolver = (*discovery.IPDiscovery)(nil)
+)
@@ -1,69 +1,521 @@
 package monitor
 
 import (
+	"context"
 	"fmt"
+	"net/netip"
+	"orgmserver/checks"
 	"orgmserver/config"
+	"orgmserver/ddns"
+	"orgmserver/discovery"
+	"orgmserver/dnscheck"
+	"orgmserver/docker"
 	"orgmserver/email"
+	"orgmserver/gateway"
 	"orgmserver/healthcheck"
+	"orgmserver/metrics"
+	"orgmserver/peer"
+	"orgmserver/pings"
+	"orgmserver/probe"
+	"orgmserver/resources"
+	"orgmserver/speedtest"
+	"orgmserver/systemd"
+	"orgmserver/ups"
 	"orgmserver/utils"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Monitor struct {
 	config            *config.Config
-	emailService      *email.EmailService
+	emailService      Notifier
 	healthcheckService *healthcheck.HealthcheckService
-	stateFilePath     string
+	store             StateStore
+	clock             utils.Clock
+	resolver          IPResolver
 	monitorInterval   time.Duration
 	isConnected       bool
 	disconnectTime    time.Time
 	debug             bool
+
+	ddns           *ddns.Manager
+	ddnsInterval   time.Duration
+	lastDDNSUpdate time.Time
+
+	dnsChecker   *dnscheck.Checker
+	dnsInterval  time.Duration
+	lastDNSCheck time.Time
+
+	router          *gateway.Client
+	routerMethod    string
+	routerInterval  time.Duration
+	lastRouterCheck time.Time
+	behindCGNAT     bool
+
+	prober     *probe.Prober
+	quality    *probe.Detector
+	lastSample probe.Sample
+
+	reportInterval time.Duration
+	lastReport     time.Time
+	outages        int
+	downtime       time.Duration
+
+	speedTester   *speedtest.Tester
+	speedInterval time.Duration
+	minDownload   float64 // bps
+	minUpload     float64 // bps
+	speedHistory  int
+	lastSpeedTest time.Time
+	speedLow      bool         // último aviso enviado: velocidad baja
+	speedPending  *speedChange // aviso de velocidad aún no enviado
+
+	metrics *metrics.Registry
+
+	checks *checks.Manager
+
+	resources         *resources.Collector
+	resourceTracker   *resources.Tracker
+	resourceInterval  time.Duration
+	lastResourceCheck time.Time
+	lastResources     *resources.Snapshot
+
+	docker           *docker.Watcher
+	dockerInterval   time.Duration
+	lastDocker       time.Time
+	containerPending []docker.Event
+
+	systemd         *systemd.Watcher
+	systemdInterval time.Duration
+	lastSystemd     time.Time
+	unitPending     []systemd.Event
+
+	notifier *systemd.Notifier
+
+	ups          *ups.Watcher
+	upsInterval  time.Duration
+	lastUPS      time.Time
+	powerPending []ups.Event
+
+	peerName     string
+	peerClient   *peer.Client
+	peerTracker  *peer.Tracker
+	peerInterval time.Duration
+	lastPeer     time.Time
+	peerSince    time.Time
+	peerPending  []peer.Event
+
+	pings *pings.Registry
+
+	startTime        time.Time
+	shutdownTimeout  time.Duration
+	shutdownNotified atomic.Bool
+
+	lastIPs            utils.ExternalIPs
+	lastOutageEnd      time.Time
+	lastOutageDuration time.Duration
+	lastCheck          time.Time
+	statusMu           sync.Mutex
+	status             Status
+
+	// ctx es el contexto de Start: al cancelarlo se interrumpen las consultas en curso
+	ctx  context.Context
+	done chan struct{}
 }
 
 func NewMonitor(
 	cfg *config.Config,
-	emailSvc *email.EmailService,
+	emailSvc Notifier,
+	store StateStore,
 	debug bool,
 ) *Monitor {
-	healthcheckSvc := healthcheck.NewHealthcheckService(cfg.HealthcheckURL, debug)
+	healthcheckSvc := healthcheck.NewHealthcheckService(healthcheck.Options{
+		URLs:    cfg.Healthcheck.URLs,
+		Method:  cfg.Healthcheck.Method,
+		Headers: cfg.Healthcheck.Headers,
+		Retries: cfg.Healthcheck.Retries,
+		Signals: cfg.Healthcheck.Signals,
+	}, debug)
 
 	return &Monitor{
 		config:            cfg,
 		emailService:      emailSvc,
 		healthcheckService: healthcheckSvc,
-		stateFilePath:     cfg.StateFilePath,
+		store:             store,
+		clock:             utils.SystemClock,
+		resolver:          IPResolverFunc(discovery.GetExternalIPs),
 		monitorInterval:   cfg.MonitorInterval,
 		isConnected:       true,
 		debug:             debug,
+		startTime:         utils.SystemClock.Now(),
+		ctx:               context.Background(),
+		done:              make(chan struct{}),
+	}
+}
+
+// SetClock reemplaza el reloj del sistema (para pruebas); debe llamarse antes que
+// los demás Set
+func (m *Monitor) SetClock(clock utils.Clock) {
+	m.clock = clock
+	m.startTime = clock.Now()
+}
+
+// SetIPResolver reemplaza la consulta de IP externa configurada con ConfigureIPDiscovery
+func (m *Monitor) SetIPResolver(resolver IPResolver) {
+	m.resolver = resolver
+}
+
+// SetDDNS habilita la actualización de DNS dinámico al cambiar la IP.
+// Además, cada interval se reenvía la IP actual para verificar los registros.
+func (m *Monitor) SetDDNS(manager *ddns.Manager, interval time.Duration) {
+	m.ddns = manager
+	m.ddnsInterval = interval
+}
+
+// SetDNSCheck habilita la verificación periódica de que los nombres públicos
+// resuelven a la IP externa actual
+func (m *Monitor) SetDNSCheck(checker *dnscheck.Checker, interval time.Duration) {
+	m.dnsChecker = checker
+	m.dnsInterval = interval
+}
+
+// SetRouterCheck habilita la consulta periódica de la IP WAN al router para
+// detectar si la conexión está detrás de CGNAT
+func (m *Monitor) SetRouterCheck(client *gateway.Client, method string, interval time.Duration) {
+	m.router = client
+	m.routerMethod = method
+	m.routerInterval = interval
+}
+
+// SetProbe habilita la medición de latencia, jitter y pérdida en cada verificación
+func (m *Monitor) SetProbe(prober *probe.Prober, detector *probe.Detector) {
+	m.prober = prober
+	m.quality = detector
+}
+
+// SetReport habilita el informe periódico de estado; el primero se envía tras un intervalo completo
+func (m *Monitor) SetReport(interval time.Duration) {
+	m.reportInterval = interval
+	m.lastReport = m.clock.Now()
+}
+
+// SetSpeedTest habilita la medición periódica de ancho de banda. minDownload y
+// minUpload están en Mbps (0 = sin alerta); history es el máximo de resultados guardados.
+// Recupera del estado si la última alerta de velocidad baja sigue vigente.
+func (m *Monitor) SetSpeedTest(tester *speedtest.Tester, interval time.Duration, minDownload, minUpload float64, history int) {
+	m.speedTester = tester
+	m.speedInterval = interval
+	m.minDownload = minDownload * 1e6
+	m.minUpload = minUpload * 1e6
+	m.speedHistory = history
+	m.speedLow = m.store.Get().SpeedLow
+}
+
+// SetMetrics habilita la publicación de métricas del monitor
+func (m *Monitor) SetMetrics(registry *metrics.Registry) {
+	m.metrics = registry
+}
+
+// SetChecks habilita los checks de servicios y recupera su último estado guardado
+func (m *Monitor) SetChecks(manager *checks.Manager) {
+	m.checks = manager
+
+	state := m.store.Get()
+	for name, record := range state.Checks {
+		manager.Restore(name, checks.Status(record.Status), record.Since, checks.Status(record.Notified))
+	}
+}
+
+// SetPings habilita los checks que reciben pings y recupera su último estado guardado
+func (m *Monitor) SetPings(registry *pings.Registry) {
+	m.pings = registry
+
+	state := m.store.Get()
+	for id, record := range state.Pings {
+		registry.Restore(id, pings.Status(record.Status), record.Since, record.LastPing, pings.Status(record.Notified))
+	}
+}
+
+// SetResources habilita el monitoreo de disco, memoria, carga y temperatura del host
+func (m *Monitor) SetResources(collector *resources.Collector, tracker *resources.Tracker, interval time.Duration) {
+	m.resources = collector
+	m.resourceTracker = tracker
+	m.resourceInterval = interval
+}
+
+// SetDocker habilita la vigilancia de contenedores por el socket de Docker
+func (m *Monitor) SetDocker(watcher *docker.Watcher, interval time.Duration) {
+	m.docker = watcher
+	m.dockerInterval = interval
+}
+
+// SetSystemd habilita la vigilancia de unidades de systemd por D-Bus
+func (m *Monitor) SetSystemd(watcher *systemd.Watcher, interval time.Duration) {
+	m.systemd = watcher
+	m.systemdInterval = interval
+}
+
+// SetUPS habilita la consulta del UPS por upsd (Network UPS Tools)
+func (m *Monitor) SetUPS(watcher *ups.Watcher, interval time.Duration) {
+	m.ups = watcher
+	m.upsInterval = interval
+
+	// Un corte que terminó con el equipo apagado no registró power_restored: la
+	// primera lectura en línea lo registra y lo notifica
+	events := m.store.Get().PowerEvents
+	if n := len(events); n > 0 {
+		last := events[n-1]
+		since := last.Time
+		for i := n - 1; i >= 0 && events[i].Type != ups.EventPowerRestored; i-- {
+			if events[i].Type == ups.EventOnBattery {
+				since = events[i].Time
+			}
+		}
+		watcher.Resume(last.Type, since)
+	}
+}
+
+// SetPeer habilita el heartbeat con otra instancia: se le envía el estado propio
+// cada interval y se avisa cuando la otra deja de reportarse
+func (m *Monitor) SetPeer(name string, client *peer.Client, tracker *peer.Tracker, interval time.Duration) {
+	m.peerName = name
+	m.peerClient = client
+	m.peerTracker = tracker
+	m.peerInterval = interval
+	m.peerSince = m.clock.Now()
+}
+
+// SetShutdownNotice habilita el aviso de apagado; timeout limita cuánto puede
+// demorar el envío
+func (m *Monitor) SetShutdownNotice(timeout time.Duration) {
+	m.shutdownTimeout = timeout
+}
+
+// NotifyShutdown envía el aviso de apagado si está habilitado. Puede llamarse desde
+// otra goroutine: solo usa el estado publicado. El aviso se envía una sola vez, para
+// no repetirlo cuando a la batería baja del UPS le sigue el SIGTERM del apagado.
+func (m *Monitor) NotifyShutdown(reason string) error {
+	if m.shutdownTimeout <= 0 {
+		return nil
+	}
+	// Reservar el aviso antes de enviarlo para que dos llamadas simultáneas no envíen dos correos
+	if !m.shutdownNotified.CompareAndSwap(false, true) {
+		return nil
+	}
+
+	st := m.Status()
+	ip := utils.ExternalIPs{IPv4: st.IPv4, IPv6: st.IPv6}.String()
+	hostUptime, _ := utils.SystemUptime()
+
+	utils.WriteLog("[MONITOR] Enviando aviso de apagado: "+reason, m.debug)
+	if err := m.emailService.SendShutdownEmail(reason, ip, m.clock.Now().Sub(m.startTime), hostUptime, m.shutdownTimeout); err != nil {
+		// Liberar la reserva para reintentar en la próxima señal
+		m.shutdownNotified.Store(false)
+		return err
 	}
+	return nil
+}
+
+// SetNotifier habilita la notificación de estado a systemd (Type=notify y watchdog)
+func (m *Monitor) SetNotifier(notifier *systemd.Notifier) {
+	m.notifier = notifier
 }
 
-// Start inicia el loop de monitoreo
-func (m *Monitor) Start() error {
+// Start ejecuta el loop de monitoreo hasta que se cancela ctx. La cancelación
+// interrumpe las consultas en curso; el cierre se completa con Shutdown.
+func (m *Monitor) Start(ctx context.Context) error {
 	utils.WriteLog("[MONITOR] Iniciando loop de monitoreo", m.debug)
+	m.ctx = ctx
+	defer close(m.done)
 
 	ticker := time.NewTicker(m.monitorInterval)
 	defer ticker.Stop()
 
-	// Primera verificación inmediata
-	m.checkConnection()
+	// El watchdog se renueva desde este mismo loop: si una verificación se cuelga
+	// dejan de llegar pings y systemd reinicia el servicio
+	var watchdog <-chan time.Time
+	if interval := m.notifier.WatchdogInterval(); interval > 0 {
+		wt := time.NewTicker(interval / 2)
+		defer wt.Stop()
+		watchdog = wt.C
+	}
+
+	// Primera verificación inmediata; recién entonces el servicio se considera listo
+	m.runCycle()
+	if err := m.notifier.Ready(m.statusLine()); err != nil {
+		utils.WriteLog(fmt.Sprintf("[MONITOR] Error notificando a systemd: %v", err), m.debug)
+	}
 
 	for {
 		select {
+		case <-ctx.Done():
+			utils.WriteLog("[MONITOR] Loop de monitoreo detenido", m.debug)
+			return nil
 		case <-ticker.C:
-			m.checkConnection()
+			m.runCycle()
+			m.notifier.Status(m.statusLine())
+			m.notifier.Watchdog()
+		case <-watchdog:
+			m.notifier.Watchdog()
+		}
+	}
+}
+
+// Shutdown espera que termine la vuelta en curso del loop (el contexto de Start ya
+// debe estar cancelado), guarda el estado final y entrega las notificaciones
+// pendientes, todo dentro del plazo de ctx. Si la vuelta no termina a tiempo, el
+// estado queda como lo guardó la última verificación.
+func (m *Monitor) Shutdown(ctx context.Context) error {
+	select {
+	case <-m.done:
+	case <-ctx.Done():
+		m.healthcheckService.Close(ctx)
+		return fmt.Errorf("la verificación en curso no terminó a tiempo: %w", ctx.Err())
+	}
+
+	m.saveFinalState()
+
+	if err := m.healthcheckService.Close(ctx); err != nil {
+		return fmt.Errorf("healthchecks pendientes sin enviar: %w", err)
+	}
+	return nil
+}
+
+// saveFinalState guarda el estado según la última verificación del monitor,
+// conservando los historiales registrados durante la ejecución
+func (m *Monitor) saveFinalState() {
+	if m.checks != nil {
+		m.saveChecks()
+	}
+	if m.pings != nil {
+		m.savePings()
+	}
+
+	err := m.store.Update(func(state *utils.State) {
+		if m.isConnected && !m.lastCheck.IsZero() {
+			state.LastConnected = m.lastCheck
+			m.setStateIPs(state, m.lastIPs)
+		}
+		if !m.isConnected {
+			state.LastDisconnected = m.disconnectTime
+		}
+		// El servicio se detiene: el próximo inicio no debe asumir que seguía conectado
+		state.IsConnected = false
+	})
+	if err != nil {
+		utils.WriteLog(fmt.Sprintf("[MONITOR] Error guardando estado final: %v", err), m.debug)
+		return
+	}
+	utils.WriteLog("[MONITOR] Estado final guardado", m.debug)
+}
+
+// statusLine resume el estado para systemctl status
+func (m *Monitor) statusLine() string {
+	if !m.isConnected {
+		return "Sin conexión a internet desde " + m.disconnectTime.Format("2006-01-02 15:04:05")
+	}
+	line := "Conectado, IP " + m.lastIPs.String()
+	if m.quality != nil && m.quality.Degraded() {
+		line += ", conexión degradada"
+	}
+	if m.behindCGNAT {
+		line += ", detrás de CGNAT"
+	}
+	return line
+}
+
+// runCycle ejecuta todas las verificaciones de una vuelta del loop
+func (m *Monitor) runCycle() {
+	if m.config.Healthcheck.Start {
+		m.healthcheckService.Send(healthcheck.Signal{Kind: healthcheck.SignalStart})
+	}
+
+	steps := []func(){
+		m.checkConnection,
+		m.runChecks,
+		m.checkPings,
+		m.checkResources,
+		m.checkDocker,
+		m.checkSystemd,
+		m.checkUPS,
+		m.checkPeer,
+	}
+	for _, step := range steps {
+		// Al detener el servicio la vuelta se corta: las consultas canceladas no son
+		// resultados válidos y no deben generar alertas
+		if m.ctx.Err() != nil {
+			return
+		}
+		step()
+	}
+
+	m.lastCheck = m.clock.Now()
+	m.publishStatus()
+	m.signalHealthcheck()
+}
+
+// signalHealthcheck informa el resultado de la vuelta: éxito con internet y /fail sin
+// él, o con HEALTHCHECK_EXIT_STATUS el código 0 (todo bien), 1 (sin internet) o
+// 2 (con alertas abiertas). El cuerpo lleva el diagnóstico.
+func (m *Monitor) signalHealthcheck() {
+	if !m.healthcheckService.Enabled() {
+		return
+	}
+
+	alerts := m.activeAlerts()
+	sig := healthcheck.Signal{Kind: healthcheck.SignalSuccess, Body: m.healthcheckBody(alerts)}
+	if !m.isConnected {
+		sig.Kind = healthcheck.SignalFail
+	}
+
+	if m.config.Healthcheck.ExitStatus {
+		sig.Kind = healthcheck.SignalExit
+		switch {
+		case !m.isConnected:
+			sig.ExitStatus = 1
+		case len(alerts) > 0:
+			sig.ExitStatus = 2
 		}
 	}
+
+	m.healthcheckService.Send(sig)
+}
+
+// healthcheckBody arma el diagnóstico que acompaña al healthcheck
+func (m *Monitor) healthcheckBody(alerts []string) string {
+	lines := []string{"Estado: conectado"}
+	if !m.isConnected {
+		lines[0] = "Estado: sin conexión desde " + m.disconnectTime.Format("2006-01-02 15:04:05")
+	}
+	lines = append(lines, "IP: "+m.lastIPs.String())
+
+	if m.prober != nil && !m.lastSample.Time.IsZero() {
+		lines = append(lines, fmt.Sprintf("Latencia: %s, jitter %s, pérdida %.0f%%",
+			m.lastSample.RTT.Round(time.Millisecond), m.lastSample.Jitter.Round(time.Millisecond), m.lastSample.Loss()))
+	}
+
+	if !m.lastOutageEnd.IsZero() {
+		lines = append(lines, fmt.Sprintf("Última desconexión: terminó el %s, duró %s",
+			m.lastOutageEnd.Format("2006-01-02 15:04:05"), m.lastOutageDuration.Round(time.Second)))
+	}
+
+	for _, a := range alerts {
+		lines = append(lines, "Alerta: "+a)
+	}
+	return strings.Join(lines, "\n")
 }
 
 // checkConnection verifica la conexión a internet
 func (m *Monitor) checkConnection() {
 	utils.WriteLog("[MONITOR] Verificando conexión a internet", m.debug)
 
-	// Intentar obtener IP externa para verificar conexión
-	ip, err := utils.GetExternalIP()
-	
+	// Intentar obtener IP externa (IPv4 e IPv6 por separado) para verificar conexión
+	ips, err := m.resolver.GetExternalIPs(m.ctx, m.config.IPv6Enabled)
+
 	if err != nil {
+		m.metrics.Set("orgmserver_connected", "1 si hay conexión a internet", 0)
 		// No hay conexión
 		if m.isConnected {
 			// Acabamos de perder la conexión
@@ -73,22 +525,27 @@ func (m *Monitor) checkConnection() {
 		return
 	}
 
-	// Hay conexión
+	m.metrics.Set("orgmserver_connected", "1 si hay conexión a internet", 1)
+	m.lastIPs = ips
+
+	// Hay conexión: medir la calidad antes de notificar para incluirla en los correos
+	m.measureQuality()
+
 	if !m.isConnected {
 		// Acabamos de recuperar la conexión
-		m.handleReconnection(ip)
+		m.handleReconnection(ips)
 	} else {
 		// Conexión estable, verificar cambio de IP y actualizar estado
-		m.checkIPChange(ip)
-		m.updateState(ip)
+		m.checkIPChange(ips)
+		m.updateState(ips)
 	}
 
-	// Enviar healthcheck si está configurado (en goroutine para no bloquear)
-	go func() {
-		if err := m.healthcheckService.SendHealthcheck(); err != nil {
-			utils.WriteLog(fmt.Sprintf("[MONITOR] Error en healthcheck: %v", err), m.debug)
-		}
-	}()
+	m.verifyDDNS(ips)
+	m.verifyDNS(ips)
+	m.verifyRouter(ips)
+	m.evaluateQuality(ips)
+	m.runSpeedTest(ips)
+	m.sendReport(ips)
 
 	m.isConnected = true
 }
@@ -97,102 +554,1144 @@ func (m *Monitor) checkConnection() {
 func (m *Monitor) handleDisconnection() {
 	utils.WriteLog("[MONITOR] Conexión perdida", m.debug)
 	m.isConnected = false
-	m.disconnectTime = time.Now()
+	m.disconnectTime = m.clock.Now()
+	m.metrics.Add("orgmserver_disconnections_total", "Desconexiones detectadas", 1)
 
 	// Actualizar estado
-	state, err := utils.LoadState(m.stateFilePath)
+	err := m.store.Update(func(state *utils.State) {
+		state.IsConnected = false
+		state.LastDisconnected = m.disconnectTime
+	})
 	if err != nil {
-		utils.WriteLog(fmt.Sprintf("[MONITOR] Error cargando estado: %v", err), m.debug)
-		state = &utils.State{
-			StartTime: time.Now(),
-		}
-	}
-
-	state.IsConnected = false
-	state.LastDisconnected = m.disconnectTime
-
-	if err := utils.SaveState(m.stateFilePath, state); err != nil {
 		utils.WriteLog(fmt.Sprintf("[MONITOR] Error guardando estado: %v", err), m.debug)
 	}
 }
 
 // handleReconnection maneja cuando se recupera la conexión
-func (m *Monitor) handleReconnection(ip string) {
+func (m *Monitor) handleReconnection(ips utils.ExternalIPs) {
 	utils.WriteLog("[MONITOR] Conexión restaurada", m.debug)
 	
 	// Calcular duración de desconexión desde el estado guardado
-	state, err := utils.LoadState(m.stateFilePath)
+	state := m.store.Get()
 	var duration time.Duration
 	
-	if err == nil && !state.LastDisconnected.IsZero() {
-		duration = time.Since(state.LastDisconnected)
+	if !state.LastDisconnected.IsZero() {
+		duration = m.clock.Now().Sub(state.LastDisconnected)
 	} else {
 		// Si no hay timestamp de desconexión, usar el tiempo desde que detectamos la desconexión
-		duration = time.Since(m.disconnectTime)
+		duration = m.clock.Now().Sub(m.disconnectTime)
 	}
 	
 	// Enviar correo de reconexión (solo si hubo desconexión real, no reinicio manual)
 	if duration > 0 {
-		if err := m.emailService.SendReconnectionEmail(ip, duration); err != nil {
+		m.outages++
+		m.downtime += duration
+		m.lastOutageEnd = m.clock.Now()
+		m.lastOutageDuration = duration
+		if err := m.emailService.SendReconnectionEmail(ips.String(), duration, m.reconnectionQuality()); err != nil {
 			utils.WriteLog(fmt.Sprintf("[MONITOR] Error enviando correo de reconexión: %v", err), m.debug)
 		}
 	}
 
+	// Es común que el proveedor asigne otra IP al reconectar: notificarla y
+	// actualizar el DNS dinámico antes de guardar la nueva
+	m.checkIPChange(ips)
+
 	// Actualizar estado
-	state, err = utils.LoadState(m.stateFilePath)
+	err := m.store.Update(func(state *utils.State) {
+		state.IsConnected = true
+		state.LastConnected = m.clock.Now()
+		state.LastDisconnected = time.Time{} // Limpiar desconexión
+		m.setStateIPs(state, ips)             // Guardar la nueva IP
+	})
 	if err != nil {
-		state = &utils.State{
-			StartTime: time.Now(),
-		}
+		utils.WriteLog(fmt.Sprintf("[MONITOR] Error guardando estado: %v", err), m.debug)
 	}
 
-	state.IsConnected = true
-	state.LastConnected = time.Now()
-	state.LastDisconnected = time.Time{} // Limpiar desconexión
-	state.LastIP = ip // Guardar la nueva IP
+	m.isConnected = true
+}
+
+// checkIPChange verifica si la IP de cada familia ha cambiado y envía notificación
+func (m *Monitor) checkIPChange(ips utils.ExternalIPs) {
+	state := m.store.Get()
 
-	if err := utils.SaveState(m.stateFilePath, state); err != nil {
-		utils.WriteLog(fmt.Sprintf("[MONITOR] Error guardando estado: %v", err), m.debug)
+	oldIPv4, oldIPv6 := state.LastIPv4, state.LastIPv6
+
+	// Si hay una IP anterior y es diferente a la nueva, hubo un cambio
+	if ips.IPv4 != "" && oldIPv4 != "" && oldIPv4 != ips.IPv4 {
+		utils.WriteLog(fmt.Sprintf("[MONITOR] Cambio de IPv4 detectado: %s -> %s", oldIPv4, ips.IPv4), m.debug)
+
+		// Actualizar DNS dinámico antes de notificar para incluir el resultado
+		report := m.updateDDNS(ips.IPv4)
+
+		// Enviar correo de cambio de IP
+		if err := m.emailService.SendIPChangeEmail("IPv4", ips.IPv4, oldIPv4, report); err != nil {
+			utils.WriteLog(fmt.Sprintf("[MONITOR] Error enviando correo de cambio de IP: %v", err), m.debug)
+		}
 	}
 
-	m.isConnected = true
+	if ips.IPv6 != "" && oldIPv6 != "" && oldIPv6 != ips.IPv6 {
+		m.handleIPv6Change(oldIPv6, state.LastIPv6Prefix, ips.IPv6)
+	}
 }
 
-// checkIPChange verifica si la IP ha cambiado y envía notificación
-func (m *Monitor) checkIPChange(newIP string) {
-	state, err := utils.LoadState(m.stateFilePath)
+// handleIPv6Change distingue entre un cambio del prefijo delegado y un cambio
+// de dirección dentro del mismo prefijo (por ejemplo, direcciones temporales)
+func (m *Monitor) handleIPv6Change(oldIP, oldPrefix, newIP string) {
+	utils.WriteLog(fmt.Sprintf("[MONITOR] Cambio de IPv6 detectado: %s -> %s", oldIP, newIP), m.debug)
+
+	newPrefix, err := utils.IPv6Prefix(newIP, m.config.IPv6PrefixLength)
 	if err != nil {
-		utils.WriteLog(fmt.Sprintf("[MONITOR] Error cargando estado para verificar IP: %v", err), m.debug)
+		utils.WriteLog(fmt.Sprintf("[MONITOR] Error calculando prefijo IPv6: %v", err), m.debug)
+	}
+	if oldPrefix == "" {
+		oldPrefix, _ = utils.IPv6Prefix(oldIP, m.config.IPv6PrefixLength)
+	}
+
+	// La dirección cambió: los registros AAAA deben actualizarse siempre
+	report := m.updateDDNS(newIP)
+
+	if newPrefix != "" && oldPrefix != "" && newPrefix != oldPrefix {
+		utils.WriteLog(fmt.Sprintf("[MONITOR] Cambio de prefijo IPv6 detectado: %s -> %s", oldPrefix, newPrefix), m.debug)
+		if err := m.emailService.SendIPv6PrefixChangeEmail(newPrefix, oldPrefix, newIP, report); err != nil {
+			utils.WriteLog(fmt.Sprintf("[MONITOR] Error enviando correo de cambio de prefijo IPv6: %v", err), m.debug)
+		}
 		return
 	}
 
-	// Si hay una IP anterior y es diferente a la nueva, hubo un cambio
-	if state.LastIP != "" && state.LastIP != newIP {
-		utils.WriteLog(fmt.Sprintf("[MONITOR] Cambio de IP detectado: %s -> %s", state.LastIP, newIP), m.debug)
-		
-		// Enviar correo de cambio de IP
-		if err := m.emailService.SendIPChangeEmail(newIP, state.LastIP); err != nil {
+	if m.config.IPv6NotifyAddress {
+		if err := m.emailService.SendIPChangeEmail("IPv6", newIP, oldIP, report); err != nil {
 			utils.WriteLog(fmt.Sprintf("[MONITOR] Error enviando correo de cambio de IP: %v", err), m.debug)
 		}
 	}
 }
 
+// setStateIPs guarda las IP por familia; una familia no disponible conserva el último valor
+func (m *Monitor) setStateIPs(state *utils.State, ips utils.ExternalIPs) {
+	if ips.Primary() != "" {
+		state.LastIP = ips.Primary()
+	}
+	if ips.IPv4 != "" {
+		state.LastIPv4 = ips.IPv4
+	}
+	if ips.IPv6 != "" {
+		state.LastIPv6 = ips.IPv6
+		if prefix, err := utils.IPv6Prefix(ips.IPv6, m.config.IPv6PrefixLength); err == nil {
+			state.LastIPv6Prefix = prefix
+		}
+	}
+}
+
 // updateState actualiza el estado cuando hay conexión estable
-func (m *Monitor) updateState(ip string) {
-	state, err := utils.LoadState(m.stateFilePath)
+func (m *Monitor) updateState(ips utils.ExternalIPs) {
+	err := m.store.Update(func(state *utils.State) {
+		state.IsConnected = true
+		state.LastConnected = m.clock.Now()
+		m.setStateIPs(state, ips) // Guardar la IP actual
+	})
 	if err != nil {
-		state = &utils.State{
-			StartTime: time.Now(),
+		utils.WriteLog(fmt.Sprintf("[MONITOR] Error guardando estado: %v", err), m.debug)
+	}
+}
+
+// updateDDNS actualiza los proveedores de DNS dinámico y retorna el resumen
+func (m *Monitor) updateDDNS(ip string) string {
+	if !m.ddns.Enabled() {
+		return ""
+	}
+
+	results := m.ddns.Update(m.ctx, ip)
+	m.lastDDNSUpdate = m.clock.Now()
+	return ddns.Summary(results)
+}
+
+// verifyDDNS reenvía periódicamente las IP actuales para corregir registros desactualizados
+func (m *Monitor) verifyDDNS(ips utils.ExternalIPs) {
+	if !m.ddns.Enabled() || m.ddnsInterval <= 0 {
+		return
+	}
+	if !m.lastDDNSUpdate.IsZero() && m.clock.Now().Sub(m.lastDDNSUpdate) < m.ddnsInterval {
+		return
+	}
+
+	utils.WriteLog("[MONITOR] Verificación periódica de DNS dinámico", m.debug)
+	for _, ip := range []string{ips.IPv4, ips.IPv6} {
+		if ip != "" {
+			m.updateDDNS(ip)
+		}
+	}
+}
+
+// verifyDNS comprueba que los nombres configurados resuelven a las IP externas
+func (m *Monitor) verifyDNS(ips utils.ExternalIPs) {
+	if !m.dnsChecker.Enabled() {
+		return
+	}
+	if !m.lastDNSCheck.IsZero() && m.clock.Now().Sub(m.lastDNSCheck) < m.dnsInterval {
+		return
+	}
+	m.lastDNSCheck = m.clock.Now()
+
+	var expected []netip.Addr
+	for _, ip := range []string{ips.IPv4, ips.IPv6} {
+		if addr, err := netip.ParseAddr(ip); err == nil {
+			expected = append(expected, addr.Unmap())
 		}
 	}
 
-	state.IsConnected = true
-	state.LastConnected = time.Now()
-	state.LastIP = ip // Guardar la IP actual
+	if len(expected) == 0 {
+		// Sin IP confirmada no hay contra qué comparar
+		return
+	}
+
+	utils.WriteLog("[MONITOR] Verificando resolución DNS de nombres públicos", m.debug)
+	alerts, recovered := m.dnsChecker.Check(m.ctx, expected)
+	ip := ips.String()
 
-	if err := utils.SaveState(m.stateFilePath, state); err != nil {
-		utils.WriteLog(fmt.Sprintf("[MONITOR] Error guardando estado: %v", err), m.debug)
+	if len(alerts) > 0 {
+		lines := make([]string, len(alerts))
+		for i, a := range alerts {
+			lines[i] = "- " + a.String()
+		}
+		if err := m.emailService.SendDNSMismatchEmail(ip, strings.Join(lines, "\n")); err != nil {
+			utils.WriteLog(fmt.Sprintf("[MONITOR] Error enviando correo de DNS desactualizado: %v", err), m.debug)
+		}
+	}
+
+	if recovered {
+		if err := m.emailService.SendDNSResolvedEmail(ip); err != nil {
+			utils.WriteLog(fmt.Sprintf("[MONITOR] Error enviando correo de DNS correcto: %v", err), m.debug)
+		}
 	}
 }
 
+// verifyRouter compara la IP WAN que informa el router con la IP pública vista
+// desde fuera; si no coinciden hay otra capa de NAT (CGNAT) entre el router e internet
+func (m *Monitor) verifyRouter(ips utils.ExternalIPs) {
+	if m.router == nil {
+		return
+	}
+	if !m.lastRouterCheck.IsZero() && m.clock.Now().Sub(m.lastRouterCheck) < m.routerInterval {
+		return
+	}
+	m.lastRouterCheck = m.clock.Now()
+
+	ctx, cancel := context.WithTimeout(m.ctx, 15*time.Second)
+	defer cancel()
+
+	wanIP, method, err := m.router.ExternalIP(ctx, m.routerMethod)
+	if err != nil {
+		utils.WriteLog(fmt.Sprintf("[MONITOR] Error consultando IP WAN al router: %v", err), m.debug)
+		return
+	}
+	utils.WriteLog(fmt.Sprintf("[MONITOR] IP WAN del router (%s): %s", method, wanIP), m.debug)
+
+	var reason string
+	switch {
+	case gateway.IsCGNAT(wanIP):
+		reason = "La IP WAN del router pertenece al espacio compartido 100.64.0.0/10 (RFC 6598) usado por CGNAT."
+	case !utils.IsPublicIP(wanIP):
+		reason = "La IP WAN del router no es pública; hay otro router o NAT delante."
+	case ips.IPv4 != "" && wanIP.String() != ips.IPv4:
+		reason = "La IP WAN del router no coincide con la IP pública vista desde internet."
+	case ips.IPv4 == "":
+		// Sin IPv4 pública confirmada no se puede descartar el CGNAT
+		return
+	}
+
+	if reason != "" && !m.behindCGNAT {
+		m.behindCGNAT = true
+		utils.WriteLog(fmt.Sprintf("[MONITOR] Posible CGNAT: router %s, pública %s", wanIP, ips.IPv4), m.debug)
+		if err := m.emailService.SendCGNATEmail(wanIP.String(), ips.IPv4, reason); err != nil {
+			utils.WriteLog(fmt.Sprintf("[MONITOR] Error enviando correo de CGNAT: %v", err), m.debug)
+		}
+	} else if reason == "" && m.behindCGNAT {
+		m.behindCGNAT = false
+		utils.WriteLog("[MONITOR] La IP WAN del router coincide con la IP pública", m.debug)
+		if err := m.emailService.SendCGNATClearedEmail(wanIP.String()); err != nil {
+			utils.WriteLog(fmt.Sprintf("[MONITOR] Error enviando correo de CGNAT resuelto: %v", err), m.debug)
+		}
+	}
+}
+
+// measureQuality mide latencia, jitter y pérdida y guarda la muestra en el historial
+func (m *Monitor) measureQuality() {
+	if m.prober == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, 30*time.Second)
+	defer cancel()
+
+	sample := m.prober.Measure(ctx)
+	if m.ctx.Err() != nil {
+		return
+	}
+	m.lastSample = sample
+	m.quality.History.Add(m.lastSample)
+
+	if m.lastSample.Received > 0 {
+		m.metrics.Set("orgmserver_probe_rtt_seconds", "Latencia promedio de la última medición", m.lastSample.RTT.Seconds())
+		m.metrics.Set("orgmserver_probe_jitter_seconds", "Jitter de la última medición", m.lastSample.Jitter.Seconds())
+	}
+	m.metrics.Set("orgmserver_probe_loss_ratio", "Pérdida de la última medición (0 a 1)", m.lastSample.Loss()/100)
+	utils.WriteLog(fmt.Sprintf("[MONITOR] Calidad: rtt=%s jitter=%s pérdida=%.1f%%",
+		m.lastSample.RTT, m.lastSample.Jitter, m.lastSample.Loss()), m.debug)
+}
+
+// reconnectionQuality describe la medición actual y la calidad previa a la desconexión
+func (m *Monitor) reconnectionQuality() string {
+	if m.prober == nil {
+		return ""
+	}
+
+	current := m.quality.History.Since(m.lastSample.Time)
+	text := "Medición actual:\n" + current.String()
+
+	before := m.quality.History.Between(m.disconnectTime.Add(-m.quality.Period), m.disconnectTime)
+	if before.Samples > 0 {
+		text += fmt.Sprintf("\n\nAntes de la desconexión (últimos %s):\n%s", m.quality.Period, before.String())
+	}
+	return text
+}
+
+// evaluateQuality notifica cuando la conexión entra o sale del estado degradado
+func (m *Monitor) evaluateQuality(ips utils.ExternalIPs) {
+	if m.prober == nil {
+		return
+	}
+
+	event := m.quality.Evaluate(m.clock.Now())
+
+	degraded := 0.0
+	if m.quality.Degraded() {
+		degraded = 1
+	}
+	m.metrics.Set("orgmserver_connection_degraded", "1 si la calidad supera los límites de forma sostenida", degraded)
+
+	if event == nil {
+		return
+	}
+
+	if event.Degraded {
+		reasons := strings.Join(event.Reasons, ", ")
+		utils.WriteLog(fmt.Sprintf("[MONITOR] Conexión degradada: %s", reasons), m.debug)
+		if err := m.emailService.SendDegradedEmail(ips.String(), m.quality.Period, reasons, event.Stats.String()); err != nil {
+			utils.WriteLog(fmt.Sprintf("[MONITOR] Error enviando correo de conexión degradada: %v", err), m.debug)
+		}
+		return
+	}
+
+	utils.WriteLog("[MONITOR] Calidad de conexión normal", m.debug)
+	if err := m.emailService.SendDegradationClearedEmail(ips.String(), event.Stats.String()); err != nil {
+		utils.WriteLog(fmt.Sprintf("[MONITOR] Error enviando correo de calidad normal: %v", err), m.debug)
+	}
+}
+
+// sendReport envía el informe periódico con la disponibilidad y la calidad del período
+func (m *Monitor) sendReport(ips utils.ExternalIPs) {
+	if m.reportInterval <= 0 || m.clock.Now().Sub(m.lastReport) < m.reportInterval {
+		return
+	}
+
+	sections := []email.ReportSection{{
+		Title: "Disponibilidad",
+		Body: fmt.Sprintf("Desconexiones: %d\nTiempo sin conexión: %s",
+			m.outages, m.downtime.Round(time.Second)),
+	}}
+
+	if m.prober != nil {
+		status := "normal"
+		if m.quality.Degraded() {
+			status = "degradada"
+		}
+		sections = append(sections, email.ReportSection{
+			Title: "Calidad de la conexión",
+			Body:  fmt.Sprintf("Estado actual: %s\n%s", status, m.quality.History.Since(m.lastReport).String()),
+		})
+	}
 
+	if m.lastResources != nil {
+		body := m.lastResources.String()
+		for _, a := range m.resourceTracker.Active() {
+			body += "\nALERTA: " + a.Description
+		}
+		sections = append(sections, email.ReportSection{
+			Title: "Recursos del host",
+			Body:  body,
+		})
+	}
+
+	if m.speedTester != nil {
+		sections = append(sections, email.ReportSection{
+			Title: "Velocidad",
+			Body:  m.speedSummary(m.lastReport),
+		})
+	}
+
+	if m.ups != nil {
+		sections = append(sections, email.ReportSection{
+			Title: "Energía",
+			Body:  m.powerSummary(m.lastReport),
+		})
+	}
+
+	utils.WriteLog("[MONITOR] Enviando informe periódico", m.debug)
+	if err := m.emailService.SendReportEmail(ips.String(), m.lastReport, sections); err != nil {
+		utils.WriteLog(fmt.Sprintf("[MONITOR] Error enviando informe: %v", err), m.debug)
+		return
+	}
+
+	m.lastReport = m.clock.Now()
+	m.outages = 0
+	m.downtime = 0
+}
+
+// runSpeedTest mide el ancho de banda cada speedInterval, guarda el resultado en el
+// historial del estado y alerta si queda por debajo de las velocidades mínimas
+func (m *Monitor) runSpeedTest(ips utils.ExternalIPs) {
+	if m.speedTester == nil {
+		return
+	}
+
+	m.sendSpeedEmail(ips)
+
+	if m.lastSpeedTest.IsZero() {
+		// Tras un reinicio, respetar el intervalo desde la última prueba guardada
+		if state := m.store.Get(); len(state.SpeedTests) > 0 {
+			m.lastSpeedTest = state.SpeedTests[len(state.SpeedTests)-1].Time
+		}
+	}
+	if !m.lastSpeedTest.IsZero() && m.clock.Now().Sub(m.lastSpeedTest) < m.speedInterval {
+		return
+	}
+	m.lastSpeedTest = m.clock.Now()
+
+	utils.WriteLog("[MONITOR] Ejecutando prueba de velocidad", m.debug)
+	result, err := m.speedTester.Run(m.ctx)
+	if m.ctx.Err() != nil {
+		// Prueba interrumpida por la detención del servicio
+		m.lastSpeedTest = time.Time{}
+		return
+	}
+
+	record := utils.SpeedTestRecord{
+		Time:         m.lastSpeedTest,
+		DownloadMbps: result.DownloadBps / 1e6,
+		UploadMbps:   result.UploadBps / 1e6,
+	}
+	if err != nil {
+		record.Error = err.Error()
+		utils.WriteLog(fmt.Sprintf("[MONITOR] Error en prueba de velocidad: %v", err), m.debug)
+	} else {
+		utils.WriteLog(fmt.Sprintf("[MONITOR] Velocidad: bajada %s, subida %s",
+			speedtest.FormatBps(result.DownloadBps), speedtest.FormatBps(result.UploadBps)), m.debug)
+	}
+	m.saveSpeedTest(record)
+
+	if err != nil {
+		// Una prueba fallida no confirma ni descarta una velocidad baja
+		return
+	}
+
+	m.metrics.Set("orgmserver_speedtest_timestamp_seconds", "Hora de la última prueba de velocidad", float64(result.Time.Unix()))
+	if result.DownloadBytes > 0 {
+		m.metrics.Set("orgmserver_speedtest_download_bps", "Velocidad de bajada medida en bits por segundo", result.DownloadBps)
+	}
+	if result.UploadBytes > 0 {
+		m.metrics.Set("orgmserver_speedtest_upload_bps", "Velocidad de subida medida en bits por segundo", result.UploadBps)
+	}
+
+	var below []string
+	if m.minDownload > 0 && result.DownloadBytes > 0 && result.DownloadBps < m.minDownload {
+		below = append(below, fmt.Sprintf("bajada %s < %s", speedtest.FormatBps(result.DownloadBps), speedtest.FormatBps(m.minDownload)))
+	}
+	if m.minUpload > 0 && result.UploadBytes > 0 && result.UploadBps < m.minUpload {
+		below = append(below, fmt.Sprintf("subida %s < %s", speedtest.FormatBps(result.UploadBps), speedtest.FormatBps(m.minUpload)))
+	}
+
+	low := len(below) > 0
+	if low == m.speedLow {
+		// Un aviso aún no enviado que ya no corresponde se descarta
+		m.speedPending = nil
+		return
+	}
+	m.speedPending = &speedChange{low: low, below: strings.Join(below, ", "), result: result.String()}
+	m.sendSpeedEmail(ips)
+}
+
+// speedChange es un cambio de la velocidad respecto del último aviso enviado
+type speedChange struct {
+	low    bool
+	below  string
+	result string
+}
+
+// sendSpeedEmail envía el aviso de velocidad pendiente; si falla se reintenta en la
+// siguiente vuelta. El estado avisado se guarda para no repetirlo tras un reinicio.
+func (m *Monitor) sendSpeedEmail(ips utils.ExternalIPs) {
+	p := m.speedPending
+	if p == nil {
+		return
+	}
+	var err error
+	if p.low {
+		err = m.emailService.SendSlowSpeedEmail(ips.String(), p.below, p.result)
+	} else {
+		err = m.emailService.SendSpeedRestoredEmail(ips.String(), p.result)
+	}
+	if err != nil {
+		utils.WriteLog(fmt.Sprintf("[MONITOR] Error enviando aviso de velocidad, se reintentará: %v", err), m.debug)
+		return
+	}
+	m.speedPending = nil
+	m.speedLow = p.low
+	if err := m.store.Update(func(state *utils.State) { state.SpeedLow = p.low }); err != nil {
+		utils.WriteLog(fmt.Sprintf("[MONITOR] Error guardando estado: %v", err), m.debug)
+	}
+}
+
+// saveSpeedTest agrega el resultado al historial del estado, conservando los más recientes
+func (m *Monitor) saveSpeedTest(record utils.SpeedTestRecord) {
+	err := m.store.Update(func(state *utils.State) {
+		state.SpeedTests = append(state.SpeedTests, record)
+		if m.speedHistory > 0 && len(state.SpeedTests) > m.speedHistory {
+			state.SpeedTests = state.SpeedTests[len(state.SpeedTests)-m.speedHistory:]
+		}
+	})
+	if err != nil {
+		utils.WriteLog(fmt.Sprintf("[MONITOR] Error guardando estado: %v", err), m.debug)
+	}
+}
+
+// speedSummary resume las pruebas de velocidad realizadas desde since
+func (m *Monitor) speedSummary(since time.Time) string {
+	state := m.store.Get()
+
+	var count, failed int
+	var down, up float64
+	var minDown, minUp float64
+	for _, r := range state.SpeedTests {
+		if r.Time.Before(since) {
+			continue
+		}
+		if r.Error != "" {
+			failed++
+			continue
+		}
+		count++
+		down += r.DownloadMbps
+		up += r.UploadMbps
+		if minDown == 0 || r.DownloadMbps < minDown {
+			minDown = r.DownloadMbps
+		}
+		if minUp == 0 || r.UploadMbps < minUp {
+			minUp = r.UploadMbps
+		}
+	}
+	if count == 0 {
+		return fmt.Sprintf("Sin pruebas exitosas (%d fallidas)", failed)
+	}
+	return fmt.Sprintf("Bajada promedio: %.2f Mbps (mín %.2f)\nSubida promedio: %.2f Mbps (mín %.2f)\nPruebas: %d (%d fallidas)",
+		down/float64(count), minDown, up/float64(count), minUp, count, failed)
+}
+
+// runChecks ejecuta los checks de servicios pendientes y notifica sus cambios de
+// estado. Se ejecuta aunque no haya internet para vigilar también servicios locales;
+// si un correo no se puede enviar, el cambio queda pendiente para la siguiente vuelta.
+func (m *Monitor) runChecks() {
+	if m.checks == nil {
+		return
+	}
+
+	m.checks.RunDue(m.ctx, m.clock.Now())
+
+	for _, t := range m.checks.Pending() {
+		var err error
+		switch {
+		case t.Type == checks.TypeTLS && t.Status == checks.StatusDown:
+			utils.WriteLog(fmt.Sprintf("[MONITOR] Certificado %s con problemas: %s", t.Name, t.Error), m.debug)
+			err = m.emailService.SendCertificateAlertEmail(t.Name, t.Target, t.Error)
+		case t.Type == checks.TypeTLS:
+			utils.WriteLog(fmt.Sprintf("[MONITOR] Certificado %s correcto", t.Name), m.debug)
+			err = m.emailService.SendCertificateOKEmail(t.Name, t.Target)
+		case t.Status == checks.StatusDown:
+			utils.WriteLog(fmt.Sprintf("[MONITOR] Check %s caído: %s", t.Name, t.Error), m.debug)
+			err = m.emailService.SendCheckDownEmail(t.Name, t.Type, t.Target, t.Error, t.Since)
+		default:
+			utils.WriteLog(fmt.Sprintf("[MONITOR] Check %s restablecido", t.Name), m.debug)
+			var downtime time.Duration
+			if !t.Previous.IsZero() {
+				downtime = t.Since.Sub(t.Previous)
+			}
+			err = m.emailService.SendCheckUpEmail(t.Name, t.Type, t.Target, downtime)
+		}
+		if err != nil {
+			utils.WriteLog(fmt.Sprintf("[MONITOR] Error enviando correo del check %s: %v", t.Name, err), m.debug)
+			continue
+		}
+		m.checks.MarkNotified(t.Name, t.Status)
+	}
+
+	m.saveChecks()
+}
+
+// saveChecks publica las métricas de los checks y guarda su estado si cambió
+func (m *Monitor) saveChecks() {
+	snapshot := m.checks.Snapshot()
+
+	records := make(map[string]utils.CheckRecord, len(snapshot))
+	for _, c := range snapshot {
+		if c.Status != checks.StatusUnknown {
+			up := 0.0
+			if c.Status == checks.StatusUp {
+				up = 1
+			}
+			m.metrics.Set("orgmserver_check_up", "1 si el check está arriba", up, "check", c.Name, "type", c.Type)
+		}
+		m.metrics.Set("orgmserver_check_duration_seconds", "Duración de la última ejecución del check", c.LastDuration.Seconds(), "check", c.Name, "type", c.Type)
+
+		records[c.Name] = utils.CheckRecord{
+			Status:   string(c.Status),
+			Since:    c.Since,
+			Notified: string(c.Notified()),
+		}
+	}
+
+	if checkRecordsEqual(m.store.Get().Checks, records) {
+		return
+	}
+	if err := m.store.Update(func(state *utils.State) { state.Checks = records }); err != nil {
+		utils.WriteLog(fmt.Sprintf("[MONITOR] Error guardando estado: %v", err), m.debug)
+	}
+}
+
+func checkRecordsEqual(a, b map[string]utils.CheckRecord) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, ra := range a {
+		rb, ok := b[name]
+		if !ok || ra.Status != rb.Status || ra.Notified != rb.Notified || !ra.Since.Equal(rb.Since) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkPings detecta los checks de ping atrasados y notifica sus cambios de estado.
+// Como runChecks, se ejecuta sin internet y reintenta los correos no enviados.
+func (m *Monitor) checkPings() {
+	if m.pings == nil {
+		return
+	}
+
+	m.pings.Evaluate(m.clock.Now())
+
+	for _, t := range m.pings.Pending() {
+		var err error
+		if t.Status == pings.StatusDown {
+			utils.WriteLog(fmt.Sprintf("[MONITOR] Ping %s caído: %s", t.ID, t.Error), m.debug)
+			err = m.emailService.SendPingDownEmail(t.Name, t.ID, t.Error, t.LastPing, t.Body)
+		} else {
+			utils.WriteLog(fmt.Sprintf("[MONITOR] Ping %s restablecido", t.ID), m.debug)
+			var downtime time.Duration
+			if !t.Previous.IsZero() {
+				downtime = t.Since.Sub(t.Previous)
+			}
+			err = m.emailService.SendPingUpEmail(t.Name, t.ID, downtime)
+		}
+		if err != nil {
+			utils.WriteLog(fmt.Sprintf("[MONITOR] Error enviando correo del ping %s: %v", t.ID, err), m.debug)
+			continue
+		}
+		m.pings.MarkNotified(t.ID, t.Status)
+	}
+
+	m.savePings()
+}
+
+// savePings publica las métricas de los pings y guarda su estado si cambió
+func (m *Monitor) savePings() {
+	snapshot := m.pings.Snapshot()
+
+	records := make(map[string]utils.PingRecord, len(snapshot))
+	for _, c := range snapshot {
+		if c.Status != pings.StatusUnknown {
+			up := 0.0
+			if c.Status == pings.StatusUp {
+				up = 1
+			}
+			m.metrics.Set("orgmserver_ping_up", "1 si el check de ping recibe pings a tiempo", up, "ping", c.ID)
+		}
+		if !c.LastPing.IsZero() {
+			m.metrics.Set("orgmserver_ping_last_timestamp_seconds", "Hora del último ping recibido", float64(c.LastPing.Unix()), "ping", c.ID)
+		}
+
+		records[c.ID] = utils.PingRecord{
+			Status:   string(c.Status),
+			Since:    c.Since,
+			LastPing: c.LastPing,
+			Notified: string(c.Notified()),
+		}
+	}
+
+	if pingRecordsEqual(m.store.Get().Pings, records) {
+		return
+	}
+	if err := m.store.Update(func(state *utils.State) { state.Pings = records }); err != nil {
+		utils.WriteLog(fmt.Sprintf("[MONITOR] Error guardando estado: %v", err), m.debug)
+	}
+}
+
+func pingRecordsEqual(a, b map[string]utils.PingRecord) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for id, ra := range a {
+		rb, ok := b[id]
+		if !ok || ra.Status != rb.Status || ra.Notified != rb.Notified || !ra.Since.Equal(rb.Since) || !ra.LastPing.Equal(rb.LastPing) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkResources lee los recursos del host y notifica los límites superados y resueltos
+func (m *Monitor) checkResources() {
+	if m.resources == nil {
+		return
+	}
+	if !m.lastResourceCheck.IsZero() && m.clock.Now().Sub(m.lastResourceCheck) < m.resourceInterval {
+		return
+	}
+	m.lastResourceCheck = m.clock.Now()
+
+	snapshot := m.resources.Collect()
+	m.lastResources = &snapshot
+	m.publishResourceMetrics(snapshot)
+
+	raised, cleared := m.resourceTracker.Update(snapshot)
+	for _, a := range raised {
+		utils.WriteLog(fmt.Sprintf("[MONITOR] Recurso sobre el límite: %s", a.Description), m.debug)
+	}
+	for _, a := range cleared {
+		utils.WriteLog(fmt.Sprintf("[MONITOR] Recurso normalizado: %s", a.Key), m.debug)
+	}
+
+	// Los avisos que no se pudieron enviar se reintentan en la próxima lectura
+	for _, t := range m.resourceTracker.Pending() {
+		var err error
+		if t.Active {
+			err = m.emailService.SendResourceAlertEmail(t.Description, snapshot.String())
+		} else {
+			err = m.emailService.SendResourceRecoveredEmail(t.Description, snapshot.String())
+		}
+		if err != nil {
+			utils.WriteLog(fmt.Sprintf("[MONITOR] Error enviando correo de recursos, se reintentará: %v", err), m.debug)
+			continue
+		}
+		m.resourceTracker.MarkNotified(t)
+	}
+}
+
+func (m *Monitor) publishResourceMetrics(s resources.Snapshot) {
+	for _, d := range s.Disks {
+		if d.Error != "" {
+			continue
+		}
+		m.metrics.Set("orgmserver_disk_used_ratio", "Uso del sistema de archivos (0 a 1)", d.UsedPct/100, "path", d.Path)
+		m.metrics.Set("orgmserver_disk_free_bytes", "Espacio disponible del sistema de archivos", float64(d.FreeBytes), "path", d.Path)
+	}
+	if s.Memory != nil {
+		m.metrics.Set("orgmserver_memory_used_ratio", "Uso de memoria (0 a 1)", s.Memory.UsedPct/100)
+	}
+	if s.Load != nil {
+		m.metrics.Set("orgmserver_load5", "Carga promedio de 5 minutos", s.Load.Load5)
+	}
+	for _, t := range s.Temperatures {
+		m.metrics.Set("orgmserver_temperature_celsius", "Temperatura de la zona térmica", t.Celsius, "zone", t.Zone, "type", t.Type)
+	}
+}
+
+// checkDocker consulta el estado de los contenedores y notifica problemas nuevos y resueltos
+func (m *Monitor) checkDocker() {
+	if m.docker == nil {
+		return
+	}
+	if !m.lastDocker.IsZero() && m.clock.Now().Sub(m.lastDocker) < m.dockerInterval {
+		return
+	}
+	m.lastDocker = m.clock.Now()
+
+	ctx, cancel := context.WithTimeout(m.ctx, 30*time.Second)
+	defer cancel()
+
+	events, err := m.docker.Check(ctx)
+	if err != nil {
+		utils.WriteLog(fmt.Sprintf("[MONITOR] Error consultando Docker: %v", err), m.debug)
+	}
+	for _, e := range events {
+		if e.Resolved {
+			utils.WriteLog(fmt.Sprintf("[MONITOR] Contenedor %s: resuelto %s", e.Container, e.Problem), m.debug)
+		} else {
+			utils.WriteLog(fmt.Sprintf("[MONITOR] Contenedor %s: %s (%s)", e.Container, e.Problem, e.Detail), m.debug)
+		}
+	}
+	m.containerPending = append(m.containerPending, events...)
+	m.sendContainerEmails()
+	if err != nil {
+		return
+	}
+
+	for _, c := range m.docker.States() {
+		running, healthy := 0.0, 0.0
+		if c.Status == "running" {
+			running = 1
+		}
+		if c.Health == "" || c.Health == "healthy" {
+			healthy = 1
+		}
+		m.metrics.Set("orgmserver_container_running", "1 si el contenedor está en ejecución", running, "container", c.Name)
+		m.metrics.Set("orgmserver_container_healthy", "1 si el healthcheck del contenedor es correcto o no tiene", healthy, "container", c.Name)
+		m.metrics.Set("orgmserver_container_restarts", "Reinicios del contenedor según Docker", float64(c.RestartCount), "container", c.Name)
+	}
+}
+
+// sendContainerEmails envía en orden los avisos de contenedores pendientes; si un
+// envío falla se reintenta en la próxima consulta
+func (m *Monitor) sendContainerEmails() {
+	for len(m.containerPending) > 0 {
+		e := m.containerPending[0]
+		var err error
+		if e.Resolved {
+			err = m.emailService.SendContainerRecoveredEmail(e.Container, e.Describe(), e.Detail)
+		} else {
+			err = m.emailService.SendContainerAlertEmail(e.Container, e.Describe(), e.Detail)
+		}
+		if err != nil {
+			utils.WriteLog(fmt.Sprintf("[MONITOR] Error enviando correo del contenedor %s, se reintentará: %v", e.Container, err), m.debug)
+			return
+		}
+		m.containerPending = m.containerPending[1:]
+	}
+}
+
+// checkSystemd consulta las unidades de systemd y notifica fallos, reinicios y recuperaciones
+func (m *Monitor) checkSystemd() {
+	if m.systemd == nil {
+		return
+	}
+	if !m.lastSystemd.IsZero() && m.clock.Now().Sub(m.lastSystemd) < m.systemdInterval {
+		return
+	}
+	m.lastSystemd = m.clock.Now()
+
+	events, err := m.systemd.Check(m.ctx)
+	if err != nil {
+		utils.WriteLog(fmt.Sprintf("[MONITOR] Error consultando systemd: %v", err), m.debug)
+	}
+	for _, e := range events {
+		if e.Resolved {
+			utils.WriteLog(fmt.Sprintf("[MONITOR] Unidad %s: resuelto %s", e.Unit, e.Problem), m.debug)
+		} else {
+			utils.WriteLog(fmt.Sprintf("[MONITOR] Unidad %s: %s (%s)", e.Unit, e.Problem, e.Detail), m.debug)
+		}
+	}
+	m.unitPending = append(m.unitPending, events...)
+	m.sendUnitEmails()
+	if err != nil {
+		return
+	}
+
+	for _, u := range m.systemd.States() {
+		active, failed := 0.0, 0.0
+		if u.ActiveState == "active" {
+			active = 1
+		}
+		if u.Failed() {
+			failed = 1
+		}
+		m.metrics.Set("orgmserver_systemd_unit_active", "1 si la unidad de systemd está activa", active, "unit", u.Name)
+		m.metrics.Set("orgmserver_systemd_unit_failed", "1 si la unidad de systemd falló o no existe", failed, "unit", u.Name)
+		m.metrics.Set("orgmserver_systemd_unit_restarts", "Reinicios automáticos de la unidad según systemd", float64(u.Restarts), "unit", u.Name)
+	}
+}
+
+// sendUnitEmails envía en orden los avisos de unidades pendientes; si un envío
+// falla se reintenta en la próxima consulta
+func (m *Monitor) sendUnitEmails() {
+	for len(m.unitPending) > 0 {
+		e := m.unitPending[0]
+		var err error
+		if e.Resolved {
+			err = m.emailService.SendUnitRecoveredEmail(e.Unit, e.Describe(), e.Detail)
+		} else {
+			err = m.emailService.SendUnitAlertEmail(e.Unit, e.Describe(), e.Detail)
+		}
+		if err != nil {
+			utils.WriteLog(fmt.Sprintf("[MONITOR] Error enviando correo de la unidad %s, se reintentará: %v", e.Unit, err), m.debug)
+			return
+		}
+		m.unitPending = m.unitPending[1:]
+	}
+}
+
+// maxPowerEvents es la cantidad de eventos de energía que se conservan en el estado
+const maxPowerEvents = 100
+
+// checkUPS consulta el UPS, registra los eventos de energía en el estado y los
+// notifica. Los correos que no se pudieron enviar (por ejemplo, sin internet
+// durante el corte) se reintentan en las consultas siguientes.
+func (m *Monitor) checkUPS() {
+	if m.ups == nil {
+		return
+	}
+	if !m.lastUPS.IsZero() && m.clock.Now().Sub(m.lastUPS) < m.upsInterval {
+		return
+	}
+	m.lastUPS = m.clock.Now()
+
+	ctx, cancel := context.WithTimeout(m.ctx, 15*time.Second)
+	defer cancel()
+
+	st, events, err := m.ups.Poll(ctx)
+	if err != nil {
+		utils.WriteLog(fmt.Sprintf("[MONITOR] Error consultando el UPS: %v", err), m.debug)
+	} else {
+		for _, e := range events {
+			utils.WriteLog(fmt.Sprintf("[MONITOR] UPS %s: %s (%s)", st.UPS, e.Type, e.Status), m.debug)
+		}
+		if len(events) > 0 {
+			m.savePowerEvents(events)
+			m.powerPending = append(m.powerPending, events...)
+		}
+
+		onBattery := 0.0
+		if st.OnBattery() {
+			onBattery = 1
+		}
+		m.metrics.Set("orgmserver_ups_on_battery", "1 si el UPS está funcionando con batería", onBattery, "ups", st.UPS)
+		if st.Charge >= 0 {
+			m.metrics.Set("orgmserver_ups_battery_charge_percent", "Carga de la batería del UPS", st.Charge, "ups", st.UPS)
+		}
+		if st.Runtime >= 0 {
+			m.metrics.Set("orgmserver_ups_battery_runtime_seconds", "Autonomía estimada de la batería del UPS", st.Runtime.Seconds(), "ups", st.UPS)
+		}
+	}
+
+	m.sendPowerEmails()
+}
+
+// sendPowerEmails envía los avisos de energía pendientes en orden
+func (m *Monitor) sendPowerEmails() {
+	for len(m.powerPending) > 0 {
+		e := m.powerPending[0]
+		var err error
+		switch e.Type {
+		case ups.EventOnBattery:
+			err = m.emailService.SendOnBatteryEmail(e.Status.UPS, e.Status.String(), e.Time)
+		case ups.EventLowBattery:
+			// Con aviso de apagado habilitado, la batería baja se notifica como apagado inminente
+			if m.shutdownTimeout > 0 {
+				err = m.NotifyShutdown(fmt.Sprintf("batería baja del UPS %s (%s)", e.Status.UPS, e.Status.String()))
+			} else {
+				err = m.emailService.SendLowBatteryEmail(e.Status.UPS, e.Status.String(), e.Time)
+			}
+		case ups.EventPowerRestored:
+			// Si la energía volvió sin apagar el equipo, un apagado posterior se vuelve a avisar
+			m.shutdownNotified.Store(false)
+			err = m.emailService.SendPowerRestoredEmail(e.Status.UPS, e.Status.String(), e.OnBatteryFor, e.Time)
+		}
+		if err != nil {
+			utils.WriteLog(fmt.Sprintf("[MONITOR] Error enviando aviso de energía, se reintentará: %v", err), m.debug)
+			return
+		}
+		m.powerPending = m.powerPending[1:]
+	}
+}
+
+// savePowerEvents agrega los eventos al historial del estado
+func (m *Monitor) savePowerEvents(events []ups.Event) {
+	err := m.store.Update(func(state *utils.State) {
+		for _, e := range events {
+			record := utils.PowerEvent{Time: e.Time, Type: e.Type, Charge: e.Status.Charge, RuntimeSeconds: -1}
+			if e.Status.Runtime >= 0 {
+				record.RuntimeSeconds = e.Status.Runtime.Seconds()
+			}
+			state.PowerEvents = append(state.PowerEvents, record)
+		}
+		if len(state.PowerEvents) > maxPowerEvents {
+			state.PowerEvents = state.PowerEvents[len(state.PowerEvents)-maxPowerEvents:]
+		}
+	})
+	if err != nil {
+		utils.WriteLog(fmt.Sprintf("[MONITOR] Error guardando estado: %v", err), m.debug)
+	}
+}
+
+// powerSummary resume los cortes de energía registrados desde since
+func (m *Monitor) powerSummary(since time.Time) string {
+	var lines []string
+	if st := m.ups.Last(); st != nil {
+		lines = append(lines, "Estado actual: "+st.String())
+	}
+
+	state := m.store.Get()
+	outages := 0
+	var events []string
+	for _, e := range state.PowerEvents {
+		if e.Time.Before(since) {
+			continue
+		}
+		if e.Type == ups.EventOnBattery {
+			outages++
+		}
+		events = append(events, fmt.Sprintf("%s %s", e.Time.Format("2006-01-02 15:04:05"), e.Type))
+	}
+	lines = append(lines, fmt.Sprintf("Cortes de energía: %d", outages))
+	return strings.Join(append(lines, events...), "\n")
+}
+
+// checkPeer envía el heartbeat a la otra instancia y verifica que ella siga reportándose
+func (m *Monitor) checkPeer() {
+	if m.peerClient == nil {
+		return
+	}
+
+	now := m.clock.Now()
+	if m.lastPeer.IsZero() || now.Sub(m.lastPeer) >= m.peerInterval {
+		m.lastPeer = now
+		m.sendHeartbeat(now)
+	}
+
+	// Sin conexión propia no llegan heartbeats ni se pueden enviar avisos; el silencio
+	// de la otra instancia se cuenta recién desde que se recupera la conexión
+	if !m.isConnected {
+		m.peerSince = now
+		return
+	}
+
+	for _, e := range m.peerTracker.Check(now, m.peerSince) {
+		if e.Type == peer.EventSilent {
+			utils.WriteLog(fmt.Sprintf("[MONITOR] La instancia %s no se reporta hace %s", m.peerName, e.Silence.Round(time.Second)), m.debug)
+		} else {
+			utils.WriteLog(fmt.Sprintf("[MONITOR] La instancia %s volvió a reportarse", m.peerName), m.debug)
+		}
+		// El tracker ya cambió de estado: el aviso queda pendiente hasta enviarse
+		m.peerPending = append(m.peerPending, e)
+	}
+	m.sendPeerEmails()
+
+	last, silent := m.peerTracker.Last()
+	up := 1.0
+	if silent {
+		up = 0
+	}
+	m.metrics.Set("orgmserver_peer_up", "1 si la otra instancia envía heartbeats", up, "peer", m.peerName)
+	if last != nil {
+		m.metrics.Set("orgmserver_peer_last_heartbeat_timestamp_seconds", "Hora del último heartbeat de la otra instancia", float64(last.Time.Unix()), "peer", m.peerName)
+	}
+}
+
+// sendPeerEmails envía en orden los avisos de la otra instancia pendientes; si un
+// envío falla se reintenta en la próxima vuelta
+func (m *Monitor) sendPeerEmails() {
+	for len(m.peerPending) > 0 {
+		e := m.peerPending[0]
+		last := ""
+		if e.Last != nil {
+			last = e.Last.Describe()
+		}
+		var err error
+		if e.Type == peer.EventSilent {
+			err = m.emailService.SendPeerSilentEmail(m.peerName, e.Silence, last)
+		} else {
+			err = m.emailService.SendPeerRecoveredEmail(m.peerName, e.Silence, last)
+		}
+		if err != nil {
+			utils.WriteLog(fmt.Sprintf("[MONITOR] Error enviando correo de la instancia %s, se reintentará: %v", m.peerName, err), m.debug)
+			return
+		}
+		m.peerPending = m.peerPending[1:]
+	}
+}
+
+// sendHeartbeat envía el estado propio a la otra instancia
+func (m *Monitor) sendHeartbeat(now time.Time) {
+	// Si el loop es más lento que el intervalo de heartbeat, la otra instancia
+	// debe esperar el intervalo real
+	interval := m.peerInterval
+	if m.monitorInterval > interval {
+		interval = m.monitorInterval
+	}
+
+	hb := peer.Heartbeat{
+		Instance:        m.config.AppName,
+		Time:            now,
+		IntervalSeconds: interval.Seconds(),
+		UptimeSeconds:   now.Sub(m.startTime).Seconds(),
+		Connected:       m.isConnected,
+		Alerts:          m.activeAlerts(),
+	}
+	if m.isConnected {
+		hb.IPv4 = m.lastIPs.IPv4
+		hb.IPv6 = m.lastIPs.IPv6
+	}
+	if m.ups != nil {
+		if st := m.ups.Last(); st != nil {
+			hb.OnBattery = st.OnBattery()
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, 10*time.Second)
+	defer cancel()
+	if err := m.peerClient.Send(ctx, hb); err != nil {
+		utils.WriteLog(fmt.Sprintf("[MONITOR] Error enviando heartbeat a %s: %v", m.peerName, err), m.debug)
+	}
+}
+
+// activeAlerts lista los problemas abiertos que se informan a la otra instancia
+func (m *Monitor) activeAlerts() []string {
+	var alerts []string
+	if m.quality != nil && m.quality.Degraded() {
+		alerts = append(alerts, "conexión degradada")
+	}
+	if m.behindCGNAT {
+		alerts = append(alerts, "detrás de CGNAT")
+	}
+	if m.resourceTracker != nil {
+		for _, a := range m.resourceTracker.Active() {
+			alerts = append(alerts, a.Description)
+		}
+	}
+	if m.checks != nil {
+		for _, c := range m.checks.Snapshot() {
+			if c.Status == checks.StatusDown {
+				alerts = append(alerts, "check "+c.Name+" caído")
+			}
+		}
+	}
+	if m.pings != nil {
+		for _, c := range m.pings.Snapshot() {
+			if c.Status == pings.StatusDown {
+				alerts = append(alerts, "ping "+c.ID+" caído")
+			}
+		}
+	}
+	if m.docker != nil {
+		for _, c := range m.docker.States() {
+			if len(c.Problems) > 0 {
+				alerts = append(alerts, "contenedor "+c.Name+": "+strings.Join(c.Problems, ", "))
+			}
+		}
+	}
+	if m.systemd != nil {
+		for _, u := range m.systemd.States() {
+			if u.Failed() {
+				alerts = append(alerts, "unidad "+u.Name+" "+u.ActiveState)
+			}
+		}
+	}
+	return alerts
+}
@@ -0,0 +1,447 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"orgmserver/config"
+	"orgmserver/email"
+	"orgmserver/utils"
+	"orgmserver/utils/clocktest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeResolver retorna las IP indicadas o, con offline, un error como sin internet
+type fakeResolver struct {
+	ips     utils.ExternalIPs
+	offline bool
+}
+
+func (r *fakeResolver) GetExternalIPs(ctx context.Context, withIPv6 bool) (utils.ExternalIPs, error) {
+	if r.offline {
+		return utils.ExternalIPs{}, errors.New("sin conexión")
+	}
+	ips := r.ips
+	if !withIPv6 {
+		ips.IPv6 = ""
+	}
+	return ips, nil
+}
+
+// memStore guarda el estado en memoria; compartirlo entre dos monitores simula un reinicio
+type memStore struct {
+	mu    sync.Mutex
+	state utils.State
+}
+
+func (s *memStore) Get() utils.State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+func (s *memStore) Update(fn func(*utils.State)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.state)
+	return nil
+}
+
+// fakeNotifier registra los avisos enviados como "tipo detalle..."
+type fakeNotifier struct {
+	mu   sync.Mutex
+	sent []string
+
+	shutdownErr error // error que retorna SendShutdownEmail
+	failing     bool  // todos los envíos fallan, como sin salida al servidor SMTP
+}
+
+func (n *fakeNotifier) record(kind string, args ...interface{}) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.failing {
+		return errors.New("servidor SMTP no disponible")
+	}
+	n.sent = append(n.sent, strings.TrimSpace(kind+" "+fmt.Sprintln(args...)))
+	return nil
+}
+
+// take retorna los avisos enviados desde la última llamada
+func (n *fakeNotifier) take() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	sent := n.sent
+	n.sent = nil
+	return sent
+}
+
+func (n *fakeNotifier) SendReconnectionEmail(ip string, duration time.Duration, quality string) error {
+	return n.record("reconnection", ip, duration)
+}
+func (n *fakeNotifier) SendIPChangeEmail(family string, newIP string, oldIP string, ddnsReport string) error {
+	return n.record("ip_change", family, oldIP, newIP)
+}
+func (n *fakeNotifier) SendIPv6PrefixChangeEmail(newPrefix string, oldPrefix string, newIP string, ddnsReport string) error {
+	return n.record("ipv6_prefix_change", oldPrefix, newPrefix)
+}
+func (n *fakeNotifier) SendDNSMismatchEmail(ip string, details string) error {
+	return n.record("dns_mismatch", ip)
+}
+func (n *fakeNotifier) SendDNSResolvedEmail(ip string) error {
+	return n.record("dns_resolved", ip)
+}
+func (n *fakeNotifier) SendCGNATEmail(routerIP, publicIP, reason string) error {
+	return n.record("cgnat", routerIP, publicIP)
+}
+func (n *fakeNotifier) SendCGNATClearedEmail(ip string) error {
+	return n.record("cgnat_cleared", ip)
+}
+func (n *fakeNotifier) SendDegradedEmail(ip string, period time.Duration, reasons string, stats string) error {
+	return n.record("degraded", reasons)
+}
+func (n *fakeNotifier) SendDegradationClearedEmail(ip string, stats string) error {
+	return n.record("degradation_cleared")
+}
+func (n *fakeNotifier) SendReportEmail(ip string, since time.Time, sections []email.ReportSection) error {
+	return n.record("report", len(sections))
+}
+func (n *fakeNotifier) SendSlowSpeedEmail(ip string, below string, result string) error {
+	return n.record("slow_speed", below)
+}
+func (n *fakeNotifier) SendSpeedRestoredEmail(ip string, result string) error {
+	return n.record("speed_restored")
+}
+func (n *fakeNotifier) SendCheckDownEmail(name, checkType, target, errMsg string, since time.Time) error {
+	return n.record("check_down", name)
+}
+func (n *fakeNotifier) SendCheckUpEmail(name, checkType, target string, downtime time.Duration) error {
+	return n.record("check_up", name, downtime)
+}
+func (n *fakeNotifier) SendCertificateAlertEmail(name, target, problem string) error {
+	return n.record("certificate_alert", name)
+}
+func (n *fakeNotifier) SendCertificateOKEmail(name, target string) error {
+	return n.record("certificate_ok", name)
+}
+func (n *fakeNotifier) SendPingDownEmail(name, id, reason string, lastPing time.Time, output string) error {
+	return n.record("ping_down", id)
+}
+func (n *fakeNotifier) SendPingUpEmail(name, id string, downtime time.Duration) error {
+	return n.record("ping_up", id, downtime)
+}
+func (n *fakeNotifier) SendResourceAlertEmail(alert string, snapshot string) error {
+	return n.record("resource_alert", alert)
+}
+func (n *fakeNotifier) SendResourceRecoveredEmail(alert string, snapshot string) error {
+	return n.record("resource_recovered", alert)
+}
+func (n *fakeNotifier) SendContainerAlertEmail(container, problem, detail string) error {
+	return n.record("container_alert", container, problem)
+}
+func (n *fakeNotifier) SendContainerRecoveredEmail(container, problem, status string) error {
+	return n.record("container_recovered", container, problem)
+}
+func (n *fakeNotifier) SendUnitAlertEmail(unit, problem, detail string) error {
+	return n.record("unit_alert", unit, problem)
+}
+func (n *fakeNotifier) SendUnitRecoveredEmail(unit, problem, state string) error {
+	return n.record("unit_recovered", unit, problem)
+}
+func (n *fakeNotifier) SendOnBatteryEmail(ups, status string, at time.Time) error {
+	return n.record("on_battery", ups)
+}
+func (n *fakeNotifier) SendLowBatteryEmail(ups, status string, at time.Time) error {
+	return n.record("low_battery", ups)
+}
+func (n *fakeNotifier) SendPowerRestoredEmail(ups, status string, onBattery time.Duration, at time.Time) error {
+	return n.record("power_restored", ups, onBattery)
+}
+func (n *fakeNotifier) SendShutdownEmail(reason, ip string, uptime, hostUptime, timeout time.Duration) error {
+	n.mu.Lock()
+	err := n.shutdownErr
+	n.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return n.record("shutdown", reason)
+}
+func (n *fakeNotifier) SendPeerSilentEmail(peer string, silence time.Duration, last string) error {
+	return n.record("peer_silent", peer, silence)
+}
+func (n *fakeNotifier) SendPeerRecoveredEmail(peer string, silence time.Duration, current string) error {
+	return n.record("peer_recovered", peer, silence)
+}
+
+// scenario agrupa un monitor con sus dependencias simuladas
+type scenario struct {
+	t        *testing.T
+	clock    *clocktest.Clock
+	resolver *fakeResolver
+	store    *memStore
+	notifier *fakeNotifier
+	mon      *Monitor
+}
+
+func newScenario(t *testing.T) *scenario {
+	s := &scenario{
+		t:        t,
+		clock:    clocktest.New(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)),
+		resolver: &fakeResolver{ips: utils.ExternalIPs{IPv4: "203.0.113.7", IPv6: "2001:db8:1:1::10"}},
+		store:    &memStore{},
+		notifier: &fakeNotifier{},
+	}
+	s.restart()
+	return s
+}
+
+// restart crea un monitor nuevo sobre el mismo estado, como tras reiniciar el servicio
+func (s *scenario) restart() {
+	cfg := &config.Config{
+		MonitorInterval:  time.Minute,
+		IPv6Enabled:      true,
+		IPv6PrefixLength: 64,
+	}
+	s.mon = NewMonitor(cfg, s.notifier, s.store, false)
+	s.mon.SetClock(s.clock)
+	s.mon.SetIPResolver(s.resolver)
+}
+
+// tick avanza el reloj un intervalo y ejecuta una vuelta del loop
+func (s *scenario) tick() {
+	s.clock.Advance(time.Minute)
+	s.mon.runCycle()
+}
+
+// expect verifica los avisos enviados desde la última verificación
+func (s *scenario) expect(want ...string) {
+	s.t.Helper()
+	got := s.notifier.take()
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		s.t.Errorf("avisos:\n  %s\nse esperaba:\n  %s", strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+	}
+}
+
+func TestScenarioOutage(t *testing.T) {
+	s := newScenario(t)
+	s.tick()
+	s.expect()
+	if !s.store.Get().IsConnected {
+		t.Fatal("el estado debe registrar la conexión")
+	}
+
+	// Se cae internet: se registra el inicio del corte sin avisar (no hay cómo)
+	s.resolver.offline = true
+	s.tick()
+	start := s.clock.Now()
+	for i := 0; i < 9; i++ {
+		s.tick()
+	}
+	s.expect()
+
+	st := s.store.Get()
+	if st.IsConnected || !st.LastDisconnected.Equal(start) {
+		t.Errorf("estado durante el corte: conectado=%v desde %v, se esperaba %v", st.IsConnected, st.LastDisconnected, start)
+	}
+	if s.mon.Status().Connected {
+		t.Error("el estado publicado debe indicar sin conexión")
+	}
+
+	// Vuelve internet: un aviso con la duración exacta del corte
+	s.resolver.offline = false
+	s.tick()
+	s.expect("reconnection 203.0.113.7, 2001:db8:1:1::10 10m0s")
+
+	st = s.store.Get()
+	if !st.IsConnected || !st.LastDisconnected.IsZero() || !st.LastConnected.Equal(s.clock.Now()) {
+		t.Errorf("estado tras reconectar: %+v", st)
+	}
+	if s.mon.outages != 1 || s.mon.downtime != 10*time.Minute {
+		t.Errorf("cortes = %d, tiempo sin conexión = %s", s.mon.outages, s.mon.downtime)
+	}
+}
+
+func TestScenarioFlapping(t *testing.T) {
+	s := newScenario(t)
+	s.tick()
+
+	// La conexión se corta y vuelve en vueltas alternadas: cada corte se avisa una
+	// sola vez al volver, con su propia duración
+	for i := 0; i < 3; i++ {
+		s.resolver.offline = true
+		s.tick()
+		s.tick()
+		s.resolver.offline = false
+		s.tick()
+	}
+	s.expect(
+		"reconnection 203.0.113.7, 2001:db8:1:1::10 2m0s",
+		"reconnection 203.0.113.7, 2001:db8:1:1::10 2m0s",
+		"reconnection 203.0.113.7, 2001:db8:1:1::10 2m0s",
+	)
+	if s.mon.outages != 3 || s.mon.downtime != 6*time.Minute {
+		t.Errorf("cortes = %d, tiempo sin conexión = %s", s.mon.outages, s.mon.downtime)
+	}
+	if !s.mon.lastOutageEnd.Equal(s.clock.Now()) || s.mon.lastOutageDuration != 2*time.Minute {
+		t.Errorf("último corte: %v, %s", s.mon.lastOutageEnd, s.mon.lastOutageDuration)
+	}
+}
+
+func TestScenarioIPChange(t *testing.T) {
+	s := newScenario(t)
+	s.tick()
+
+	s.resolver.ips.IPv4 = "198.51.100.20"
+	s.tick()
+	s.expect("ip_change IPv4 203.0.113.7 198.51.100.20")
+	if st := s.store.Get(); st.LastIPv4 != "198.51.100.20" || st.LastIP != "198.51.100.20" {
+		t.Errorf("IP guardada: %q, %q", st.LastIPv4, st.LastIP)
+	}
+
+	// Otra dirección dentro del mismo prefijo IPv6 no se avisa por defecto
+	s.resolver.ips.IPv6 = "2001:db8:1:1::20"
+	s.tick()
+	s.expect()
+
+	// Un prefijo delegado nuevo sí
+	s.resolver.ips.IPv6 = "2001:db8:9:1::20"
+	s.tick()
+	s.expect("ipv6_prefix_change 2001:db8:1:1::/64 2001:db8:9:1::/64")
+	if st := s.store.Get(); st.LastIPv6Prefix != "2001:db8:9:1::/64" {
+		t.Errorf("prefijo guardado: %q", st.LastIPv6Prefix)
+	}
+
+	// Sin cambios no hay avisos
+	s.tick()
+	s.expect()
+}
+
+func TestScenarioNoConsensusKeepsIP(t *testing.T) {
+	s := newScenario(t)
+	s.tick()
+
+	// Conectado pero sin consenso en ninguna familia: no se borra la IP guardada
+	s.resolver.ips = utils.ExternalIPs{}
+	s.tick()
+	s.expect()
+	if st := s.store.Get(); st.LastIP != "203.0.113.7" || st.LastIPv4 != "203.0.113.7" || st.LastIPv6 != "2001:db8:1:1::10" {
+		t.Errorf("IP guardada: %q, %q, %q", st.LastIP, st.LastIPv4, st.LastIPv6)
+	}
+}
+
+func TestScenarioIPChangeDuringOutage(t *testing.T) {
+	s := newScenario(t)
+	s.tick()
+
+	s.resolver.offline = true
+	s.tick()
+	s.clock.Advance(4 * time.Minute)
+	s.resolver.offline = false
+	s.resolver.ips.IPv4 = "198.51.100.20"
+	s.tick()
+
+	s.expect(
+		"reconnection 198.51.100.20, 2001:db8:1:1::10 5m0s",
+		"ip_change IPv4 203.0.113.7 198.51.100.20",
+	)
+	if st := s.store.Get(); st.LastIPv4 != "198.51.100.20" {
+		t.Errorf("IP guardada: %q", st.LastIPv4)
+	}
+}
+
+func TestScenarioRestart(t *testing.T) {
+	s := newScenario(t)
+	s.tick()
+
+	// Detención ordenada: el estado final lo escribe el monitor
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.mon.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.mon.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	stopped := s.clock.Now()
+	st := s.store.Get()
+	if st.IsConnected || !st.LastConnected.Equal(stopped) {
+		t.Errorf("estado final: conectado=%v, última conexión %v (se esperaba %v)", st.IsConnected, st.LastConnected, stopped)
+	}
+
+	// Reinicio con la misma IP: no hay nada que avisar
+	s.clock.Advance(30 * time.Second)
+	s.restart()
+	s.tick()
+	s.expect()
+
+	// La IP cambió mientras el servicio estaba detenido: se compara con la guardada
+	s.clock.Advance(time.Hour)
+	s.resolver.ips.IPv4 = "198.51.100.20"
+	s.restart()
+	s.tick()
+	s.expect("ip_change IPv4 203.0.113.7 198.51.100.20")
+}
+
+func TestScenarioRestartDuringOutage(t *testing.T) {
+	s := newScenario(t)
+	s.tick()
+	s.resolver.offline = true
+	s.tick()
+	s.mon.saveFinalState()
+
+	// Sin internet al reiniciar: el corte se vuelve a registrar desde el reinicio y
+	// al volver se avisa una sola vez
+	s.clock.Advance(time.Minute)
+	s.restart()
+	s.tick()
+	restarted := s.clock.Now()
+	if st := s.store.Get(); !st.LastDisconnected.Equal(restarted) {
+		t.Errorf("inicio del corte = %v, se esperaba %v", st.LastDisconnected, restarted)
+	}
+
+	s.tick()
+	s.resolver.offline = false
+	s.tick()
+	s.expect("reconnection 203.0.113.7, 2001:db8:1:1::10 2m0s")
+}
+
+func TestNotifyShutdownOnce(t *testing.T) {
+	s := newScenario(t)
+	s.mon.SetShutdownNotice(10 * time.Second)
+
+	// La batería baja del UPS y el SIGTERM pueden llegar a la vez
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.mon.NotifyShutdown("SIGTERM"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	s.expect("shutdown SIGTERM")
+
+	s.mon.NotifyShutdown("batería baja")
+	s.expect()
+}
+
+func TestNotifyShutdownRetriesAfterFailure(t *testing.T) {
+	s := newScenario(t)
+	s.mon.SetShutdownNotice(10 * time.Second)
+
+	s.notifier.shutdownErr = errors.New("SMTP no disponible")
+	if err := s.mon.NotifyShutdown("batería baja"); err == nil {
+		t.Fatal("se esperaba el error del envío")
+	}
+	s.expect()
+
+	s.notifier.shutdownErr = nil
+	if err := s.mon.NotifyShutdown("SIGTERM"); err != nil {
+		t.Fatal(err)
+	}
+	s.expect("shutdown SIGTERM")
+}
@@ -0,0 +1,178 @@
+package monitor
+
+import (
+	"encoding/json"
+	"net/http"
+	"orgmserver/docker"
+	"orgmserver/peer"
+	"orgmserver/resources"
+	"orgmserver/systemd"
+	"time"
+)
+
+// Status es el estado del monitor expuesto por la API
+type Status struct {
+	Connected   bool                    `json:"connected"`
+	LastCheck   time.Time               `json:"last_check"`
+	IPv4        string                  `json:"ipv4,omitempty"`
+	IPv6        string                  `json:"ipv6,omitempty"`
+	Quality     *QualityStatus          `json:"quality,omitempty"`
+	Checks      []CheckStatus           `json:"checks,omitempty"`
+	Pings       []PingStatus            `json:"pings,omitempty"`
+	Resources   *resources.Snapshot     `json:"resources,omitempty"`
+	Alerts      []string                `json:"resource_alerts,omitempty"`
+	BehindCGNAT bool                    `json:"behind_cgnat,omitempty"`
+	Containers  []docker.ContainerState `json:"containers,omitempty"`
+	Units       []systemd.UnitState     `json:"systemd_units,omitempty"`
+	Power       *PowerStatus            `json:"power,omitempty"`
+	Peer        *PeerStatus             `json:"peer,omitempty"`
+}
+
+// PeerStatus es el estado de la otra instancia según sus heartbeats
+type PeerStatus struct {
+	Name          string          `json:"name"`
+	Silent        bool            `json:"silent"`
+	LastHeartbeat *peer.Heartbeat `json:"last_heartbeat,omitempty"`
+}
+
+// PowerStatus es el último estado leído del UPS; la carga y la autonomía valen -1
+// si el UPS no las informa
+type PowerStatus struct {
+	UPS            string   `json:"ups"`
+	Flags          []string `json:"flags"`
+	OnBattery      bool     `json:"on_battery"`
+	LowBattery     bool     `json:"low_battery"`
+	Charge         float64  `json:"battery_charge"`
+	RuntimeSeconds float64  `json:"battery_runtime_seconds"`
+}
+
+// QualityStatus resume la última medición de latencia
+type QualityStatus struct {
+	Degraded bool    `json:"degraded"`
+	RTTMs    float64 `json:"rtt_ms"`
+	JitterMs float64 `json:"jitter_ms"`
+	LossPct  float64 `json:"loss_percent"`
+}
+
+// CheckStatus es el estado de un check de servicio
+type CheckStatus struct {
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Status    string    `json:"status"`
+	Since     time.Time `json:"since,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// PingStatus es el estado de un check que recibe pings
+type PingStatus struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Since     time.Time `json:"since,omitempty"`
+	LastPing  time.Time `json:"last_ping,omitempty"`
+	Running   bool      `json:"running,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// publishStatus copia el estado actual para que la API lo lea sin bloquear el loop
+func (m *Monitor) publishStatus() {
+	st := Status{
+		Connected:   m.isConnected,
+		LastCheck:   m.lastCheck,
+		IPv4:        m.lastIPs.IPv4,
+		IPv6:        m.lastIPs.IPv6,
+		Resources:   m.lastResources,
+		BehindCGNAT: m.behindCGNAT,
+	}
+
+	if m.prober != nil && !m.lastSample.Time.IsZero() {
+		st.Quality = &QualityStatus{
+			Degraded: m.quality.Degraded(),
+			RTTMs:    float64(m.lastSample.RTT) / float64(time.Millisecond),
+			JitterMs: float64(m.lastSample.Jitter) / float64(time.Millisecond),
+			LossPct:  m.lastSample.Loss(),
+		}
+	}
+
+	if m.checks != nil {
+		for _, c := range m.checks.Snapshot() {
+			st.Checks = append(st.Checks, CheckStatus{
+				Name:      c.Name,
+				Type:      c.Type,
+				Status:    string(c.Status),
+				Since:     c.Since,
+				LastError: c.LastError,
+			})
+		}
+	}
+
+	if m.pings != nil {
+		for _, c := range m.pings.Snapshot() {
+			st.Pings = append(st.Pings, PingStatus{
+				ID:        c.ID,
+				Name:      c.Name,
+				Status:    string(c.Status),
+				Since:     c.Since,
+				LastPing:  c.LastPing,
+				Running:   !c.Started.IsZero(),
+				LastError: c.LastError,
+			})
+		}
+	}
+
+	if m.docker != nil {
+		st.Containers = m.docker.States()
+	}
+
+	if m.systemd != nil {
+		st.Units = m.systemd.States()
+	}
+
+	if m.ups != nil {
+		if p := m.ups.Last(); p != nil {
+			st.Power = &PowerStatus{
+				UPS:            p.UPS,
+				Flags:          p.Flags,
+				OnBattery:      p.OnBattery(),
+				LowBattery:     p.LowBattery(),
+				Charge:         p.Charge,
+				RuntimeSeconds: -1,
+			}
+			if p.Runtime >= 0 {
+				st.Power.RuntimeSeconds = p.Runtime.Seconds()
+			}
+		}
+	}
+
+	if m.resourceTracker != nil {
+		for _, a := range m.resourceTracker.Active() {
+			st.Alerts = append(st.Alerts, a.Description)
+		}
+	}
+
+	if m.peerTracker != nil {
+		last, silent := m.peerTracker.Last()
+		st.Peer = &PeerStatus{Name: m.peerName, Silent: silent, LastHeartbeat: last}
+	}
+
+	m.statusMu.Lock()
+	m.status = st
+	m.statusMu.Unlock()
+}
+
+// Status retorna el último estado publicado
+func (m *Monitor) Status() Status {
+	m.statusMu.Lock()
+	defer m.statusMu.Unlock()
+	return m.status
+}
+
+// StatusHandler sirve el estado en JSON
+func (m *Monitor) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(m.Status())
+	})
+}
@@ -0,0 +1,110 @@
+// Package peer implementa el heartbeat entre dos instancias de orgmserver en sitios
+// distintos: cada una envía su estado a la otra por HTTP firmado con un secreto
+// compartido y avisa cuando la otra deja de reportarse.
+package peer
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Path es la ruta donde cada instancia recibe los heartbeats
+const Path = "/peer/heartbeat"
+
+// SignatureHeader lleva el HMAC-SHA256 del cuerpo con el secreto compartido
+const SignatureHeader = "X-Orgmserver-Signature"
+
+// maxSkew es la diferencia de reloj tolerada; evita que se reenvíe un heartbeat viejo
+const maxSkew = 5 * time.Minute
+
+// Heartbeat es el estado que una instancia reporta a la otra
+type Heartbeat struct {
+	Instance        string    `json:"instance"`
+	Time            time.Time `json:"time"`
+	IntervalSeconds float64   `json:"interval_seconds"`
+	UptimeSeconds   float64   `json:"uptime_seconds"`
+	Connected       bool      `json:"connected"`
+	IPv4            string    `json:"ipv4,omitempty"`
+	IPv6            string    `json:"ipv6,omitempty"`
+	OnBattery       bool      `json:"on_battery,omitempty"`
+	Alerts          []string  `json:"alerts,omitempty"`
+}
+
+// Describe resume el heartbeat en texto para los correos
+func (h Heartbeat) Describe() string {
+	state := "con internet"
+	if !h.Connected {
+		state = "sin internet"
+	}
+	ip := strings.TrimSpace(h.IPv4 + " " + h.IPv6)
+	if ip == "" {
+		ip = "no disponible"
+	}
+	lines := []string{
+		"Instancia: " + h.Instance,
+		"Último heartbeat: " + h.Time.Local().Format("2006-01-02 15:04:05"),
+		"Estado reportado: " + state,
+		"IP reportada: " + ip,
+		"Tiempo en servicio: " + (time.Duration(h.UptimeSeconds) * time.Second).String(),
+	}
+	if h.OnBattery {
+		lines = append(lines, "Alimentación: UPS en batería")
+	}
+	for _, a := range h.Alerts {
+		lines = append(lines, "Alerta: "+a)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sign calcula la firma del cuerpo
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Client envía heartbeats a la otra instancia
+type Client struct {
+	URL    string // URL base de la otra instancia, por ejemplo https://sitio-b:9100
+	Secret string
+	HTTP   *http.Client
+}
+
+// Send envía un heartbeat firmado
+func (c *Client) Send(ctx context.Context, hb Heartbeat) error {
+	body, err := json.Marshal(hb)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(c.URL, "/")+Path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, sign(c.Secret, body))
+
+	client := c.HTTP
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("la otra instancia respondió %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package peer
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"orgmserver/utils"
+	"sync"
+	"time"
+)
+
+// Tipos de evento de la otra instancia
+const (
+	EventSilent    = "silent"
+	EventRecovered = "recovered"
+)
+
+// Event es un cambio en la comunicación con la otra instancia
+type Event struct {
+	Type string
+	// Last es el último heartbeat recibido; nil si nunca se recibió uno
+	Last *Heartbeat
+	// Silence es el tiempo sin heartbeats al momento del evento
+	Silence time.Duration
+}
+
+// Tracker recibe los heartbeats y detecta cuando la otra instancia deja de reportarse
+type Tracker struct {
+	Secret   string
+	Interval time.Duration // intervalo esperado si el heartbeat no lo informa
+	Missed   int           // heartbeats perdidos seguidos para considerarla en silencio
+	Clock    utils.Clock   // hora de recepción; nil usa el reloj del sistema
+
+	mu       sync.Mutex
+	last     *Heartbeat
+	received time.Time // hora local de recepción del último heartbeat
+	silent   bool
+	quietFor time.Time // desde cuándo se cuenta el silencio en curso
+}
+
+func (t *Tracker) now() time.Time {
+	if t.Clock == nil {
+		return utils.SystemClock.Now()
+	}
+	return t.Clock.Now()
+}
+
+// Handler atiende POST Path verificando la firma y la antigüedad del heartbeat
+func (t *Tracker) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "método no permitido", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+		if err != nil {
+			http.Error(w, "cuerpo inválido", http.StatusBadRequest)
+			return
+		}
+		if !hmac.Equal([]byte(r.Header.Get(SignatureHeader)), []byte(sign(t.Secret, body))) {
+			http.Error(w, "firma inválida", http.StatusUnauthorized)
+			return
+		}
+
+		var hb Heartbeat
+		if err := json.Unmarshal(body, &hb); err != nil {
+			http.Error(w, "heartbeat inválido", http.StatusBadRequest)
+			return
+		}
+		if err := t.record(hb, t.now()); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// record guarda el heartbeat si es reciente y posterior al último
+func (t *Tracker) record(hb Heartbeat, now time.Time) error {
+	if skew := now.Sub(hb.Time); skew > maxSkew || skew < -maxSkew {
+		return fmt.Errorf("heartbeat fuera de hora (diferencia %s); verifique la hora de ambos equipos", skew.Round(time.Second))
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.last != nil && !hb.Time.After(t.last.Time) {
+		return fmt.Errorf("heartbeat repetido o anterior al último")
+	}
+	t.last = &hb
+	t.received = now
+	return nil
+}
+
+// Check evalúa el silencio de la otra instancia. since es el momento desde el que
+// se espera recibir heartbeats (inicio del servicio o recuperación de la propia
+// conexión), para no culpar a la otra instancia de un corte propio.
+func (t *Tracker) Check(now, since time.Time) []Event {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	interval := t.Interval
+	if t.last != nil && t.last.IntervalSeconds > 0 {
+		interval = time.Duration(t.last.IntervalSeconds * float64(time.Second))
+	}
+	limit := time.Duration(t.Missed) * interval
+
+	ref := since
+	if t.received.After(ref) {
+		ref = t.received
+	}
+
+	switch {
+	case !t.silent && now.Sub(ref) > limit:
+		// El silencio informado se cuenta desde el último heartbeat, si lo hubo
+		t.silent = true
+		t.quietFor = ref
+		if !t.received.IsZero() {
+			t.quietFor = t.received
+		}
+		return []Event{{Type: EventSilent, Last: t.copyLast(), Silence: now.Sub(t.quietFor)}}
+	case t.silent && t.received.After(t.quietFor):
+		t.silent = false
+		return []Event{{Type: EventRecovered, Last: t.copyLast(), Silence: t.received.Sub(t.quietFor)}}
+	}
+	return nil
+}
+
+func (t *Tracker) copyLast() *Heartbeat {
+	if t.last == nil {
+		return nil
+	}
+	hb := *t.last
+	return &hb
+}
+
+// Last retorna el último heartbeat recibido y si la otra instancia está en silencio
+func (t *Tracker) Last() (*Heartbeat, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.copyLast(), t.silent
+}
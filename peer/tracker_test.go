@@ -0,0 +1,105 @@
+package peer
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"orgmserver/utils/clocktest"
+	"testing"
+	"time"
+)
+
+func post(t *testing.T, tr *Tracker, secret string, hb Heartbeat) int {
+	t.Helper()
+	body, err := json.Marshal(hb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, Path, bytes.NewReader(body))
+	req.Header.Set(SignatureHeader, sign(secret, body))
+	rec := httptest.NewRecorder()
+	tr.Handler().ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestTrackerRejectsHeartbeats(t *testing.T) {
+	clock := clocktest.New(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+	tr := &Tracker{Secret: "s3creto", Interval: time.Minute, Missed: 3, Clock: clock}
+	now := clock.Now()
+
+	if code := post(t, tr, "otro", Heartbeat{Instance: "b", Time: now}); code != http.StatusUnauthorized {
+		t.Errorf("firma inválida: status = %d", code)
+	}
+	// La diferencia de hora se mide contra la hora de recepción
+	for _, at := range []time.Time{now.Add(-6 * time.Minute), now.Add(6 * time.Minute)} {
+		if code := post(t, tr, "s3creto", Heartbeat{Instance: "b", Time: at}); code != http.StatusConflict {
+			t.Errorf("heartbeat de las %s: status = %d", at.Format("15:04"), code)
+		}
+	}
+	if code := post(t, tr, "s3creto", Heartbeat{Instance: "b", Time: now.Add(4 * time.Minute)}); code != http.StatusNoContent {
+		t.Fatalf("heartbeat dentro de la tolerancia: status = %d", code)
+	}
+	// Un heartbeat repetido o anterior al último se descarta aunque esté en hora
+	clock.Advance(time.Minute)
+	if code := post(t, tr, "s3creto", Heartbeat{Instance: "b", Time: now.Add(time.Minute)}); code != http.StatusConflict {
+		t.Errorf("heartbeat anterior al último: status = %d", code)
+	}
+	if hb, _ := tr.Last(); hb == nil || !hb.Time.Equal(now.Add(4*time.Minute)) {
+		t.Errorf("último heartbeat = %+v", hb)
+	}
+
+	rec := httptest.NewRecorder()
+	tr.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, Path, nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status = %d", rec.Code)
+	}
+}
+
+func TestTrackerSilenceAndRecovery(t *testing.T) {
+	clock := clocktest.New(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+	tr := &Tracker{Secret: "s3creto", Interval: time.Minute, Missed: 3, Clock: clock}
+	start := clock.Now()
+
+	// Sin heartbeats el silencio se cuenta desde since
+	clock.Advance(3 * time.Minute)
+	if events := tr.Check(clock.Now(), start); len(events) != 0 {
+		t.Fatalf("silencio antes de tres intervalos: %+v", events)
+	}
+	clock.Advance(time.Second)
+	events := tr.Check(clock.Now(), start)
+	if len(events) != 1 || events[0].Type != EventSilent || events[0].Last != nil || events[0].Silence != 3*time.Minute+time.Second {
+		t.Fatalf("eventos = %+v", events)
+	}
+	// El silencio se avisa una sola vez
+	clock.Advance(10 * time.Minute)
+	if events := tr.Check(clock.Now(), start); len(events) != 0 {
+		t.Fatalf("aviso repetido: %+v", events)
+	}
+
+	// La recuperación informa el silencio hasta el heartbeat recibido
+	if code := post(t, tr, "s3creto", Heartbeat{Instance: "b", Time: clock.Now(), IntervalSeconds: 120}); code != http.StatusNoContent {
+		t.Fatalf("status = %d", code)
+	}
+	clock.Advance(time.Minute)
+	events = tr.Check(clock.Now(), start)
+	if len(events) != 1 || events[0].Type != EventRecovered || events[0].Silence != 13*time.Minute+time.Second {
+		t.Fatalf("eventos = %+v", events)
+	}
+
+	// El intervalo informado por la otra instancia reemplaza al configurado
+	received := clock.Now().Add(-time.Minute)
+	clock.Set(received.Add(6 * time.Minute))
+	if events := tr.Check(clock.Now(), start); len(events) != 0 {
+		t.Fatalf("silencio con intervalo de 2m: %+v", events)
+	}
+	// Tras recuperar la conexión propia el plazo se cuenta desde since
+	clock.Advance(time.Minute)
+	if events := tr.Check(clock.Now(), clock.Now().Add(-time.Minute)); len(events) != 0 {
+		t.Fatalf("silencio tras un corte propio: %+v", events)
+	}
+	events = tr.Check(clock.Now(), start)
+	if len(events) != 1 || events[0].Type != EventSilent || events[0].Last.Instance != "b" || events[0].Silence != 7*time.Minute {
+		t.Fatalf("eventos = %+v", events)
+	}
+}
@@ -0,0 +1,292 @@
+// Package pings recibe pings de trabajos programados y otros equipos en
+// /ping/<id> (al estilo de healthchecks.io) y detecta los que se atrasan.
+package pings
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"orgmserver/utils"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prefix es la ruta bajo la que se reciben los pings
+const Prefix = "/ping/"
+
+var validID = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Definition describe un check del archivo de configuración. Los tiempos están en segundos.
+type Definition struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Period int    `json:"period"` // cada cuánto se espera un ping
+	Grace  int    `json:"grace"`  // tolerancia tras el período, y duración máxima tras /start
+}
+
+// Load lee y valida el archivo de checks de ping
+func Load(path string) ([]Definition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var defs []Definition
+	if err := json.Unmarshal(data, &defs); err != nil {
+		return nil, fmt.Errorf("archivo de pings inválido: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	for i := range defs {
+		d := &defs[i]
+		if !validID.MatchString(d.ID) {
+			return nil, fmt.Errorf("ping %d: id inválido %q (letras, números, '.', '_' y '-')", i+1, d.ID)
+		}
+		if seen[d.ID] {
+			return nil, fmt.Errorf("ping duplicado: %s", d.ID)
+		}
+		seen[d.ID] = true
+
+		if d.Name == "" {
+			d.Name = d.ID
+		}
+		if d.Period <= 0 {
+			return nil, fmt.Errorf("ping %s: falta period", d.ID)
+		}
+		if d.Grace <= 0 {
+			d.Grace = 300
+		}
+	}
+	return defs, nil
+}
+
+// Status es el estado de un check de ping
+type Status string
+
+const (
+	StatusUnknown Status = ""
+	StatusUp      Status = "up"
+	StatusDown    Status = "down"
+)
+
+// Check es un check de ping con su estado
+type Check struct {
+	Definition
+
+	Status    Status
+	Since     time.Time
+	LastPing  time.Time
+	Started   time.Time // último /start sin ping de finalización
+	LastBody  string    // cuerpo del último ping (salida del trabajo)
+	LastError string    // motivo de la caída
+
+	notified  Status
+	prevSince time.Time
+}
+
+// Transition es un cambio de estado todavía no notificado
+type Transition struct {
+	ID       string
+	Name     string
+	Status   Status
+	Since    time.Time
+	Error    string
+	LastPing time.Time
+	Body     string
+	Previous time.Time // inicio del estado anterior (para calcular la duración de una caída)
+}
+
+// Registry guarda los checks, atiende los pings y recuerda qué cambios se notificaron
+type Registry struct {
+	mu      sync.Mutex
+	checks  []*Check
+	clock   utils.Clock
+	started time.Time
+}
+
+func NewRegistry(defs []Definition, clock utils.Clock) *Registry {
+	r := &Registry{clock: clock, started: clock.Now()}
+	for _, d := range defs {
+		r.checks = append(r.checks, &Check{Definition: d})
+	}
+	return r
+}
+
+func (r *Registry) find(id string) *Check {
+	for _, c := range r.checks {
+		if c.ID == id {
+			return c
+		}
+	}
+	return nil
+}
+
+// Restore recupera el estado guardado de un check tras un reinicio
+func (r *Registry) Restore(id string, status Status, since, lastPing time.Time, notified Status) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c := r.find(id); c != nil {
+		c.Status = status
+		c.Since = since
+		c.LastPing = lastPing
+		c.notified = notified
+	}
+}
+
+// Handler atiende /ping/<id>, /ping/<id>/start, /ping/<id>/fail y /ping/<id>/<código de salida>
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		id, action, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, Prefix), "/")
+
+		body, _ := io.ReadAll(io.LimitReader(req.Body, 10<<10))
+		if err := r.ping(id, action, strings.TrimSpace(string(body)), r.clock.Now()); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, "OK")
+	})
+}
+
+// ping registra un ping recibido
+func (r *Registry) ping(id, action, body string, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := r.find(id)
+	if c == nil {
+		return fmt.Errorf("check desconocido: %s", id)
+	}
+
+	failure := ""
+	switch action {
+	case "":
+	case "start":
+		c.Started = now
+		return nil
+	case "fail":
+		failure = "el trabajo informó un fallo"
+	default:
+		code, err := strconv.Atoi(action)
+		if err != nil || code < 0 || code > 255 {
+			return fmt.Errorf("acción desconocida: %s", action)
+		}
+		if code != 0 {
+			failure = fmt.Sprintf("el trabajo terminó con código de salida %d", code)
+		}
+	}
+
+	c.LastPing = now
+	c.Started = time.Time{}
+	c.LastBody = body
+	if failure != "" {
+		r.setStatus(c, StatusDown, failure, now)
+	} else {
+		r.setStatus(c, StatusUp, "", now)
+	}
+	return nil
+}
+
+func (r *Registry) setStatus(c *Check, status Status, reason string, now time.Time) {
+	c.LastError = reason
+	if c.Status == status {
+		return
+	}
+	c.prevSince = c.Since
+	c.Status = status
+	c.Since = now
+}
+
+// Evaluate marca como caídos los checks cuyo ping se atrasó más que period + grace,
+// o que enviaron /start y no terminaron dentro de grace. Un check que nunca recibió
+// un ping se cuenta desde el inicio del servicio.
+func (r *Registry) Evaluate(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, c := range r.checks {
+		if c.Status == StatusDown {
+			continue
+		}
+		period := time.Duration(c.Period) * time.Second
+		grace := time.Duration(c.Grace) * time.Second
+
+		if !c.Started.IsZero() && now.Sub(c.Started) > grace {
+			r.setStatus(c, StatusDown, fmt.Sprintf("el trabajo inició el %s y no terminó dentro de %s",
+				c.Started.Format("2006-01-02 15:04:05"), grace), now)
+			continue
+		}
+
+		ref := c.LastPing
+		if ref.IsZero() {
+			ref = r.started
+		}
+		if now.Sub(ref) > period+grace {
+			reason := "no se recibió ningún ping desde el inicio del servicio"
+			if !c.LastPing.IsZero() {
+				reason = "sin ping desde el " + c.LastPing.Format("2006-01-02 15:04:05")
+			}
+			r.setStatus(c, StatusDown, reason, now)
+		}
+	}
+}
+
+// Pending retorna los cambios de estado aún no notificados. El primer "up" tras
+// iniciar no se notifica.
+func (r *Registry) Pending() []Transition {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var pending []Transition
+	for _, c := range r.checks {
+		if c.Status == StatusUnknown || c.Status == c.notified {
+			continue
+		}
+		if c.Status == StatusUp && c.notified == StatusUnknown {
+			c.notified = StatusUp
+			continue
+		}
+		pending = append(pending, Transition{
+			ID:       c.ID,
+			Name:     c.Name,
+			Status:   c.Status,
+			Since:    c.Since,
+			Error:    c.LastError,
+			LastPing: c.LastPing,
+			Body:     c.LastBody,
+			Previous: c.prevSince,
+		})
+	}
+	return pending
+}
+
+// MarkNotified registra que se notificó el estado; si el envío falla se reintenta
+// en la siguiente vuelta
+func (r *Registry) MarkNotified(id string, status Status) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c := r.find(id); c != nil {
+		c.notified = status
+	}
+}
+
+// Snapshot retorna una copia del estado de todos los checks
+func (r *Registry) Snapshot() []Check {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := make([]Check, len(r.checks))
+	for i, c := range r.checks {
+		list[i] = *c
+	}
+	return list
+}
+
+// Notified retorna el último estado notificado de un check (para persistirlo)
+func (c *Check) Notified() Status {
+	return c.notified
+}
@@ -0,0 +1,175 @@
+package pings
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"orgmserver/utils/clocktest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// send hace una petición al handler y retorna el código de respuesta
+func send(r *Registry, method, path, body string) int {
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return rec.Code
+}
+
+func TestHandlerPingPostponesDeadline(t *testing.T) {
+	clock := clocktest.New(time.Date(2025, 6, 1, 2, 0, 0, 0, time.UTC))
+	r := NewRegistry([]Definition{{ID: "backup", Name: "Respaldo", Period: 3600, Grace: 300}}, clock)
+
+	clock.Advance(10 * time.Minute)
+	pinged := clock.Now()
+	if code := send(r, http.MethodPost, Prefix+"backup", "ok\n"); code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	if c := r.Snapshot()[0]; c.Status != StatusUp || !c.Since.Equal(pinged) || c.LastBody != "ok" {
+		t.Fatalf("tras el ping = %+v", c)
+	}
+
+	// Ya pasaron period + grace desde el inicio, pero no desde el ping
+	clock.Advance(time.Hour)
+	r.Evaluate(clock.Now())
+	if c := r.Snapshot()[0]; c.Status != StatusUp {
+		t.Fatalf("caído %s después del ping: %s", clock.Now().Sub(pinged), c.LastError)
+	}
+
+	clock.Advance(5*time.Minute + time.Second)
+	r.Evaluate(clock.Now())
+	if c := r.Snapshot()[0]; c.Status != StatusDown || c.LastError != "sin ping desde el 2025-06-01 02:10:00" {
+		t.Fatalf("tras period + grace = %+v", c)
+	}
+}
+
+func TestHandlerRejectsUnknown(t *testing.T) {
+	r := NewRegistry([]Definition{{ID: "backup", Period: 3600, Grace: 300}}, clocktest.New(time.Now()))
+
+	for _, path := range []string{Prefix + "otro", Prefix + "backup/reinicio", Prefix + "backup/256", Prefix + "backup/-1"} {
+		if code := send(r, http.MethodGet, path, ""); code != http.StatusNotFound {
+			t.Errorf("%s: status = %d", path, code)
+		}
+	}
+	if c := r.Snapshot()[0]; c.Status != StatusUnknown || !c.LastPing.IsZero() {
+		t.Errorf("un ping rechazado cambió el check: %+v", c)
+	}
+}
+
+func TestEvaluateNeverPinged(t *testing.T) {
+	clock := clocktest.New(time.Date(2025, 6, 1, 2, 0, 0, 0, time.UTC))
+	r := NewRegistry([]Definition{{ID: "backup", Period: 3600, Grace: 300}}, clock)
+
+	// El plazo se cuenta desde el inicio del servicio
+	clock.Advance(65 * time.Minute)
+	r.Evaluate(clock.Now())
+	if c := r.Snapshot()[0]; c.Status != StatusUnknown || len(r.Pending()) != 0 {
+		t.Fatalf("caído justo en period + grace: %+v", c)
+	}
+
+	clock.Advance(time.Second)
+	r.Evaluate(clock.Now())
+	pending := r.Pending()
+	if len(pending) != 1 || pending[0].Status != StatusDown || !pending[0].Since.Equal(clock.Now()) ||
+		pending[0].Error != "no se recibió ningún ping desde el inicio del servicio" || !pending[0].LastPing.IsZero() {
+		t.Fatalf("pendientes = %+v", pending)
+	}
+
+	// Una vez caído, las evaluaciones siguientes no lo modifican
+	r.MarkNotified("backup", StatusDown)
+	since := clock.Now()
+	clock.Advance(time.Hour)
+	r.Evaluate(clock.Now())
+	if c := r.Snapshot()[0]; !c.Since.Equal(since) || len(r.Pending()) != 0 {
+		t.Fatalf("tras otra hora = %+v", c)
+	}
+}
+
+func TestEvaluateStartWithoutFinish(t *testing.T) {
+	clock := clocktest.New(time.Date(2025, 6, 1, 2, 0, 0, 0, time.UTC))
+	r := NewRegistry([]Definition{{ID: "backup", Period: 3600, Grace: 300}}, clock)
+
+	send(r, http.MethodPost, Prefix+"backup", "")
+	if pending := r.Pending(); len(pending) != 0 {
+		t.Fatalf("el primer up no se notifica: %+v", pending)
+	}
+	// Un /start terminado dentro de grace no cambia el estado
+	clock.Advance(10 * time.Minute)
+	send(r, http.MethodPost, Prefix+"backup/start", "")
+	clock.Advance(4 * time.Minute)
+	r.Evaluate(clock.Now())
+	send(r, http.MethodPost, Prefix+"backup", "")
+	if c := r.Snapshot()[0]; c.Status != StatusUp || !c.Started.IsZero() || !c.LastPing.Equal(clock.Now()) {
+		t.Fatalf("tras terminar = %+v", c)
+	}
+
+	clock.Advance(20 * time.Minute)
+	send(r, http.MethodPost, Prefix+"backup/start", "")
+	started := clock.Now()
+	clock.Advance(5 * time.Minute)
+	r.Evaluate(clock.Now())
+	if c := r.Snapshot()[0]; c.Status != StatusUp {
+		t.Fatalf("caído justo en grace: %s", c.LastError)
+	}
+
+	// Sin ping de finalización se considera caído aunque el período no venció
+	clock.Advance(time.Second)
+	r.Evaluate(clock.Now())
+	c := r.Snapshot()[0]
+	if c.Status != StatusDown || !c.Started.Equal(started) ||
+		c.LastError != "el trabajo inició el 2025-06-01 02:34:00 y no terminó dentro de 5m0s" {
+		t.Fatalf("sin finalizar = %+v", c)
+	}
+
+	// El ping de finalización tardío lo recupera
+	clock.Advance(time.Minute)
+	send(r, http.MethodPost, Prefix+"backup", "")
+	if c := r.Snapshot()[0]; c.Status != StatusUp || !c.Started.IsZero() || c.LastError != "" {
+		t.Fatalf("tras finalizar = %+v", c)
+	}
+}
+
+func TestFailAndExitCodes(t *testing.T) {
+	clock := clocktest.New(time.Date(2025, 6, 1, 2, 0, 0, 0, time.UTC))
+	r := NewRegistry([]Definition{{ID: "backup", Period: 3600, Grace: 300}}, clock)
+
+	for _, tc := range []struct {
+		action string
+		status Status
+		reason string
+	}{
+		{"", StatusUp, ""},
+		{"/fail", StatusDown, "el trabajo informó un fallo"},
+		{"/0", StatusUp, ""},
+		{"/3", StatusDown, "el trabajo terminó con código de salida 3"},
+		{"/255", StatusDown, "el trabajo terminó con código de salida 255"},
+	} {
+		clock.Advance(time.Minute)
+		if code := send(r, http.MethodPost, Prefix+"backup"+tc.action, "salida"+tc.action); code != http.StatusOK {
+			t.Fatalf("%q: status = %d", tc.action, code)
+		}
+		c := r.Snapshot()[0]
+		if c.Status != tc.status || c.LastError != tc.reason || c.LastBody != "salida"+tc.action || !c.LastPing.Equal(clock.Now()) {
+			t.Errorf("%q: check = %+v", tc.action, c)
+		}
+	}
+
+	// Un fallo repetido conserva el inicio de la caída y actualiza el motivo
+	if c := r.Snapshot()[0]; !c.Since.Equal(clock.Now().Add(-time.Minute)) {
+		t.Errorf("inicio de la caída = %s", c.Since)
+	}
+	pending := r.Pending()
+	if len(pending) != 1 || pending[0].Status != StatusDown || pending[0].Body != "salida/255" {
+		t.Fatalf("pendientes = %+v", pending)
+	}
+	r.MarkNotified("backup", StatusDown)
+
+	// La recuperación informa el inicio de la caída anterior
+	downSince := pending[0].Since
+	clock.Advance(time.Minute)
+	send(r, http.MethodPost, Prefix+"backup/0", "")
+	pending = r.Pending()
+	if len(pending) != 1 || pending[0].Status != StatusUp || !pending[0].Previous.Equal(downSince) {
+		t.Fatalf("pendientes = %+v", pending)
+	}
+}
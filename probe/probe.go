@@ -0,0 +1,129 @@
+// Package probe mide la calidad de la conexión (latencia, jitter y pérdida)
+// mediante conexiones TCP a destinos conocidos y mantiene estadísticas móviles.
+package probe
+
+import (
+	"context"
+	"net"
+	"orgmserver/utils"
+	"sync"
+	"time"
+)
+
+// Prober realiza Count conexiones TCP a cada destino (host:puerto) y mide el
+// tiempo del handshake. No requiere privilegios, a diferencia de ICMP.
+type Prober struct {
+	Targets []string
+	Count   int
+	Timeout time.Duration
+	Clock   utils.Clock // hora de las muestras; nil usa el reloj del sistema
+}
+
+// Sample es el resultado de una medición sobre todos los destinos
+type Sample struct {
+	Time     time.Time
+	Sent     int
+	Received int
+	RTT      time.Duration // promedio de las conexiones exitosas
+	Jitter   time.Duration // variación media entre RTT consecutivos
+}
+
+// Loss retorna la pérdida en porcentaje
+func (s Sample) Loss() float64 {
+	if s.Sent == 0 {
+		return 0
+	}
+	return float64(s.Sent-s.Received) * 100 / float64(s.Sent)
+}
+
+type targetResult struct {
+	sent   int
+	rtts   []time.Duration
+	jitter time.Duration
+	pairs  int
+}
+
+func (p *Prober) now() time.Time {
+	if p.Clock == nil {
+		return utils.SystemClock.Now()
+	}
+	return p.Clock.Now()
+}
+
+// Measure mide todos los destinos en paralelo
+func (p *Prober) Measure(ctx context.Context) Sample {
+	results := make([]targetResult, len(p.Targets))
+	var wg sync.WaitGroup
+	for i, target := range p.Targets {
+		wg.Add(1)
+		go func(i int, target string) {
+			defer wg.Done()
+			results[i] = p.measureTarget(ctx, target)
+		}(i, target)
+	}
+	wg.Wait()
+
+	sample := Sample{Time: p.now()}
+	var total, jitter time.Duration
+	var pairs int
+	for _, r := range results {
+		sample.Sent += r.sent
+		sample.Received += len(r.rtts)
+		for _, rtt := range r.rtts {
+			total += rtt
+		}
+		jitter += r.jitter
+		pairs += r.pairs
+	}
+	if sample.Received > 0 {
+		sample.RTT = total / time.Duration(sample.Received)
+	}
+	if pairs > 0 {
+		sample.Jitter = jitter / time.Duration(pairs)
+	}
+	return sample
+}
+
+// measureTarget conecta varias veces al destino; el jitter se calcula solo entre
+// intentos consecutivos exitosos del mismo destino
+func (p *Prober) measureTarget(ctx context.Context, target string) targetResult {
+	count := p.Count
+	if count <= 0 {
+		count = 3
+	}
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+
+	var r targetResult
+	var prev time.Duration
+	dialer := net.Dialer{Timeout: timeout}
+	for i := 0; i < count; i++ {
+		if ctx.Err() != nil {
+			break
+		}
+		r.sent++
+		// El RTT se mide con el reloj monotónico, no con Clock
+		start := time.Now()
+		conn, err := dialer.DialContext(ctx, "tcp", target)
+		rtt := time.Since(start)
+		if err != nil {
+			prev = 0
+			continue
+		}
+		conn.Close()
+
+		r.rtts = append(r.rtts, rtt)
+		if prev > 0 {
+			diff := rtt - prev
+			if diff < 0 {
+				diff = -diff
+			}
+			r.jitter += diff
+			r.pairs++
+		}
+		prev = rtt
+	}
+	return r
+}
@@ -0,0 +1,79 @@
+package probe
+
+import (
+	"context"
+	"net"
+	"orgmserver/utils/clocktest"
+	"testing"
+	"time"
+)
+
+// listen abre un destino local que acepta y cierra cada conexión
+func listen(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("no se pudo escuchar: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// closedPort retorna un puerto local recién liberado, que rechaza la conexión
+func closedPort(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("no se pudo escuchar: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func TestMeasureCountsLossPerTarget(t *testing.T) {
+	p := &Prober{Targets: []string{listen(t), closedPort(t)}, Count: 3, Timeout: time.Second}
+
+	s := p.Measure(context.Background())
+	if s.Sent != 6 || s.Received != 3 || s.Loss() != 50 {
+		t.Fatalf("muestra = %+v, pérdida %.1f%%", s, s.Loss())
+	}
+	// El RTT promedia solo las conexiones exitosas
+	if s.RTT <= 0 {
+		t.Errorf("RTT = %s, se esperaba positivo", s.RTT)
+	}
+}
+
+func TestMeasuredSamplesFeedHistory(t *testing.T) {
+	clock := clocktest.New(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+	p := &Prober{Targets: []string{closedPort(t)}, Count: 2, Timeout: time.Second, Clock: clock}
+	h := NewHistory(10 * time.Minute)
+
+	// Una medición por minuto; la hora de cada muestra es la del reloj inyectado
+	// aunque el RTT se mida con el reloj real
+	for i := 0; i < 15; i++ {
+		s := p.Measure(context.Background())
+		if !s.Time.Equal(clock.Now()) {
+			t.Fatalf("hora de la muestra = %s, esperado %s", s.Time, clock.Now())
+		}
+		h.Add(s)
+		clock.Advance(time.Minute)
+	}
+
+	// Las muestras de más de 10 minutos se descartan
+	if got, want := h.Oldest(), clock.Now().Add(-11*time.Minute); !got.Equal(want) {
+		t.Fatalf("muestra más antigua = %s, se esperaba %s", got, want)
+	}
+	if st := h.Since(clock.Now().Add(-5 * time.Minute)); st.Samples != 5 || st.Sent != 10 || st.Loss() != 100 {
+		t.Errorf("últimos 5 minutos = %+v", st)
+	}
+}
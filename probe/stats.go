@@ -0,0 +1,215 @@
+package probe
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Stats resume un conjunto de muestras
+type Stats struct {
+	Samples   int
+	Sent      int
+	Received  int
+	AvgRTT    time.Duration
+	MinRTT    time.Duration
+	MaxRTT    time.Duration
+	AvgJitter time.Duration
+}
+
+// Loss retorna la pérdida acumulada en porcentaje
+func (s Stats) Loss() float64 {
+	if s.Sent == 0 {
+		return 0
+	}
+	return float64(s.Sent-s.Received) * 100 / float64(s.Sent)
+}
+
+func (s Stats) String() string {
+	if s.Samples == 0 {
+		return "Sin mediciones"
+	}
+	return fmt.Sprintf("Latencia promedio: %s (mín %s, máx %s)\nJitter promedio: %s\nPérdida: %.1f%% (%d de %d)\nMuestras: %d",
+		formatMs(s.AvgRTT), formatMs(s.MinRTT), formatMs(s.MaxRTT), formatMs(s.AvgJitter),
+		s.Loss(), s.Sent-s.Received, s.Sent, s.Samples)
+}
+
+func formatMs(d time.Duration) string {
+	return fmt.Sprintf("%.1f ms", float64(d)/float64(time.Millisecond))
+}
+
+// History guarda las muestras recientes y descarta las más antiguas que retention
+type History struct {
+	mu        sync.Mutex
+	samples   []Sample
+	retention time.Duration
+}
+
+func NewHistory(retention time.Duration) *History {
+	return &History{retention: retention}
+}
+
+// Add agrega una muestra y poda las que superan la retención
+func (h *History) Add(s Sample) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.samples = append(h.samples, s)
+	cutoff := s.Time.Add(-h.retention)
+	i := 0
+	for i < len(h.samples) && h.samples[i].Time.Before(cutoff) {
+		i++
+	}
+	h.samples = h.samples[i:]
+}
+
+// Oldest retorna la hora de la muestra más antigua (cero si no hay)
+func (h *History) Oldest() time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.samples) == 0 {
+		return time.Time{}
+	}
+	return h.samples[0].Time
+}
+
+// Since calcula las estadísticas de las muestras tomadas desde t
+func (h *History) Since(t time.Time) Stats {
+	return h.Between(t, time.Time{})
+}
+
+// Between calcula las estadísticas de las muestras entre from y to (to cero = sin límite)
+func (h *History) Between(from, to time.Time) Stats {
+	return summarize(h.samplesBetween(from, to))
+}
+
+// samplesBetween retorna una copia de las muestras entre from y to (to cero = sin límite)
+func (h *History) samplesBetween(from, to time.Time) []Sample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var list []Sample
+	for _, s := range h.samples {
+		if s.Time.Before(from) || (!to.IsZero() && s.Time.After(to)) {
+			continue
+		}
+		list = append(list, s)
+	}
+	return list
+}
+
+// summarize calcula las estadísticas de un conjunto de muestras
+func summarize(samples []Sample) Stats {
+	var st Stats
+	var rttTotal, jitterTotal time.Duration
+	var withRTT int
+	for _, s := range samples {
+		st.Samples++
+		st.Sent += s.Sent
+		st.Received += s.Received
+		jitterTotal += s.Jitter
+		if s.Received == 0 {
+			continue
+		}
+		withRTT++
+		rttTotal += s.RTT
+		if st.MinRTT == 0 || s.RTT < st.MinRTT {
+			st.MinRTT = s.RTT
+		}
+		if s.RTT > st.MaxRTT {
+			st.MaxRTT = s.RTT
+		}
+	}
+	if withRTT > 0 {
+		st.AvgRTT = rttTotal / time.Duration(withRTT)
+	}
+	if st.Samples > 0 {
+		st.AvgJitter = jitterTotal / time.Duration(st.Samples)
+	}
+	return st
+}
+
+// Thresholds define los límites de una conexión aceptable; cero deshabilita el límite
+type Thresholds struct {
+	MaxRTT    time.Duration
+	MaxJitter time.Duration
+	MaxLoss   float64 // porcentaje
+}
+
+// Exceeded retorna la descripción de cada límite superado
+func (t Thresholds) Exceeded(s Stats) []string {
+	var reasons []string
+	if t.MaxRTT > 0 && s.AvgRTT > t.MaxRTT {
+		reasons = append(reasons, fmt.Sprintf("latencia %s > %s", formatMs(s.AvgRTT), formatMs(t.MaxRTT)))
+	}
+	if t.MaxJitter > 0 && s.AvgJitter > t.MaxJitter {
+		reasons = append(reasons, fmt.Sprintf("jitter %s > %s", formatMs(s.AvgJitter), formatMs(t.MaxJitter)))
+	}
+	if t.MaxLoss > 0 && s.Loss() > t.MaxLoss {
+		reasons = append(reasons, fmt.Sprintf("pérdida %.1f%% > %.1f%%", s.Loss(), t.MaxLoss))
+	}
+	return reasons
+}
+
+// Detector decide cuándo la conexión está degradada: al menos Fraction de las
+// muestras del período deben superar algún límite, así un pico aislado no genera
+// alertas aunque eleve el promedio. La recuperación exige lo mismo con muestras
+// dentro de los límites.
+type Detector struct {
+	History    *History
+	Thresholds Thresholds
+	Period     time.Duration
+	Fraction   float64 // proporción de muestras requerida, entre 0 y 1; cero exige todas
+
+	degraded bool
+}
+
+// Event describe un cambio de estado de la calidad
+type Event struct {
+	Degraded bool
+	Reasons  []string
+	Stats    Stats
+}
+
+// Degraded indica si la última evaluación dejó la conexión como degradada
+func (d *Detector) Degraded() bool {
+	return d.degraded
+}
+
+// Evaluate revisa el período más reciente y retorna un evento solo si el estado cambió
+func (d *Detector) Evaluate(now time.Time) *Event {
+	from := now.Add(-d.Period)
+	samples := d.History.samplesBetween(from, time.Time{})
+	if len(samples) == 0 {
+		return nil
+	}
+
+	var bad []Sample
+	for _, s := range samples {
+		if len(d.Thresholds.Exceeded(summarize([]Sample{s}))) > 0 {
+			bad = append(bad, s)
+		}
+	}
+	fraction := d.Fraction
+	if fraction <= 0 || fraction > 1 {
+		fraction = 1
+	}
+	required := fraction * float64(len(samples))
+	stats := summarize(samples)
+
+	switch {
+	case !d.degraded && float64(len(bad)) >= required:
+		// Solo se alerta cuando hay mediciones que cubren el período completo
+		if oldest := d.History.Oldest(); oldest.IsZero() || oldest.After(from) {
+			return nil
+		}
+		d.degraded = true
+		reasons := []string{fmt.Sprintf("%d de %d mediciones fuera de los límites", len(bad), len(samples))}
+		reasons = append(reasons, d.Thresholds.Exceeded(summarize(bad))...)
+		return &Event{Degraded: true, Reasons: reasons, Stats: stats}
+	case d.degraded && float64(len(samples)-len(bad)) >= required:
+		d.degraded = false
+		return &Event{Degraded: false, Stats: stats}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package probe
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var base = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func sample(minute int, rtt time.Duration, received int) Sample {
+	return Sample{Time: base.Add(time.Duration(minute) * time.Minute), Sent: 3, Received: received, RTT: rtt, Jitter: rtt / 10}
+}
+
+func TestHistoryRetentionAndStats(t *testing.T) {
+	h := NewHistory(5 * time.Minute)
+	h.Add(sample(0, 10*time.Millisecond, 3))
+	h.Add(sample(2, 30*time.Millisecond, 3))
+	h.Add(sample(4, 0, 0)) // sin respuesta: cuenta la pérdida pero no el RTT
+	h.Add(sample(6, 20*time.Millisecond, 3))
+
+	if got := h.Oldest(); !got.Equal(base.Add(2 * time.Minute)) {
+		t.Fatalf("muestra más antigua = %s; la de las 12:00 debía podarse", got)
+	}
+
+	st := h.Since(base)
+	if st.Samples != 3 || st.Sent != 9 || st.Received != 6 {
+		t.Fatalf("estadísticas = %+v", st)
+	}
+	if st.AvgRTT != 25*time.Millisecond || st.MinRTT != 20*time.Millisecond || st.MaxRTT != 30*time.Millisecond {
+		t.Errorf("RTT = %s (mín %s, máx %s)", st.AvgRTT, st.MinRTT, st.MaxRTT)
+	}
+	if st.AvgJitter != (3*time.Millisecond+2*time.Millisecond)/3 {
+		t.Errorf("jitter = %s", st.AvgJitter)
+	}
+	if loss := st.Loss(); loss < 33.3 || loss > 33.4 {
+		t.Errorf("pérdida = %.2f", loss)
+	}
+
+	if st := h.Between(base, base.Add(3*time.Minute)); st.Samples != 1 || st.AvgRTT != 30*time.Millisecond {
+		t.Errorf("entre 12:00 y 12:03 = %+v", st)
+	}
+	if st := h.Since(base.Add(time.Hour)); st.Samples != 0 || st.String() != "Sin mediciones" {
+		t.Errorf("sin muestras = %+v", st)
+	}
+}
+
+func TestThresholdsExceeded(t *testing.T) {
+	th := Thresholds{MaxRTT: 100 * time.Millisecond, MaxJitter: 20 * time.Millisecond, MaxLoss: 5}
+	for _, tc := range []struct {
+		name  string
+		stats Stats
+		want  []string
+	}{
+		{"dentro", Stats{Samples: 1, Sent: 3, Received: 3, AvgRTT: 100 * time.Millisecond, AvgJitter: 20 * time.Millisecond}, nil},
+		{"latencia", Stats{Samples: 1, Sent: 3, Received: 3, AvgRTT: 180 * time.Millisecond}, []string{"latencia 180.0 ms > 100.0 ms"}},
+		{"todos", Stats{Samples: 1, Sent: 10, Received: 8, AvgRTT: 120 * time.Millisecond, AvgJitter: 25 * time.Millisecond},
+			[]string{"latencia 120.0 ms > 100.0 ms", "jitter 25.0 ms > 20.0 ms", "pérdida 20.0% > 5.0%"}},
+	} {
+		got := th.Exceeded(tc.stats)
+		if strings.Join(got, "|") != strings.Join(tc.want, "|") {
+			t.Errorf("%s: %q, se esperaba %q", tc.name, got, tc.want)
+		}
+	}
+
+	// Un límite en cero no se evalúa
+	if got := (Thresholds{}).Exceeded(Stats{Samples: 1, Sent: 3, AvgRTT: time.Second}); got != nil {
+		t.Errorf("sin límites = %q", got)
+	}
+}
+
+func newDetector(fraction float64) *Detector {
+	return &Detector{
+		History:    NewHistory(time.Hour),
+		Thresholds: Thresholds{MaxRTT: 100 * time.Millisecond},
+		Period:     10 * time.Minute,
+		Fraction:   fraction,
+	}
+}
+
+// feed agrega una muestra por minuto desde minute y evalúa después de cada una
+func feed(d *Detector, minute int, rtts ...int) []*Event {
+	var events []*Event
+	for i, rtt := range rtts {
+		s := sample(minute+i, time.Duration(rtt)*time.Millisecond, 3)
+		d.History.Add(s)
+		if e := d.Evaluate(s.Time); e != nil {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+func TestDetectorIgnoresSpike(t *testing.T) {
+	d := newDetector(0)
+	// Un solo ciclo de 2 s eleva el promedio del período muy por encima del límite
+	if events := feed(d, 0, 20, 20, 20, 20, 2000, 20, 20, 20, 20, 20, 20, 20, 20); len(events) != 0 {
+		t.Fatalf("un pico aislado generó %d eventos: %+v", len(events), events[0])
+	}
+	if d.Degraded() {
+		t.Error("la conexión no debía quedar degradada")
+	}
+}
+
+func TestDetectorSustainedAndRecovery(t *testing.T) {
+	d := newDetector(0)
+
+	// Sin historial que cubra el período no se alerta aunque todo esté fuera del límite
+	if events := feed(d, 0, 300, 300, 300, 300, 300, 300, 300, 300, 300, 300); len(events) != 0 {
+		t.Fatalf("alerta antes de cubrir el período: %+v", events[0])
+	}
+	events := feed(d, 10, 300)
+	if len(events) != 1 || !events[0].Degraded || !d.Degraded() {
+		t.Fatalf("eventos = %+v", events)
+	}
+	if got := strings.Join(events[0].Reasons, ", "); got != "11 de 11 mediciones fuera de los límites, latencia 300.0 ms > 100.0 ms" {
+		t.Errorf("motivos = %s", got)
+	}
+
+	// Mientras quede una muestra fuera del límite en el período no hay recuperación
+	if events := feed(d, 11, 20, 300, 20, 20, 20, 20, 20, 20, 20, 20); len(events) != 0 {
+		t.Fatalf("recuperación prematura: %+v", events[0])
+	}
+	events = feed(d, 21, 20, 20, 20)
+	if len(events) != 1 || events[0].Degraded || d.Degraded() {
+		t.Fatalf("eventos = %+v", events)
+	}
+}
+
+func TestDetectorFraction(t *testing.T) {
+	d := newDetector(0.8)
+	feed(d, 0, 20)
+	// 9 de 11 muestras (82%) fuera del límite alcanzan el 80% requerido
+	events := feed(d, 1, 300, 300, 300, 20, 300, 300, 300, 300, 300, 300)
+	if len(events) != 1 || !events[0].Degraded {
+		t.Fatalf("eventos = %+v", events)
+	}
+	if events[0].Reasons[0] != "9 de 11 mediciones fuera de los límites" {
+		t.Errorf("motivos = %q", events[0].Reasons)
+	}
+}
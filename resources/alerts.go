@@ -0,0 +1,163 @@
+package resources
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Thresholds define los límites de cada recurso; cero deshabilita el límite
+type Thresholds struct {
+	DiskPct    float64
+	MemoryPct  float64
+	LoadPerCPU float64 // carga de 5 minutos dividida por la cantidad de CPU
+	TempC      float64
+}
+
+// Alert es un recurso por encima de su límite
+type Alert struct {
+	Key         string // identifica el recurso, por ejemplo "disk:/"
+	Description string
+}
+
+// Exceeded retorna los recursos que superan su límite en la lectura
+func (t Thresholds) Exceeded(s Snapshot) map[string]string {
+	exceeded := make(map[string]string)
+	if t.DiskPct > 0 {
+		for _, d := range s.Disks {
+			if d.Error == "" && d.UsedPct >= t.DiskPct {
+				exceeded["disk:"+d.Path] = fmt.Sprintf("Disco %s al %.1f%% (límite %.0f%%, %s libres)",
+					d.Path, d.UsedPct, t.DiskPct, formatBytes(d.FreeBytes))
+			}
+		}
+	}
+	if t.MemoryPct > 0 && s.Memory != nil && s.Memory.UsedPct >= t.MemoryPct {
+		exceeded["memory"] = fmt.Sprintf("Memoria al %.1f%% (límite %.0f%%)", s.Memory.UsedPct, t.MemoryPct)
+	}
+	if t.LoadPerCPU > 0 && s.Load != nil && s.Load.CPUs > 0 {
+		if perCPU := s.Load.Load5 / float64(s.Load.CPUs); perCPU >= t.LoadPerCPU {
+			exceeded["load"] = fmt.Sprintf("Carga de 5 minutos %.2f en %d CPU (%.2f por CPU, límite %.2f)",
+				s.Load.Load5, s.Load.CPUs, perCPU, t.LoadPerCPU)
+		}
+	}
+	if t.TempC > 0 {
+		for _, temp := range s.Temperatures {
+			if temp.Celsius >= t.TempC {
+				exceeded["temp:"+temp.Zone] = fmt.Sprintf("Temperatura %s (%s) %.1f °C (límite %.0f °C)",
+					temp.Zone, temp.Type, temp.Celsius, t.TempC)
+			}
+		}
+	}
+	return exceeded
+}
+
+// Tracker exige Samples lecturas seguidas por encima (o por debajo) del límite
+// antes de levantar (o cerrar) una alerta, para ignorar picos aislados
+type Tracker struct {
+	Thresholds Thresholds
+	Samples    int
+
+	over     map[string]int
+	under    map[string]int
+	active   map[string]string
+	notified map[string]string // alertas cuyo aviso ya se envió
+}
+
+// Transition es un cambio de una alerta todavía no notificado
+type Transition struct {
+	Alert
+	Active bool // true si la alerta se levantó, false si se resolvió
+}
+
+// Update procesa una lectura y retorna las alertas nuevas y las que se resolvieron
+func (t *Tracker) Update(s Snapshot) (raised, cleared []Alert) {
+	if t.active == nil {
+		t.over = make(map[string]int)
+		t.under = make(map[string]int)
+		t.active = make(map[string]string)
+		t.notified = make(map[string]string)
+	}
+	samples := t.Samples
+	if samples <= 0 {
+		samples = 1
+	}
+
+	exceeded := t.Thresholds.Exceeded(s)
+
+	for key, desc := range exceeded {
+		t.under[key] = 0
+		t.over[key]++
+		if _, ok := t.active[key]; !ok && t.over[key] >= samples {
+			t.active[key] = desc
+			raised = append(raised, Alert{Key: key, Description: desc})
+		} else if ok {
+			t.active[key] = desc
+		}
+	}
+
+	for key, desc := range t.active {
+		if _, ok := exceeded[key]; ok {
+			continue
+		}
+		t.under[key]++
+		if t.under[key] >= samples {
+			delete(t.active, key)
+			delete(t.under, key)
+			cleared = append(cleared, Alert{Key: key, Description: desc})
+		}
+	}
+	for key := range t.over {
+		if _, ok := exceeded[key]; !ok {
+			delete(t.over, key)
+		}
+	}
+
+	sortAlerts(raised)
+	sortAlerts(cleared)
+	return raised, cleared
+}
+
+// Pending retorna los avisos aún no enviados: las alertas abiertas sin notificar y
+// las notificadas que ya se resolvieron. Una alerta que se resolvió antes de
+// avisarla no genera ningún correo.
+func (t *Tracker) Pending() []Transition {
+	var pending []Transition
+	for key, desc := range t.active {
+		if _, ok := t.notified[key]; !ok {
+			pending = append(pending, Transition{Alert: Alert{Key: key, Description: desc}, Active: true})
+		}
+	}
+	for key, desc := range t.notified {
+		if _, ok := t.active[key]; !ok {
+			pending = append(pending, Transition{Alert: Alert{Key: key, Description: desc}})
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Key < pending[j].Key })
+	return pending
+}
+
+// MarkNotified registra que se envió el aviso; si el envío falla la transición
+// sigue pendiente y se reintenta en la próxima lectura
+func (t *Tracker) MarkNotified(tr Transition) {
+	if tr.Active {
+		if t.notified == nil {
+			t.notified = make(map[string]string)
+		}
+		t.notified[tr.Key] = tr.Description
+		return
+	}
+	delete(t.notified, tr.Key)
+}
+
+// Active retorna las alertas abiertas
+func (t *Tracker) Active() []Alert {
+	var list []Alert
+	for key, desc := range t.active {
+		list = append(list, Alert{Key: key, Description: desc})
+	}
+	sortAlerts(list)
+	return list
+}
+
+func sortAlerts(list []Alert) {
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+}
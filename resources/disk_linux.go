@@ -0,0 +1,25 @@
+package resources
+
+import "syscall"
+
+// statDisk obtiene el uso del sistema de archivos con statfs; el espacio libre es
+// el disponible para usuarios sin privilegios (Bavail), igual que df
+func statDisk(path string) (Disk, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return Disk{}, err
+	}
+
+	bsize := uint64(st.Bsize)
+	d := Disk{
+		Path:       path,
+		TotalBytes: st.Blocks * bsize,
+		FreeBytes:  st.Bavail * bsize,
+	}
+	// Como df: usado / (usado + disponible), sin contar los bloques reservados para root
+	used := (st.Blocks - st.Bfree) * bsize
+	if used+d.FreeBytes > 0 {
+		d.UsedPct = float64(used) * 100 / float64(used+d.FreeBytes)
+	}
+	return d, nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package resources
+
+import "fmt"
+
+func statDisk(path string) (Disk, error) {
+	return Disk{}, fmt.Errorf("uso de disco no soportado en este sistema")
+}
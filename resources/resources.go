@@ -0,0 +1,241 @@
+// Package resources lee el uso de disco, memoria, carga y temperatura del host
+// desde /proc y /sys, y decide cuándo un recurso supera sus límites.
+package resources
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Collector lee los recursos; ProcPath y SysPath permiten montar los del host
+// dentro de un contenedor (por ejemplo /host/proc)
+type Collector struct {
+	DiskPaths []string
+	ProcPath  string
+	SysPath   string
+}
+
+// Disk es el uso de un sistema de archivos
+type Disk struct {
+	Path       string  `json:"path"`
+	TotalBytes uint64  `json:"total_bytes"`
+	FreeBytes  uint64  `json:"free_bytes"`
+	UsedPct    float64 `json:"used_percent"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// Memory es el uso de memoria; MemAvailable ya descuenta caché recuperable
+type Memory struct {
+	TotalBytes     uint64  `json:"total_bytes"`
+	AvailableBytes uint64  `json:"available_bytes"`
+	UsedPct        float64 `json:"used_percent"`
+	SwapTotalBytes uint64  `json:"swap_total_bytes"`
+	SwapFreeBytes  uint64  `json:"swap_free_bytes"`
+}
+
+// Load es la carga promedio del sistema
+type Load struct {
+	Load1  float64 `json:"load1"`
+	Load5  float64 `json:"load5"`
+	Load15 float64 `json:"load15"`
+	CPUs   int     `json:"cpus"`
+}
+
+// Temperature es la lectura de una zona térmica en grados Celsius
+type Temperature struct {
+	Zone    string  `json:"zone"`
+	Type    string  `json:"type"`
+	Celsius float64 `json:"celsius"`
+}
+
+// Snapshot agrupa una lectura de todos los recursos
+type Snapshot struct {
+	Disks        []Disk        `json:"disks"`
+	Memory       *Memory       `json:"memory,omitempty"`
+	Load         *Load         `json:"load,omitempty"`
+	Temperatures []Temperature `json:"temperatures,omitempty"`
+	Errors       []string      `json:"errors,omitempty"`
+}
+
+// Collect lee todos los recursos; un recurso que no se puede leer se informa en Errors
+func (c *Collector) Collect() Snapshot {
+	var s Snapshot
+
+	for _, path := range c.DiskPaths {
+		d, err := statDisk(path)
+		if err != nil {
+			d = Disk{Path: path, Error: err.Error()}
+		}
+		s.Disks = append(s.Disks, d)
+	}
+
+	if mem, err := c.readMemory(); err != nil {
+		s.Errors = append(s.Errors, fmt.Sprintf("memoria: %v", err))
+	} else {
+		s.Memory = &mem
+	}
+
+	if load, err := c.readLoad(); err != nil {
+		s.Errors = append(s.Errors, fmt.Sprintf("carga: %v", err))
+	} else {
+		s.Load = &load
+	}
+
+	// Muchos equipos (y VMs) no exponen zonas térmicas; no es un error
+	s.Temperatures = c.readTemperatures()
+
+	return s
+}
+
+func (c *Collector) proc(name string) string {
+	base := c.ProcPath
+	if base == "" {
+		base = "/proc"
+	}
+	return filepath.Join(base, name)
+}
+
+func (c *Collector) sys(name string) string {
+	base := c.SysPath
+	if base == "" {
+		base = "/sys"
+	}
+	return filepath.Join(base, name)
+}
+
+// readMemory interpreta /proc/meminfo (valores en kB)
+func (c *Collector) readMemory() (Memory, error) {
+	f, err := os.Open(c.proc("meminfo"))
+	if err != nil {
+		return Memory{}, err
+	}
+	defer f.Close()
+
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		if v, err := strconv.ParseUint(fields[0], 10, 64); err == nil {
+			values[key] = v * 1024
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Memory{}, err
+	}
+
+	m := Memory{
+		TotalBytes:     values["MemTotal"],
+		SwapTotalBytes: values["SwapTotal"],
+		SwapFreeBytes:  values["SwapFree"],
+	}
+	if m.TotalBytes == 0 {
+		return Memory{}, fmt.Errorf("MemTotal no encontrado")
+	}
+	available, ok := values["MemAvailable"]
+	if !ok {
+		// Kernels anteriores a 3.14
+		available = values["MemFree"] + values["Buffers"] + values["Cached"]
+	}
+	m.AvailableBytes = available
+	m.UsedPct = float64(m.TotalBytes-min(available, m.TotalBytes)) * 100 / float64(m.TotalBytes)
+	return m, nil
+}
+
+// readLoad interpreta /proc/loadavg
+func (c *Collector) readLoad() (Load, error) {
+	data, err := os.ReadFile(c.proc("loadavg"))
+	if err != nil {
+		return Load{}, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return Load{}, fmt.Errorf("formato de loadavg inesperado")
+	}
+
+	var l Load
+	for i, dst := range []*float64{&l.Load1, &l.Load5, &l.Load15} {
+		if *dst, err = strconv.ParseFloat(fields[i], 64); err != nil {
+			return Load{}, err
+		}
+	}
+	l.CPUs = runtime.NumCPU()
+	return l, nil
+}
+
+// readTemperatures lee /sys/class/thermal/thermal_zone*/temp (miligrados)
+func (c *Collector) readTemperatures() []Temperature {
+	zones, _ := filepath.Glob(c.sys("class/thermal/thermal_zone*"))
+	sort.Strings(zones)
+
+	var temps []Temperature
+	for _, zone := range zones {
+		raw, err := os.ReadFile(filepath.Join(zone, "temp"))
+		if err != nil {
+			continue
+		}
+		milli, err := strconv.ParseInt(strings.TrimSpace(string(raw)), 10, 64)
+		if err != nil {
+			continue
+		}
+		zoneType, _ := os.ReadFile(filepath.Join(zone, "type"))
+		temps = append(temps, Temperature{
+			Zone:    filepath.Base(zone),
+			Type:    strings.TrimSpace(string(zoneType)),
+			Celsius: float64(milli) / 1000,
+		})
+	}
+	return temps
+}
+
+// String resume la lectura en texto para los correos
+func (s Snapshot) String() string {
+	var lines []string
+	for _, d := range s.Disks {
+		if d.Error != "" {
+			lines = append(lines, fmt.Sprintf("Disco %s: error (%s)", d.Path, d.Error))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("Disco %s: %.1f%% usado (%s libres de %s)",
+			d.Path, d.UsedPct, formatBytes(d.FreeBytes), formatBytes(d.TotalBytes)))
+	}
+	if s.Memory != nil {
+		lines = append(lines, fmt.Sprintf("Memoria: %.1f%% usada (%s disponibles de %s)",
+			s.Memory.UsedPct, formatBytes(s.Memory.AvailableBytes), formatBytes(s.Memory.TotalBytes)))
+	}
+	if s.Load != nil {
+		lines = append(lines, fmt.Sprintf("Carga: %.2f %.2f %.2f (%d CPU)", s.Load.Load1, s.Load.Load5, s.Load.Load15, s.Load.CPUs))
+	}
+	for _, t := range s.Temperatures {
+		lines = append(lines, fmt.Sprintf("Temperatura %s (%s): %.1f °C", t.Zone, t.Type, t.Celsius))
+	}
+	for _, e := range s.Errors {
+		lines = append(lines, "Error leyendo "+e)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
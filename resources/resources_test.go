@@ -0,0 +1,153 @@
+package resources
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestCollectFixtures(t *testing.T) {
+	c := &Collector{DiskPaths: []string{t.TempDir()}, ProcPath: "testdata/proc", SysPath: "testdata/sys"}
+	s := c.Collect()
+
+	if len(s.Errors) != 0 {
+		t.Fatalf("errores: %v", s.Errors)
+	}
+	if len(s.Disks) != 1 || s.Disks[0].Error != "" || s.Disks[0].TotalBytes == 0 {
+		t.Errorf("discos = %+v", s.Disks)
+	}
+
+	m := s.Memory
+	if m == nil || m.TotalBytes != 8000000*1024 || m.AvailableBytes != 2000000*1024 || m.UsedPct != 75 {
+		t.Fatalf("memoria = %+v", m)
+	}
+	if m.SwapTotalBytes != 2000000*1024 || m.SwapFreeBytes != 1500000*1024 {
+		t.Errorf("swap = %+v", m)
+	}
+
+	if l := s.Load; l == nil || l.Load1 != 0.52 || l.Load5 != 1.75 || l.Load15 != 1.20 || l.CPUs != runtime.NumCPU() {
+		t.Errorf("carga = %+v", s.Load)
+	}
+
+	// La zona con una lectura inválida se omite
+	want := []Temperature{{Zone: "thermal_zone0", Type: "x86_pkg_temp", Celsius: 48.5}, {Zone: "thermal_zone1", Type: "acpitz", Celsius: 71.25}}
+	if len(s.Temperatures) != len(want) {
+		t.Fatalf("temperaturas = %+v", s.Temperatures)
+	}
+	for i := range want {
+		if s.Temperatures[i] != want[i] {
+			t.Errorf("temperatura %d = %+v, esperado %+v", i, s.Temperatures[i], want[i])
+		}
+	}
+}
+
+func TestCollectWithoutMemAvailable(t *testing.T) {
+	c := &Collector{ProcPath: "testdata/proc-old", SysPath: t.TempDir()}
+	s := c.Collect()
+
+	// Sin MemAvailable se estima con MemFree + Buffers + Cached
+	if m := s.Memory; m == nil || m.AvailableBytes != 300000*1024 || m.UsedPct != 70 {
+		t.Fatalf("memoria = %+v", s.Memory)
+	}
+	// loadavg incompleto se informa como error sin afectar al resto
+	if s.Load != nil || len(s.Errors) != 1 || !strings.HasPrefix(s.Errors[0], "carga:") {
+		t.Errorf("carga = %+v, errores = %v", s.Load, s.Errors)
+	}
+	if len(s.Temperatures) != 0 {
+		t.Errorf("sin zonas térmicas se esperaba lista vacía: %+v", s.Temperatures)
+	}
+}
+
+func TestCollectMissingProc(t *testing.T) {
+	s := (&Collector{ProcPath: t.TempDir(), SysPath: t.TempDir()}).Collect()
+	if s.Memory != nil || s.Load != nil || len(s.Errors) != 2 {
+		t.Errorf("lectura = %+v", s)
+	}
+}
+
+func memSnapshot(usedPct float64) Snapshot {
+	return Snapshot{Memory: &Memory{UsedPct: usedPct}}
+}
+
+func keys(alerts []Alert) string {
+	var list []string
+	for _, a := range alerts {
+		list = append(list, a.Key)
+	}
+	return strings.Join(list, ",")
+}
+
+func TestTrackerSamplesAndHysteresis(t *testing.T) {
+	tr := &Tracker{Thresholds: Thresholds{MemoryPct: 90}, Samples: 3}
+
+	steps := []struct {
+		used            float64
+		raised, cleared string
+	}{
+		{95, "", ""},
+		{95, "", ""},
+		{80, "", ""}, // un valle reinicia la cuenta
+		{95, "", ""},
+		{95, "", ""},
+		{96, "memory", ""},
+		{97, "", ""},
+		{80, "", ""},
+		{80, "", ""},
+		{95, "", ""}, // un pico mantiene la alerta abierta y reinicia la cuenta de bajada
+		{80, "", ""},
+		{80, "", ""},
+		{80, "", "memory"},
+		{80, "", ""},
+	}
+	for i, st := range steps {
+		raised, cleared := tr.Update(memSnapshot(st.used))
+		if keys(raised) != st.raised || keys(cleared) != st.cleared {
+			t.Fatalf("paso %d (%.0f%%): levantadas %q, resueltas %q; se esperaba %q, %q", i, st.used, keys(raised), keys(cleared), st.raised, st.cleared)
+		}
+	}
+}
+
+func TestTrackerActiveDescription(t *testing.T) {
+	tr := &Tracker{Thresholds: Thresholds{MemoryPct: 90}, Samples: 1}
+	tr.Update(memSnapshot(91))
+	tr.Update(memSnapshot(99))
+	if active := tr.Active(); len(active) != 1 || !strings.Contains(active[0].Description, "99.0%") {
+		t.Errorf("alertas abiertas = %+v", active)
+	}
+}
+
+func TestTrackerPending(t *testing.T) {
+	tr := &Tracker{Thresholds: Thresholds{MemoryPct: 90}, Samples: 1}
+
+	tr.Update(memSnapshot(95))
+	pending := tr.Pending()
+	if len(pending) != 1 || !pending[0].Active || pending[0].Key != "memory" {
+		t.Fatalf("pendientes = %+v", pending)
+	}
+	// Sin MarkNotified el aviso sigue pendiente
+	tr.Update(memSnapshot(95))
+	if got := tr.Pending(); len(got) != 1 {
+		t.Fatalf("pendientes = %+v", got)
+	}
+	tr.MarkNotified(pending[0])
+	if got := tr.Pending(); len(got) != 0 {
+		t.Fatalf("pendientes tras notificar = %+v", got)
+	}
+
+	tr.Update(memSnapshot(50))
+	pending = tr.Pending()
+	if len(pending) != 1 || pending[0].Active || pending[0].Key != "memory" {
+		t.Fatalf("pendientes tras resolver = %+v", pending)
+	}
+	tr.MarkNotified(pending[0])
+	if got := tr.Pending(); len(got) != 0 {
+		t.Fatalf("pendientes = %+v", got)
+	}
+
+	// Una alerta que se resuelve antes de poder avisarla no genera correos
+	tr.Update(memSnapshot(95))
+	tr.Update(memSnapshot(50))
+	if got := tr.Pending(); len(got) != 0 {
+		t.Fatalf("pendientes = %+v", got)
+	}
+}
@@ -0,0 +1,148 @@
+// Package speedtest mide el ancho de banda de bajada y subida contra un servidor
+// HTTP configurable.
+package speedtest
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tester descarga DownloadBytes desde DownloadURL y sube UploadBytes a UploadURL.
+// Las URL pueden contener {bytes}, que se reemplaza por el tamaño pedido
+// (por ejemplo https://speed.cloudflare.com/__down?bytes={bytes}).
+type Tester struct {
+	DownloadURL   string
+	UploadURL     string
+	DownloadBytes int64
+	UploadBytes   int64
+	Timeout       time.Duration
+}
+
+// Result contiene las velocidades en bits por segundo (0 si la prueba no se realizó)
+type Result struct {
+	Time          time.Time
+	DownloadBps   float64
+	UploadBps     float64
+	DownloadBytes int64
+	UploadBytes   int64
+}
+
+func (r Result) String() string {
+	return fmt.Sprintf("Bajada: %s\nSubida: %s", FormatBps(r.DownloadBps), FormatBps(r.UploadBps))
+}
+
+// FormatBps formatea una velocidad en Mbps
+func FormatBps(bps float64) string {
+	if bps <= 0 {
+		return "No medida"
+	}
+	return fmt.Sprintf("%.2f Mbps", bps/1e6)
+}
+
+// Run ejecuta la bajada y luego la subida; un error en cualquiera invalida el resultado
+func (t *Tester) Run(ctx context.Context) (Result, error) {
+	timeout := t.Timeout
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result := Result{Time: time.Now()}
+	client := &http.Client{}
+
+	if t.DownloadURL != "" && t.DownloadBytes > 0 {
+		n, elapsed, err := t.download(ctx, client)
+		if err != nil {
+			return result, fmt.Errorf("error en bajada: %w", err)
+		}
+		result.DownloadBytes = n
+		result.DownloadBps = rate(n, elapsed)
+	}
+
+	if t.UploadURL != "" && t.UploadBytes > 0 {
+		elapsed, err := t.upload(ctx, client)
+		if err != nil {
+			return result, fmt.Errorf("error en subida: %w", err)
+		}
+		result.UploadBytes = t.UploadBytes
+		result.UploadBps = rate(t.UploadBytes, elapsed)
+	}
+
+	return result, nil
+}
+
+func rate(n int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(n) * 8 / elapsed.Seconds()
+}
+
+func expandURL(url string, size int64) string {
+	return strings.ReplaceAll(url, "{bytes}", strconv.FormatInt(size, 10))
+}
+
+// download lee como máximo DownloadBytes; el tiempo se mide desde la respuesta
+// para no contar el handshake TLS
+func (t *Tester) download(ctx context.Context, client *http.Client) (int64, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, expandURL(t.DownloadURL, t.DownloadBytes), nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	req.Header.Set("User-Agent", "ORGMServer/1.0")
+	req.Header.Set("Cache-Control", "no-cache")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, 0, fmt.Errorf("status code: %d", resp.StatusCode)
+	}
+
+	start := time.Now()
+	n, err := io.Copy(io.Discard, io.LimitReader(resp.Body, t.DownloadBytes))
+	elapsed := time.Since(start)
+	if err != nil {
+		return n, elapsed, err
+	}
+	if n < t.DownloadBytes/2 {
+		return n, elapsed, fmt.Errorf("el servidor envió solo %d bytes", n)
+	}
+	return n, elapsed, nil
+}
+
+// upload envía UploadBytes aleatorios (no comprimibles) en el cuerpo de un POST
+func (t *Tester) upload(ctx context.Context, client *http.Client) (time.Duration, error) {
+	body := io.LimitReader(rand.Reader, t.UploadBytes)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, expandURL(t.UploadURL, t.UploadBytes), body)
+	if err != nil {
+		return 0, err
+	}
+	req.ContentLength = t.UploadBytes
+	req.Header.Set("User-Agent", "ORGMServer/1.0")
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	elapsed := time.Since(start)
+	if err != nil {
+		return elapsed, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return elapsed, fmt.Errorf("status code: %d", resp.StatusCode)
+	}
+	return elapsed, nil
+}
@@ -0,0 +1,138 @@
+package speedtest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRate(t *testing.T) {
+	if got := rate(1_000_000, time.Second); got != 8e6 {
+		t.Errorf("1 MB en 1 s = %.0f bps, se esperaba 8000000", got)
+	}
+	if got := rate(250_000, 500*time.Millisecond); got != 4e6 {
+		t.Errorf("250 kB en 500 ms = %.0f bps, se esperaba 4000000", got)
+	}
+	if got := rate(1000, 0); got != 0 {
+		t.Errorf("sin tiempo medido = %.0f bps", got)
+	}
+	if got := FormatBps(12_345_678); got != "12.35 Mbps" {
+		t.Errorf("FormatBps = %q", got)
+	}
+	if got := FormatBps(0); got != "No medida" {
+		t.Errorf("FormatBps(0) = %q", got)
+	}
+}
+
+// speedServer sirve /down?bytes=N con sent bytes (todos si sent < 0) y recibe /up
+type speedServer struct {
+	mu       sync.Mutex
+	sent     int
+	upStatus int
+	uploaded int64
+	query    string
+}
+
+func (s *speedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.URL.Path {
+	case "/down":
+		s.query = r.URL.RawQuery
+		n, _ := strconv.Atoi(r.URL.Query().Get("bytes"))
+		if s.sent >= 0 {
+			n = s.sent
+		}
+		w.Write(make([]byte, n))
+	case "/up":
+		s.uploaded, _ = io.Copy(io.Discard, r.Body)
+		if s.upStatus != 0 {
+			w.WriteHeader(s.upStatus)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newSpeedServer(t *testing.T) (*speedServer, *Tester) {
+	t.Helper()
+	s := &speedServer{sent: -1}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return s, &Tester{
+		DownloadURL:   srv.URL + "/down?bytes={bytes}",
+		UploadURL:     srv.URL + "/up",
+		DownloadBytes: 200_000,
+		UploadBytes:   100_000,
+		Timeout:       5 * time.Second,
+	}
+}
+
+func TestRun(t *testing.T) {
+	s, tester := newSpeedServer(t)
+
+	result, err := tester.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.query != "bytes=200000" {
+		t.Errorf("query de descarga = %q", s.query)
+	}
+	if result.DownloadBytes != 200_000 || result.UploadBytes != 100_000 || s.uploaded != 100_000 {
+		t.Errorf("resultado = %+v, subidos %d", result, s.uploaded)
+	}
+	if result.DownloadBps <= 0 || result.UploadBps <= 0 {
+		t.Errorf("velocidades = %s", result)
+	}
+
+	// Un servidor que envía más de lo pedido no alarga la descarga
+	s.sent = 1_000_000
+	if result, err = tester.Run(context.Background()); err != nil || result.DownloadBytes != 200_000 {
+		t.Errorf("resultado = %+v, %v", result, err)
+	}
+
+	// Sin URL de subida solo se mide la bajada
+	tester.UploadURL = ""
+	if result, err = tester.Run(context.Background()); err != nil || result.UploadBytes != 0 || result.UploadBps != 0 {
+		t.Errorf("sin subida = %+v, %v", result, err)
+	}
+}
+
+func TestRunShortBody(t *testing.T) {
+	s, tester := newSpeedServer(t)
+
+	// Menos de la mitad de lo pedido invalida la medición
+	s.sent = 99_999
+	if _, err := tester.Run(context.Background()); err == nil || err.Error() != "error en bajada: el servidor envió solo 99999 bytes" {
+		t.Errorf("error = %v", err)
+	}
+	s.sent = 100_000
+	if result, err := tester.Run(context.Background()); err != nil || result.DownloadBytes != 100_000 {
+		t.Errorf("la mitad de lo pedido = %+v, %v", result, err)
+	}
+}
+
+func TestRunHTTPErrors(t *testing.T) {
+	s, tester := newSpeedServer(t)
+
+	s.upStatus = http.StatusRequestEntityTooLarge
+	result, err := tester.Run(context.Background())
+	if err == nil || err.Error() != "error en subida: status code: 413" {
+		t.Fatalf("error = %v", err)
+	}
+	// La bajada ya medida se conserva en el resultado
+	if result.DownloadBytes != 200_000 || result.UploadBps != 0 {
+		t.Errorf("resultado = %+v", result)
+	}
+
+	tester.DownloadURL = strings.Replace(tester.DownloadURL, "/down", "/otra", 1)
+	if _, err := tester.Run(context.Background()); err == nil || err.Error() != "error en bajada: status code: 404" {
+		t.Errorf("error = %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package systemd
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Notifier envía mensajes de estado a systemd por el socket de NOTIFY_SOCKET
+// (protocolo sd_notify). Si el proceso no corre bajo systemd con Type=notify
+// todos los métodos son no-op.
+type Notifier struct {
+	addr     *net.UnixAddr
+	watchdog time.Duration
+}
+
+// NewNotifier lee NOTIFY_SOCKET y WATCHDOG_USEC del entorno. Retorna nil si no hay
+// socket de notificación; los métodos aceptan un Notifier nil.
+func NewNotifier() *Notifier {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// Los sockets abstractos se indican con '@', que Go ya interpreta así
+	if !strings.HasPrefix(socket, "/") && !strings.HasPrefix(socket, "@") {
+		return nil
+	}
+	n := &Notifier{addr: &net.UnixAddr{Name: socket, Net: "unixgram"}}
+
+	// WATCHDOG_PID indica a qué proceso va dirigido el watchdog; si es otro se ignora
+	if pid := os.Getenv("WATCHDOG_PID"); pid == "" || pid == strconv.Itoa(os.Getpid()) {
+		if usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64); err == nil && usec > 0 {
+			n.watchdog = time.Duration(usec) * time.Microsecond
+		}
+	}
+	return n
+}
+
+// Enabled indica si el proceso corre bajo systemd con notificación
+func (n *Notifier) Enabled() bool {
+	return n != nil
+}
+
+// WatchdogInterval retorna el tiempo máximo entre pings configurado con WatchdogSec (0 = sin watchdog)
+func (n *Notifier) WatchdogInterval() time.Duration {
+	if n == nil {
+		return 0
+	}
+	return n.watchdog
+}
+
+// Notify envía líneas VARIABLE=valor en un único datagrama
+func (n *Notifier) Notify(lines ...string) error {
+	if n == nil {
+		return nil
+	}
+	conn, err := net.DialUnix("unixgram", nil, n.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(strings.Join(lines, "\n")))
+	return err
+}
+
+// Ready indica que el servicio terminó de iniciar
+func (n *Notifier) Ready(status string) error {
+	return n.Notify("READY=1", "STATUS="+statusLine(status))
+}
+
+// Status actualiza el texto que muestra systemctl status
+func (n *Notifier) Status(status string) error {
+	return n.Notify("STATUS=" + statusLine(status))
+}
+
+// Watchdog renueva el watchdog de systemd
+func (n *Notifier) Watchdog() error {
+	if n.WatchdogInterval() == 0 {
+		return nil
+	}
+	return n.Notify("WATCHDOG=1")
+}
+
+// Stopping indica que el servicio se está deteniendo
+func (n *Notifier) Stopping(status string) error {
+	return n.Notify("STOPPING=1", "STATUS="+statusLine(status))
+}
+
+// statusLine evita que un salto de línea parta el mensaje en otra variable
+func statusLine(s string) string {
+	return strings.ReplaceAll(s, "\n", " ")
+}
@@ -0,0 +1,279 @@
+// Package systemd vigila unidades de systemd mediante su API de D-Bus
+// (org.freedesktop.systemd1) y notifica a systemd el estado del propio servicio
+// por el protocolo sd_notify.
+package systemd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"orgmserver/dbus"
+	"orgmserver/utils"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	busName       = "org.freedesktop.systemd1"
+	managerPath   = "/org/freedesktop/systemd1"
+	managerIface  = "org.freedesktop.systemd1.Manager"
+	unitIface     = "org.freedesktop.systemd1.Unit"
+	serviceIface  = "org.freedesktop.systemd1.Service"
+	errNoSuchUnit = "org.freedesktop.systemd1.NoSuchUnit"
+)
+
+// Tipos de problema de una unidad
+const (
+	ProblemFailed    = "failed"
+	ProblemNotFound  = "not-found"
+	ProblemRestarted = "restarted"
+)
+
+// Event es un problema nuevo o resuelto de una unidad. Los reinicios no tienen
+// recuperación: se notifican una vez por cada reinicio detectado.
+type Event struct {
+	Unit     string
+	Problem  string
+	Resolved bool
+	Detail   string
+}
+
+// Describe retorna el problema en texto
+func (e Event) Describe() string {
+	switch e.Problem {
+	case ProblemFailed:
+		return "en estado failed"
+	case ProblemNotFound:
+		return "no encontrada"
+	case ProblemRestarted:
+		return "reiniciada"
+	}
+	return e.Problem
+}
+
+// UnitState es el último estado conocido de una unidad vigilada
+type UnitState struct {
+	Name        string    `json:"name"`
+	LoadState   string    `json:"load_state"`
+	ActiveState string    `json:"active_state"`
+	SubState    string    `json:"sub_state"`
+	Result      string    `json:"result,omitempty"`
+	Restarts    uint32    `json:"restarts"`
+	ActiveSince time.Time `json:"active_since,omitempty"`
+	CheckedAt   time.Time `json:"checked_at"`
+}
+
+// bus es la parte de la conexión D-Bus que usa el watcher
+type bus interface {
+	Call(ctx context.Context, dest string, path dbus.ObjectPath, iface, member string, args ...string) ([]interface{}, error)
+	GetProperty(ctx context.Context, dest string, path dbus.ObjectPath, iface, name string) (interface{}, error)
+	Close() error
+}
+
+// Watcher vigila una lista de unidades. La conexión al bus se abre en la primera
+// consulta y se reabre si falla.
+type Watcher struct {
+	Units   []string
+	Address string      // dirección del bus; vacío usa el bus del sistema
+	Clock   utils.Clock // hora de las consultas; nil usa el reloj del sistema
+
+	conn   bus
+	paths  map[string]dbus.ObjectPath
+	states map[string]UnitState
+	active map[string]map[string]bool
+}
+
+func (w *Watcher) now() time.Time {
+	if w.Clock == nil {
+		return utils.SystemClock.Now()
+	}
+	return w.Clock.Now()
+}
+
+// Check consulta las unidades y retorna los eventos nuevos. Si el bus no responde
+// se retorna error y se conserva el estado anterior. ctx limita las llamadas al bus.
+func (w *Watcher) Check(ctx context.Context) ([]Event, error) {
+	if w.states == nil {
+		w.paths = make(map[string]dbus.ObjectPath)
+		w.states = make(map[string]UnitState)
+		w.active = make(map[string]map[string]bool)
+	}
+	if err := w.connect(ctx); err != nil {
+		return nil, err
+	}
+
+	now := w.now()
+	current := make(map[string]UnitState)
+	for _, name := range w.Units {
+		st, err := w.query(ctx, name)
+		if err != nil {
+			// Una conexión rota se descarta para reconectar en la próxima vuelta
+			var dbusErr *dbus.Error
+			if !errors.As(err, &dbusErr) {
+				w.conn.Close()
+				w.conn = nil
+			}
+			delete(w.paths, name)
+			return nil, fmt.Errorf("unidad %s: %w", name, err)
+		}
+		st.CheckedAt = now
+		current[name] = st
+	}
+
+	var events []Event
+	for _, name := range w.Units {
+		events = append(events, w.evaluate(name, current[name])...)
+		w.states[name] = current[name]
+	}
+	return events, nil
+}
+
+func (w *Watcher) connect(ctx context.Context) error {
+	if w.conn != nil {
+		return nil
+	}
+	var conn *dbus.Conn
+	var err error
+	if w.Address != "" {
+		conn, err = dbus.Dial(ctx, w.Address)
+	} else {
+		conn, err = dbus.SystemBus(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("conexión al bus D-Bus: %w", err)
+	}
+	w.conn = conn
+	return nil
+}
+
+// unitPath obtiene la ruta del objeto de la unidad. GetUnit solo encuentra unidades
+// cargadas; LoadUnit también devuelve las inactivas o inexistentes (LoadState not-found).
+func (w *Watcher) unitPath(ctx context.Context, name string) (dbus.ObjectPath, error) {
+	if path, ok := w.paths[name]; ok {
+		return path, nil
+	}
+	values, err := w.conn.Call(ctx, busName, managerPath, managerIface, "GetUnit", name)
+	var dbusErr *dbus.Error
+	if errors.As(err, &dbusErr) && dbusErr.Name == errNoSuchUnit {
+		values, err = w.conn.Call(ctx, busName, managerPath, managerIface, "LoadUnit", name)
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(values) != 1 {
+		return "", fmt.Errorf("respuesta inesperada de systemd")
+	}
+	path, ok := values[0].(dbus.ObjectPath)
+	if !ok {
+		return "", fmt.Errorf("respuesta inesperada de systemd")
+	}
+	w.paths[name] = path
+	return path, nil
+}
+
+// query lee las propiedades de estado de una unidad
+func (w *Watcher) query(ctx context.Context, name string) (UnitState, error) {
+	st := UnitState{Name: name}
+	path, err := w.unitPath(ctx, name)
+	if err != nil {
+		return st, err
+	}
+
+	for _, p := range []struct {
+		name string
+		dst  *string
+	}{
+		{"LoadState", &st.LoadState},
+		{"ActiveState", &st.ActiveState},
+		{"SubState", &st.SubState},
+	} {
+		v, err := w.conn.GetProperty(ctx, busName, path, unitIface, p.name)
+		if err != nil {
+			return st, err
+		}
+		*p.dst, _ = v.(string)
+	}
+
+	v, err := w.conn.GetProperty(ctx, busName, path, unitIface, "ActiveEnterTimestamp")
+	if err != nil {
+		return st, err
+	}
+	if usec, ok := v.(uint64); ok && usec > 0 {
+		st.ActiveSince = time.UnixMicro(int64(usec))
+	}
+
+	// Solo los servicios cargados tienen contador de reinicios y resultado
+	if strings.HasSuffix(name, ".service") && st.LoadState == "loaded" {
+		if v, err := w.conn.GetProperty(ctx, busName, path, serviceIface, "NRestarts"); err == nil {
+			st.Restarts, _ = v.(uint32)
+		}
+		if v, err := w.conn.GetProperty(ctx, busName, path, serviceIface, "Result"); err == nil {
+			st.Result, _ = v.(string)
+		}
+	}
+	return st, nil
+}
+
+// evaluate compara el estado actual con el anterior y retorna los eventos
+func (w *Watcher) evaluate(name string, st UnitState) []Event {
+	problems := make(map[string]string)
+	if st.LoadState == "not-found" {
+		problems[ProblemNotFound] = "systemd no conoce la unidad"
+	} else if st.ActiveState == "failed" {
+		detail := "estado " + st.ActiveState + "/" + st.SubState
+		if st.Result != "" && st.Result != "success" {
+			detail += ", resultado " + st.Result
+		}
+		problems[ProblemFailed] = detail
+	}
+
+	if w.active[name] == nil {
+		w.active[name] = make(map[string]bool)
+	}
+	active := w.active[name]
+
+	var events []Event
+	for _, problem := range []string{ProblemNotFound, ProblemFailed} {
+		detail, now := problems[problem]
+		switch {
+		case now && !active[problem]:
+			active[problem] = true
+			events = append(events, Event{Unit: name, Problem: problem, Detail: detail})
+		case !now && active[problem]:
+			delete(active, problem)
+			events = append(events, Event{Unit: name, Problem: problem, Resolved: true, Detail: st.ActiveState + "/" + st.SubState})
+		}
+	}
+
+	// Un reinicio se detecta por el contador de systemd (Restart=) o porque la unidad
+	// volvió a activarse desde la consulta anterior (reinicio manual o por dependencia).
+	// La salida de failed ya se notifica como recuperación.
+	prev, known := w.states[name]
+	if known && len(events) == 0 && st.ActiveState == "active" {
+		switch {
+		case st.Restarts > prev.Restarts:
+			events = append(events, Event{Unit: name, Problem: ProblemRestarted,
+				Detail: fmt.Sprintf("systemd la reinició %d veces desde la última consulta (total %d)", st.Restarts-prev.Restarts, st.Restarts)})
+		case !prev.ActiveSince.IsZero() && st.ActiveSince.After(prev.ActiveSince):
+			events = append(events, Event{Unit: name, Problem: ProblemRestarted,
+				Detail: "activa de nuevo desde " + st.ActiveSince.Format("2006-01-02 15:04:05")})
+		}
+	}
+	return events
+}
+
+// States retorna el último estado de las unidades vigiladas
+func (w *Watcher) States() []UnitState {
+	list := make([]UnitState, 0, len(w.states))
+	for _, s := range w.states {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Failed indica si la unidad tiene un problema activo
+func (s UnitState) Failed() bool {
+	return s.LoadState == "not-found" || s.ActiveState == "failed"
+}
@@ -0,0 +1,196 @@
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"orgmserver/dbus"
+	"orgmserver/utils/clocktest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeUnit son las propiedades que expone el bus para una unidad
+type fakeUnit struct {
+	load, active, sub, result string
+	restarts                  uint32
+	since                     time.Time
+	unloaded                  bool // GetUnit responde NoSuchUnit y hay que usar LoadUnit
+}
+
+// fakeBus responde como systemd para las unidades definidas
+type fakeBus struct {
+	units map[string]*fakeUnit
+	calls []string
+	props []string
+	err   error // respuesta de GetProperty mientras no sea nil
+}
+
+func (b *fakeBus) Call(ctx context.Context, dest string, path dbus.ObjectPath, iface, member string, args ...string) ([]interface{}, error) {
+	b.calls = append(b.calls, member+" "+strings.Join(args, " "))
+	u, ok := b.units[args[0]]
+	if member == "GetUnit" && (!ok || u.unloaded) {
+		return nil, &dbus.Error{Name: errNoSuchUnit}
+	}
+	return []interface{}{dbus.ObjectPath("/unit/" + args[0])}, nil
+}
+
+func (b *fakeBus) GetProperty(ctx context.Context, dest string, path dbus.ObjectPath, iface, name string) (interface{}, error) {
+	unit := strings.TrimPrefix(string(path), "/unit/")
+	b.props = append(b.props, unit+" "+name)
+	if b.err != nil {
+		return nil, b.err
+	}
+	u, ok := b.units[unit]
+	if !ok {
+		// LoadUnit de una unidad inexistente
+		u = &fakeUnit{load: "not-found", active: "inactive", sub: "dead"}
+	}
+	switch name {
+	case "LoadState":
+		return u.load, nil
+	case "ActiveState":
+		return u.active, nil
+	case "SubState":
+		return u.sub, nil
+	case "Result":
+		return u.result, nil
+	case "NRestarts":
+		return u.restarts, nil
+	case "ActiveEnterTimestamp":
+		if u.since.IsZero() {
+			return uint64(0), nil
+		}
+		return uint64(u.since.UnixMicro()), nil
+	}
+	return nil, &dbus.Error{Name: "org.freedesktop.DBus.Error.UnknownProperty"}
+}
+
+func (b *fakeBus) Close() error { return nil }
+
+func eventKeys(events []Event) string {
+	var keys []string
+	for _, e := range events {
+		key := e.Unit + ":" + e.Problem
+		if e.Resolved {
+			key += ":resuelto"
+		}
+		keys = append(keys, key)
+	}
+	return fmt.Sprint(keys)
+}
+
+func TestWatcherEvents(t *testing.T) {
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	web := &fakeUnit{load: "loaded", active: "active", sub: "running", result: "success", since: start}
+	backup := &fakeUnit{load: "loaded", active: "inactive", sub: "dead", result: "success", unloaded: true}
+	bus := &fakeBus{units: map[string]*fakeUnit{"web.service": web, "backup.timer": backup}}
+	w := &Watcher{Units: []string{"web.service", "backup.timer", "nada.service"}, conn: bus}
+	ctx := context.Background()
+
+	check := func(want string) {
+		t.Helper()
+		events, err := w.Check(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := eventKeys(events); got != want {
+			t.Fatalf("eventos = %s, se esperaba %s", got, want)
+		}
+	}
+
+	// La unidad inexistente se avisa desde la primera consulta
+	check("[nada.service:not-found]")
+	check("[]")
+
+	// failed y su recuperación, sin aviso de reinicio adicional
+	web.active, web.sub, web.result = "failed", "failed", "exit-code"
+	check("[web.service:failed]")
+	if st := w.States()[2]; st.Name != "web.service" || !st.Failed() || st.Result != "exit-code" {
+		t.Fatalf("estado = %+v", st)
+	}
+	web.active, web.sub, web.result, web.since = "active", "running", "success", start.Add(time.Hour)
+	check("[web.service:failed:resuelto]")
+
+	// Restart= de systemd incrementa NRestarts
+	web.restarts = 2
+	check("[web.service:restarted]")
+	check("[]")
+
+	// Un reinicio manual solo cambia ActiveEnterTimestamp
+	web.since = start.Add(2 * time.Hour)
+	check("[web.service:restarted]")
+
+	// Las unidades que no son servicios no tienen NRestarts
+	for _, p := range bus.props {
+		if p == "backup.timer NRestarts" || p == "nada.service NRestarts" {
+			t.Errorf("propiedad inesperada: %s", p)
+		}
+	}
+	// La ruta se resuelve una sola vez por unidad; LoadUnit solo si no está cargada
+	want := "[GetUnit web.service GetUnit backup.timer LoadUnit backup.timer GetUnit nada.service LoadUnit nada.service]"
+	if got := fmt.Sprint(bus.calls); got != want {
+		t.Errorf("llamadas = %s", got)
+	}
+}
+
+func TestWatcherKeepsStateOnBusError(t *testing.T) {
+	web := &fakeUnit{load: "loaded", active: "active", sub: "running", result: "success"}
+	bus := &fakeBus{units: map[string]*fakeUnit{"web.service": web}}
+	clock := clocktest.New(time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC))
+	w := &Watcher{Units: []string{"web.service"}, Clock: clock, conn: bus}
+	ctx := context.Background()
+
+	if _, err := w.Check(ctx); err != nil {
+		t.Fatal(err)
+	}
+	checked := clock.Now()
+
+	// Un error del bus no borra ni actualiza el último estado conocido
+	clock.Advance(time.Minute)
+	web.active, web.sub = "failed", "failed"
+	bus.err = &dbus.Error{Name: "org.freedesktop.DBus.Error.AccessDenied"}
+	if _, err := w.Check(ctx); err == nil || !strings.Contains(err.Error(), "web.service") {
+		t.Fatalf("error = %v", err)
+	}
+	if st := w.States()[0]; !st.CheckedAt.Equal(checked) || st.ActiveState != "active" {
+		t.Fatalf("estado = %+v", st)
+	}
+
+	// La ruta de la unidad se vuelve a resolver y el fallo se avisa con la hora nueva
+	clock.Advance(time.Minute)
+	bus.err = nil
+	events, err := w.Check(ctx)
+	if err != nil || eventKeys(events) != "[web.service:failed]" {
+		t.Fatalf("eventos = %s, %v", eventKeys(events), err)
+	}
+	if st := w.States()[0]; !st.CheckedAt.Equal(clock.Now()) {
+		t.Errorf("hora de la consulta = %s, esperado %s", st.CheckedAt, clock.Now())
+	}
+	if want := "[GetUnit web.service GetUnit web.service]"; fmt.Sprint(bus.calls) != want {
+		t.Errorf("llamadas = %v", bus.calls)
+	}
+}
+
+func TestEvaluateEventDetails(t *testing.T) {
+	w := &Watcher{active: make(map[string]map[string]bool), states: make(map[string]UnitState)}
+
+	events := w.evaluate("db.service", UnitState{LoadState: "loaded", ActiveState: "failed", SubState: "failed", Result: "oom-kill"})
+	if len(events) != 1 || events[0].Detail != "estado failed/failed, resultado oom-kill" || events[0].Describe() != "en estado failed" {
+		t.Fatalf("eventos = %+v", events)
+	}
+	w.states["db.service"] = UnitState{Restarts: 1}
+
+	// Volver a activa resuelve el fallo y no cuenta como reinicio aunque suba NRestarts
+	events = w.evaluate("db.service", UnitState{LoadState: "loaded", ActiveState: "active", SubState: "running", Restarts: 3})
+	if len(events) != 1 || !events[0].Resolved || events[0].Detail != "active/running" {
+		t.Fatalf("eventos = %+v", events)
+	}
+	w.states["db.service"] = UnitState{Restarts: 3}
+
+	events = w.evaluate("db.service", UnitState{LoadState: "loaded", ActiveState: "active", SubState: "running", Restarts: 5})
+	if len(events) != 1 || events[0].Problem != ProblemRestarted ||
+		events[0].Detail != "systemd la reinició 2 veces desde la última consulta (total 5)" {
+		t.Fatalf("eventos = %+v", events)
+	}
+}
@@ -0,0 +1,179 @@
+// Package ups consulta el estado de un UPS a través de upsd de Network UPS Tools
+// (protocolo de texto sobre TCP, puerto 3493).
+package ups
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultPort = 3493
+
+// Client se conecta a upsd en cada consulta
+type Client struct {
+	Addr     string // host:puerto de upsd
+	Username string // opcional, solo si upsd exige autenticación
+	Password string
+	Timeout  time.Duration
+}
+
+// ParseName separa un nombre NUT "ups@host[:puerto]" en nombre y dirección
+func ParseName(name string) (string, string, error) {
+	ups, host, ok := strings.Cut(name, "@")
+	if ups == "" {
+		return "", "", fmt.Errorf("nombre de UPS inválido: %q (formato ups@host[:puerto])", name)
+	}
+	if !ok || host == "" {
+		host = "localhost"
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(strings.Trim(host, "[]"), strconv.Itoa(DefaultPort))
+	}
+	return ups, host, nil
+}
+
+// Vars retorna todas las variables del UPS (LIST VAR)
+func (c *Client) Vars(ctx context.Context, ups string) (map[string]string, error) {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", c.Addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	s := &session{conn: conn, r: bufio.NewReader(conn)}
+	if c.Username != "" {
+		if err := s.command("USERNAME " + quote(c.Username)); err != nil {
+			return nil, err
+		}
+		if err := s.command("PASSWORD " + quote(c.Password)); err != nil {
+			return nil, err
+		}
+	}
+
+	vars, err := s.listVars(ups)
+	if err != nil {
+		return nil, err
+	}
+	s.send("LOGOUT")
+	return vars, nil
+}
+
+type session struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (s *session) send(line string) error {
+	_, err := s.conn.Write([]byte(line + "\n"))
+	return err
+}
+
+func (s *session) readLine() (string, error) {
+	line, err := s.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if rest, ok := strings.CutPrefix(line, "ERR "); ok {
+		return "", fmt.Errorf("upsd respondió error: %s", rest)
+	}
+	return line, nil
+}
+
+// command envía un comando que responde "OK"
+func (s *session) command(line string) error {
+	if err := s.send(line); err != nil {
+		return err
+	}
+	resp, err := s.readLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(resp, "OK") {
+		return fmt.Errorf("respuesta inesperada de upsd: %s", resp)
+	}
+	return nil
+}
+
+// listVars lee el bloque BEGIN LIST VAR ... END LIST VAR
+func (s *session) listVars(ups string) (map[string]string, error) {
+	if err := s.send("LIST VAR " + ups); err != nil {
+		return nil, err
+	}
+	line, err := s.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if line != "BEGIN LIST VAR "+ups {
+		return nil, fmt.Errorf("respuesta inesperada de upsd: %s", line)
+	}
+
+	vars := make(map[string]string)
+	for {
+		line, err := s.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if line == "END LIST VAR "+ups {
+			return vars, nil
+		}
+		fields := splitFields(line)
+		if len(fields) != 4 || fields[0] != "VAR" || fields[1] != ups {
+			return nil, fmt.Errorf("línea inválida de upsd: %s", line)
+		}
+		vars[fields[2]] = fields[3]
+	}
+}
+
+// splitFields separa por espacios respetando comillas y escapes con '\'
+func splitFields(line string) []string {
+	var fields []string
+	var cur strings.Builder
+	inQuote, escaped, started := false, false, false
+	for _, r := range line {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			inQuote = !inQuote
+			started = true
+		case r == ' ' && !inQuote:
+			if started {
+				fields = append(fields, cur.String())
+				cur.Reset()
+				started = false
+			}
+		default:
+			cur.WriteRune(r)
+			started = true
+		}
+	}
+	if started {
+		fields = append(fields, cur.String())
+	}
+	return fields
+}
+
+func quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
@@ -0,0 +1,277 @@
+package ups
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"orgmserver/utils/clocktest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeUpsd simula upsd con un único UPS y autenticación opcional
+type fakeUpsd struct {
+	mu       sync.Mutex
+	name     string
+	vars     map[string]string
+	username string
+	password string
+}
+
+func (f *fakeUpsd) set(key, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.vars[key] = value
+}
+
+func (f *fakeUpsd) serve(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.handle(conn)
+		}
+	}()
+	return l.Addr().String()
+}
+
+func (f *fakeUpsd) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	var user, pass string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := splitFields(strings.TrimSpace(line))
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "USERNAME":
+			user = fields[1]
+			fmt.Fprint(conn, "OK\n")
+		case "PASSWORD":
+			pass = fields[1]
+			fmt.Fprint(conn, "OK\n")
+		case "LOGOUT":
+			fmt.Fprint(conn, "OK Goodbye\n")
+			return
+		case "LIST":
+			if f.username != "" && (user != f.username || pass != f.password) {
+				fmt.Fprint(conn, "ERR ACCESS-DENIED\n")
+				continue
+			}
+			if len(fields) != 3 || fields[2] != f.name {
+				fmt.Fprint(conn, "ERR UNKNOWN-UPS\n")
+				continue
+			}
+			f.mu.Lock()
+			keys := make([]string, 0, len(f.vars))
+			for k := range f.vars {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			fmt.Fprintf(conn, "BEGIN LIST VAR %s\n", f.name)
+			for _, k := range keys {
+				fmt.Fprintf(conn, "VAR %s %s %s\n", f.name, k, quote(f.vars[k]))
+			}
+			fmt.Fprintf(conn, "END LIST VAR %s\n", f.name)
+			f.mu.Unlock()
+		default:
+			fmt.Fprint(conn, "ERR UNKNOWN-COMMAND\n")
+		}
+	}
+}
+
+func eventTypes(events []Event) string {
+	var types []string
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	return strings.Join(types, ",")
+}
+
+func TestWatcherEvents(t *testing.T) {
+	fake := &fakeUpsd{name: "rack", vars: map[string]string{
+		"ups.status":      "OL",
+		"battery.charge":  "100",
+		"battery.runtime": "1800",
+		"ups.mfr":         "APC",
+		"ups.model":       `Smart-UPS "1500"`,
+	}}
+	addr := fake.serve(t)
+	w := &Watcher{Client: &Client{Addr: addr, Timeout: 2 * time.Second}, UPS: "rack"}
+
+	steps := []struct {
+		status string
+		charge string
+		want   string
+	}{
+		{"OL", "100", ""},
+		{"OB DISCHRG", "90", EventOnBattery},
+		{"OB DISCHRG", "60", ""},
+		{"OB LB", "10", EventLowBattery},
+		{"OL CHRG LB", "12", EventPowerRestored},
+		{"OL CHRG", "40", ""},
+		{"OB LB", "8", EventOnBattery + "," + EventLowBattery},
+	}
+	for i, step := range steps {
+		fake.set("ups.status", step.status)
+		fake.set("battery.charge", step.charge)
+		_, events, err := w.Poll(context.Background())
+		if err != nil {
+			t.Fatalf("paso %d: %v", i, err)
+		}
+		if got := eventTypes(events); got != step.want {
+			t.Errorf("paso %d (%s): eventos %q, se esperaba %q", i, step.status, got, step.want)
+		}
+	}
+
+	st := w.Last()
+	if st == nil || st.Model != `APC Smart-UPS "1500"` || st.Runtime != 30*time.Minute || st.Charge != 8 {
+		t.Errorf("estado inesperado: %+v", st)
+	}
+	if !st.OnBattery() || !st.LowBattery() {
+		t.Errorf("se esperaba OB LB: %v", st.Flags)
+	}
+}
+
+func TestWatcherStartsOnBattery(t *testing.T) {
+	fake := &fakeUpsd{name: "ups", vars: map[string]string{"ups.status": "OB"}}
+	w := &Watcher{Client: &Client{Addr: fake.serve(t)}, UPS: "ups"}
+
+	st, events, err := w.Poll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := eventTypes(events); got != EventOnBattery {
+		t.Errorf("eventos %q, se esperaba %q", got, EventOnBattery)
+	}
+	if st.Charge != -1 || st.Runtime != -1 {
+		t.Errorf("carga y autonomía desconocidas deberían ser -1: %+v", st)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	fake := &fakeUpsd{name: "ups", vars: map[string]string{"ups.status": "OL"}, username: "mon", password: `p"ss`}
+	addr := fake.serve(t)
+
+	if _, err := (&Client{Addr: addr}).Vars(context.Background(), "ups"); err == nil || !strings.Contains(err.Error(), "ACCESS-DENIED") {
+		t.Errorf("sin credenciales se esperaba ACCESS-DENIED, se obtuvo %v", err)
+	}
+
+	c := &Client{Addr: addr, Username: "mon", Password: `p"ss`}
+	if _, err := c.Vars(context.Background(), "otro"); err == nil || !strings.Contains(err.Error(), "UNKNOWN-UPS") {
+		t.Errorf("se esperaba UNKNOWN-UPS, se obtuvo %v", err)
+	}
+	vars, err := c.Vars(context.Background(), "ups")
+	if err != nil || vars["ups.status"] != "OL" {
+		t.Errorf("Vars = %v, %v", vars, err)
+	}
+}
+
+func TestParseName(t *testing.T) {
+	cases := []struct{ in, ups, addr string }{
+		{"ups", "ups", "localhost:3493"},
+		{"rack@nas", "rack", "nas:3493"},
+		{"rack@nas:4000", "rack", "nas:4000"},
+		{"rack@[::1]", "rack", "[::1]:3493"},
+	}
+	for _, c := range cases {
+		ups, addr, err := ParseName(c.in)
+		if err != nil || ups != c.ups || addr != c.addr {
+			t.Errorf("ParseName(%q) = %q, %q, %v", c.in, ups, addr, err)
+		}
+	}
+	if _, _, err := ParseName("@nas"); err == nil {
+		t.Error("se esperaba error sin nombre de UPS")
+	}
+}
+
+func TestWatcherOnBatteryDuration(t *testing.T) {
+	fake := &fakeUpsd{name: "ups", vars: map[string]string{"ups.status": "OL"}}
+	clock := clocktest.New(time.Date(2025, 6, 1, 3, 0, 0, 0, time.UTC))
+	w := &Watcher{Client: &Client{Addr: fake.serve(t)}, UPS: "ups", Clock: clock}
+	poll := func(want string) []Event {
+		t.Helper()
+		_, events, err := w.Poll(context.Background())
+		if err != nil || eventTypes(events) != want {
+			t.Fatalf("eventos = %+v, %v; se esperaba %q", events, err, want)
+		}
+		return events
+	}
+
+	poll("")
+	clock.Advance(time.Minute)
+	fake.set("ups.status", "OB")
+	cut := clock.Now()
+	if events := poll(EventOnBattery); !events[0].Time.Equal(cut) {
+		t.Errorf("hora del corte = %s, esperado %s", events[0].Time, cut)
+	}
+
+	// La batería baja no reinicia el conteo del tiempo en batería
+	clock.Advance(8 * time.Minute)
+	fake.set("ups.status", "OB LB")
+	poll(EventLowBattery)
+	clock.Advance(4 * time.Minute)
+	poll("")
+
+	fake.set("ups.status", "OL CHRG")
+	if events := poll(EventPowerRestored); events[0].OnBatteryFor != 12*time.Minute {
+		t.Errorf("tiempo en batería = %s, esperado 12m", events[0].OnBatteryFor)
+	}
+
+	// Un segundo corte se mide desde su propio inicio
+	clock.Advance(time.Hour)
+	fake.set("ups.status", "OB")
+	poll(EventOnBattery)
+	clock.Advance(90 * time.Second)
+	fake.set("ups.status", "OL")
+	if events := poll(EventPowerRestored); events[0].OnBatteryFor != 90*time.Second {
+		t.Errorf("tiempo en batería = %s, esperado 1m30s", events[0].OnBatteryFor)
+	}
+}
+
+func TestWatcherResumesAfterPowerLoss(t *testing.T) {
+	fake := &fakeUpsd{name: "ups", vars: map[string]string{"ups.status": "OL"}}
+	clock := clocktest.New(time.Date(2025, 6, 1, 3, 0, 0, 0, time.UTC))
+	w := &Watcher{Client: &Client{Addr: fake.serve(t)}, UPS: "ups", Clock: clock}
+
+	// El equipo se apagó en batería; al volver el UPS está en línea
+	w.Resume(EventLowBattery, clock.Now().Add(-time.Hour))
+	_, events, err := w.Poll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Type != EventPowerRestored || events[0].OnBatteryFor != time.Hour {
+		t.Fatalf("eventos = %+v", events)
+	}
+
+	// Si sigue en batería no se repite el aviso de corte
+	w = &Watcher{Client: &Client{Addr: fake.serve(t)}, UPS: "ups", Clock: clock}
+	fake.set("ups.status", "OB")
+	w.Resume(EventOnBattery, clock.Now().Add(-time.Hour))
+	if _, events, err = w.Poll(context.Background()); err != nil || len(events) != 0 {
+		t.Fatalf("eventos = %+v, %v", events, err)
+	}
+
+	// Tras power_restored no hay nada que retomar
+	w = &Watcher{Client: &Client{Addr: fake.serve(t)}, UPS: "ups", Clock: clock}
+	w.Resume(EventPowerRestored, clock.Now().Add(-time.Hour))
+	if _, events, err = w.Poll(context.Background()); err != nil || eventTypes(events) != EventOnBattery {
+		t.Fatalf("eventos = %+v, %v", events, err)
+	}
+}
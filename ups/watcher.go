@@ -0,0 +1,161 @@
+package ups
+
+import (
+	"context"
+	"fmt"
+	"orgmserver/utils"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tipos de evento de energía
+const (
+	EventOnBattery     = "on_battery"
+	EventLowBattery    = "low_battery"
+	EventPowerRestored = "power_restored"
+)
+
+// Status es el estado del UPS según ups.status y las variables de batería.
+// Charge y Runtime valen -1 si el UPS no los informa.
+type Status struct {
+	UPS     string
+	Flags   []string
+	Charge  float64 // porcentaje
+	Runtime time.Duration
+	Model   string
+}
+
+// ParseStatus interpreta las variables de LIST VAR
+func ParseStatus(ups string, vars map[string]string) Status {
+	s := Status{UPS: ups, Charge: -1, Runtime: -1}
+	s.Flags = strings.Fields(vars["ups.status"])
+	if v, err := strconv.ParseFloat(vars["battery.charge"], 64); err == nil {
+		s.Charge = v
+	}
+	if v, err := strconv.ParseFloat(vars["battery.runtime"], 64); err == nil {
+		s.Runtime = time.Duration(v) * time.Second
+	}
+	s.Model = strings.TrimSpace(vars["ups.mfr"] + " " + vars["ups.model"])
+	return s
+}
+
+func (s Status) has(flag string) bool {
+	for _, f := range s.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// OnBattery indica si el UPS está alimentando desde la batería
+func (s Status) OnBattery() bool {
+	return s.has("OB")
+}
+
+// LowBattery indica si la batería está baja
+func (s Status) LowBattery() bool {
+	return s.has("LB")
+}
+
+// String resume la batería en texto
+func (s Status) String() string {
+	parts := []string{"estado " + strings.Join(s.Flags, " ")}
+	if s.Charge >= 0 {
+		parts = append(parts, fmt.Sprintf("carga %.0f%%", s.Charge))
+	}
+	if s.Runtime >= 0 {
+		parts = append(parts, fmt.Sprintf("autonomía %s", s.Runtime))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Event es un cambio del estado de energía
+type Event struct {
+	Type   string
+	Time   time.Time
+	Status Status
+	// OnBatteryFor es el tiempo en batería al restaurarse la energía
+	OnBatteryFor time.Duration
+}
+
+// Watcher consulta periódicamente un UPS y detecta los cambios de energía
+type Watcher struct {
+	Client *Client
+	UPS    string
+	Clock  utils.Clock // hora de los eventos; nil usa el reloj del sistema
+
+	last         *Status
+	resumed      *Status
+	batterySince time.Time
+}
+
+// Resume retoma el último evento de energía registrado antes de reiniciar el
+// servicio. Si el equipo se apagó con el UPS en batería, la primera lectura con
+// el UPS en línea genera power_restored; since es el inicio del corte.
+func (w *Watcher) Resume(eventType string, since time.Time) {
+	switch eventType {
+	case EventOnBattery:
+		w.resumed = &Status{UPS: w.UPS, Flags: []string{"OB"}}
+	case EventLowBattery:
+		w.resumed = &Status{UPS: w.UPS, Flags: []string{"OB", "LB"}}
+	default:
+		w.resumed = nil
+		return
+	}
+	w.batterySince = since
+}
+
+func (w *Watcher) now() time.Time {
+	if w.Clock == nil {
+		return utils.SystemClock.Now()
+	}
+	return w.Clock.Now()
+}
+
+// Poll consulta el UPS y retorna su estado y los eventos nuevos
+func (w *Watcher) Poll(ctx context.Context) (Status, []Event, error) {
+	vars, err := w.Client.Vars(ctx, w.UPS)
+	if err != nil {
+		return Status{}, nil, err
+	}
+	st := ParseStatus(w.UPS, vars)
+	if len(st.Flags) == 0 {
+		return st, nil, fmt.Errorf("el UPS %s no informa ups.status", w.UPS)
+	}
+
+	// Al iniciar se parte del último evento registrado; sin eventos se asume que el
+	// UPS estaba en línea, para avisar si ya está en batería
+	prev := Status{}
+	if w.last != nil {
+		prev = *w.last
+	} else if w.resumed != nil {
+		prev = *w.resumed
+	}
+
+	var events []Event
+	now := w.now()
+	if st.OnBattery() && !prev.OnBattery() {
+		w.batterySince = now
+		events = append(events, Event{Type: EventOnBattery, Time: now, Status: st})
+	}
+	if st.LowBattery() && !prev.LowBattery() {
+		events = append(events, Event{Type: EventLowBattery, Time: now, Status: st})
+	}
+	if !st.OnBattery() && prev.OnBattery() {
+		events = append(events, Event{Type: EventPowerRestored, Time: now, Status: st, OnBatteryFor: now.Sub(w.batterySince)})
+	}
+
+	w.last = &st
+	return st, events, nil
+}
+
+// Last retorna el último estado leído, o nil si aún no hubo lectura
+func (w *Watcher) Last() *Status {
+	if w.last == nil {
+		return nil
+	}
+	s := *w.last
+	return &s
+}
@@ -0,0 +1,18 @@
+package utils
+
+import "time"
+
+// Clock da la hora actual. Se recibe en lugar de llamar a time.Now para poder
+// probar con un reloj simulado.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock es el reloj del sistema
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
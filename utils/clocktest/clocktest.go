@@ -0,0 +1,40 @@
+// Package clocktest provee un reloj simulado para los tests: la hora solo avanza
+// cuando el test lo indica.
+package clocktest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock es un utils.Clock manual; es seguro usarlo desde varias goroutines
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// New crea un reloj detenido en t
+func New(t time.Time) *Clock {
+	return &Clock{now: t}
+}
+
+// Now retorna la hora simulada
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance adelanta el reloj d
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Set fija la hora simulada
+func (c *Clock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// ExternalIPs contiene las IP externas por familia (vacías si no están disponibles
+// o si los proveedores no llegaron a un consenso)
+type ExternalIPs struct {
+	IPv4 string
+	IPv6 string
+}
+
+// Primary retorna la IPv4 si existe, si no la IPv6
+func (e ExternalIPs) Primary() string {
+	if e.IPv4 != "" {
+		return e.IPv4
+	}
+	return e.IPv6
+}
+
+// String retorna las IP disponibles separadas por coma
+func (e ExternalIPs) String() string {
+	var parts []string
+	if e.IPv4 != "" {
+		parts = append(parts, e.IPv4)
+	}
+	if e.IPv6 != "" {
+		parts = append(parts, e.IPv6)
+	}
+	if len(parts) == 0 {
+		return "No disponible"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Rangos especiales que no pueden ser una IP pública (RFC 6890 y relacionados)
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // CGNAT (RFC 6598)
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"), // TEST-NET-1
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"), // TEST-NET-2
+	netip.MustParsePrefix("203.0.113.0/24"),  // TEST-NET-3
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001::/23"),
+	netip.MustParsePrefix("2001:db8::/32"), // documentación
+	netip.MustParsePrefix("2002::/16"),     // 6to4
+}
+
+// IsPublicIP indica si la dirección es unicast global enrutable en internet
+func IsPublicIP(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// IPv6Prefix retorna el prefijo de red de una IPv6 con la longitud dada (por ejemplo 2001:db8:1::/56)
+func IPv6Prefix(ip string, bits int) (string, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return "", err
+	}
+	if !addr.Is6() || addr.Is4In6() {
+		return "", fmt.Errorf("%s no es IPv6", ip)
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return "", err
+	}
+	return prefix.String(), nil
+}
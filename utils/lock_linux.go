@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// lockFile toma un bloqueo exclusivo (flock) sobre path. El sistema lo libera si el
+// proceso termina, así que un cierre inesperado no deja el bloqueo tomado.
+func lockFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		pid, _ := os.ReadFile(path)
+		f.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, fmt.Errorf("otro proceso (pid %s) usa el archivo de estado: %s", string(pid), path)
+		}
+		return nil, fmt.Errorf("error bloqueando %s: %w", path, err)
+	}
+
+	// El PID del dueño ayuda a identificar la otra instancia
+	f.Truncate(0)
+	f.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+	return f, nil
+}
+
+func unlockFile(f *os.File) error {
+	syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	return f.Close()
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Un segundo dueño del mismo archivo de estado se rechaza hasta que el primero lo libera
+func TestStoreLockRejectsSecondOwner(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	first, err := OpenStateStore(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := OpenStateStore(path, false)
+	if err == nil {
+		second.Close()
+		t.Fatal("se abrió el mismo estado dos veces")
+	}
+	if want := "otro proceso (pid " + strconv.Itoa(os.Getpid()) + ")"; !strings.Contains(err.Error(), want) {
+		t.Errorf("error = %v, se esperaba %q", err, want)
+	}
+
+	// Otro archivo de estado en el mismo directorio no está bloqueado
+	other, err := OpenStateStore(filepath.Join(filepath.Dir(path), "otro.json"), false)
+	if err != nil {
+		t.Fatalf("otro estado: %v", err)
+	}
+	other.Close()
+
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+	second, err = OpenStateStore(path, false)
+	if err != nil {
+		t.Fatalf("tras liberar el bloqueo: %v", err)
+	}
+	second.Close()
+}
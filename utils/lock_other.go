@@ -0,0 +1,14 @@
+//go:build !linux
+
+package utils
+
+import "os"
+
+// lockFile solo crea el archivo: el bloqueo entre procesos requiere flock (Linux)
+func lockFile(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+}
+
+func unlockFile(f *os.File) error {
+	return f.Close()
+}
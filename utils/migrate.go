@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// StateVersion es la versión actual del formato del archivo de estado. Los archivos
+// sin campo "version" son de la versión 1: todos los formatos anteriores al
+// versionado agregaban campos opcionales sobre el original y se leen igual.
+const StateVersion = 2
+
+// ErrStateTooNew indica un archivo de estado escrito por una versión más nueva del
+// servicio; no se reemplaza para no perder datos al volver a una versión anterior
+var ErrStateTooNew = errors.New("archivo de estado de una versión más nueva")
+
+// stateMigrations[i] convierte un documento de la versión i+1 a la i+2. Operan
+// sobre el JSON genérico para poder leer campos que ya no existen en State.
+var stateMigrations = []func(doc map[string]interface{}) error{
+	migrateStateV1,
+}
+
+// migrateStateV1 separa la IP de LastIP por familia: la versión 1 original solo
+// guardaba last_ip, con IPv4 o IPv6 según cuál se hubiera obtenido. Un valor que
+// no es una IP (por ejemplo la página de error de un proveedor) se descarta.
+func migrateStateV1(doc map[string]interface{}) error {
+	raw, ok := doc["last_ip"]
+	if !ok {
+		return nil
+	}
+	ip, _ := raw.(string)
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		delete(doc, "last_ip")
+		return nil
+	}
+
+	// Una IPv4 mapeada en IPv6 (::ffff:a.b.c.d) se guarda como IPv4
+	key := "last_ipv6"
+	if addr.Is4() || addr.Is4In6() {
+		addr = addr.Unmap()
+		key = "last_ipv4"
+	}
+	doc["last_ip"] = addr.String()
+	if current, _ := doc[key].(string); current == "" {
+		doc[key] = addr.String()
+	}
+	return nil
+}
+
+// decodeState lee un archivo de estado de cualquier versión y lo lleva a la actual
+func decodeState(data []byte) (*State, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, fmt.Errorf("archivo de estado vacío")
+	}
+
+	version := 1
+	if v, ok := doc["version"]; ok {
+		n, ok := v.(float64)
+		if !ok || n < 1 || n != float64(int(n)) {
+			return nil, fmt.Errorf("versión de estado inválida: %v", v)
+		}
+		version = int(n)
+	}
+	if version > StateVersion {
+		return nil, fmt.Errorf("%w: versión %d, soportada hasta %d", ErrStateTooNew, version, StateVersion)
+	}
+
+	if version < StateVersion {
+		for v := version; v < StateVersion; v++ {
+			if err := stateMigrations[v-1](doc); err != nil {
+				return nil, fmt.Errorf("error migrando el estado de la versión %d: %w", v, err)
+			}
+		}
+		doc["version"] = StateVersion
+		var err error
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, err
+		}
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
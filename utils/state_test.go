@@ -0,0 +1,250 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Los archivos de testdata reproducen el estado tal como lo escribía cada versión
+// anterior del servicio; todos deben seguir cargándose en el formato actual
+func TestLoadStateFixtures(t *testing.T) {
+	tests := []struct {
+		file  string
+		check func(t *testing.T, s *State)
+	}{
+		{"state_v1_base.json", func(t *testing.T, s *State) {
+			if s.LastIPv4 != "203.0.113.7" || s.LastIPv6 != "" {
+				t.Errorf("IP por familia = %q, %q", s.LastIPv4, s.LastIPv6)
+			}
+			if !s.IsConnected || s.LastConnected.IsZero() || s.StartTime.IsZero() {
+				t.Errorf("campos originales perdidos: %+v", s)
+			}
+		}},
+		{"state_v1_base_ipv6.json", func(t *testing.T, s *State) {
+			if s.LastIPv4 != "" || s.LastIPv6 != "2001:db8:1234:5678::1" {
+				t.Errorf("IP por familia = %q, %q", s.LastIPv4, s.LastIPv6)
+			}
+			if s.IsConnected || s.LastDisconnected.IsZero() {
+				t.Errorf("desconexión perdida: %+v", s)
+			}
+		}},
+		{"state_v1_ipfamilies.json", func(t *testing.T, s *State) {
+			if s.LastIPv4 != "203.0.113.7" || s.LastIPv6 != "2001:db8:1234:5678::1" || s.LastIPv6Prefix != "2001:db8:1234:5678::/64" {
+				t.Errorf("IP por familia = %q, %q, %q", s.LastIPv4, s.LastIPv6, s.LastIPv6Prefix)
+			}
+		}},
+		{"state_v1_speedtests.json", func(t *testing.T, s *State) {
+			if len(s.SpeedTests) != 2 || s.SpeedTests[0].DownloadMbps != 94.2 || s.SpeedTests[1].Error == "" {
+				t.Errorf("pruebas de velocidad = %+v", s.SpeedTests)
+			}
+		}},
+		{"state_v1_checks.json", func(t *testing.T, s *State) {
+			if len(s.Checks) != 2 || s.Checks["nas"].Status != "down" || s.Checks["nas"].Notified != "down" {
+				t.Errorf("checks = %+v", s.Checks)
+			}
+		}},
+		{"state_v1_power.json", func(t *testing.T, s *State) {
+			if len(s.PowerEvents) != 2 || s.PowerEvents[0].Type != "on_battery" || s.PowerEvents[1].RuntimeSeconds != -1 {
+				t.Errorf("eventos de energía = %+v", s.PowerEvents)
+			}
+		}},
+		{"state_v1_pings.json", func(t *testing.T, s *State) {
+			p, ok := s.Pings["backup"]
+			if !ok || p.Status != "up" || p.LastPing.IsZero() {
+				t.Errorf("pings = %+v", s.Pings)
+			}
+			if s.LastIPv4 != "" || s.LastIPv6 != "2001:db8:1234:5678::1" {
+				t.Errorf("IP por familia = %q, %q", s.LastIPv4, s.LastIPv6)
+			}
+		}},
+		// Un last_ip que no es una IP se descarta sin impedir la carga
+		{"state_v1_garbage_ip.json", func(t *testing.T, s *State) {
+			if s.LastIP != "" || s.LastIPv4 != "" || s.LastIPv6 != "" {
+				t.Errorf("IP = %q, %q, %q", s.LastIP, s.LastIPv4, s.LastIPv6)
+			}
+			if !s.IsConnected || s.StartTime.IsZero() {
+				t.Errorf("campos originales perdidos: %+v", s)
+			}
+		}},
+		{"state_v1_empty_ip.json", func(t *testing.T, s *State) {
+			if s.LastIP != "" || s.LastIPv4 != "" || s.LastIPv6 != "" {
+				t.Errorf("IP = %q, %q, %q", s.LastIP, s.LastIPv4, s.LastIPv6)
+			}
+		}},
+		{"state_v1_number_ip.json", func(t *testing.T, s *State) {
+			if s.LastIP != "" || s.LastIPv4 != "" || s.LastIPv6 != "" {
+				t.Errorf("IP = %q, %q, %q", s.LastIP, s.LastIPv4, s.LastIPv6)
+			}
+		}},
+		{"state_v1_mapped_ip.json", func(t *testing.T, s *State) {
+			if s.LastIP != "203.0.113.7" || s.LastIPv4 != "203.0.113.7" || s.LastIPv6 != "" {
+				t.Errorf("IP = %q, %q, %q", s.LastIP, s.LastIPv4, s.LastIPv6)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			s, err := LoadState(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.Version != StateVersion {
+				t.Errorf("versión = %d, se esperaba %d", s.Version, StateVersion)
+			}
+			tt.check(t, s)
+		})
+	}
+}
+
+// Al guardar, un estado migrado se escribe en el formato actual y se vuelve a leer igual
+func TestStoreRewritesMigratedState(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "state_v1_checks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := OpenStateStore(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if err := store.Update(func(s *State) { s.LastConnected = time.Now() }); err != nil {
+		t.Fatal(err)
+	}
+
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(written), `"version": 2`) {
+		t.Errorf("el archivo no quedó en la versión actual:\n%s", written)
+	}
+	backup, err := os.ReadFile(path + ".bak")
+	if err != nil || string(backup) != string(data) {
+		t.Errorf("el respaldo debe conservar el archivo original (err %v)", err)
+	}
+
+	reloaded, err := LoadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.LastIPv4 != "203.0.113.7" || len(reloaded.Checks) != 2 || len(reloaded.SpeedTests) != 1 {
+		t.Errorf("estado releído = %+v", reloaded)
+	}
+}
+
+// Un archivo que no es JSON se rechaza en lugar de cargarse como estado vacío
+func TestLoadStateGarbage(t *testing.T) {
+	for _, file := range []string{"state_garbage.json", "state_empty.json"} {
+		if s, err := LoadState(filepath.Join("testdata", file)); err == nil {
+			t.Errorf("%s: se cargó %+v", file, s)
+		}
+	}
+}
+
+// Un archivo de una versión más nueva no se reemplaza
+func TestStateTooNew(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte(`{"version": 99, "last_ip": "203.0.113.7"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadState(path); !errors.Is(err, ErrStateTooNew) {
+		t.Errorf("LoadState = %v, se esperaba ErrStateTooNew", err)
+	}
+	if _, err := OpenStateStore(path, false); !errors.Is(err, ErrStateTooNew) {
+		t.Errorf("OpenStateStore = %v, se esperaba ErrStateTooNew", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("el archivo no debe apartarse: %v", err)
+	}
+}
+
+func TestStateInvalidVersion(t *testing.T) {
+	for _, doc := range []string{`{"version": "2"}`, `{"version": 0}`, `{"version": 1.5}`, `null`} {
+		if _, err := decodeState([]byte(doc)); err == nil {
+			t.Errorf("%s: se esperaba error", doc)
+		}
+	}
+}
+
+// Si el archivo principal se daña se recupera el guardado anterior desde el respaldo
+func TestStoreRecoversFromBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	store, err := OpenStateStore(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Update(func(s *State) { s.LastIPv4 = "203.0.113.7" }); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Update(func(s *State) { s.LastIPv4 = "198.51.100.20" }); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	// Un corte a mitad de una escritura ajena al store deja el archivo truncado
+	if err := os.WriteFile(path, []byte(`{"version": 2, "last_ipv4": "198.51`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := LoadState(path); err != nil || s.LastIPv4 != "203.0.113.7" {
+		t.Fatalf("LoadState = %+v, %v", s, err)
+	}
+
+	store, err = OpenStateStore(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !store.Loaded() || store.Get().LastIPv4 != "203.0.113.7" {
+		t.Fatalf("estado recuperado = %+v (cargado %v)", store.Get(), store.Loaded())
+	}
+	// El siguiente guardado reemplaza el archivo dañado y conserva el respaldo
+	if err := store.Update(func(s *State) { s.LastIPv6 = "2001:db8::1" }); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+	if s, _, err := readStateFile(path); err != nil || s.LastIPv4 != "203.0.113.7" || s.LastIPv6 != "2001:db8::1" {
+		t.Errorf("archivo principal = %+v, %v", s, err)
+	}
+	if s, _, err := readStateFile(path + ".bak"); err != nil || s.LastIPv4 != "203.0.113.7" || s.LastIPv6 != "" {
+		t.Errorf("respaldo = %+v, %v", s, err)
+	}
+}
+
+// Con el archivo y el respaldo dañados se empieza de cero y se aparta el archivo dañado
+func TestStoreBothFilesCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	garbage := []byte("<html>502 Bad Gateway</html>")
+	for _, p := range []string{path, path + ".bak"} {
+		if err := os.WriteFile(p, garbage, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := LoadState(path); err == nil || !strings.Contains(err.Error(), "respaldo no disponible") {
+		t.Errorf("LoadState = %v", err)
+	}
+
+	store, err := OpenStateStore(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if store.Loaded() || store.Get().Version != StateVersion {
+		t.Errorf("estado nuevo = %+v (cargado %v)", store.Get(), store.Loaded())
+	}
+	if data, err := os.ReadFile(path + ".corrupt"); err != nil || string(data) != string(garbage) {
+		t.Errorf("archivo dañado apartado = %q, %v", data, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("el archivo dañado debía moverse: %v", err)
+	}
+}
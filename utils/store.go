@@ -0,0 +1,227 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// StateStore es el dueño del estado: lo mantiene en memoria y guarda cada cambio
+// en el archivo. La escritura es atómica (archivo temporal, fsync y rename) y la
+// versión anterior se conserva en <archivo>.bak para recuperarla si el archivo
+// principal se daña. Un archivo <archivo>.lock impide que dos procesos usen el
+// mismo estado.
+type StateStore struct {
+	path  string
+	debug bool
+
+	mu     sync.Mutex
+	state  State
+	saved  []byte // contenido del último guardado, que pasa a ser el respaldo
+	loaded bool
+	lock   *os.File
+}
+
+// OpenStateStore toma el bloqueo del archivo de estado y lo carga. Si el archivo y
+// su respaldo están dañados se empieza con un estado nuevo y el archivo dañado se
+// conserva como <archivo>.corrupt.
+func OpenStateStore(path string, debug bool) (*StateStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	lock, err := lockFile(path + ".lock")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &StateStore{path: path, debug: debug, lock: lock}
+	state, data, err := loadStateFile(path, debug)
+	if errors.Is(err, ErrStateTooNew) {
+		unlockFile(lock)
+		return nil, err
+	}
+	if err != nil {
+		WriteLog(fmt.Sprintf("[STATE] Estado ilegible (%v), se inicia uno nuevo", err), debug)
+		if err := os.Rename(path, path+".corrupt"); err != nil && !os.IsNotExist(err) {
+			WriteLog(fmt.Sprintf("[STATE] Error apartando el estado dañado: %v", err), debug)
+		}
+		state = newState()
+	}
+	s.state = *state
+	s.saved = data
+	s.loaded = data != nil
+	return s, nil
+}
+
+// Loaded indica si el estado se leyó de un archivo existente; false si se empezó
+// de cero
+func (s *StateStore) Loaded() bool {
+	return s.loaded
+}
+
+// Get retorna una copia del estado actual
+func (s *StateStore) Get() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.clone()
+}
+
+// Update aplica fn al estado y lo guarda en el archivo. El estado en memoria queda
+// actualizado aunque falle la escritura; el siguiente cambio vuelve a intentarla.
+func (s *StateStore) Update(fn func(*State)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.state)
+	return s.persist()
+}
+
+// Close libera el bloqueo del archivo de estado
+func (s *StateStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lock == nil {
+		return nil
+	}
+	err := unlockFile(s.lock)
+	s.lock = nil
+	return err
+}
+
+// persist escribe el estado si cambió desde el último guardado, respaldando antes
+// la versión anterior
+func (s *StateStore) persist() error {
+	data, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(data, s.saved) {
+		return nil
+	}
+
+	if s.saved != nil {
+		if err := writeFileAtomic(s.path+".bak", s.saved); err != nil {
+			return fmt.Errorf("error guardando respaldo del estado: %w", err)
+		}
+	}
+	if err := writeFileAtomic(s.path, data); err != nil {
+		return err
+	}
+	s.saved = data
+	return nil
+}
+
+// LoadState lee el estado desde el archivo sin tomar el bloqueo, para consultarlo
+// fuera del proceso que lo escribe. Si el archivo está dañado usa el respaldo.
+func LoadState(filePath string) (*State, error) {
+	state, _, err := loadStateFile(filePath, false)
+	return state, err
+}
+
+// loadStateFile lee el archivo de estado y, si está dañado, el respaldo. Retorna
+// también el contenido leído (nil si el archivo no existe).
+func loadStateFile(path string, debug bool) (*State, []byte, error) {
+	state, data, err := readStateFile(path)
+	if err == nil {
+		return state, data, nil
+	}
+	if os.IsNotExist(err) {
+		// Archivo no existe, crear estado inicial
+		return newState(), nil, nil
+	}
+	if errors.Is(err, ErrStateTooNew) {
+		return nil, nil, err
+	}
+
+	WriteLog(fmt.Sprintf("[STATE] Archivo de estado dañado: %v; se usa el respaldo", err), debug)
+	state, data, bakErr := readStateFile(path + ".bak")
+	if bakErr != nil {
+		return nil, nil, fmt.Errorf("estado dañado (%v) y respaldo no disponible (%v)", err, bakErr)
+	}
+	return state, data, nil
+}
+
+func readStateFile(path string) (*State, []byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	state, err := decodeState(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return state, data, nil
+}
+
+func newState() *State {
+	return &State{
+		Version:       StateVersion,
+		LastConnected: time.Now(),
+		IsConnected:   true,
+		StartTime:     time.Now(),
+	}
+}
+
+// writeFileAtomic escribe en un archivo temporal del mismo directorio, lo sincroniza
+// y lo renombra sobre el destino: tras un corte se conserva el contenido anterior o
+// el nuevo completo, nunca uno a medias
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp) // sin efecto tras el rename
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+
+	// Sincronizar el directorio para que el rename sobreviva a un corte
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// clone copia el estado, incluidos los historiales, para que quien lo lea no
+// comparta slices ni mapas con el dueño
+func (s State) clone() State {
+	c := s
+	c.SpeedTests = append([]SpeedTestRecord(nil), s.SpeedTests...)
+	c.PowerEvents = append([]PowerEvent(nil), s.PowerEvents...)
+	if s.Checks != nil {
+		c.Checks = make(map[string]CheckRecord, len(s.Checks))
+		for k, v := range s.Checks {
+			c.Checks[k] = v
+		}
+	}
+	if s.Pings != nil {
+		c.Pings = make(map[string]PingRecord, len(s.Pings))
+		for k, v := range s.Pings {
+			c.Pings[k] = v
+		}
+	}
+	return c
+}
@@ -1,120 +1,64 @@
 package utils
 
 import (
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"os"
-	"path/filepath"
-	"strings"
 	"time"
 )
 
 type State struct {
+	Version          int       `json:"version"` // formato del archivo, ver StateVersion
 	LastConnected    time.Time `json:"last_connected"`
 	LastDisconnected time.Time `json:"last_disconnected"`
 	IsConnected      bool      `json:"is_connected"`
 	StartTime        time.Time `json:"start_time"`
 	LastIP           string    `json:"last_ip"`
+	LastIPv4         string    `json:"last_ipv4,omitempty"`
+	LastIPv6         string    `json:"last_ipv6,omitempty"`
+	LastIPv6Prefix   string    `json:"last_ipv6_prefix,omitempty"`
+	SpeedLow         bool      `json:"speed_low,omitempty"` // se avisó velocidad baja y aún no se recuperó
+
+	SpeedTests  []SpeedTestRecord      `json:"speed_tests,omitempty"`
+	Checks      map[string]CheckRecord `json:"checks,omitempty"`
+	PowerEvents []PowerEvent           `json:"power_events,omitempty"`
+	Pings       map[string]PingRecord  `json:"pings,omitempty"`
 }
 
-// GetExternalIP obtiene la IP externa intentando múltiples servicios
-func GetExternalIP() (string, error) {
-	services := []string{
-		"https://api.ipify.org?format=text",
-		"https://ifconfig.me/ip",
-		"https://icanhazip.com",
-		"https://api.ip.sb/ip",
-	}
-
-	for _, service := range services {
-		ip, err := tryGetIP(service)
-		if err == nil && ip != "" {
-			log.Printf("[DEBUG] IP externa obtenida desde %s: %s", service, ip)
-			return ip, nil
-		}
-		log.Printf("[DEBUG] Error obteniendo IP desde %s: %v", service, err)
-	}
-
-	return "", fmt.Errorf("no se pudo obtener la IP externa desde ningún servicio")
+// PingRecord guarda el estado de un check de ping entre reinicios
+type PingRecord struct {
+	Status   string    `json:"status"`
+	Since    time.Time `json:"since"`
+	LastPing time.Time `json:"last_ping"`
+	Notified string    `json:"notified,omitempty"`
 }
 
-func tryGetIP(url string) (string, error) {
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-
-	resp, err := client.Get(url)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("status code: %d", resp.StatusCode)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
-
-	ip := string(body)
-	// Limpiar espacios y saltos de línea
-	ip = strings.TrimSpace(ip)
-	
-	if ip == "" {
-		return "", fmt.Errorf("respuesta vacía")
-	}
-
-	return ip, nil
+// PowerEvent es un cambio de la alimentación informado por el UPS
+type PowerEvent struct {
+	Time           time.Time `json:"time"`
+	Type           string    `json:"type"`
+	Charge         float64   `json:"battery_charge"`
+	RuntimeSeconds float64   `json:"battery_runtime_seconds"`
 }
 
-// LoadState carga el estado desde el archivo
-func LoadState(filePath string) (*State, error) {
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			// Archivo no existe, crear estado inicial
-			state := &State{
-				LastConnected: time.Now(),
-				IsConnected:   true,
-				StartTime:     time.Now(),
-			}
-			return state, nil
-		}
-		return nil, err
-	}
-
-	var state State
-	if err := json.Unmarshal(data, &state); err != nil {
-		return nil, err
-	}
-
-	return &state, nil
+// CheckRecord guarda el estado de un check de servicio entre reinicios
+type CheckRecord struct {
+	Status   string    `json:"status"`
+	Since    time.Time `json:"since"`
+	Notified string    `json:"notified,omitempty"`
 }
 
-// SaveState guarda el estado en el archivo
-func SaveState(filePath string, state *State) error {
-	data, err := json.MarshalIndent(state, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	// Asegurar que el directorio existe
-	dir := filepath.Dir(filePath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return err
-	}
-
-	return os.WriteFile(filePath, data, 0644)
+// SpeedTestRecord es una medición de ancho de banda guardada en el historial
+type SpeedTestRecord struct {
+	Time         time.Time `json:"time"`
+	DownloadMbps float64   `json:"download_mbps,omitempty"`
+	UploadMbps   float64   `json:"upload_mbps,omitempty"`
+	Error        string    `json:"error,omitempty"`
 }
 
 // GetCurrentTime retorna el tiempo actual
 func GetCurrentTime() time.Time {
-	return time.Now()
+	return SystemClock.Now()
 }
 
 // WriteLog escribe un mensaje de log con timestamp
@@ -148,3 +92,18 @@ func WriteLog(message string, debug bool) {
 	log.Printf("[LOG] %s", message)
 }
 
+
+// SystemUptime retorna el tiempo desde el arranque del sistema según /proc/uptime
+func SystemUptime() (time.Duration, error) {
+	data, err := os.ReadFile("/proc/uptime")
+	if err != nil {
+		return 0, err
+	}
+
+	// /proc/uptime contiene dos valores: uptime total y tiempo idle
+	var seconds float64
+	if _, err := fmt.Sscanf(string(data), "%f", &seconds); err != nil {
+		return 0, fmt.Errorf("formato inválido de /proc/uptime: %w", err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}